@@ -0,0 +1,197 @@
+package crest
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// authSession caches the credentials produced by a login handshake. Clones
+// share the session of the client they were cloned from, so a suite logs in
+// once rather than once per clone.
+type authSession struct {
+	login func() (http.Header, time.Time, error)
+	// bearer is set for sessions started with UseBearerToken or
+	// UseBearerTokenSource, which NoBearerToken removes.
+	bearer bool
+	// mint is set for sessions started with UseBearerTokenMinter.
+	mint func(issuedAt time.Time) (string, time.Time, error)
+	// refreshOnUnauthorized makes a request answered with 401 be sent again
+	// once with new credentials.
+	refreshOnUnauthorized bool
+	// remote is set for sessions whose login is a request, which is not
+	// made in dry-run mode.
+	remote bool
+
+	lock    sync.Mutex
+	header  http.Header
+	expires time.Time
+}
+
+func newAuthSession(login func() (http.Header, time.Time, error)) *authSession {
+	return &authSession{login: login}
+}
+
+// credentials returns the cached credentials, logging in first if there are
+// none or they have expired.
+func (s *authSession) credentials(now time.Time) (http.Header, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.header != nil && (s.expires.IsZero() || now.Before(s.expires)) {
+		return s.header, nil
+	}
+	header, expires, err := s.login()
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		header = make(http.Header)
+	}
+	s.header = header
+	s.expires = expires
+	return s.header, nil
+}
+
+func (s *authSession) invalidate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.header = nil
+	s.expires = time.Time{}
+}
+
+// unshared returns a session with the same login but no cached credentials.
+func (s *authSession) unshared() *authSession {
+	unshared := newAuthSession(s.login)
+	unshared.bearer = s.bearer
+	unshared.mint = s.mint
+	unshared.refreshOnUnauthorized = s.refreshOnUnauthorized
+	unshared.remote = s.remote
+	return unshared
+}
+
+// UseSessionAuth makes the client log in with login before its first request
+// and send the headers login returns (e.g. a Cookie or Authorization header)
+// with every request. login is given a copy of the client without session
+// auth to do the handshake with.
+func (c *client) UseSessionAuth(login func(c Client) (http.Header, error)) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	loginClient := c.detached()
+	loginClient.auth = nil
+	c.auth = newAuthSession(func() (http.Header, time.Time, error) {
+		cl := loginClient.detached()
+		header, err := login(cl)
+		if err == nil {
+			err = cl.Error()
+		}
+		if err != nil {
+			return nil, time.Time{}, errors.Wrap(err, "logging in")
+		}
+		return header, time.Time{}, nil
+	})
+	return c
+}
+
+// UseBearerToken sends "Authorization: Bearer <token>" with every request.
+func (c *client) UseBearerToken(token string) Client {
+	return c.UseBearerTokenSource(func() (string, time.Time, error) {
+		return token, time.Time{}, nil
+	})
+}
+
+// UseBearerTokenSource sends a bearer token obtained from source with every
+// request. source is called again once the token has expired, unless it
+// returns a zero expiry, and after InvalidateAuth.
+func (c *client) UseBearerTokenSource(source func() (token string, expires time.Time, err error)) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.auth = newBearerSession(source)
+	return c
+}
+
+// UseBearerTokenMinter is UseBearerTokenSource for suites that can mint
+// their own tokens. mint is given the time to issue the token at, which
+// follows the client's clock, so WithClockSkew and WithExpiredCredentials
+// apply to the tokens too.
+func (c *client) UseBearerTokenMinter(mint func(issuedAt time.Time) (token string, expires time.Time, err error)) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	skew := c.clockSkew
+	c.auth = newBearerSession(func() (string, time.Time, error) {
+		return mint(time.Now().Add(skew))
+	})
+	c.auth.mint = mint
+	return c
+}
+
+func newBearerSession(source func() (string, time.Time, error)) *authSession {
+	s := newAuthSession(func() (http.Header, time.Time, error) {
+		token, expires, err := source()
+		if err != nil {
+			return nil, time.Time{}, errors.Wrap(err, "getting bearer token")
+		}
+		return http.Header{"Authorization": {"Bearer " + token}}, expires, nil
+	})
+	s.bearer = true
+	return s
+}
+
+// NoBearerToken stops sending the bearer token set with UseBearerToken or
+// UseBearerTokenSource.
+func (c *client) NoBearerToken() Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if c.auth != nil && c.auth.bearer {
+		c.auth = nil
+	}
+	return c
+}
+
+// UnshareAuth gives the client its own credentials cache, so it logs in
+// separately from the client it was cloned from.
+func (c *client) UnshareAuth() Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if c.auth != nil {
+		c.auth = c.auth.unshared()
+	}
+	return c
+}
+
+// InvalidateAuth drops the cached credentials of the client and every client
+// sharing them, forcing a new login on the next request.
+func (c *client) InvalidateAuth() Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if c.auth != nil {
+		c.auth.invalidate()
+	}
+	return c
+}
+
+func (c *client) addAuth(req *http.Request) error {
+	if c.auth == nil || c.dryRun && c.auth.remote {
+		return nil
+	}
+	header, err := c.auth.credentials(time.Now())
+	if err != nil {
+		return err
+	}
+	for key, vals := range header {
+		req.Header.Del(key)
+		for _, val := range vals {
+			req.Header.Add(key, val)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package crest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionAuth(t *testing.T) {
+	var logins int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			n := atomic.AddInt32(&logins, 1)
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: string(rune('0' + n))})
+		case "/fail":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			cookie, err := r.Cookie("session")
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(cookie.Value))
+		}
+	}))
+	defer srv.Close()
+
+	login := func(c Client) (http.Header, error) {
+		rw := c.PostNoBody("/login").ExpectStatus(http.StatusOK)
+		header := make(http.Header)
+		for _, cookie := range rw.Response().Cookies() {
+			header.Add("Cookie", cookie.String())
+		}
+		return header, nil
+	}
+
+	c := NewClient(srv.URL).UseSessionAuth(login)
+	c.Get("/me").ExpectBodyEquals("1")
+	c.Clone().Get("/me").ExpectBodyEquals("1")
+	c.Clone().Group("/v2").Get("/me").ExpectBodyEquals("1")
+	require.NoError(t, c.Error())
+	require.Equal(t, int32(1), atomic.LoadInt32(&logins))
+
+	c.Clone().UnshareAuth().Get("/me").ExpectBodyEquals("2")
+	c.Get("/me").ExpectBodyEquals("1")
+	c.InvalidateAuth().Get("/me").ExpectBodyEquals("3")
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL).UseSessionAuth(func(c Client) (http.Header, error) {
+		c.PostNoBody("/fail").ExpectStatus(http.StatusOK)
+		return nil, nil
+	})
+	c.Get("/me")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "logging in")
+}
+
+func TestBearerToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).UseBearerToken("abc")
+	c.Get("/").ExpectBodyEquals("Bearer abc")
+	c.Clone().NoBearerToken().Get("/").ExpectBodyEquals("")
+	c.Get("/").ExpectBodyEquals("Bearer abc")
+	require.NoError(t, c.Error())
+
+	var refreshes int32
+	expires := time.Now().Add(time.Hour)
+	c = NewClient(srv.URL).UseBearerTokenSource(func() (string, time.Time, error) {
+		n := atomic.AddInt32(&refreshes, 1)
+		return fmt.Sprintf("token%d", n), expires, nil
+	})
+	c.Get("/").ExpectBodyEquals("Bearer token1")
+	c.Get("/").ExpectBodyEquals("Bearer token1")
+	c.InvalidateAuth().Get("/").ExpectBodyEquals("Bearer token2")
+	expires = time.Now().Add(-time.Second)
+	c.InvalidateAuth().Get("/").ExpectBodyEquals("Bearer token3")
+	c.Get("/").ExpectBodyEquals("Bearer token4")
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL).UseBasicAuth("u", "p").NoBearerToken()
+	c.Get("/").ExpectBodyEquals("Basic dTpw")
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL).UseBearerTokenSource(func() (string, time.Time, error) {
+		return "", time.Time{}, errors.New("no token")
+	})
+	c.Get("/")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "getting bearer token: no token")
+}
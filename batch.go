@@ -0,0 +1,157 @@
+package crest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Batch packs several requests into a single multipart/mixed request, as
+// the batch endpoints of OData and Google APIs expect, and unpacks the
+// responses to them from the multipart response.
+type Batch interface {
+	// Add adds a request. A nil body sends no body; others are encoded as
+	// JSON, as Post does.
+	Add(method, path string, body interface{}) Batch
+	// Do sends the batch. It returns the wrapper of the batch response, and
+	// one wrapper per added request, in the order they were added.
+	Do() (ResponseWrapper, []ResponseWrapper)
+}
+
+type batch struct {
+	client   *client
+	path     string
+	requests []batchRequest
+}
+
+type batchRequest struct {
+	method string
+	path   string
+	body   interface{}
+}
+
+// Batch starts a batch that is sent to path.
+func (c *client) Batch(path string) Batch {
+	return &batch{
+		client: c,
+		path:   path,
+	}
+}
+
+func (b *batch) Add(method, path string, body interface{}) Batch {
+	b.requests = append(b.requests, batchRequest{
+		method: method,
+		path:   path,
+		body:   body,
+	})
+	return b
+}
+
+func (b *batch) Do() (ResponseWrapper, []ResponseWrapper) {
+	c := b.client
+	responses := make([]ResponseWrapper, len(b.requests))
+	for i := range responses {
+		responses[i] = c.nop()
+	}
+	if c.errGetter() != nil {
+		return c.nop(), responses
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	reqs := make([]*http.Request, len(b.requests))
+	bodies := make([][]byte, len(b.requests))
+	for i, sub := range b.requests {
+		req, body, err := c.batchRequest(sub)
+		if err != nil {
+			c.errSetter(errors.Wrapf(err, "creating batch request %d", i+1))
+			return c.nop(), responses
+		}
+		reqs[i], bodies[i] = req, body
+
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type": {"application/http"},
+			"Content-Id":   {fmt.Sprintf("<item%d>", i+1)},
+		})
+		if err == nil {
+			err = req.Write(pw)
+		}
+		if err != nil {
+			c.errSetter(errors.Wrapf(err, "writing batch request %d", i+1))
+			return c.nop(), responses
+		}
+	}
+	mw.Close()
+
+	rw := c.doReqRaw(http.MethodPost, b.path, buf.Bytes(), "multipart/mixed; boundary="+mw.Boundary())
+	if c.errGetter() != nil {
+		return rw, responses
+	}
+	parts, err := rw.ParseMultipartBody()
+	if err != nil {
+		return rw, responses
+	}
+
+	byID := make(map[int]Part)
+	for i, part := range parts {
+		byID[batchPartID(part, i)] = part
+	}
+	for i, req := range reqs {
+		part, ok := byID[i+1]
+		if !ok {
+			c.errSetter(errors.Errorf("the batch response has no response to request %d, %v %v", i+1, req.Method, req.URL.RequestURI()))
+			return rw, responses
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(part.Body)), req)
+		if err != nil {
+			c.errSetter(errors.Wrapf(err, "reading the batch response to request %d", i+1))
+			return rw, responses
+		}
+		responses[i] = c.subResponseWrapper(req, bodies[i], resp)
+	}
+	return rw, responses
+}
+
+// batchRequest builds a request of the batch as it would be sent on its own,
+// but without the client's headers and auth, which the batch request
+// carries.
+func (c *client) batchRequest(sub batchRequest) (*http.Request, []byte, error) {
+	var body []byte
+	if sub.body != nil {
+		bs, err := c.jsonEncoder(sub.body)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "marshalling JSON body")
+		}
+		body = bs
+	}
+	req, err := http.NewRequest(sub.method, c.buildPath(c.apiVersion.applyPath(sub.path)), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	if body == nil {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+	} else {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, body, nil
+}
+
+// batchPartID returns the 1-based number of the request a batch response part
+// answers. Servers echo the Content-ID of the request, possibly prefixed with
+// "response-"; parts without one are taken to be in order.
+func batchPartID(part Part, i int) int {
+	id := strings.Trim(part.Header.Get("Content-Id"), "<>")
+	id = strings.TrimPrefix(id, "response-")
+	if n, err := strconv.Atoi(strings.TrimPrefix(id, "item")); err == nil && strings.HasPrefix(id, "item") {
+		return n
+	}
+	return i + 1
+}
@@ -0,0 +1,90 @@
+package crest
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		type item struct {
+			id   string
+			resp string
+		}
+		var items []item
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			require.Equal(t, "application/http", part.Header.Get("Content-Type"))
+			sub, err := http.ReadRequest(bufio.NewReader(part))
+			require.NoError(t, err)
+			body, _ := ioutil.ReadAll(sub.Body)
+			resp := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/json\r\n\r\n{\"method\":%q,\"path\":%q,\"body\":%q}", sub.Method, sub.URL.Path, body)
+			if sub.URL.Path == "/missing" {
+				resp = "HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n"
+			}
+			items = append(items, item{id: part.Header.Get("Content-Id"), resp: resp})
+		}
+
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+		// Answer out of order, as servers may.
+		for i := len(items) - 1; i >= 0; i-- {
+			pw, _ := mw.CreatePart(textproto.MIMEHeader{
+				"Content-Type": {"application/http"},
+				"Content-Id":   {"<response-" + items[i].id[1:]},
+			})
+			pw.Write([]byte(items[i].resp))
+		}
+		mw.Close()
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	rw, responses := c.Batch("/batch").
+		Add(http.MethodGet, "/users/1", nil).
+		Add(http.MethodPost, "/users", map[string]string{"name": "a"}).
+		Add(http.MethodGet, "/missing", nil).
+		Do()
+	rw.ExpectStatus(http.StatusOK)
+	require.Len(t, responses, 3)
+	responses[0].ExpectJSONPath("method", "GET").ExpectJSONPath("path", "/users/1")
+	responses[1].ExpectJSONPath("method", "POST").ExpectJSONPath("body", `{"name":"a"}`)
+	responses[2].ExpectStatus(http.StatusNotFound)
+	require.NoError(t, c.Error())
+
+	responses[2].ExpectStatus(http.StatusOK)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "/missing")
+}
+
+func TestBatchMissingResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "multipart/mixed; boundary=b")
+		w.Write([]byte("--b\r\nContent-Type: application/http\r\n\r\nHTTP/1.1 204 No Content\r\n\r\n\r\n--b--\r\n"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	_, responses := c.Batch("/batch").
+		Add(http.MethodDelete, "/a", nil).
+		Add(http.MethodDelete, "/b", nil).
+		Do()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "no response to request 2")
+	responses[1].ExpectStatus(http.StatusNoContent)
+}
@@ -0,0 +1,160 @@
+package crest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultCapabilitiesPath is where Capabilities looks for the features a
+// server supports, unless set with WithCapabilitiesPath.
+const DefaultCapabilitiesPath = "/.well-known/capabilities"
+
+// Capabilities is what a server says it supports.
+type Capabilities struct {
+	// Methods are the methods listed in the Allow header of the response
+	// to an OPTIONS request for the base URL.
+	Methods []string
+	// Features are the features listed at the capabilities path, which
+	// holds a JSON array of names, an object mapping names to booleans, or
+	// an object with either under "features".
+	Features map[string]bool
+}
+
+// Supports reports whether the server lists feature as enabled.
+func (c Capabilities) Supports(feature string) bool {
+	return c.Features[feature]
+}
+
+// Allows reports whether the server allows method on the base URL.
+func (c Capabilities) Allows(method string) bool {
+	for _, m := range c.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// capabilityCache holds the capabilities of a server once probed. Clones
+// share it.
+type capabilityCache struct {
+	path string
+
+	once sync.Once
+	caps Capabilities
+	err  error
+}
+
+// WithCapabilitiesPath sets the path Capabilities reads features from.
+func (c *client) WithCapabilitiesPath(path string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.capabilities = &capabilityCache{path: path}
+	return c
+}
+
+// Capabilities probes the server for what it supports with an OPTIONS
+// request for the base URL and a GET of the capabilities path, see
+// DefaultCapabilitiesPath. The result is cached for the client and its
+// clones. A server without the capabilities path supports no features;
+// failing to reach the server is an error. In dry-run mode the probes are
+// not sent, so nothing is supported and nothing is cached.
+func (c *client) Capabilities() Capabilities {
+	if c.errGetter() != nil {
+		return Capabilities{}
+	}
+	cache := c.capabilities
+	if c.dryRun {
+		caps, err := c.detached().probeCapabilities(cache.path)
+		if err != nil {
+			c.errSetter(err)
+		}
+		return caps
+	}
+	cache.once.Do(func() {
+		cache.caps, cache.err = c.detached().probeCapabilities(cache.path)
+	})
+	if cache.err != nil {
+		c.errSetter(cache.err)
+	}
+	return cache.caps
+}
+
+func (c *client) probeCapabilities(path string) (Capabilities, error) {
+	caps := Capabilities{Features: make(map[string]bool)}
+	rw := c.doReqNoBody(http.MethodOptions, "/")
+	if err := c.Error(); err != nil {
+		return caps, errors.Wrap(err, "probing capabilities")
+	}
+	if rw.DryRun() {
+		return caps, nil
+	}
+	for _, allow := range rw.Response().Header.Values("Allow") {
+		for _, method := range strings.Split(allow, ",") {
+			if method = strings.TrimSpace(method); method != "" {
+				caps.Methods = append(caps.Methods, strings.ToUpper(method))
+			}
+		}
+	}
+	sort.Strings(caps.Methods)
+
+	rw = c.doReqNoBody(http.MethodGet, path)
+	if err := c.Error(); err != nil {
+		return caps, errors.Wrap(err, "probing capabilities")
+	}
+	if rw.Response().StatusCode == http.StatusNotFound {
+		return caps, nil
+	}
+	if rw.Response().StatusCode != http.StatusOK {
+		return caps, errors.Errorf("probing capabilities: got status %v from %v", rw.Response().Status, path)
+	}
+	if err := parseFeatures([]byte(rw.Body()), caps.Features); err != nil {
+		return caps, errors.Wrapf(err, "probing capabilities: parsing %v", path)
+	}
+	return caps, nil
+}
+
+// parseFeatures adds the features listed in body to features.
+func parseFeatures(body []byte, features map[string]bool) error {
+	var list []string
+	if json.Unmarshal(body, &list) == nil {
+		for _, name := range list {
+			features[name] = true
+		}
+		return nil
+	}
+	var flags map[string]bool
+	if json.Unmarshal(body, &flags) == nil {
+		for name, enabled := range flags {
+			features[name] = enabled
+		}
+		return nil
+	}
+	var wrapped struct {
+		Features json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err != nil || wrapped.Features == nil {
+		return errors.New("expected a list of features")
+	}
+	return parseFeatures(wrapped.Features, features)
+}
+
+// SkipUnlessSupports skips the test t unless the server supports feature,
+// according to Capabilities. Failing to find out fails the test.
+func (c *client) SkipUnlessSupports(t testing.TB, feature string) {
+	t.Helper()
+	caps := c.Capabilities()
+	if err := c.Error(); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !caps.Supports(feature) {
+		t.Skipf("the server does not support %q", feature)
+	}
+}
@@ -0,0 +1,63 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities(t *testing.T) {
+	probes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodOptions:
+			w.Header().Set("Allow", "get, POST")
+			w.Header().Add("Allow", "DELETE")
+		case r.URL.Path == DefaultCapabilitiesPath:
+			probes++
+			w.Write([]byte(`{"features":{"feature-flag-x":true,"feature-flag-y":false}}`))
+		case r.URL.Path == "/list":
+			w.Write([]byte(`["feature-flag-z"]`))
+		case r.URL.Path == "/broken":
+			w.Write([]byte(`"nope"`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	caps := c.Capabilities()
+	require.NoError(t, c.Error())
+	require.Equal(t, []string{"DELETE", "GET", "POST"}, caps.Methods)
+	require.True(t, caps.Allows("get"))
+	require.False(t, caps.Allows("PUT"))
+	require.True(t, caps.Supports("feature-flag-x"))
+	require.False(t, caps.Supports("feature-flag-y"))
+	require.False(t, caps.Supports("feature-flag-z"))
+	c.Clone().Capabilities()
+	require.Equal(t, 1, probes)
+
+	caps = NewClient(srv.URL).WithCapabilitiesPath("/list").Capabilities()
+	require.True(t, caps.Supports("feature-flag-z"))
+
+	c = NewClient(srv.URL).WithCapabilitiesPath("/missing")
+	require.Empty(t, c.Capabilities().Features)
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL).WithCapabilitiesPath("/broken")
+	c.Capabilities()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "probing capabilities: parsing /broken: expected a list of features")
+
+	t.Run("supported", func(t *testing.T) {
+		NewClient(srv.URL).SkipUnlessSupports(t, "feature-flag-x")
+	})
+	skipped := t.Run("unsupported", func(t *testing.T) {
+		NewClient(srv.URL).SkipUnlessSupports(t, "feature-flag-y")
+		t.Error("not skipped")
+	})
+	require.True(t, skipped)
+}
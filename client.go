@@ -3,33 +3,128 @@ package crest
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"net/http/httptrace"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"testing"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
 type Client interface {
+	DisableAutoDecompression() Client
+	InsecureSkipVerify() Client
 	NoBasicAuth() Client
 	UseBasicAuth(string, string) Client
+	UseBearerToken(token string) Client
+	UseBearerTokenSource(source func() (token string, expires time.Time, err error)) Client
+	UseBearerTokenMinter(mint func(issuedAt time.Time) (token string, expires time.Time, err error)) Client
+	UseOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) Client
+	NoBearerToken() Client
+	SignAsStripe(secret string) Client
+	SignWithHMAC(secret string, scheme HMACScheme) Client
+	NoSignature() Client
+	UseSessionAuth(login func(c Client) (http.Header, error)) Client
+	UnshareAuth() Client
+	InvalidateAuth() Client
 	UseCookies(bool) Client
+	WithAcceptEncoding(encodings ...string) Client
+	WithAcceptLanguage(tags ...string) Client
+	WithAllowedHosts(patterns ...string) Client
+	WithAPIVersion(version string, style VersionStyle) Client
+	WithCapabilitiesPath(path string) Client
+	WithClientCert(certFile, keyFile string) Client
+	WithClockSkew(time.Duration) Client
+	WithConcurrencyLimit(n int) Client
+	WithDefaultContentType(contentType string) Client
+	WithDryRun(enabled bool) Client
+	WithEndpointRateLimit(template string, n int, per time.Duration) Client
+	WithErrorCollection() Client
+	WithErrorFormatter(func(FailureInfo) error) Client
+	WithExpiredCredentials() Client
+	WithFlagMatrix(flags map[string][]string, chain func(c Client)) Client
+	WithFlakyRetry(n int, classify func(error) bool) Client
+	WithHARRecorder(path string) Client
 	WithHeader(key, value string) Client
+	WithIdentityHeaders(IdentityHeaders) Client
+	WithJSONEncoder(func(interface{}) ([]byte, error)) Client
+	WithLogger(logger Logger, verbosity LogVerbosity) Client
+	WithMaxBodySize(n int64, policy BodySizePolicy) Client
+	WithMaxFailures(n int) Client
+	WithMetrics(rec Recorder) Client
+	WithNetwork(network string) Client
+	WithOpenAPISpec(specPath string) Client
+	WithPathEncoding(PathEncoding) Client
+	WithPriority(Priority) Client
+	WithProxy(proxyURL string) Client
+	WithProxyFromEnv() Client
+	WithQueryParam(key, value string) Client
+	WithRateLimit(n int, per time.Duration) Client
+	WithReadIdleTimeout(time.Duration) Client
+	WithRedirectCredentialHeaders(headers ...string) Client
+	WithRequestHook(hook func(*http.Request)) Client
+	WithResolver(*net.Resolver) Client
+	WithResponseHook(hook func(resp *http.Response, body string)) Client
+	WithRetry(attempts int, backoff BackoffStrategy) Client
+	WithRetryStatuses(codes ...int) Client
+	WithShadow(secondary Client, compare bool) Client
+	WithSuiteDeadline(time.Time) Client
+	WithTestNameHeader(name string) Client
 	WithTimeout(time.Duration) Client
+	WithTLSConfig(*tls.Config) Client
+	WithTrafficRecorder(rec *TrafficRecorder) Client
+	WithUnicodeNormalization(caseFold bool) Client
+	WithWireCapture() Client
 
+	Capabilities() Capabilities
+	Close() error
+	OnClose(teardown func(c Client) error) Client
+	OnFirstUse(setup func(c Client) error) Client
 	Error() error
+	Errors() []error
+	Flaky(chain func(c Client)) Client
+	FlakyErrors() []error
+	ShadowErrors() []error
+	SkipUnlessSupports(t testing.TB, feature string)
 	Clone() Client
+	Group(prefix string) Client
+	Tag(tags ...string) Client
+	AsTenant(id string) Client
+	Impersonate(userID string) Client
+	ForEachLocale(locales []string, f func(locale string, c Client)) error
 
+	Do(req *http.Request) ResponseWrapper
+	Request(method, path string) RequestBuilder
+	Batch(path string) Batch
+	MultipartUpload(path string, data io.ReaderAt, size int64) Upload
+	FollowOperation(location string, opts LROOptions) ResponseWrapper
+	PollGet(path string, opts PollOptions) ResponseWrapper
+	GraphQL(path, query string, variables map[string]interface{}) ResponseWrapper
 	Delete(path string) ResponseWrapper
 	Get(path string) ResponseWrapper
+	GetSSE(path string) EventStreamWrapper
+	GetStream(path string, w io.Writer) ResponseWrapper
+	RangeReader(path string) io.ReaderAt
+	Websocket(path string) WSWrapper
 	Patch(path string, body interface{}) ResponseWrapper
 	Post(path string, body interface{}) ResponseWrapper
 	Put(path string, body interface{}) ResponseWrapper
+	DeleteCtx(ctx context.Context, path string) ResponseWrapper
+	GetCtx(ctx context.Context, path string) ResponseWrapper
+	PatchCtx(ctx context.Context, path string, body interface{}) ResponseWrapper
+	PostCtx(ctx context.Context, path string, body interface{}) ResponseWrapper
+	PutCtx(ctx context.Context, path string, body interface{}) ResponseWrapper
 	PatchNoBody(path string) ResponseWrapper
 	PostNoBody(path string) ResponseWrapper
 	PutNoBody(path string) ResponseWrapper
@@ -39,48 +134,174 @@ type Client interface {
 	PatchBytes(path string, body []byte) ResponseWrapper
 	PostBytes(path string, body []byte) ResponseWrapper
 	PutBytes(path string, body []byte) ResponseWrapper
+	PatchStringTyped(path string, body string, contentType string) ResponseWrapper
+	PostStringTyped(path string, body string, contentType string) ResponseWrapper
+	PutStringTyped(path string, body string, contentType string) ResponseWrapper
+	PatchBytesTyped(path string, body []byte, contentType string) ResponseWrapper
+	PostBytesTyped(path string, body []byte, contentType string) ResponseWrapper
+	PutBytesTyped(path string, body []byte, contentType string) ResponseWrapper
 	PostForm(path string, body url.Values) ResponseWrapper
+	PostWithContentMD5(path string, body interface{}) ResponseWrapper
+	PutIfMatch(path string, body interface{}, etag string) ResponseWrapper
+	PatchMerge(path string, patch interface{}) ResponseWrapper
+	PatchJSONPatch(path string, ops []PatchOp) ResponseWrapper
 }
 
 type client struct {
 	baseURL    string
 	httpClient *http.Client
+	parent     *client
 
-	err       error
+	errState  *errorState
 	errGetter func() error
 	errSetter func(error)
-	errLock   sync.RWMutex
 
-	useBasicAuth  bool
-	basicAuthUser string
-	basicAuthPass string
-	useCookies    bool
-	headers       http.Header
-	timeout       time.Duration
+	useBasicAuth   bool
+	basicAuthUser  string
+	basicAuthPass  string
+	useCookies     bool
+	headers        http.Header
+	query          url.Values
+	pathEncoding   PathEncoding
+	timeout        time.Duration
+	decompress     bool
+	acceptEncoding string
+
+	defaultContentType string
+	jsonEncoder        func(interface{}) ([]byte, error)
+	shadow             *shadow
+	har                *harRecorder
+	logger             *exchangeLogger
+	traffic            *TrafficRecorder
+	metrics            Recorder
+	apiVersion         apiVersion
+	clockSkew          time.Duration
+	stampTime          bool
+	identityHeaders    IdentityHeaders
+	normalize          func(string) string
+	suiteDeadline      time.Time
+	lifecycle          *lifecycle
+	// pathTemplate is the path of a request built with path parameters,
+	// before they were filled in.
+	pathTemplate string
+	// inHook is set on the clients OnFirstUse and OnClose hooks are given,
+	// whose requests must not wait for the OnFirstUse hooks.
+	inHook            bool
+	errorFormatter    func(FailureInfo) error
+	flaky             *flakyRetry
+	auth              *authSession
+	requestHooks      []func(*http.Request)
+	responseHooks     []func(*http.Response, string)
+	signer            signer
+	testName          string
+	testNameHeader    string
+	tags              []string
+	allowedHosts      []string
+	sink              io.Writer
+	maxBodySize       int64
+	truncateBody      bool
+	dryRun            bool
+	spec              *openAPISpec
+	dial              dialSettings
+	dialTransport     *http.Transport
+	ctx               context.Context
+	credentialHeaders []string
+	headerOverrides   http.Header
+	limits            *rateLimits
+	scheduler         *scheduler
+	capabilities      *capabilityCache
+	priority          Priority
+	retry             *retryPolicy
+	readIdleTimeout   time.Duration
+}
+
+// ErrSuiteDeadlineExceeded is the cause of the error of a client whose suite
+// deadline has passed.
+var ErrSuiteDeadlineExceeded = errors.New("suite deadline exceeded")
+
+// IdentityHeaders names the headers used by AsTenant and Impersonate.
+type IdentityHeaders struct {
+	Tenant      string
+	Impersonate string
+}
+
+var DefaultIdentityHeaders = IdentityHeaders{
+	Tenant:      "X-Tenant-ID",
+	Impersonate: "X-Impersonate-User",
 }
 
+// ErrInvalidBaseURL is the cause of the error of a client created with a
+// base URL that cannot be used.
+var ErrInvalidBaseURL = errors.New("invalid base URL")
+
+// NewClient returns a client for the API at the base URL url. If url is not
+// an absolute URL, the client starts out with an error wrapping
+// ErrInvalidBaseURL.
 func NewClient(url string) Client {
 	return NewCustomClient(url, &http.Client{})
 }
 
+// MustNewClient is NewClient, but panics if url is not a valid base URL.
+func MustNewClient(url string) Client {
+	c := NewClient(url)
+	if err := c.Error(); err != nil {
+		panic(err)
+	}
+	return c
+}
+
 func NewCustomClient(url string, httpClient *http.Client) Client {
 	cl := &client{
-		baseURL:    url,
-		httpClient: httpClient,
+		baseURL:           url,
+		httpClient:        httpClient,
+		decompress:        true,
+		acceptEncoding:    "gzip",
+		jsonEncoder:       json.Marshal,
+		identityHeaders:   DefaultIdentityHeaders,
+		credentialHeaders: append([]string(nil), DefaultCredentialHeaders...),
+		lifecycle:         newLifecycle(),
+		limits:            &rateLimits{},
+		scheduler:         &scheduler{},
+		capabilities:      &capabilityCache{path: DefaultCapabilitiesPath},
 	}
-	cl.errGetter = func() error {
-		cl.errLock.RLock()
-		defer cl.errLock.RUnlock()
-
-		return cl.err
+	cl.newErrorState(&errorState{})
+	if err := validateBaseURL(url); err != nil {
+		cl.errSetter(err)
 	}
-	cl.errSetter = func(err error) {
-		cl.errLock.Lock()
-		defer cl.errLock.Unlock()
+	return cl
+}
 
-		cl.err = err
+func validateBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	switch {
+	case err != nil:
+		return errors.Wrapf(ErrInvalidBaseURL, "%v", err)
+	case u.Scheme == "":
+		return errors.Wrapf(ErrInvalidBaseURL, "%q has no scheme", baseURL)
+	case u.Host == "":
+		return errors.Wrapf(ErrInvalidBaseURL, "%q has no host", baseURL)
 	}
-	return cl
+	return nil
+}
+
+// newErrorState gives the client an error of its own. Clones share the
+// error of the client they were cloned from.
+func (c *client) newErrorState(state *errorState) {
+	c.errState = state
+	c.errGetter = state.gate
+	c.errSetter = state.set
+}
+
+// detached returns a copy of the client that does not share its error, but
+// reports it the same way.
+func (c *client) detached() *client {
+	cloned := c.cloneConfig()
+	collect, maxFailures := c.errState.settings()
+	cloned.newErrorState(&errorState{
+		collect:     collect,
+		maxFailures: maxFailures,
+	})
+	return cloned
 }
 
 func (c *client) NoBasicAuth() Client {
@@ -93,6 +314,51 @@ func (c *client) NoBasicAuth() Client {
 	return c
 }
 
+// WithAcceptLanguage sends the tags as Accept-Language, in decreasing order
+// of preference.
+func (c *client) WithAcceptLanguage(tags ...string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if c.headers == nil {
+		c.headers = make(http.Header)
+	}
+	if len(tags) == 0 {
+		c.headers.Del("Accept-Language")
+		return c
+	}
+	c.headers.Set("Accept-Language", acceptLanguage(tags))
+	return c
+}
+
+func acceptLanguage(tags []string) string {
+	parts := make([]string, len(tags))
+	for i, tag := range tags {
+		q := 10 - i
+		if q < 1 {
+			q = 1
+		}
+		if i == 0 {
+			parts[i] = tag
+		} else {
+			parts[i] = fmt.Sprintf("%v;q=0.%d", tag, q)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ForEachLocale runs f once per locale with a client that asks for that
+// locale. A failure for one locale does not stop the others; the returned
+// *MatrixError lists the failures by locale.
+func (c *client) ForEachLocale(locales []string, f func(locale string, c Client)) error {
+	if err := c.errGetter(); err != nil {
+		return err
+	}
+	return c.runMatrix(locales, func(locale string, cl *client) {
+		cl.WithAcceptLanguage(locale)
+	}, f)
+}
+
 func (c *client) UseBasicAuth(user, pass string) Client {
 	if c.errGetter() != nil {
 		return c
@@ -120,6 +386,124 @@ func (c *client) UseCookies(use bool) Client {
 	return c
 }
 
+// DisableAutoDecompression leaves response bodies as they arrived, so
+// assertions see the compressed payload.
+func (c *client) DisableAutoDecompression() Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.decompress = false
+	return c
+}
+
+// BodySizePolicy says what happens to a response body over the limit set
+// with WithMaxBodySize.
+type BodySizePolicy int
+
+const (
+	// FailOversizedBody fails the request.
+	FailOversizedBody BodySizePolicy = iota
+	// TruncateOversizedBody keeps the body up to the limit and marks it
+	// Truncated in Sizes.
+	TruncateOversizedBody
+)
+
+// WithMaxBodySize reads at most n bytes of each response body, both as
+// received and after decoding, so a test cannot pull a huge body into
+// memory by accident. Zero removes the limit. Bodies streamed by GetStream
+// are not limited.
+func (c *client) WithMaxBodySize(n int64, policy BodySizePolicy) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if n < 0 {
+		c.errSetter(errors.Errorf("invalid max body size %v", n))
+		return c
+	}
+	c.maxBodySize = n
+	c.truncateBody = policy == TruncateOversizedBody
+	return c
+}
+
+// WithAcceptEncoding sends the encodings, in decreasing order of preference,
+// as Accept-Encoding instead of "gzip". With no encodings, "identity" is
+// sent. Only gzip and deflate bodies are decompressed; others are left as
+// they arrived.
+func (c *client) WithAcceptEncoding(encodings ...string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if len(encodings) == 0 {
+		c.acceptEncoding = "identity"
+		return c
+	}
+	c.acceptEncoding = strings.Join(encodings, ", ")
+	return c
+}
+
+func (c *client) WithAPIVersion(version string, style VersionStyle) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.apiVersion = apiVersion{
+		version: version,
+		style:   style,
+	}
+	return c
+}
+
+// WithClockSkew makes the client's clock run off by skew, and has it send
+// Date and X-Timestamp headers from that clock so servers' handling of
+// expired and future-dated requests can be tested.
+func (c *client) WithClockSkew(skew time.Duration) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.clockSkew = skew
+	c.stampTime = true
+	if c.auth != nil && c.auth.mint != nil {
+		c.UseBearerTokenMinter(c.auth.mint)
+	}
+	return c
+}
+
+// ExpiredCredentialsAge is how far in the past WithExpiredCredentials dates
+// credentials.
+var ExpiredCredentialsAge = 24 * time.Hour
+
+// WithExpiredCredentials turns the client's clock back by
+// ExpiredCredentialsAge, so it presents signatures, Date and X-Timestamp
+// headers and minted bearer tokens that servers should reject as expired. A
+// bearer token that cannot be minted for a time in the past is an error.
+func (c *client) WithExpiredCredentials() Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if c.auth != nil && c.auth.bearer && c.auth.mint == nil {
+		c.errSetter(errors.New("cannot present an expired bearer token that was not set with UseBearerTokenMinter"))
+		return c
+	}
+	return c.WithClockSkew(c.clockSkew - ExpiredCredentialsAge)
+}
+
+func (c *client) WithDefaultContentType(contentType string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.defaultContentType = contentType
+	return c
+}
+
+// WithErrorFormatter replaces the error reported for failed expectations with
+// the one returned by formatter.
+func (c *client) WithErrorFormatter(formatter func(FailureInfo) error) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.errorFormatter = formatter
+	return c
+}
+
 func (c *client) WithHeader(key, value string) Client {
 	if c.errGetter() != nil {
 		return c
@@ -131,6 +515,48 @@ func (c *client) WithHeader(key, value string) Client {
 	return c
 }
 
+func (c *client) WithIdentityHeaders(headers IdentityHeaders) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.identityHeaders = headers
+	return c
+}
+
+func (c *client) WithJSONEncoder(encoder func(interface{}) ([]byte, error)) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if encoder == nil {
+		encoder = json.Marshal
+	}
+	c.jsonEncoder = encoder
+	return c
+}
+
+// WithQueryParam adds a query parameter to every request. Values are added
+// to any the path already has rather than replacing them.
+func (c *client) WithQueryParam(key, value string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if c.query == nil {
+		c.query = make(url.Values)
+	}
+	c.query.Add(key, value)
+	return c
+}
+
+// WithSuiteDeadline makes every request fail with ErrSuiteDeadlineExceeded
+// once deadline has passed, and cuts requests in flight short at deadline.
+func (c *client) WithSuiteDeadline(deadline time.Time) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.suiteDeadline = deadline
+	return c
+}
+
 func (c *client) WithTimeout(timeout time.Duration) Client {
 	if c.errGetter() != nil {
 		return c
@@ -139,14 +565,103 @@ func (c *client) WithTimeout(timeout time.Duration) Client {
 	return c
 }
 
+func (c *client) WithShadow(secondary Client, compare bool) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if secondary == nil {
+		c.shadow = nil
+		return c
+	}
+	sec, ok := secondary.(*client)
+	if !ok {
+		c.errSetter(errors.Errorf("cannot shadow requests to a %T", secondary))
+		return c
+	}
+	c.shadow = &shadow{
+		secondary: sec.cloneConfig(),
+		compare:   compare,
+	}
+	return c
+}
+
+// WithUnicodeNormalization makes body assertions compare NFC-normalized text
+// and, if caseFold is set, ignore case as well.
+func (c *client) WithUnicodeNormalization(caseFold bool) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if caseFold {
+		c.normalize = func(s string) string {
+			return foldCase(nfc(s))
+		}
+	} else {
+		c.normalize = nfc
+	}
+	return c
+}
+
+// Close cancels requests in flight, closes idle connections and flushes
+// anything recorded by this client or its clones. Requests made afterwards
+// fail with ErrClientClosed.
+func (c *client) Close() error {
+	err := c.lifecycle.close()
+	c.httpClient.CloseIdleConnections()
+	return err
+}
+
 func (c *client) Error() error {
-	return c.errGetter()
+	return c.errState.get()
+}
+
+// Errors returns every failure recorded, in the order they happened. Unless
+// the client collects errors with WithErrorCollection, that is at most the
+// first one.
+func (c *client) Errors() []error {
+	return c.errState.all()
+}
+
+// WithErrorCollection makes failures accumulate instead of stopping the
+// chain at the first one. It applies to the client and all its clones.
+func (c *client) WithErrorCollection() Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.errState.configure(func(s *errorState) {
+		s.collect = true
+	})
+	return c
+}
+
+// WithMaxFailures stops a client collecting errors after n failures, turning
+// the rest of the chain into no-ops. Zero means no limit.
+func (c *client) WithMaxFailures(n int) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.errState.configure(func(s *errorState) {
+		s.maxFailures = n
+	})
+	return c
+}
+
+// ShadowErrors waits for mirrored requests still in flight and returns the
+// failures and, if comparing, the differences seen by the shadow client.
+func (c *client) ShadowErrors() []error {
+	if c.shadow == nil {
+		return nil
+	}
+	return c.shadow.wait()
 }
 
 func (c *client) Clone() Client {
 	if c.errGetter() != nil {
 		return c
 	}
+	return c.cloneConfig()
+}
+
+func (c *client) cloneConfig() *client {
 	cloned := *c
 	cloned.headers = make(http.Header)
 	for key, vals := range c.headers {
@@ -154,15 +669,108 @@ func (c *client) Clone() Client {
 			cloned.headers.Add(key, val)
 		}
 	}
+	cloned.query = make(url.Values)
+	for key, vals := range c.query {
+		cloned.query[key] = append([]string(nil), vals...)
+	}
 	return &cloned
 }
 
+// Group returns a child client whose paths are prefixed with prefix. The
+// child shares the parent's error, and the parent's headers and query
+// parameters, including ones added later, are sent before the child's own.
+func (c *client) Group(prefix string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	child := c.cloneConfig()
+	child.parent = c
+	child.headers = make(http.Header)
+	child.query = make(url.Values)
+	child.baseURL = strings.TrimSuffix(c.buildPath(prefix), "/")
+	return child
+}
+
+// now is the client's idea of the current time, which is what generated
+// timestamps and signatures should use.
+func (c *client) now() time.Time {
+	return time.Now().Add(c.clockSkew)
+}
+
+func (c *client) rootBaseURL() string {
+	if c.parent == nil {
+		return c.baseURL
+	}
+	return c.parent.rootBaseURL()
+}
+
+// AsTenant returns a clone of the client whose requests are made on behalf
+// of the given tenant.
+func (c *client) AsTenant(id string) Client {
+	return c.withIdentity(c.identityHeaders.Tenant, id)
+}
+
+// Impersonate returns a clone of the client whose requests are made on
+// behalf of the given user.
+func (c *client) Impersonate(userID string) Client {
+	return c.withIdentity(c.identityHeaders.Impersonate, userID)
+}
+
+func (c *client) withIdentity(header, value string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if header == "" {
+		c.errSetter(errors.New("no identity header configured"))
+		return c
+	}
+	cloned := c.cloneConfig()
+	cloned.headers.Set(header, value)
+	return cloned
+}
+
+func (c *client) allHeaders() http.Header {
+	if c.parent == nil {
+		return c.headers
+	}
+	headers := c.parent.allHeaders().Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	for key, vals := range c.headers {
+		headers[key] = append(headers[key], vals...)
+	}
+	return headers
+}
+
+func (c *client) allQuery() url.Values {
+	if c.parent == nil {
+		return c.query
+	}
+	query := make(url.Values)
+	for key, vals := range c.parent.allQuery() {
+		query[key] = append([]string(nil), vals...)
+	}
+	for key, vals := range c.query {
+		query[key] = append(query[key], vals...)
+	}
+	return query
+}
+
 func (c *client) buildPath(path string) string {
 	return c.baseURL + "/" + strings.TrimPrefix(path, "/")
 }
 
-func (c *client) buildReq(method, path string, body io.Reader) *http.Request {
-	req, err := http.NewRequest(method, c.buildPath(path), body)
+func (c *client) buildReq(method, path string, body []byte) *http.Request {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.buildPath(c.apiVersion.applyPath(path)), rd)
 	if err != nil {
 		c.errSetter(errors.Wrap(err, "creating request"))
 		return nil
@@ -170,52 +778,86 @@ func (c *client) buildReq(method, path string, body io.Reader) *http.Request {
 	return c.populateReq(req)
 }
 
-func (c *client) doReq(method, path string, body io.Reader) ResponseWrapper {
+// nop returns the wrapper handed out for a request that was not made. Its
+// Error reports the client's error.
+func (c *client) nop() ResponseWrapper {
+	return &nopResponseWrapper{errGetter: c.errGetter}
+}
+
+func (c *client) doReq(method, path string, body []byte) ResponseWrapper {
 	if c.errGetter() != nil {
-		return &nopResponseWrapper{}
+		return c.nop()
 	}
 	req := c.buildReq(method, path, body)
 	return c.do(req)
 }
 
 func (c *client) doReqJSON(method, path string, body interface{}) ResponseWrapper {
+	return c.doReqJSONTyped(method, path, body, "")
+}
+
+func (c *client) doReqJSONTyped(method, path string, body interface{}, contentType string) ResponseWrapper {
 	if c.errGetter() != nil {
-		return &nopResponseWrapper{}
+		return c.nop()
 	}
-	bs, err := json.Marshal(body)
+	bs, err := c.jsonEncoder(body)
 	if err != nil {
 		c.errSetter(errors.Wrap(err, "marshalling JSON body"))
-		return &nopResponseWrapper{}
+		return c.nop()
+	}
+	if contentType == "" {
+		return c.doReq(method, path, bs)
 	}
-	return c.doReq(method, path, bytes.NewBuffer(bs))
+	return c.doReqRaw(method, path, bs, contentType)
 }
 
-func (c *client) doReqString(method, path string, body string) ResponseWrapper {
+func (c *client) doReqString(method, path string, body string, contentType string) ResponseWrapper {
 	if c.errGetter() != nil {
-		return &nopResponseWrapper{}
+		return c.nop()
 	}
-	return c.doReq(method, path, bytes.NewBufferString(body))
+	return c.doReqRaw(method, path, []byte(body), contentType)
 }
 
-func (c *client) doReqBytes(method, path string, body []byte) ResponseWrapper {
+func (c *client) doReqBytes(method, path string, body []byte, contentType string) ResponseWrapper {
 	if c.errGetter() != nil {
-		return &nopResponseWrapper{}
+		return c.nop()
 	}
-	return c.doReq(method, path, bytes.NewBuffer(body))
+	if body == nil {
+		body = []byte{}
+	}
+	return c.doReqRaw(method, path, body, contentType)
+}
+
+// doReqRaw sends a body crest knows nothing about. An explicit content type
+// wins over the client's headers, which win over the default content type.
+func (c *client) doReqRaw(method, path string, body []byte, contentType string) ResponseWrapper {
+	req := c.buildReq(method, path, body)
+	if req == nil {
+		return c.nop()
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	} else if req.Header.Get("Content-Type") == "" && c.defaultContentType != "" {
+		req.Header.Set("Content-Type", c.defaultContentType)
+	}
+	return c.do(req)
 }
 
 func (c *client) doReqNoBody(method, path string) ResponseWrapper {
 	if c.errGetter() != nil {
-		return &nopResponseWrapper{}
+		return c.nop()
 	}
 	return c.doReq(method, path, nil)
 }
 
 func (c *client) doReqForm(method, path string, body url.Values) ResponseWrapper {
 	if c.errGetter() != nil {
-		return &nopResponseWrapper{}
+		return c.nop()
+	}
+	req := c.buildReq(method, path, []byte(body.Encode()))
+	if req == nil {
+		return c.nop()
 	}
-	req := c.buildReq(method, path, bytes.NewBufferString(body.Encode()))
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	return c.do(req)
 }
@@ -224,29 +866,419 @@ func (c *client) populateReq(req *http.Request) *http.Request {
 	if c.useBasicAuth {
 		req.SetBasicAuth(c.basicAuthUser, c.basicAuthPass)
 	}
-	for key, vals := range c.headers {
+	for key, vals := range c.allHeaders() {
 		for _, val := range vals {
 			req.Header.Add(key, val)
 		}
 	}
-	if c.timeout > 0 {
-		ctx, _ := context.WithTimeout(context.Background(), c.timeout)
-		req = req.WithContext(ctx)
+	for key, vals := range c.headerOverrides {
+		req.Header[key] = append([]string(nil), vals...)
+	}
+	if query := c.allQuery(); len(query) > 0 {
+		// The query in the path is sent as written, followed by the
+		// client's parameters.
+		encoded := c.pathEncoding.encodeQuery(query)
+		if req.URL.RawQuery != "" {
+			encoded = req.URL.RawQuery + "&" + encoded
+		}
+		req.URL.RawQuery = encoded
+	}
+	c.pathEncoding.applyReq(req)
+	c.apiVersion.applyReq(req)
+	if c.testName != "" && c.testNameHeader != "" && req.Header.Get(c.testNameHeader) == "" {
+		req.Header.Set(c.testNameHeader, c.testName)
+	}
+	if c.stampTime {
+		now := c.now()
+		if req.Header.Get("Date") == "" {
+			req.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+		}
+		if req.Header.Get("X-Timestamp") == "" {
+			req.Header.Set("X-Timestamp", strconv.FormatInt(now.Unix(), 10))
+		}
+	}
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		// Asking for an encoding ourselves stops the transport from decoding
+		// the body transparently, so the wrapper can see the size on the wire
+		// and, with decompression disabled, the raw payload.
+		req.Header.Set("Accept-Encoding", c.acceptEncoding)
+	}
+	if err := c.addAuth(req); err != nil {
+		c.errSetter(errors.Wrap(err, "authenticating"))
+		return nil
 	}
 	return req
 }
 
+// requestContext bounds ctx by the client's lifecycle, timeout and suite
+// deadline. The cancel function must be called once the response body has
+// been read.
+func (c *client) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(c.lifecycle.ctx, cancel)
+	cancelTimeout, cancelDeadline := context.CancelFunc(func() {}), context.CancelFunc(func() {})
+	if c.timeout > 0 {
+		ctx, cancelTimeout = context.WithTimeout(ctx, c.timeout)
+	}
+	if !c.suiteDeadline.IsZero() {
+		ctx, cancelDeadline = context.WithDeadline(ctx, c.suiteDeadline)
+	}
+	return ctx, func() {
+		stop()
+		cancelDeadline()
+		cancelTimeout()
+		cancel()
+	}
+}
+
+func (c *client) suiteDeadlinePassed() bool {
+	return !c.suiteDeadline.IsZero() && !time.Now().Before(c.suiteDeadline)
+}
+
 func (c *client) do(req *http.Request) ResponseWrapper {
+	if c.errGetter() != nil || req == nil {
+		return c.nop()
+	}
+	req = req.WithContext(withRequestID(req.Context()))
+	for _, hook := range c.requestHooks {
+		hook(req)
+	}
+	if err := makeRewindable(req); err != nil {
+		c.errSetter(errors.Wrap(err, "buffering request body"))
+		return c.nop()
+	}
+	if err := c.sign(req); err != nil {
+		c.errSetter(errors.Wrap(err, "signing request"))
+		return c.nop()
+	}
+	return c.exchange(req)
+}
+
+// exchange sends req as it is, retrying as the client's retry policy says.
+func (c *client) exchange(req *http.Request) (rw ResponseWrapper) {
 	if c.errGetter() != nil {
-		return newResponseWrapper(nil, c.Error, c.errSetter)
+		return c.nop()
 	}
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
+	if err := c.checkHost(req.URL); err != nil {
+		c.errSetter(errors.Wrapf(err, "not doing a %v request to URL %q", req.Method, req.URL.String()))
+		return c.nop()
+	}
+	if err := makeRewindable(req); err != nil {
+		c.errSetter(errors.Wrap(err, "buffering request body"))
+		return c.nop()
+	}
+	if c.lifecycle.isClosed() {
+		c.errSetter(errors.Wrapf(ErrClientClosed, "not doing a %v request to URL %q", req.Method, req.URL.String()))
+		return c.nop()
+	}
+	if c.suiteDeadlinePassed() {
+		c.errSetter(errors.Wrapf(ErrSuiteDeadlineExceeded, "not doing a %v request to URL %q", req.Method, req.URL.String()))
+		return c.nop()
+	}
+	if !c.inHook {
+		if err := c.lifecycle.firstUse(); err != nil {
+			c.errSetter(errors.Wrapf(err, "not doing a %v request to URL %q", req.Method, req.URL.String()))
+			return c.nop()
+		}
+	}
+	if c.traffic != nil {
+		if err := c.traffic.admit(c, req); err != nil {
+			c.errSetter(errors.Wrapf(err, "not doing a %v request to URL %q", req.Method, req.URL.String()))
+			return c.nop()
+		}
+	}
+	if rw := c.dryRunResponse(req); rw != nil {
+		return rw
+	}
+	if c.metrics != nil {
+		start := time.Now()
+		defer func() {
+			c.observe(req, start, rw)
+		}()
+	}
+	ctx, cancel := c.requestContext(withRequestID(req.Context()))
+	defer cancel()
+	if err := c.scheduler.acquire(ctx, c.priority); err != nil {
+		c.errSetter(errors.Wrapf(err, "waiting to do a %v request to URL %q", req.Method, req.URL.String()))
+		return c.nop()
+	}
+	defer c.scheduler.release()
+
+	getBody := req.GetBody
+	var retryErrors []error
+	sent, reconnected, reauthenticated := false, false, false
+	for n := 1; ; n++ {
+		attemptReq := req.WithContext(withAttempt(ctx, n))
+		if sent && getBody != nil {
+			body, err := getBody()
+			if err != nil {
+				c.errSetter(errors.Wrap(err, "rewinding request body"))
+				return c.nop()
+			}
+			attemptReq.Body = body
+		}
+		a, err := c.send(attemptReq)
+		sent = true
+		if err != nil && a != nil && c.har != nil {
+			c.har.add(a, "", err, c.credentialHeaders, c.tags)
+		}
+		if err != nil && a != nil && c.logger != nil {
+			c.logger.log(a, "", err, c.credentialHeaders)
+		}
+		if err != nil && !reconnected && a != nil && a.timings.get().Reused && isIdempotent(req) && isConnReset(err) && ctx.Err() == nil {
+			// The server closed the kept-alive connection as it was reused;
+			// try once more, without counting an attempt. The transport has
+			// already dropped the dead connection, and the transport may be
+			// shared, so the rest of its pool is left alone.
+			reconnected = true
+			n--
+			continue
+		}
+		if err == nil && !reauthenticated && a.resp.StatusCode == http.StatusUnauthorized && c.auth != nil && c.auth.refreshOnUnauthorized {
+			// The credentials were revoked or expired early; get new ones
+			// and try once more, without counting an attempt.
+			reauthenticated = true
+			c.discard(a)
+			c.auth.invalidate()
+			if err := c.addAuth(req); err != nil {
+				c.errSetter(errors.Wrap(err, "authenticating"))
+				return c.nop()
+			}
+			if err := c.sign(req); err != nil {
+				c.errSetter(errors.Wrap(err, "signing request"))
+				return c.nop()
+			}
+			n--
+			continue
+		}
+		retry := c.retry != nil && n < c.retry.attempts
+		if err == nil && !(retry && c.retry.retriesStatus(a.resp.StatusCode)) {
+			return c.wrap(a, n, retryErrors)
+		}
+		if err == nil {
+			c.discard(a)
+			err = errors.Errorf("got status %v", a.resp.Status)
+		} else if ctx.Err() != nil || errors.Is(err, ErrHostNotAllowed) {
+			retry = false
+		}
+		if retry {
+			retryErrors = append(retryErrors, errors.Wrapf(err, "attempt %d", n))
+			if err = c.retry.wait(ctx, n); err == nil {
+				continue
+			}
+		}
+		if c.lifecycle.isClosed() {
+			err = errors.Wrap(ErrClientClosed, err.Error())
+		} else if c.suiteDeadlinePassed() {
+			err = errors.Wrap(ErrSuiteDeadlineExceeded, err.Error())
+		}
+		if len(retryErrors) > 0 {
+			err = errors.Wrapf(err, "attempt %d, after %v", n, joinErrors(retryErrors))
+		}
 		c.errSetter(errors.Wrap(err, "doing request"))
+		return c.nop()
 	}
-	return newResponseWrapper(resp, c.Error, func(err error) {
-		c.errSetter(errors.Wrapf(err, "doing a %v request to URL %q", req.Method, req.URL.String()))
-	})
+}
+
+// attempt is a single sending of a request, and what was recorded while it
+// was sent.
+type attempt struct {
+	start   time.Time
+	req     *http.Request
+	resp    *http.Response
+	sent    *bodyRecorder
+	timings *timingsRecorder
+	wire    *wireRecorder
+	proxy   *proxyRecorder
+	// dryRun is set if the request was not sent because the client is in
+	// dry-run mode, in which case there is no response.
+	dryRun bool
+	// done must be called once the response body has been read.
+	done func()
+}
+
+// send sends req once, after waiting for the client's rate limits. If sending
+// fails, the attempt is returned along with the error if it got as far as
+// the transport.
+func (c *client) send(req *http.Request) (*attempt, error) {
+	ctx := req.Context()
+	if err := c.limits.wait(ctx, c.relativePath(req.URL)); err != nil {
+		return nil, errors.Wrap(err, "waiting for rate limit")
+	}
+
+	a := &attempt{
+		start:   time.Now(),
+		timings: &timingsRecorder{},
+		proxy:   &proxyRecorder{},
+		done:    func() {},
+	}
+	ctx = withProxyRecorder(ctx, a.proxy)
+	var watchdog *idleWatchdog
+	if c.readIdleTimeout > 0 {
+		ctx, watchdog = newIdleWatchdog(ctx, c.readIdleTimeout)
+		ctx = httptrace.WithClientTrace(ctx, watchdog.trace())
+		a.done = watchdog.stop
+	}
+	ctx = httptrace.WithClientTrace(ctx, a.timings.trace())
+	if c.dial.captureWire {
+		a.wire = &wireRecorder{}
+		ctx = httptrace.WithClientTrace(ctx, a.wire.trace())
+	}
+	a.req = req.WithContext(ctx)
+
+	httpClient := *c.httpClient
+	httpClient.CheckRedirect = c.checkRedirect(c.httpClient.CheckRedirect)
+	a.sent = recordBody(a.req)
+	resp, err := httpClient.Do(a.req)
+	if err != nil {
+		a.done()
+		if watchdog != nil {
+			err = watchdog.err(err)
+		}
+		return a, err
+	}
+	if watchdog != nil {
+		resp.Body = watchdog.wrap(resp.Body)
+	}
+	a.resp = resp
+	return a, nil
+}
+
+// discard reads and closes the body of a response that will not be wrapped,
+// e.g. because the request is retried, recording it if the client records
+// HAR files or logs.
+func (c *client) discard(a *attempt) {
+	defer a.done()
+	defer a.resp.Body.Close()
+	if c.har == nil && c.logger == nil {
+		io.Copy(ioutil.Discard, a.resp.Body)
+		return
+	}
+	var rd io.Reader = a.resp.Body
+	if decoded, err := decodingReader(a.resp.Header.Get("Content-Encoding"), rd); err == nil && decoded != nil {
+		defer decoded.Close()
+		rd = decoded
+	}
+	body, _ := ioutil.ReadAll(rd)
+	if c.har != nil {
+		c.har.add(a, string(body), nil, c.credentialHeaders, c.tags)
+	}
+	if c.logger != nil {
+		c.logger.log(a, string(body), nil, c.credentialHeaders)
+	}
+}
+
+// wrap reads the response of the nth attempt to send a request.
+func (c *client) wrap(a *attempt, n int, retryErrors []error) ResponseWrapper {
+	req, sent := a.req, a.sent
+	var rw *responseWrapper
+	rw = newResponseWrapperWithSettings(a.resp, c.errGetter, func(err error) {
+		c.reportFailure(err, req, sent, rw)
+	}, c.responseSettings())
+	a.done()
+	rw.report = c.Error
+	rw.attempts = n
+	rw.retryErrors = retryErrors
+	rw.tags = c.tags
+	rw.req = req
+	rw.timings = a.timings.get()
+	rw.timings.Total = time.Since(a.start)
+	rw.proxy = a.proxy.get()
+	if a.wire != nil {
+		rw.wire = a.wire.get()
+	}
+	rw.sentBody = sent
+	rw.replay = func() ResponseWrapper {
+		return c.replay(req, sent.Bytes())
+	}
+	if c.har != nil {
+		c.har.add(a, rw.body, nil, c.credentialHeaders, c.tags)
+	}
+	if c.logger != nil {
+		c.logger.log(a, rw.body, nil, c.credentialHeaders)
+	}
+	if c.shadow != nil {
+		c.shadow.mirror(c.rootBaseURL(), req, sent.Bytes(), rw)
+	}
+	if c.errGetter() == nil {
+		for _, hook := range c.responseHooks {
+			hook(rw.resp, rw.body)
+		}
+	}
+	return rw
+}
+
+// subResponseWrapper wraps the response to a request that was not sent on its
+// own, e.g. one of a batch.
+func (c *client) subResponseWrapper(req *http.Request, body []byte, resp *http.Response) ResponseWrapper {
+	sent := &bodyRecorder{}
+	sent.write(body)
+	var rw *responseWrapper
+	rw = newResponseWrapperWithSettings(resp, c.errGetter, func(err error) {
+		c.reportFailure(err, req, sent, rw)
+	}, c.responseSettings())
+	rw.report = c.Error
+	rw.attempts = 1
+	rw.tags = c.tags
+	rw.req = req
+	rw.sentBody = sent
+	return rw
+}
+
+// reportFailure sets the client's error for a failed assertion on rw.
+func (c *client) reportFailure(err error, req *http.Request, sent *bodyRecorder, rw *responseWrapper) {
+	info := newFailureInfo(err, req, sent, rw, c.credentialHeaders)
+	if c.errorFormatter != nil {
+		c.errSetter(c.errorFormatter(info))
+	} else {
+		c.errSetter(info.DefaultError())
+	}
+}
+
+// replay sends req again exactly as it was sent, without applying the
+// client's headers, auth, request hooks or other settings a second time.
+func (c *client) replay(req *http.Request, body []byte) ResponseWrapper {
+	if c.errGetter() != nil {
+		return c.nop()
+	}
+	again := req.Clone(context.Background())
+	again.Body = nil
+	again.GetBody = nil
+	if req.Body != nil && req.Body != http.NoBody {
+		again.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+		again.Body, _ = again.GetBody()
+		again.ContentLength = int64(len(body))
+	}
+	return c.exchange(again)
+}
+
+func (c *client) responseSettings() responseSettings {
+	settings := responseSettings{
+		decompress:        c.decompress,
+		normalize:         c.normalize,
+		credentialHeaders: c.credentialHeaders,
+		sink:              c.sink,
+		maxBodySize:       c.maxBodySize,
+		truncateBody:      c.truncateBody,
+		spec:              c.spec,
+		specRoot:          c.rootBaseURL(),
+	}
+	if c.apiVersion.style.kind == versionHeader {
+		settings.versionHeader = c.apiVersion.style.name
+	}
+	return settings
+}
+
+// Do sends a request built elsewhere, adding the client's headers, auth and
+// other settings to it. Its URL is used as is rather than being resolved
+// against the base URL.
+func (c *client) Do(req *http.Request) ResponseWrapper {
+	if c.errGetter() != nil {
+		return c.nop()
+	}
+	return c.do(c.populateReq(req.Clone(req.Context())))
 }
 
 func (c *client) Delete(path string) ResponseWrapper {
@@ -282,29 +1314,195 @@ func (c *client) PutNoBody(path string) ResponseWrapper {
 }
 
 func (c *client) PatchString(path string, body string) ResponseWrapper {
-	return c.doReqString(http.MethodPatch, path, body)
+	return c.doReqString(http.MethodPatch, path, body, "")
 }
 
 func (c *client) PostString(path string, body string) ResponseWrapper {
-	return c.doReqString(http.MethodPost, path, body)
+	return c.doReqString(http.MethodPost, path, body, "")
 }
 
 func (c *client) PutString(path string, body string) ResponseWrapper {
-	return c.doReqString(http.MethodPut, path, body)
+	return c.doReqString(http.MethodPut, path, body, "")
 }
 
 func (c *client) PatchBytes(path string, body []byte) ResponseWrapper {
-	return c.doReqBytes(http.MethodPatch, path, body)
+	return c.doReqBytes(http.MethodPatch, path, body, "")
 }
 
 func (c *client) PostBytes(path string, body []byte) ResponseWrapper {
-	return c.doReqBytes(http.MethodPost, path, body)
+	return c.doReqBytes(http.MethodPost, path, body, "")
 }
 
 func (c *client) PutBytes(path string, body []byte) ResponseWrapper {
-	return c.doReqBytes(http.MethodPut, path, body)
+	return c.doReqBytes(http.MethodPut, path, body, "")
+}
+
+func (c *client) PatchStringTyped(path string, body string, contentType string) ResponseWrapper {
+	return c.doReqString(http.MethodPatch, path, body, contentType)
+}
+
+func (c *client) PostStringTyped(path string, body string, contentType string) ResponseWrapper {
+	return c.doReqString(http.MethodPost, path, body, contentType)
+}
+
+func (c *client) PutStringTyped(path string, body string, contentType string) ResponseWrapper {
+	return c.doReqString(http.MethodPut, path, body, contentType)
+}
+
+func (c *client) PatchBytesTyped(path string, body []byte, contentType string) ResponseWrapper {
+	return c.doReqBytes(http.MethodPatch, path, body, contentType)
+}
+
+func (c *client) PostBytesTyped(path string, body []byte, contentType string) ResponseWrapper {
+	return c.doReqBytes(http.MethodPost, path, body, contentType)
+}
+
+func (c *client) PutBytesTyped(path string, body []byte, contentType string) ResponseWrapper {
+	return c.doReqBytes(http.MethodPut, path, body, contentType)
+}
+
+// withContext returns a copy of the client, sharing its error, whose
+// requests are made with ctx.
+func (c *client) withContext(ctx context.Context) *client {
+	cloned := c.cloneConfig()
+	cloned.ctx = ctx
+	return cloned
+}
+
+func (c *client) DeleteCtx(ctx context.Context, path string) ResponseWrapper {
+	return c.withContext(ctx).Delete(path)
+}
+
+func (c *client) GetCtx(ctx context.Context, path string) ResponseWrapper {
+	return c.withContext(ctx).Get(path)
+}
+
+func (c *client) PatchCtx(ctx context.Context, path string, body interface{}) ResponseWrapper {
+	return c.withContext(ctx).Patch(path, body)
+}
+
+func (c *client) PostCtx(ctx context.Context, path string, body interface{}) ResponseWrapper {
+	return c.withContext(ctx).Post(path, body)
+}
+
+func (c *client) PutCtx(ctx context.Context, path string, body interface{}) ResponseWrapper {
+	return c.withContext(ctx).Put(path, body)
 }
 
 func (c *client) PostForm(path string, body url.Values) ResponseWrapper {
 	return c.doReqForm(http.MethodPost, path, body)
 }
+
+func (c *client) PatchMerge(path string, patch interface{}) ResponseWrapper {
+	return c.doReqJSONTyped(http.MethodPatch, path, patch, "application/merge-patch+json")
+}
+
+func (c *client) PatchJSONPatch(path string, ops []PatchOp) ResponseWrapper {
+	if ops == nil {
+		ops = []PatchOp{}
+	}
+	return c.doReqJSONTyped(http.MethodPatch, path, ops, "application/json-patch+json")
+}
+
+// makeRewindable makes sure req.GetBody is set, so the body can be sent
+// again on retries and on 307/308 redirects.
+func makeRewindable(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+	bs, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(bs))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(bs)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+// bodyRecorder keeps a copy of the request body bytes as the transport reads
+// them, so the wrapper can report what was actually sent.
+type bodyRecorder struct {
+	lock     sync.Mutex
+	buf      bytes.Buffer
+	original []byte
+	rewinds  int
+}
+
+func (b *bodyRecorder) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+func (b *bodyRecorder) Rewinds() int {
+	if b == nil {
+		return 0
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.rewinds
+}
+
+func (b *bodyRecorder) reset() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.buf.Reset()
+	b.rewinds++
+}
+
+func (b *bodyRecorder) write(p []byte) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.buf.Write(p)
+}
+
+func (b *bodyRecorder) wrap(body io.ReadCloser) io.ReadCloser {
+	return &recordingReadCloser{ReadCloser: body, recorder: b}
+}
+
+type recordingReadCloser struct {
+	io.ReadCloser
+	recorder *bodyRecorder
+}
+
+func (r *recordingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.recorder.write(p[:n])
+	return n, err
+}
+
+// recordBody tees req.Body into a recorder. A rewound body (via GetBody, as
+// used for retries and redirects) starts the recording afresh.
+func recordBody(req *http.Request) *bodyRecorder {
+	rec := &bodyRecorder{}
+	if req.Body == nil || req.Body == http.NoBody {
+		return rec
+	}
+	req.Body = rec.wrap(req.Body)
+	if getBody := req.GetBody; getBody != nil {
+		if body, err := getBody(); err == nil {
+			rec.original, _ = ioutil.ReadAll(body)
+			body.Close()
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			rec.reset()
+			return rec.wrap(body), nil
+		}
+	}
+	return rec
+}
@@ -1,16 +1,641 @@
 package crest
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
 func TestNewClient(t *testing.T) {
-	baseURL := "base URL"
+	baseURL := "http://example.com/api"
 	c := NewClient(baseURL)
 	cImpl, ok := c.(*client)
 	require.True(t, ok)
 	require.Equal(t, baseURL, cImpl.baseURL)
 	require.NoError(t, c.Error())
+	require.Equal(t, baseURL, MustNewClient(baseURL).(*client).baseURL)
+
+	for baseURL, message := range map[string]string{
+		"base URL":           `"base URL" has no scheme`,
+		"localhost:8080":     `"localhost:8080" has no host`,
+		"http:///api":        `"http:///api" has no host`,
+		"http://[::1":        "missing ']' in host",
+		"example.com/api/v1": `"example.com/api/v1" has no scheme`,
+	} {
+		c := NewClient(baseURL)
+		require.Error(t, c.Error(), baseURL)
+		require.True(t, errors.Is(c.Error(), ErrInvalidBaseURL), baseURL)
+		require.Contains(t, c.Error().Error(), message)
+		c.Get("/").ExpectStatus(http.StatusOK)
+		require.True(t, errors.Is(c.Error(), ErrInvalidBaseURL), baseURL)
+		require.Panics(t, func() { MustNewClient(baseURL) }, baseURL)
+	}
+}
+
+func TestClientCompressedTransfer(t *testing.T) {
+	body := strings.Repeat("compressible ", 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write([]byte(body))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(body))
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	rw := c.Get("/").
+		ExpectStatus(http.StatusOK).
+		ExpectCompressedTransfer().
+		ExpectBodyEquals(body)
+	require.NoError(t, c.Error())
+	require.True(t, rw.Sizes().Wire < rw.Sizes().Decoded)
+}
+
+func TestClientAcceptEncoding(t *testing.T) {
+	body := strings.Repeat("compressible ", 100)
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(body))
+	zw.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write([]byte(body))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(compressed.Bytes())
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).DisableAutoDecompression()
+	c.Get("/").
+		ExpectHeaderEquals("X-Accept-Encoding", "gzip").
+		ExpectHeaderEquals("Content-Encoding", "gzip").
+		ExpectCompressedTransfer().
+		ExpectBodyEquals(compressed.String())
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL).WithAcceptEncoding("br", "gzip;q=0.5")
+	c.Get("/").
+		ExpectHeaderEquals("X-Accept-Encoding", "br, gzip;q=0.5").
+		ExpectCompressedTransfer().
+		ExpectBodyEquals(body)
+	c.Clone().WithAcceptEncoding().Get("/").
+		ExpectHeaderEquals("X-Accept-Encoding", "identity").
+		ExpectHeaderNotPresent("Content-Encoding").
+		ExpectBodyEquals(body)
+	require.NoError(t, c.Error())
+}
+
+func TestClientSentBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		w.Write(bs)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	rw := c.PostString("/", "string body").ExpectBodyEquals("string body")
+	require.Equal(t, "string body", rw.SentBody())
+	rw = c.Post("/", map[string]string{"key": "value"})
+	require.Equal(t, `{"key":"value"}`, rw.SentBody())
+	rw = c.Get("/")
+	require.Equal(t, "", rw.SentBody())
+	require.NoError(t, c.Error())
+}
+
+func TestClientRedirectPreservesBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/temporary":
+			http.Redirect(w, r, "/target", http.StatusTemporaryRedirect)
+		case "/see-other":
+			http.Redirect(w, r, "/target", http.StatusSeeOther)
+		default:
+			bs, _ := ioutil.ReadAll(r.Body)
+			w.Write(bs)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.PostString("/temporary", "payload").
+		ExpectBodyEquals("payload").
+		ExpectRedirectPreservedBody()
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL)
+	c.PostString("/see-other", "payload").ExpectRedirectPreservedBody()
+	require.Error(t, c.Error())
+
+	c = NewClient(srv.URL)
+	c.PostString("/target", "payload").ExpectRedirectPreservedBody()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "not")
+}
+
+func TestClientDefaultContentType(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Content-Type")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.PostString("/", "body").ExpectBodyEquals("")
+	c.WithDefaultContentType("text/plain")
+	c.PostString("/", "body").ExpectBodyEquals("text/plain")
+	c.PutBytes("/", []byte("body")).ExpectBodyEquals("text/plain")
+	c.PostForm("/", nil).ExpectBodyEquals("application/x-www-form-urlencoded")
+	c.Clone().
+		WithHeader("Content-Type", "application/xml").
+		PatchString("/", "<body/>").
+		ExpectBodyEquals("application/xml")
+	require.NoError(t, c.Error())
+}
+
+func TestClientTypedBodies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Header.Get("Content-Type") + " " + string(bs)))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).
+		WithDefaultContentType("text/plain").
+		WithHeader("Content-Type", "application/json")
+	c.PostStringTyped("/", "<a/>", "application/xml").ExpectBodyEquals("application/xml <a/>")
+	c.PutStringTyped("/", "a,b", "text/csv").ExpectBodyEquals("text/csv a,b")
+	c.PatchStringTyped("/", "x", "text/x").ExpectBodyEquals("text/x x")
+	c.PostBytesTyped("/", []byte("b"), "application/octet-stream").ExpectBodyEquals("application/octet-stream b")
+	c.PutBytesTyped("/", []byte("b"), "image/png").ExpectBodyEquals("image/png b")
+	c.PatchBytesTyped("/", []byte("b"), "").ExpectBodyEquals("application/json b")
+	require.NoError(t, c.Error())
+}
+
+func TestClientJSONEncoder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		w.Write(bs)
+	}))
+	defer srv.Close()
+
+	body := map[string]string{"html": "<b>"}
+	c := NewClient(srv.URL)
+	c.Post("/", body).ExpectBodyEquals(`{"html":"\u003cb\u003e"}`)
+	c.WithJSONEncoder(func(v interface{}) ([]byte, error) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		err := enc.Encode(v)
+		return bytes.TrimSpace(buf.Bytes()), err
+	})
+	c.Put("/", body).ExpectBodyEquals(`{"html":"<b>"}`)
+	require.NoError(t, c.Error())
+
+	c.WithJSONEncoder(func(interface{}) ([]byte, error) {
+		return nil, fmt.Errorf("encoder failure")
+	})
+	c.Patch("/", body)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "encoder failure")
+}
+
+func TestClientGroup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + strings.Join(r.Header["X-Layer"], ",")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithHeader("X-Layer", "root")
+	v2 := c.Group("/api/v2/")
+	users := v2.Group("users").WithHeader("X-Layer", "users")
+	c.WithHeader("X-Layer", "late")
+
+	v2.Get("/status").ExpectBodyEquals("/api/v2/status root,late")
+	users.Get("/1").ExpectBodyEquals("/api/v2/users/1 root,late,users")
+	users.Clone().Get("").ExpectBodyEquals("/api/v2/users/ root,late,users")
+	c.Get("/").ExpectBodyEquals("/ root,late")
+	require.NoError(t, c.Error())
+
+	users.Get("/1").ExpectStatus(http.StatusNotFound)
+	require.Error(t, c.Error())
+}
+
+func TestClientQueryParams(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RequestURI()))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithQueryParam("q", "a b&c")
+	clone := c.Clone().WithQueryParam("page", "2")
+	group := c.Group("/items").WithQueryParam("q", "d")
+	c.WithQueryParam("lang", "en")
+
+	c.Get("/search?q=x").ExpectBodyEquals("/search?q=x&lang=en&q=a+b%26c")
+	c.Get("/search?z=1&a=%7e").ExpectBodyEquals("/search?z=1&a=%7e&lang=en&q=a+b%26c")
+	clone.Get("/search").ExpectBodyEquals("/search?page=2&q=a+b%26c")
+	group.Get("/").ExpectBodyEquals("/items/?lang=en&q=a+b%26c&q=d")
+	require.NoError(t, c.Error())
+}
+
+func TestClientAPIVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Api-Version", "v2")
+		w.Write([]byte(r.URL.String() + " " + r.Header.Get("Accept") + " " + r.Header.Get("Api-Version")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.Clone().WithAPIVersion("v2", VersionInAccept("foo")).Get("/items").
+		ExpectBodyEquals("/items application/vnd.foo.v2+json ").
+		ExpectAPIVersion("v2")
+	c.Clone().WithAPIVersion("v2", VersionInHeader("api-version")).Get("/items").
+		ExpectBodyEquals("/items  v2")
+	c.Clone().WithAPIVersion("v2", VersionInPath()).Get("/items").
+		ExpectBodyEquals("/v2/items  ")
+	rw := c.Clone().WithAPIVersion("v2", VersionInQuery("api")).Get("/items?z=b&a=%7e").
+		ExpectBodyEquals("/items?z=b&a=%7e&api=v2  ").
+		ExpectRequestQuerySent("api", "v2")
+	require.Equal(t, "/items?z=b&a=%7e&api=v2", rw.SentURL().RequestURI())
+	c.Clone().WithAPIVersion("v2", VersionInQuery("api")).Get("/items").
+		ExpectBodyEquals("/items?api=v2  ")
+	require.NoError(t, c.Error())
+
+	c.Get("/").ExpectAPIVersion("v3")
+	require.Error(t, c.Error())
+}
+
+func TestClientClockSkew(t *testing.T) {
+	var date, timestamp string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		date = r.Header.Get("Date")
+		timestamp = r.Header.Get("X-Timestamp")
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.Get("/")
+	require.Empty(t, date)
+	require.Empty(t, timestamp)
+
+	c.WithClockSkew(-time.Hour).Get("/")
+	require.NoError(t, c.Error())
+	sent, err := http.ParseTime(date)
+	require.NoError(t, err)
+	require.True(t, time.Since(sent) > 59*time.Minute)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	require.NoError(t, err)
+	require.Equal(t, sent.Unix(), unix)
+}
+
+func TestClientExpiredCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		issued, err := strconv.ParseInt(token, 10, 64)
+		if err != nil || time.Since(time.Unix(issued, 0)) > time.Hour {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(r.Header.Get("X-Timestamp")))
+	}))
+	defer srv.Close()
+
+	mint := func(issuedAt time.Time) (string, time.Time, error) {
+		return strconv.FormatInt(issuedAt.Unix(), 10), issuedAt.Add(time.Hour), nil
+	}
+	c := NewClient(srv.URL).UseBearerTokenMinter(mint)
+	c.Get("/").ExpectStatus(http.StatusOK).ExpectBodyEquals("")
+	expired := c.Clone().WithExpiredCredentials()
+	rw := expired.Get("/").ExpectStatus(http.StatusUnauthorized)
+	require.NoError(t, c.Error())
+	sent, err := strconv.ParseInt(rw.Response().Request.Header.Get("X-Timestamp"), 10, 64)
+	require.NoError(t, err)
+	require.True(t, time.Since(time.Unix(sent, 0)) > 23*time.Hour)
+	c.Get("/").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL).WithExpiredCredentials().UseBearerTokenMinter(mint)
+	c.Get("/").ExpectStatus(http.StatusUnauthorized)
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL).UseBearerToken("abc").WithExpiredCredentials()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "UseBearerTokenMinter")
+}
+
+func TestClientReplayRequest(t *testing.T) {
+	seen := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		key := r.Header.Get("X-Nonce") + " " + string(bs)
+		if seen[key] {
+			w.WriteHeader(http.StatusConflict)
+		}
+		seen[key] = true
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithHeader("X-Nonce", "n1")
+	rw := c.PostString("/", "payload").ExpectStatus(http.StatusOK)
+	replayed := rw.ReplayRequest().ExpectStatus(http.StatusConflict)
+	require.NoError(t, c.Error())
+	require.Equal(t, "payload", replayed.SentBody())
+	require.Equal(t, []string{"n1"}, replayed.Response().Request.Header["X-Nonce"])
+
+	ec := &errContainer{}
+	rw = newResponseWrapper(respWithBody(""), ec.Error, ec.Set)
+	replayed = rw.ReplayRequest()
+	require.Error(t, ec.Error())
+	require.Equal(t, ec.Error(), replayed.Error())
+}
+
+func TestClientIdentityHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Tenant-ID") + "|" + r.Header.Get("X-Impersonate-User") + "|" + r.Header.Get("X-Org")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	acme := c.AsTenant("acme")
+	acme.Get("/").ExpectBodyEquals("acme||")
+	acme.Impersonate("alice").Get("/").ExpectBodyEquals("acme|alice|")
+	acme.AsTenant("other").Get("/").ExpectBodyEquals("other||")
+	c.Get("/").ExpectBodyEquals("||")
+	c.Clone().WithIdentityHeaders(IdentityHeaders{Tenant: "X-Org"}).AsTenant("acme").Get("/").ExpectBodyEquals("||acme")
+	require.NoError(t, c.Error())
+
+	c.Clone().WithIdentityHeaders(IdentityHeaders{}).Impersonate("bob")
+	require.Error(t, c.Error())
+}
+
+func TestClientSuiteDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithSuiteDeadline(time.Now().Add(-time.Second))
+	c.Get("/")
+	require.Error(t, c.Error())
+	require.Equal(t, ErrSuiteDeadlineExceeded, errors.Cause(c.Error()))
+
+	c = NewClient(srv.URL).WithSuiteDeadline(time.Now().Add(50 * time.Millisecond))
+	c.Get("/").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+	c.Get("/slow")
+	require.Error(t, c.Error())
+	require.Equal(t, ErrSuiteDeadlineExceeded, errors.Cause(c.Error()))
+}
+
+func TestClientTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithTimeout(20 * time.Millisecond)
+	c.Get("/")
+	require.Error(t, c.Error())
+	require.NotEqual(t, ErrSuiteDeadlineExceeded, errors.Cause(c.Error()))
+}
+
+func TestClientNetworkErrorWrapper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+
+	c := NewClient(srv.URL)
+	rw := c.Get("/")
+	require.Error(t, c.Error())
+	require.Equal(t, c.Error(), rw.Error())
+	require.Equal(t, c.Error(), c.Get("/again").Error())
+}
+
+func TestClientContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		bs, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + string(bs)))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	ctx := context.Background()
+	c.GetCtx(ctx, "/").ExpectBodyEquals("GET ")
+	c.DeleteCtx(ctx, "/").ExpectBodyEquals("DELETE ")
+	c.PatchCtx(ctx, "/", 1).ExpectBodyEquals("PATCH 1")
+	c.PostCtx(ctx, "/", "a").ExpectBodyEquals(`POST "a"`)
+	c.PutCtx(ctx, "/", nil).ExpectBodyEquals("PUT null")
+	require.NoError(t, c.Error())
+
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	c.GetCtx(ctx, "/slow")
+	require.Less(t, time.Since(start), time.Second)
+	require.True(t, errors.Is(c.Error(), context.DeadlineExceeded))
+
+	c = NewClient(srv.URL)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.PostCtx(cancelled, "/", "a")
+	require.True(t, errors.Is(c.Error(), context.Canceled))
+}
+
+func TestClientClose(t *testing.T) {
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	clone := c.Clone()
+	c.Get("/").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+
+	impl := c.(*client)
+	flushed := false
+	impl.lifecycle.onClose(func() error {
+		flushed = true
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		clone.Get("/slow")
+	}()
+	<-started
+	require.NoError(t, c.Close())
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("request in flight was not cancelled")
+	}
+	require.True(t, flushed)
+	require.Equal(t, ErrClientClosed, errors.Cause(c.Error()))
+	require.NoError(t, c.Close())
+
+	c = NewClient(srv.URL)
+	require.NoError(t, c.Close())
+	c.Get("/")
+	require.Equal(t, ErrClientClosed, errors.Cause(c.Error()))
+}
+
+func TestClientErrorCollection(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithErrorCollection()
+	c.Get("/").
+		ExpectStatus(http.StatusCreated).
+		ExpectBodyEquals("other").
+		ExpectBodyContains("body")
+	c.Get("/").ExpectHeaderPresent("X-Missing")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "3 failures")
+	require.Contains(t, c.Error().Error(), "expected status code 201")
+	require.Contains(t, c.Error().Error(), `expected body to be "other"`)
+	require.Contains(t, c.Error().Error(), "X-Missing")
+
+	c = NewClient(srv.URL).WithErrorCollection().WithMaxFailures(2)
+	clone := c.Clone()
+	requests := 0
+	clone.Get("/").
+		ExpectStatus(http.StatusCreated).
+		ExpectBodyEquals("other").
+		ExpectBodyPasses(func(string) bool {
+			requests++
+			return false
+		})
+	clone.Get("/").ExpectBodyPasses(func(string) bool {
+		requests++
+		return false
+	})
+	require.Equal(t, 0, requests)
+	require.Contains(t, c.Error().Error(), "2 failures")
+
+	c = NewClient("http://127.0.0.1:0").WithErrorCollection()
+	c.Get("/").ExpectStatus(http.StatusOK)
+	c.Get("/").ExpectStatus(http.StatusOK)
+	require.Contains(t, c.Error().Error(), "2 failures")
+	require.Contains(t, c.Error().Error(), "doing request")
+	require.Len(t, c.Errors(), 2)
+	require.Contains(t, c.Errors()[0].Error(), "doing request")
+
+	c = NewClient(srv.URL)
+	require.Empty(t, c.Errors())
+	c.Get("/").ExpectStatus(http.StatusCreated).ExpectBodyEquals("other")
+	require.Len(t, c.Errors(), 1)
+	require.Contains(t, c.Errors()[0].Error(), "expected status code 201")
+
+	c = NewClient(srv.URL).WithErrorCollection()
+	c.Get("/").ExpectStatus(http.StatusCreated).ExpectBodyEquals("other")
+	require.Contains(t, c.Error().Error(), "\n    reproduce with: curl")
+}
+
+func TestClientErrorsUnwrap(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithErrorCollection()
+	c.Get("/").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+
+	c.Get("/").ExpectStatus(http.StatusCreated)
+	errs, ok := c.Error().(Errors)
+	require.True(t, ok)
+	require.Len(t, errs, 1)
+	require.Equal(t, errs[0].Error(), c.Error().Error())
+
+	c.WithSuiteDeadline(time.Now().Add(-time.Second)).Get("/")
+	errs, ok = c.Error().(Errors)
+	require.True(t, ok)
+	require.Len(t, errs, 2)
+	require.True(t, errors.Is(c.Error(), ErrSuiteDeadlineExceeded))
+	require.False(t, errors.Is(c.Error(), ErrClientClosed))
+	require.Len(t, errs.Unwrap(), 2)
+
+	c = NewClient(srv.URL)
+	c.Get("/").ExpectStatus(http.StatusCreated)
+	_, ok = c.Error().(Errors)
+	require.False(t, ok)
+}
+
+func TestClientDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.URL.Path + " " + r.Header.Get("X-Client") + " " + string(bs)))
+	}))
+	defer srv.Close()
+
+	c := NewClient("http://unused.invalid").WithHeader("X-Client", "crest")
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/external", strings.NewReader("payload"))
+	require.NoError(t, err)
+	rw := c.Do(req).ExpectBodyEquals("PUT /external crest payload")
+	require.NoError(t, rw.Error())
+	require.NoError(t, c.Error())
+
+	rw.ExpectStatus(http.StatusCreated)
+	require.Error(t, rw.Error())
+	require.Equal(t, c.Error(), rw.Error())
+}
+
+func TestClientAttemptMetadata(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/elsewhere" {
+			http.Redirect(w, r, target.URL+"/", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	rw := c.Get("/")
+	require.Equal(t, 1, rw.Attempts())
+	require.Empty(t, rw.RetryErrors())
+	require.Equal(t, srv.URL, rw.ServedBy())
+	require.Equal(t, target.URL, c.Get("/elsewhere").ServedBy())
+	require.NoError(t, c.Error())
 }
@@ -0,0 +1,64 @@
+package crest
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PutIfMatch sends body encoded as JSON like Put, with an If-Match header so
+// the server only replaces the resource if its current ETag is etag. A bare
+// tag is quoted; weak tags ("W/...") and "*" are sent as they are. Use
+// ExpectPreconditionFailed to check that a stale ETag is rejected.
+func (c *client) PutIfMatch(path string, body interface{}, etag string) ResponseWrapper {
+	return c.doReqJSONWithHeaders(http.MethodPut, path, body, func([]byte) http.Header {
+		return http.Header{"If-Match": {quoteETag(etag)}}
+	})
+}
+
+// PostWithContentMD5 sends body encoded as JSON like Post, with a Content-MD5
+// header holding the base64-encoded MD5 digest of the encoded body, as APIs
+// that check the integrity of uploads expect.
+func (c *client) PostWithContentMD5(path string, body interface{}) ResponseWrapper {
+	return c.doReqJSONWithHeaders(http.MethodPost, path, body, func(encoded []byte) http.Header {
+		sum := md5.Sum(encoded)
+		return http.Header{"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])}}
+	})
+}
+
+// ExpectPreconditionFailed fails unless the status is 412 Precondition
+// Failed, as for a conditional request whose ETag no longer matches.
+func (r *responseWrapper) ExpectPreconditionFailed() ResponseWrapper {
+	return r.ExpectStatus(http.StatusPreconditionFailed)
+}
+
+// doReqJSONWithHeaders is like doReqJSON, but sets the headers computed from
+// the encoded body on the request.
+func (c *client) doReqJSONWithHeaders(method, path string, body interface{}, headers func(encoded []byte) http.Header) ResponseWrapper {
+	if c.errGetter() != nil {
+		return c.nop()
+	}
+	bs, err := c.jsonEncoder(body)
+	if err != nil {
+		c.errSetter(errors.Wrap(err, "marshalling JSON body"))
+		return c.nop()
+	}
+	req := c.buildReq(method, path, bs)
+	if req == nil {
+		return c.nop()
+	}
+	for key, vals := range headers(bs) {
+		req.Header[key] = vals
+	}
+	return c.do(req)
+}
+
+func quoteETag(etag string) string {
+	if etag == "*" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
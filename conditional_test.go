@@ -0,0 +1,58 @@
+package crest
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutIfMatch(t *testing.T) {
+	etag := `"v1"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			return
+		}
+		if got := r.Header.Get("If-Match"); got != etag && got != "*" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		etag = `"v2"`
+		w.Header().Set("ETag", etag)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.PutIfMatch("/doc", map[string]string{"a": "b"}, "v1").ExpectStatus(http.StatusOK).ExpectHeaderEquals("Etag", `"v2"`)
+	c.PutIfMatch("/doc", map[string]string{"a": "c"}, `"v1"`).ExpectPreconditionFailed()
+	c.PutIfMatch("/doc", map[string]string{"a": "c"}, "*").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+
+	c.PutIfMatch("/doc", nil, `W/"v3"`).ExpectStatus(http.StatusOK)
+	require.Error(t, c.Error())
+
+	c = NewClient(srv.URL)
+	c.Get("/doc").ExpectPreconditionFailed()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected status code 412 but got 200")
+}
+
+func TestPostWithContentMD5(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sum := md5.Sum(body)
+		if r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.PostWithContentMD5("/upload", map[string]int{"n": 1}).ExpectStatus(http.StatusOK).ExpectBodyEquals(`{"n":1}`)
+	require.NoError(t, c.Error())
+}
@@ -0,0 +1,46 @@
+package crest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	attemptKey
+	proxyKey
+)
+
+// RequestIDFromContext returns the ID the client gave the request whose
+// context ctx is. All attempts to send a request share its ID, so request
+// hooks, transports and response hooks can tell which attempts belong
+// together.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey).(string)
+	return id, ok
+}
+
+// AttemptFromContext returns which attempt to send a request ctx belongs to,
+// starting from 1. It is only set on the requests transports see and on the
+// request of a response, e.g. to sign only first attempts in a transport.
+func AttemptFromContext(ctx context.Context) (int, bool) {
+	n, ok := ctx.Value(attemptKey).(int)
+	return n, ok
+}
+
+// withRequestID gives ctx a new request ID unless it already has one.
+func withRequestID(ctx context.Context) context.Context {
+	if _, ok := RequestIDFromContext(ctx); ok {
+		return ctx
+	}
+	var id [8]byte
+	rand.Read(id[:])
+	return context.WithValue(ctx, requestIDKey, hex.EncodeToString(id[:]))
+}
+
+func withAttempt(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, attemptKey, n)
+}
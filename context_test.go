@@ -0,0 +1,59 @@
+package crest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestContext(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	var hookIDs, sentIDs []string
+	var attempts []int
+	var respAttempt int
+	base := http.DefaultTransport
+	httpClient := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		id, _ := RequestIDFromContext(req.Context())
+		n, _ := AttemptFromContext(req.Context())
+		sentIDs = append(sentIDs, id)
+		attempts = append(attempts, n)
+		return base.RoundTrip(req)
+	})}
+	c := NewCustomClient(srv.URL, httpClient).
+		WithRetry(2, ConstantBackoff(time.Millisecond)).
+		WithRequestHook(func(req *http.Request) {
+			id, ok := RequestIDFromContext(req.Context())
+			require.True(t, ok)
+			hookIDs = append(hookIDs, id)
+		}).
+		WithResponseHook(func(resp *http.Response, body string) {
+			respAttempt, _ = AttemptFromContext(resp.Request.Context())
+		})
+
+	c.Get("/").ExpectStatus(http.StatusOK)
+	c.Get("/").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+
+	require.Len(t, hookIDs, 2)
+	require.NotEqual(t, hookIDs[0], hookIDs[1])
+	require.Equal(t, []string{hookIDs[0], hookIDs[0], hookIDs[1], hookIDs[1]}, sentIDs)
+	require.Equal(t, []int{1, 2, 1, 2}, attempts)
+	require.Equal(t, 2, respAttempt)
+
+	_, ok := RequestIDFromContext(context.Background())
+	require.False(t, ok)
+	_, ok = AttemptFromContext(context.Background())
+	require.False(t, ok)
+}
@@ -0,0 +1,153 @@
+package crest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// AsCurl returns a curl command that sends the request as Do would,
+// including the client's headers, auth, request hooks and signature, for
+// reproducing it by hand. It returns "" if the request cannot be built, with
+// the client's error saying why.
+func (b *requestBuilder) AsCurl() string {
+	c := b.client
+	if c.errGetter() != nil {
+		return ""
+	}
+	var body []byte
+	if b.hasBody {
+		bs, err := c.jsonEncoder(b.body)
+		if err != nil {
+			c.errSetter(errors.Wrap(err, "marshalling JSON body"))
+			return ""
+		}
+		body = bs
+	}
+	req := c.buildReq(b.method, b.expandedPath(), body)
+	if req == nil {
+		return ""
+	}
+	for _, hook := range c.requestHooks {
+		hook(req)
+	}
+	if err := makeRewindable(req); err != nil {
+		c.errSetter(errors.Wrap(err, "buffering request body"))
+		return ""
+	}
+	if err := c.sign(req); err != nil {
+		c.errSetter(errors.Wrap(err, "signing request"))
+		return ""
+	}
+	// A hook may have replaced the body.
+	body = nil
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			c.errSetter(errors.Wrap(err, "buffering request body"))
+			return ""
+		}
+		body, err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			c.errSetter(errors.Wrap(err, "buffering request body"))
+			return ""
+		}
+	}
+	return curlCommand(req.Method, req.URL.String(), req.Header, body, nil)
+}
+
+// curlCommand renders a request as a curl command line, replacing the values
+// of the redacted headers. The Accept-Encoding crest sends becomes
+// --compressed, so curl decodes the body.
+func curlCommand(method, url string, header http.Header, body []byte, redacted []string) string {
+	args := []string{"curl"}
+	switch method {
+	case http.MethodGet:
+	case http.MethodHead:
+		args = append(args, "--head")
+	default:
+		args = append(args, "-X", method)
+	}
+	args = append(args, shellQuote(url))
+
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch http.CanonicalHeaderKey(key) {
+		case "Accept-Encoding":
+			args = append(args, "--compressed")
+			continue
+		case "Content-Length":
+			continue
+		}
+		for _, val := range header[key] {
+			if contains(redacted, http.CanonicalHeaderKey(key)) {
+				val = "REDACTED"
+			}
+			args = append(args, "-H", shellQuote(key+": "+val))
+		}
+	}
+	if len(body) > 0 {
+		args = append(args, "--data-binary", shellQuote(string(body)))
+	}
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes s for POSIX shells, falling back to bash's $'...' for
+// text with control characters or invalid UTF-8.
+func shellQuote(s string) string {
+	printable := utf8.ValidString(s) && strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsPrint(r) && r != ' '
+	}) < 0
+	if printable {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+	var b strings.Builder
+	b.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '\'' || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case ch == '\n':
+			b.WriteString(`\n`)
+		case ch == '\t':
+			b.WriteString(`\t`)
+		case ch < 0x20 || ch >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	b.WriteString("'")
+	return b.String()
+}
+
+// curlError appends the curl command reproducing a failed request to the
+// message of its error.
+type curlError struct {
+	err  error
+	curl string
+}
+
+func (e *curlError) Error() string {
+	return e.err.Error() + "\nreproduce with: " + e.curl
+}
+
+func (e *curlError) Cause() error {
+	return e.err
+}
+
+func (e *curlError) Unwrap() error {
+	return e.err
+}
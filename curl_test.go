@@ -0,0 +1,60 @@
+package crest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsCurl(t *testing.T) {
+	c := NewClient("http://example.com/api").WithHeader("X-Trace", "it's me")
+	curl := c.Request(http.MethodPost, "/items").
+		WithQueryParam("draft", "1").
+		WithBody(map[string]string{"name": "a"}).
+		AsCurl()
+	require.Equal(t, `curl -X POST 'http://example.com/api/items?draft=1' --compressed -H 'X-Trace: it'\''s me' --data-binary '{"name":"a"}'`, curl)
+
+	curl = c.Clone().UseBearerToken("secret").Request(http.MethodGet, "/items").AsCurl()
+	require.Equal(t, `curl 'http://example.com/api/items' --compressed -H 'Authorization: Bearer secret' -H 'X-Trace: it'\''s me'`, curl)
+
+	require.Equal(t, `curl --head 'http://example.com/api/' --compressed`, NewClient("http://example.com/api").Request(http.MethodHead, "/").WithHeader("Accept-Encoding", "gzip").AsCurl())
+	require.NoError(t, c.Error())
+
+	hooked := NewClient("http://example.com/api").WithRequestHook(func(req *http.Request) {
+		req.Header.Set("X-Hooked", "yes")
+		req.Body = ioutil.NopCloser(strings.NewReader("replaced"))
+		req.GetBody = nil
+	})
+	curl = hooked.Request(http.MethodPut, "/items/1").WithBody("original").AsCurl()
+	require.Equal(t, `curl -X PUT 'http://example.com/api/items/1' --compressed -H 'X-Hooked: yes' --data-binary 'replaced'`, curl)
+	require.NoError(t, hooked.Error())
+}
+
+func TestShellQuote(t *testing.T) {
+	require.Equal(t, `'plain text'`, shellQuote("plain text"))
+	require.Equal(t, `'héllo'`, shellQuote("héllo"))
+	require.Equal(t, `'a'\''b'`, shellQuote("a'b"))
+	require.Equal(t, `$'line\none\\\'s\x00\xff'`, shellQuote("line\none\\'s\x00\xff"))
+}
+
+func TestFailureIncludesCurl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).UseBearerToken("secret")
+	c.PostString("/teapot", "tea").ExpectStatus(http.StatusOK)
+	require.Error(t, c.Error())
+	msg := c.Error().Error()
+	require.Contains(t, msg, "expected status code 200 but got 418\nreproduce with: curl -X POST '"+srv.URL+"/teapot'")
+	require.Contains(t, msg, "-H 'Authorization: REDACTED'")
+	require.NotContains(t, msg, "secret")
+	require.Contains(t, msg, "--data-binary 'tea'")
+	require.Equal(t, "expected status code 200 but got 418", errors.Cause(c.Error()).Error())
+}
@@ -0,0 +1,254 @@
+package crest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// dialSettings control how the client opens connections. The zero value
+// dials the way the http.Client it was created with does.
+type dialSettings struct {
+	resolver    *net.Resolver
+	network     string
+	captureWire bool
+	// proxy, if set, replaces the transport's proxy.
+	proxy *proxySettings
+	// tls, if set, replaces the transport's TLS configuration.
+	tls *tls.Config
+}
+
+// customDial reports whether connections are dialed differently than the
+// transport would.
+func (s dialSettings) customDial() bool {
+	return s.resolver != nil || s.network != "" || s.captureWire
+}
+
+func (s dialSettings) dialContext() func(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Resolver:  s.resolver,
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if s.network != "" {
+			network = s.network
+		}
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err != nil || !s.captureWire {
+			return conn, err
+		}
+		return &wireConn{Conn: conn}, nil
+	}
+}
+
+// WithResolver makes the client look up host names with r instead of the
+// system resolver. A nil r restores the system resolver.
+func (c *client) WithResolver(r *net.Resolver) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	dial := c.dial
+	dial.resolver = r
+	if err := c.useDialSettings(dial); err != nil {
+		c.errSetter(errors.Wrap(err, "setting resolver"))
+	}
+	return c
+}
+
+// WithNetwork makes the client connect over network, which is "tcp4" or
+// "tcp6" to only use one address family, or "tcp" to use either.
+func (c *client) WithNetwork(network string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	switch network {
+	case "tcp":
+		network = ""
+	case "tcp4", "tcp6":
+	default:
+		c.errSetter(errors.Errorf("unsupported network %q", network))
+		return c
+	}
+	dial := c.dial
+	dial.network = network
+	if err := c.useDialSettings(dial); err != nil {
+		c.errSetter(errors.Wrap(err, "setting network"))
+	}
+	return c
+}
+
+// WithWireCapture makes the client keep the exact bytes written to and read
+// from the connection for each request, available from the response's Wire.
+func (c *client) WithWireCapture() Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	dial := c.dial
+	dial.captureWire = true
+	if err := c.useDialSettings(dial); err != nil {
+		c.errSetter(errors.Wrap(err, "capturing wire"))
+	}
+	return c
+}
+
+// useDialSettings gives the client a transport of its own that dials
+// according to dial. Only *http.Transport can be configured this way.
+func (c *client) useDialSettings(dial dialSettings) error {
+	if c.dialTransport == nil {
+		var base *http.Transport
+		switch t := c.httpClient.Transport.(type) {
+		case nil:
+			base = http.DefaultTransport.(*http.Transport)
+		case *http.Transport:
+			base = t
+		default:
+			return errors.Errorf("cannot configure dialing of a %T", t)
+		}
+		c.dialTransport = base
+	}
+
+	transport := c.dialTransport.Clone()
+	if dial.customDial() {
+		transport.DialContext = dial.dialContext()
+	}
+	if dial.proxy != nil {
+		transport.Proxy = dial.proxy.proxyFunc()
+	}
+	if dial.tls != nil {
+		transport.TLSClientConfig = dial.tls
+	}
+	httpClient := *c.httpClient
+	httpClient.Transport = transport
+	c.httpClient = &httpClient
+	c.dial = dial
+	return nil
+}
+
+// NewDoHResolver returns a resolver that sends its queries as DNS over HTTPS
+// (RFC 8484) POST requests to endpoint, e.g.
+// "https://cloudflare-dns.com/dns-query".
+func NewDoHResolver(endpoint string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return &dohConn{
+				ctx:      ctx,
+				endpoint: endpoint,
+			}, nil
+		},
+	}
+}
+
+// dohConn is a net.Conn for the resolver that sends every DNS message written
+// to it as an HTTP request, and reads back the answer. The resolver frames
+// messages with a two byte length, as it would over TCP.
+type dohConn struct {
+	ctx      context.Context
+	endpoint string
+	deadline time.Time
+
+	written bytes.Buffer
+	answer  bytes.Buffer
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	c.written.Write(b)
+	for c.written.Len() >= 2 {
+		size := int(binary.BigEndian.Uint16(c.written.Bytes()))
+		if c.written.Len() < 2+size {
+			break
+		}
+		c.written.Next(2)
+		if err := c.exchange(c.written.Next(size)); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (c *dohConn) exchange(query []byte) error {
+	ctx := c.ctx
+	if !c.deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(query))
+	if err != nil {
+		return errors.Wrap(err, "creating DNS over HTTPS request")
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "doing DNS over HTTPS request")
+	}
+	defer resp.Body.Close()
+	answer, err := ioutil.ReadAll(io.LimitReader(resp.Body, 0xffff+1))
+	if err != nil {
+		return errors.Wrap(err, "reading DNS over HTTPS response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("DNS over HTTPS request failed with status %v", resp.StatusCode)
+	}
+	if len(answer) > 0xffff {
+		return errors.New("DNS over HTTPS response is too long")
+	}
+	var size [2]byte
+	binary.BigEndian.PutUint16(size[:], uint16(len(answer)))
+	c.answer.Write(size[:])
+	c.answer.Write(answer)
+	return nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	if c.answer.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.answer.Read(b)
+}
+
+func (c *dohConn) Close() error {
+	return nil
+}
+
+func (c *dohConn) LocalAddr() net.Addr {
+	return dohAddr{}
+}
+
+func (c *dohConn) RemoteAddr() net.Addr {
+	return dohAddr{}
+}
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *dohConn) SetWriteDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+type dohAddr struct{}
+
+func (dohAddr) Network() string {
+	return "https"
+}
+
+func (dohAddr) String() string {
+	return "dns-over-https"
+}
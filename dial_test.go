@@ -0,0 +1,103 @@
+package crest
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dohServer answers A queries for every name in hosts, and nothing else.
+func dohServer(t *testing.T, hosts map[string]net.IP) (*httptest.Server, func() []string) {
+	var lock sync.Mutex
+	var asked []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/dns-message", r.Header.Get("Content-Type"))
+		query, _ := ioutil.ReadAll(r.Body)
+
+		// Skip the header, then the labels of the question's name.
+		var labels []string
+		i := 12
+		for query[i] != 0 {
+			labels = append(labels, string(query[i+1:i+1+int(query[i])]))
+			i += 1 + int(query[i])
+		}
+		name := strings.Join(labels, ".")
+		qtype := binary.BigEndian.Uint16(query[i+1:])
+		question := query[12 : i+5]
+
+		lock.Lock()
+		asked = append(asked, name)
+		lock.Unlock()
+
+		answer := append([]byte{}, query[:2]...)
+		answer = append(answer, 0x81, 0x80, 0, 1, 0, 0, 0, 0, 0, 0)
+		answer = append(answer, question...)
+		if ip := hosts[name].To4(); ip != nil && qtype == 1 {
+			answer[7] = 1
+			answer = append(answer, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+			answer = append(answer, ip...)
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(answer)
+	}))
+	return srv, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, asked...)
+	}
+}
+
+func TestWithResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer srv.Close()
+	port := srv.Listener.Addr().(*net.TCPAddr).Port
+
+	doh, asked := dohServer(t, map[string]net.IP{
+		"api.crest.test": net.ParseIP("127.0.0.1"),
+	})
+	defer doh.Close()
+
+	u := &url.URL{Scheme: "http", Host: net.JoinHostPort("api.crest.test", strconv.Itoa(port))}
+	c := NewClient(u.String()).WithResolver(NewDoHResolver(doh.URL))
+	c.Get("/").ExpectStatus(http.StatusOK).ExpectBodyEquals(u.Host)
+	require.NoError(t, c.Error())
+	require.Contains(t, asked(), "api.crest.test")
+
+	c = NewClient("http://unknown.crest.test").WithResolver(NewDoHResolver(doh.URL))
+	c.Get("/")
+	require.Error(t, c.Error())
+
+	c = NewHandlerClient(http.NotFoundHandler()).WithResolver(NewDoHResolver(doh.URL))
+	require.EqualError(t, c.Error(), "setting resolver: cannot configure dialing of a crest.handlerTransport")
+}
+
+func TestWithNetwork(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	port := strconv.Itoa(srv.Listener.Addr().(*net.TCPAddr).Port)
+
+	c := NewClient("http://localhost:" + port)
+	require.Equal(t, srv.Listener.Addr().String(), c.Clone().WithNetwork("tcp4").Get("/").RemoteAddr())
+	require.Equal(t, srv.Listener.Addr().String(), c.Clone().WithNetwork("tcp").Get("/").RemoteAddr())
+	require.NoError(t, c.Error())
+
+	c.Clone().WithNetwork("tcp6").Get("/")
+	require.Error(t, c.Error())
+
+	c = NewClient(srv.URL).WithNetwork("udp")
+	require.EqualError(t, c.Error(), `unsupported network "udp"`)
+
+	c = NewHandlerClient(http.NotFoundHandler())
+	require.Equal(t, "", c.Get("/").RemoteAddr())
+}
@@ -0,0 +1,195 @@
+package crest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type diffConfig struct {
+	ignoreStatus bool
+	headers      []string
+	ignoreFields []string
+}
+
+type DiffOption func(*diffConfig)
+
+// DiffHeaders adds headers whose values must match in both responses.
+func DiffHeaders(keys ...string) DiffOption {
+	return func(cfg *diffConfig) {
+		cfg.headers = append(cfg.headers, keys...)
+	}
+}
+
+// DiffIgnoreFields drops fields from JSON bodies before they are compared.
+// Fields are dot-separated paths from the document root, e.g. "meta.requestId".
+func DiffIgnoreFields(fields ...string) DiffOption {
+	return func(cfg *diffConfig) {
+		cfg.ignoreFields = append(cfg.ignoreFields, fields...)
+	}
+}
+
+func DiffIgnoreStatus() DiffOption {
+	return func(cfg *diffConfig) {
+		cfg.ignoreStatus = true
+	}
+}
+
+// Diff compares two responses, typically the same request answered by two
+// environments. It returns an error listing every difference, or nil.
+func Diff(rw1, rw2 ResponseWrapper, opts ...DiffOption) error {
+	cfg := &diffConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	resp1, resp2 := rw1.Response(), rw2.Response()
+	if resp1 == nil || resp2 == nil {
+		return fmt.Errorf("cannot diff responses: at least one request did not complete")
+	}
+
+	var diffs []string
+	if !cfg.ignoreStatus && resp1.StatusCode != resp2.StatusCode {
+		diffs = append(diffs, fmt.Sprintf("status: %d != %d", resp1.StatusCode, resp2.StatusCode))
+	}
+	for _, key := range cfg.headers {
+		key = http.CanonicalHeaderKey(key)
+		vals1, vals2 := resp1.Header[key], resp2.Header[key]
+		if !reflect.DeepEqual(vals1, vals2) {
+			diffs = append(diffs, fmt.Sprintf("header %q: %q != %q", key, vals1, vals2))
+		}
+	}
+	diffs = append(diffs, diffBodies(rw1.Body(), rw2.Body(), cfg.ignoreFields)...)
+
+	if len(diffs) > 0 {
+		return fmt.Errorf("responses differ:\n  %v", strings.Join(diffs, "\n  "))
+	}
+	return nil
+}
+
+func diffBodies(body1, body2 string, ignoreFields []string) []string {
+	var v1, v2 interface{}
+	if json.Unmarshal([]byte(body1), &v1) != nil || json.Unmarshal([]byte(body2), &v2) != nil {
+		if body1 != body2 {
+			return []string{fmt.Sprintf("body: %q != %q", body1, body2)}
+		}
+		return nil
+	}
+	for _, field := range ignoreFields {
+		removeJSONField(v1, strings.Split(field, "."))
+		removeJSONField(v2, strings.Split(field, "."))
+	}
+	return jsonDiff("$", v1, v2)
+}
+
+func removeJSONField(v interface{}, path []string) {
+	obj, ok := v.(map[string]interface{})
+	if !ok || len(path) == 0 {
+		return
+	}
+	if len(path) == 1 {
+		delete(obj, path[0])
+		return
+	}
+	removeJSONField(obj[path[0]], path[1:])
+}
+
+// jsonDiff describes the differences between two decoded JSON values, one
+// line per differing path.
+func jsonDiff(path string, a, b interface{}) []string {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]struct{})
+		for key := range av {
+			keys[key] = struct{}{}
+		}
+		for key := range bv {
+			keys[key] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		var diffs []string
+		for _, key := range sorted {
+			childPath := path + "." + key
+			aChild, aOK := av[key]
+			bChild, bOK := bv[key]
+			switch {
+			case !aOK:
+				diffs = append(diffs, fmt.Sprintf("%v: missing != %v", childPath, jsonString(bChild)))
+			case !bOK:
+				diffs = append(diffs, fmt.Sprintf("%v: %v != missing", childPath, jsonString(aChild)))
+			default:
+				diffs = append(diffs, jsonDiff(childPath, aChild, bChild)...)
+			}
+		}
+		return diffs
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		if len(av) != len(bv) {
+			return []string{fmt.Sprintf("%v: array of length %d != array of length %d", path, len(av), len(bv))}
+		}
+		var diffs []string
+		for i := range av {
+			diffs = append(diffs, jsonDiff(fmt.Sprintf("%v[%d]", path, i), av[i], bv[i])...)
+		}
+		return diffs
+	case json.Number:
+		// Numbers decoded with UseNumber compare by value, so that 1 equals
+		// 1.0 and large integers keep every digit.
+		if bv, ok := b.(json.Number); ok && numbersEqual(av, bv) {
+			return nil
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		return []string{fmt.Sprintf("%v: %v != %v", path, jsonString(a), jsonString(b))}
+	}
+	return nil
+}
+
+func numbersEqual(a, b json.Number) bool {
+	x, ok := new(big.Rat).SetString(a.String())
+	if !ok {
+		return false
+	}
+	y, ok := new(big.Rat).SetString(b.String())
+	return ok && x.Cmp(y) == 0
+}
+
+// decodeJSON is json.Unmarshal with numbers decoded as json.Number.
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
+func jsonString(v interface{}) string {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(bs)
+}
@@ -0,0 +1,44 @@
+package crest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func wrapperWithBody(status int, body string, headers ...string) ResponseWrapper {
+	resp := respWithBody(body)
+	resp.StatusCode = status
+	for i := 0; i+1 < len(headers); i += 2 {
+		resp.Header.Add(headers[i], headers[i+1])
+	}
+	return newResponseWrapper(resp, neverErr, (&errContainer{}).Set)
+}
+
+func TestDiff(t *testing.T) {
+	blue := wrapperWithBody(200, `{"id": 1, "tags": ["a"], "meta": {"host": "blue"}}`, "Version", "1")
+	green := wrapperWithBody(200, `{"tags":["a"],"id":1,"meta":{"host":"green"}}`, "Version", "2")
+
+	require.NoError(t, Diff(blue, green, DiffIgnoreFields("meta.host")))
+
+	err := Diff(blue, green)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `$.meta.host: "blue" != "green"`)
+
+	err = Diff(blue, green, DiffHeaders("version"), DiffIgnoreFields("meta"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `header "Version"`)
+
+	failed := wrapperWithBody(500, `{"id": 1, "tags": ["a", "b"]}`)
+	err = Diff(blue, failed, DiffIgnoreFields("meta"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "status: 200 != 500")
+	require.Contains(t, err.Error(), "$.tags: array of length 1 != array of length 2")
+	err = Diff(blue, failed, DiffIgnoreStatus(), DiffIgnoreFields("meta"))
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "status")
+
+	require.NoError(t, Diff(wrapperWithBody(200, "text"), wrapperWithBody(200, "text")))
+	require.Error(t, Diff(wrapperWithBody(200, "text"), wrapperWithBody(200, "other")))
+	require.Error(t, Diff(blue, nopResponseWrapper{}))
+}
@@ -0,0 +1,80 @@
+package crest
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrDryRun is the cause of the error of operations that need a response,
+// such as reading from a RangeReader, on a client in dry-run mode.
+var ErrDryRun = errors.New("dry run, not sent")
+
+// WithDryRun makes the client build requests, run its request hooks and
+// checks on them, and then not send them. Dry-run requests are logged and
+// recorded in HAR files, marked as such, but get no response: their
+// wrappers report DryRun, make no assertions, and export the request they
+// stand for with ExportJSON.
+func (c *client) WithDryRun(enabled bool) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.dryRun = enabled
+	return c
+}
+
+// dryRunRequest is the request a dry-run nop wrapper stands for.
+type dryRunRequest struct {
+	req      *http.Request
+	body     string
+	tags     []string
+	redacted []string
+}
+
+func newDryRunRequest(req *http.Request, tags, redacted []string) (*dryRunRequest, error) {
+	d := &dryRunRequest{req: req, tags: tags, redacted: redacted}
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		bs, err := ioutil.ReadAll(rc)
+		if err != nil {
+			return nil, err
+		}
+		d.body = string(bs)
+	}
+	return d, nil
+}
+
+func (d *dryRunRequest) exportJSON(w io.Writer) error {
+	e := exchange{DryRun: true, Tags: d.tags}
+	e.setRequest(d.req, d.body, d.redacted)
+	return e.write(w)
+}
+
+// dryRunResponse logs and records req as not sent and returns the wrapper
+// of the dry-run request, or returns nil if the client sends requests.
+func (c *client) dryRunResponse(req *http.Request) ResponseWrapper {
+	if !c.dryRun {
+		return nil
+	}
+	dryRun, err := newDryRunRequest(req, c.tags, c.credentialHeaders)
+	if err != nil {
+		c.errSetter(errors.Wrap(err, "reading dry-run request body"))
+		return c.nop()
+	}
+	a := &attempt{start: time.Now(), req: req, sent: &bodyRecorder{}, timings: &timingsRecorder{}, dryRun: true}
+	a.sent.write([]byte(dryRun.body))
+	if c.har != nil {
+		c.har.add(a, "", nil, c.credentialHeaders, c.tags)
+	}
+	if c.logger != nil {
+		c.logger.log(a, "", nil, c.credentialHeaders)
+	}
+	return nopResponseWrapper{dryRun: dryRun, errGetter: c.errGetter}
+}
@@ -0,0 +1,133 @@
+package crest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRun(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithDryRun(true).WithHeader("X-Env", "test").Tag("setup")
+	rw := c.Post("/users", map[string]string{"name": "a"}).
+		ExpectStatus(http.StatusTeapot).
+		ExpectBodyEquals("never checked")
+	require.NoError(t, c.Error())
+	require.True(t, rw.DryRun())
+	require.Nil(t, rw.Response())
+	require.Equal(t, `{"name":"a"}`, rw.SentBody())
+	require.Equal(t, srv.URL+"/users", rw.SentURL().String())
+	require.Equal(t, []string{"setup"}, rw.Tags())
+	require.Equal(t, int32(0), atomic.LoadInt32(&hits))
+
+	var buf bytes.Buffer
+	require.NoError(t, rw.ExportJSON(&buf))
+	var exported struct {
+		DryRun  bool `json:"dryRun"`
+		Request struct {
+			Method string      `json:"method"`
+			Header http.Header `json:"header"`
+			Body   string      `json:"body"`
+		} `json:"request"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &exported))
+	require.True(t, exported.DryRun)
+	require.Equal(t, http.MethodPost, exported.Request.Method)
+	require.Equal(t, "test", exported.Request.Header.Get("X-Env"))
+	require.Equal(t, `{"name":"a"}`, exported.Request.Body)
+
+	// Checks on the request itself still apply.
+	c.Clone().WithAllowedHosts("example.com").Get("/")
+	require.True(t, errors.Is(c.Error(), ErrHostNotAllowed))
+
+	c = NewClient(srv.URL).WithDryRun(true).WithDryRun(false)
+	rw = c.Get("/").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+	require.False(t, rw.DryRun())
+	require.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestDryRunRecorded(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+
+	var logged []string
+	harPath := filepath.Join(t.TempDir(), "run.har")
+	rec := NewTrafficRecorder()
+	c := NewClient(srv.URL).WithDryRun(true).
+		WithHARRecorder(harPath).
+		WithLogger(LoggerFunc(func(format string, v ...interface{}) {
+			logged = append(logged, fmt.Sprintf(format, v...))
+		}), LogBodies).
+		WithTrafficRecorder(rec)
+	c.Post("/users", map[string]string{"name": "a"})
+	require.NoError(t, c.Error())
+	require.NoError(t, c.Close())
+	require.Equal(t, int32(0), atomic.LoadInt32(&hits))
+
+	require.Equal(t, []string{"POST /users"}, rec.Calls())
+	require.Len(t, logged, 1)
+	require.Contains(t, logged[0], "POST "+srv.URL+"/users (dry run, not sent)")
+	require.Contains(t, logged[0], `{"name":"a"}`)
+
+	var har harFile
+	bs, err := ioutil.ReadFile(harPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(bs, &har))
+	require.Len(t, har.Log.Entries, 1)
+	entry := har.Log.Entries[0]
+	require.True(t, entry.DryRun)
+	require.Empty(t, entry.Error)
+	require.Equal(t, 0, entry.Response.Status)
+	require.Equal(t, `{"name":"a"}`, entry.Request.PostData.Text)
+
+	// A strict recorder refuses undeclared dry-run requests too.
+	c = NewClient(srv.URL).WithDryRun(true).WithTrafficRecorder(NewTrafficRecorder().Strict())
+	c.Get("/users")
+	require.True(t, errors.Is(c.Error(), ErrUnexpectedRequest))
+}
+
+func TestDryRunOperations(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithDryRun(true)
+	require.Equal(t, Capabilities{Features: map[string]bool{}}, c.Capabilities())
+	require.NoError(t, c.Error())
+
+	rw := c.FollowOperation("/operations/1", LROOptions{})
+	require.NoError(t, c.Error())
+	require.True(t, rw.DryRun())
+
+	rw = c.MultipartUpload("/files/a", bytes.NewReader([]byte("data")), 4).Do()
+	require.NoError(t, c.Error())
+	require.True(t, rw.DryRun())
+
+	_, err := c.RangeReader("/files/a").ReadAt(make([]byte, 4), 0)
+	require.True(t, errors.Is(err, ErrDryRun))
+
+	_, err = (&http.Client{Transport: Transport(c)}).Get(srv.URL + "/items")
+	require.True(t, errors.Is(err, ErrDryRun))
+	require.NoError(t, c.Error())
+	require.Equal(t, int32(0), atomic.LoadInt32(&hits))
+}
@@ -0,0 +1,116 @@
+package crest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// errorState holds the error shared by a client and its clones. Normally the
+// first failure wins and everything after it becomes a no-op. When
+// collecting, failures accumulate and the chain keeps going, until
+// maxFailures (if set) is reached.
+type errorState struct {
+	lock        sync.RWMutex
+	errs        []error
+	collect     bool
+	maxFailures int
+	// report, if set, is told about every failure recorded, and whether the
+	// chain stops at it.
+	report func(err error, fatal bool)
+}
+
+// gate returns the error that should stop further requests and assertions.
+func (s *errorState) gate() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if len(s.errs) == 0 {
+		return nil
+	}
+	if s.collect && (s.maxFailures <= 0 || len(s.errs) < s.maxFailures) {
+		return nil
+	}
+	return s.combined()
+}
+
+func (s *errorState) set(err error) {
+	s.lock.Lock()
+	recorded := true
+	switch {
+	case err == nil:
+		s.errs = nil
+		recorded = false
+	case !s.collect:
+		s.errs = []error{err}
+	case s.maxFailures <= 0 || len(s.errs) < s.maxFailures:
+		s.errs = append(s.errs, err)
+	default:
+		recorded = false
+	}
+	report, fatal := s.report, !s.collect
+	s.lock.Unlock()
+
+	// Reporting may not return, so it must happen without the lock held.
+	if recorded && report != nil {
+		report(err, fatal)
+	}
+}
+
+func (s *errorState) get() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.combined()
+}
+
+func (s *errorState) all() []error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]error(nil), s.errs...)
+}
+
+func (s *errorState) combined() error {
+	if len(s.errs) == 0 {
+		return nil
+	}
+	if !s.collect {
+		return s.errs[0]
+	}
+	return append(Errors(nil), s.errs...)
+}
+
+// Errors is the error of a client collecting errors: every failure, in the
+// order they happened. errors.Is and errors.As look at each of them.
+type Errors []error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := make([]string, len(e))
+	for i, err := range e {
+		// Keep multi-line errors, e.g. with a curl command, indented.
+		lines[i] = strings.ReplaceAll(err.Error(), "\n", "\n    ")
+	}
+	return fmt.Sprintf("%d failures:\n  %v", len(e), strings.Join(lines, "\n  "))
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+func (s *errorState) configure(f func(s *errorState)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	f(s)
+}
+
+func (s *errorState) settings() (collect bool, maxFailures int) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.collect, s.maxFailures
+}
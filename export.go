@@ -0,0 +1,117 @@
+package crest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// exchange is the record ExportJSON writes.
+type exchange struct {
+	Request struct {
+		Method string      `json:"method"`
+		URL    string      `json:"url"`
+		Proto  string      `json:"proto,omitempty"`
+		Header http.Header `json:"header"`
+		Body   string      `json:"body"`
+	} `json:"request"`
+	DryRun   bool `json:"dryRun,omitempty"`
+	Response struct {
+		Status     string      `json:"status"`
+		StatusCode int         `json:"statusCode"`
+		Proto      string      `json:"proto,omitempty"`
+		Header     http.Header `json:"header"`
+		Body       string      `json:"body"`
+		ServedBy   string      `json:"servedBy,omitempty"`
+	} `json:"response"`
+	Tags        []string       `json:"tags,omitempty"`
+	Attempts    int            `json:"attempts"`
+	RetryErrors []string       `json:"retryErrors,omitempty"`
+	Timings     exchangeTiming `json:"timings"`
+	Error       string         `json:"error,omitempty"`
+}
+
+type exchangeTiming struct {
+	Total  string         `json:"total"`
+	DNS    string         `json:"dns,omitempty"`
+	Dials  []exchangeDial `json:"dials,omitempty"`
+	Chosen string         `json:"chosen,omitempty"`
+	Reused bool           `json:"reused"`
+}
+
+type exchangeDial struct {
+	Network  string `json:"network"`
+	Address  string `json:"address"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ExportJSON writes the request, the response, the timings and the client's
+// error, if any, as a single JSON document, e.g. to attach to a failed CI
+// run.
+func (r *responseWrapper) ExportJSON(w io.Writer) error {
+	var e exchange
+	if r.req != nil {
+		e.setRequest(r.req, string(r.sentBody.Bytes()), r.settings.credentialHeaders)
+	}
+	e.Response.Status = r.resp.Status
+	e.Response.StatusCode = r.resp.StatusCode
+	e.Response.Proto = r.resp.Proto
+	e.Response.Header = r.resp.Header
+	e.Response.Body = r.body
+	e.Response.ServedBy = r.ServedBy()
+	e.Tags = r.tags
+	e.Attempts = r.attempts
+	for _, err := range r.retryErrors {
+		e.RetryErrors = append(e.RetryErrors, err.Error())
+	}
+	e.Timings = exchangeTiming{
+		Total:  r.timings.Total.String(),
+		Chosen: r.timings.Chosen,
+		Reused: r.timings.Reused,
+	}
+	if r.timings.DNS > 0 {
+		e.Timings.DNS = r.timings.DNS.String()
+	}
+	for _, dial := range r.timings.Dials {
+		d := exchangeDial{
+			Network:  dial.Network,
+			Address:  dial.Address,
+			Duration: dial.Duration.String(),
+		}
+		if dial.Err != nil {
+			d.Error = dial.Err.Error()
+		}
+		e.Timings.Dials = append(e.Timings.Dials, d)
+	}
+	if err := r.Error(); err != nil {
+		e.Error = err.Error()
+	}
+
+	return e.write(w)
+}
+
+// setRequest records req with the values of the redacted headers replaced.
+func (e *exchange) setRequest(req *http.Request, body string, redacted []string) {
+	e.Request.Method = req.Method
+	e.Request.URL = req.URL.String()
+	e.Request.Proto = req.Proto
+	e.Request.Header = make(http.Header, len(req.Header))
+	for key, vals := range req.Header {
+		if contains(redacted, http.CanonicalHeaderKey(key)) {
+			redactedVals := make([]string, len(vals))
+			for i := range redactedVals {
+				redactedVals[i] = "REDACTED"
+			}
+			vals = redactedVals
+		}
+		e.Request.Header[key] = vals
+	}
+	e.Request.Body = body
+}
+
+func (e *exchange) write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e)
+}
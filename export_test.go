@@ -0,0 +1,46 @@
+package crest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithHeader("X-Test", "a").WithHeader("Authorization", "Bearer secret")
+	rw := c.PostString("/items", "payload").ExpectStatus(http.StatusOK)
+
+	var buf bytes.Buffer
+	require.NoError(t, rw.ExportJSON(&buf))
+	var exported map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &exported))
+
+	request := exported["request"].(map[string]interface{})
+	require.Equal(t, "POST", request["method"])
+	require.Equal(t, srv.URL+"/items", request["url"])
+	require.Equal(t, "payload", request["body"])
+	require.Equal(t, []interface{}{"a"}, request["header"].(map[string]interface{})["X-Test"])
+	require.Equal(t, []interface{}{"REDACTED"}, request["header"].(map[string]interface{})["Authorization"])
+	require.NotContains(t, buf.String(), "secret")
+
+	response := exported["response"].(map[string]interface{})
+	require.Equal(t, "201 Created", response["status"])
+	require.Equal(t, 201.0, response["statusCode"])
+	require.Equal(t, `{"id":1}`, response["body"])
+	require.Equal(t, []interface{}{"1"}, response["header"].(map[string]interface{})["X-Served"])
+
+	require.Equal(t, 1.0, exported["attempts"])
+	require.NotEmpty(t, exported["timings"].(map[string]interface{})["total"])
+	require.Contains(t, exported["error"], "expected status code 200 but got 201")
+}
@@ -0,0 +1,147 @@
+package crest
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FailureInfo describes a failed expectation and the request it was made
+// about.
+type FailureInfo struct {
+	// Assertion is the name of the failing ResponseWrapper method, e.g.
+	// "ExpectStatus". It is empty if the response could not be read.
+	Assertion string
+	// CallSite is the file:line the failing assertion was called from.
+	CallSite string
+	Err      error
+
+	Method        string
+	URL           string
+	RequestHeader http.Header
+	RequestBody   []byte
+
+	// Curl is a curl command sending the request again, with the values of
+	// credential headers redacted.
+	Curl string
+
+	StatusCode   int
+	ResponseBody string
+
+	// Attempts is how many times the request was sent, and RetryErrors why
+	// each attempt before the last one failed.
+	Attempts    int
+	RetryErrors []error
+}
+
+// DefaultError returns the error crest reports when no formatter is set. For
+// a failed expectation, it ends with the curl command in Curl.
+func (f FailureInfo) DefaultError() error {
+	var err error
+	if len(f.RetryErrors) > 0 {
+		err = errors.Wrapf(f.Err, "doing a %v request to URL %q on attempt %d, after %v", f.Method, f.URL, f.Attempts, joinErrors(f.RetryErrors))
+	} else {
+		err = errors.Wrapf(f.Err, "doing a %v request to URL %q", f.Method, f.URL)
+	}
+	if f.CallSite != "" {
+		err = errors.Wrapf(err, "%v at %v", f.Assertion, f.CallSite)
+	}
+	if f.Assertion != "" && f.Curl != "" {
+		err = &curlError{err: err, curl: f.Curl}
+	}
+	return err
+}
+
+// Rebuild sends the request the failure was about again through c, with the
+// recorded method, URL, headers and body, e.g. to look into a failure while
+// debugging or to retry a quarantined test. Headers c sets itself replace
+// the recorded ones, and so do the recorded credentials if c has auth of
+// its own.
+func (f FailureInfo) Rebuild(c Client) ResponseWrapper {
+	req, err := http.NewRequest(f.Method, f.URL, bytes.NewReader(f.RequestBody))
+	if err != nil {
+		cl, ok := c.(*client)
+		if !ok {
+			return &nopResponseWrapper{errGetter: func() error { return err }}
+		}
+		if cl.errGetter() == nil {
+			cl.errSetter(errors.Wrap(err, "rebuilding request"))
+		}
+		return cl.nop()
+	}
+	req.Header = f.RequestHeader.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	drop := []string{"Accept-Encoding", "Content-Length", "Host"}
+	if cl, ok := c.(*client); ok {
+		for key := range cl.allHeaders() {
+			drop = append(drop, key)
+		}
+		if cl.auth != nil || cl.useBasicAuth {
+			drop = append(drop, cl.credentialHeaders...)
+		}
+	}
+	for _, key := range drop {
+		req.Header.Del(key)
+	}
+	return c.Do(req)
+}
+
+func newFailureInfo(err error, req *http.Request, sent *bodyRecorder, rw *responseWrapper, redacted []string) FailureInfo {
+	assertion, callSite := failingAssertion()
+	info := FailureInfo{
+		Assertion:     assertion,
+		CallSite:      callSite,
+		Err:           err,
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		RequestHeader: req.Header.Clone(),
+		RequestBody:   sent.Bytes(),
+		Curl:          curlCommand(req.Method, req.URL.String(), req.Header, sent.Bytes(), redacted),
+	}
+	if rw != nil {
+		if rw.resp != nil {
+			info.StatusCode = rw.resp.StatusCode
+		}
+		info.ResponseBody = rw.body
+		info.Attempts = rw.attempts
+		info.RetryErrors = rw.retryErrors
+	}
+	return info
+}
+
+// failingAssertion finds the outermost ResponseWrapper method on the stack,
+// and the first place outside crest that led to it being called.
+func failingAssertion() (assertion, callSite string) {
+	const marker = "(*responseWrapper)."
+
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if i := strings.Index(frame.Function, marker); i >= 0 {
+			assertion = strings.TrimSuffix(frame.Function[i+len(marker):], "-fm")
+			callSite = ""
+		} else if assertion != "" && callSite == "" && !isCrestFrame(frame) {
+			callSite = fmt.Sprintf("%v:%d", filepath.Base(frame.File), frame.Line)
+		}
+		if !more {
+			break
+		}
+	}
+	return assertion, callSite
+}
+
+func isCrestFrame(frame runtime.Frame) bool {
+	return strings.HasPrefix(frame.Function, crestPackage+".") && !strings.HasSuffix(frame.File, "_test.go")
+}
+
+var crestPackage = reflect.TypeOf(responseWrapper{}).PkgPath()
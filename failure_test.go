@@ -0,0 +1,113 @@
+package crest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorFormatter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+	defer srv.Close()
+
+	var info FailureInfo
+	c := NewClient(srv.URL).
+		WithHeader("X-Test", "1").
+		WithErrorFormatter(func(f FailureInfo) error {
+			info = f
+			return fmt.Errorf("see runbook: %v", f.Err)
+		})
+	c.PostString("/teapot", "tea").
+		ExpectBodyContains("stout").
+		ExpectStatus(http.StatusOK)
+	require.Error(t, c.Error())
+	require.Equal(t, "see runbook: expected status code 200 but got 418", c.Error().Error())
+	require.Equal(t, "ExpectStatus", info.Assertion)
+	require.Equal(t, http.MethodPost, info.Method)
+	require.Equal(t, srv.URL+"/teapot", info.URL)
+	require.Equal(t, "1", info.RequestHeader.Get("X-Test"))
+	require.Equal(t, "tea", string(info.RequestBody))
+	require.Equal(t, http.StatusTeapot, info.StatusCode)
+	require.Equal(t, "short and stout", info.ResponseBody)
+
+	c = NewClient(srv.URL)
+	c.Get("/teapot").ExpectStatus(http.StatusOK)
+	expected := fmt.Sprintf("doing a GET request to URL %q: expected status code 200 but got 418", srv.URL+"/teapot")
+	require.Contains(t, c.Error().Error(), expected)
+}
+
+func TestFailureCallSite(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+
+	var info FailureInfo
+	c := NewClient(srv.URL).WithErrorFormatter(func(f FailureInfo) error {
+		info = f
+		return f.DefaultError()
+	})
+	_, _, line, _ := runtime.Caller(0)
+	c.Get("/").
+		ExpectStatus(http.StatusOK).
+		ExpectBodyContains("body").
+		ExpectBodyPasses(func(string) bool { return false })
+	require.Error(t, c.Error())
+	require.Equal(t, "ExpectBodyPasses", info.Assertion)
+	require.Equal(t, fmt.Sprintf("failure_test.go:%d", line+4), info.CallSite)
+	require.True(t, strings.HasPrefix(c.Error().Error(), "ExpectBodyPasses at "+info.CallSite+": doing a GET"), c.Error().Error())
+}
+
+func TestFailureInfoRebuild(t *testing.T) {
+	var received []*http.Request
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, string(bs))
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer srv.Close()
+
+	var info FailureInfo
+	c := NewClient(srv.URL).
+		WithHeader("X-Trace", "first").
+		WithQueryParam("v", "1").
+		UseBearerToken("old").
+		WithErrorFormatter(func(f FailureInfo) error {
+			info = f
+			return f.DefaultError()
+		})
+	c.Request(http.MethodPut, "/items/1").
+		WithHeader("If-Match", `"abc"`).
+		WithBody(map[string]int{"n": 1}).
+		Do().
+		ExpectStatus(http.StatusOK)
+	require.Error(t, c.Error())
+
+	c2 := NewClient("http://unused.example").WithHeader("X-Trace", "second").UseBearerToken("new")
+	rw := info.Rebuild(c2)
+	require.NoError(t, c2.Error())
+	require.Equal(t, http.StatusConflict, rw.Response().StatusCode)
+	require.Len(t, received, 2)
+	again := received[1]
+	require.Equal(t, http.MethodPut, again.Method)
+	require.Equal(t, "/items/1?v=1", again.RequestURI)
+	require.Equal(t, `"abc"`, again.Header.Get("If-Match"))
+	require.Equal(t, []string{"second"}, again.Header["X-Trace"])
+	require.Equal(t, []string{"Bearer new"}, again.Header["Authorization"])
+	require.Equal(t, bodies[0], bodies[1])
+
+	info.Rebuild(NewClient("http://unused.example"))
+	require.Equal(t, []string{"Bearer old"}, received[2].Header["Authorization"])
+	require.Equal(t, []string{"first"}, received[2].Header["X-Trace"])
+}
@@ -0,0 +1,143 @@
+package crest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ReplayBaseURL is the base URL of clients created by NewReplayClient.
+const ReplayBaseURL = "http://replay.crest"
+
+// ErrNoRecordedResponse is the cause of the error of requests a replay
+// client has no recorded response for.
+var ErrNoRecordedResponse = errors.New("no recorded response")
+
+// NewReplayClient returns a client whose requests are answered from the
+// responses recorded in the HAR files (*.har) and ExportJSON records
+// (*.json) in fixtureDir, without using the network, so tests can run
+// offline and deterministically. A request gets the response recorded for
+// the same method, path and query, and body, ignoring the recorded host;
+// JSON bodies match if they are equal as JSON. Requests recorded more than
+// once get their responses in the order recorded, the last one repeating.
+// Requests that got no response when recorded fail with the recorded error.
+func NewReplayClient(fixtureDir string) Client {
+	t := &fixtureTransport{}
+	c := NewCustomClient(ReplayBaseURL, &http.Client{Transport: t}).(*client)
+	if err := t.load(fixtureDir); err != nil {
+		c.errSetter(errors.Wrapf(err, "loading fixtures from %v", fixtureDir))
+	}
+	return c
+}
+
+type fixture struct {
+	cassetteEntry
+	served bool
+}
+
+type fixtureTransport struct {
+	lock     sync.Mutex
+	fixtures []*fixture
+}
+
+func (t *fixtureTransport) load(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file.Name()))
+		if file.IsDir() || ext != ".har" && ext != ".json" {
+			continue
+		}
+		entries, err := readCassette(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return errors.Wrapf(err, "reading %v", file.Name())
+		}
+		for _, entry := range entries {
+			t.fixtures = append(t.fixtures, &fixture{cassetteEntry: entry})
+		}
+	}
+	if len(t.fixtures) == 0 {
+		return errors.New("no recorded requests found")
+	}
+	return nil
+}
+
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	t.lock.Lock()
+	var match *fixture
+	for _, f := range t.fixtures {
+		if f.matches(req, body) {
+			match = f
+			if !f.served {
+				break
+			}
+		}
+	}
+	if match != nil {
+		match.served = true
+	}
+	t.lock.Unlock()
+
+	if match == nil {
+		return nil, errors.Wrapf(ErrNoRecordedResponse, "for %v %v", req.Method, req.URL.RequestURI())
+	}
+	if match.err != "" {
+		return nil, errors.New(match.err)
+	}
+	header := match.resp.Header.Clone()
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        match.resp.Status,
+		StatusCode:    match.resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(match.respBody)),
+		ContentLength: int64(len(match.respBody)),
+		Request:       req,
+	}, nil
+}
+
+func (f *fixture) matches(req *http.Request, body []byte) bool {
+	recorded := f.req.URL
+	if req.Method != f.req.Method || req.URL.Path != recorded.Path || !sameQuery(req.URL.RawQuery, recorded.RawQuery) {
+		return false
+	}
+	if bytes.Equal(body, f.body) {
+		return true
+	}
+	var got, want interface{}
+	return json.Unmarshal(body, &got) == nil && json.Unmarshal(f.body, &want) == nil && reflect.DeepEqual(got, want)
+}
+
+// sameQuery compares two query strings regardless of the order of their
+// parameters.
+func sameQuery(a, b string) bool {
+	if a == b {
+		return true
+	}
+	qa, errA := url.ParseQuery(a)
+	qb, errB := url.ParseQuery(b)
+	return errA == nil && errB == nil && reflect.DeepEqual(qa, qb)
+}
@@ -0,0 +1,94 @@
+package crest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewReplayClient(t *testing.T) {
+	count := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/counter":
+			count++
+			w.Write([]byte(`{"count":` + strconv.Itoa(count) + `}`))
+		case "/api/echo":
+			w.Write([]byte(`{"got":` + string(body) + `,"q":"` + r.URL.RawQuery + `"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	c := NewClient(srv.URL).WithHARRecorder(filepath.Join(dir, "run.har"))
+	c.Post("/api/counter", nil)
+	c.Post("/api/counter", nil)
+	c.Post("/api/echo?a=1&b=2", map[string]int{"x": 1, "y": 2})
+	c.Get("/missing").ExpectStatus(http.StatusNotFound)
+	require.NoError(t, c.Error())
+	require.NoError(t, c.Close())
+
+	var record bytes.Buffer
+	require.NoError(t, NewClient(srv.URL).Get("/api/echo?exported=1").ExportJSON(&record))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "record.json"), record.Bytes(), 0o644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o644))
+	srv.Close()
+
+	r := NewReplayClient(dir)
+	r.Post("/api/counter", nil).ExpectBodyEquals(`{"count":1}`)
+	r.Post("/api/counter", nil).ExpectBodyEquals(`{"count":2}`)
+	r.Post("/api/counter", nil).ExpectBodyEquals(`{"count":2}`)
+	r.PostString("/api/echo?b=2&a=1", `{"y": 2, "x": 1}`).
+		ExpectStatus(http.StatusOK).
+		ExpectHeaderEquals("Content-Type", "application/json").
+		ExpectBodyEquals(`{"got":{"x":1,"y":2},"q":"a=1&b=2"}`)
+	r.Get("/missing").ExpectStatus(http.StatusNotFound)
+	r.Get("/api/echo?exported=1").ExpectBodyEquals(`{"got":,"q":"exported=1"}`)
+	require.NoError(t, r.Error())
+
+	r.Post("/api/echo?a=1&b=2", map[string]int{"x": 2})
+	require.Error(t, r.Error())
+	require.True(t, errors.Is(r.Error(), ErrNoRecordedResponse))
+
+	r = NewReplayClient(filepath.Join(dir, "missing"))
+	require.Error(t, r.Error())
+	require.Contains(t, r.Error().Error(), "loading fixtures")
+
+	r = NewReplayClient(t.TempDir())
+	require.Error(t, r.Error())
+	require.Contains(t, r.Error().Error(), "no recorded requests found")
+}
+
+func TestNewReplayClientRecordedError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("up"))
+	}))
+	dir := t.TempDir()
+	c := NewClient(srv.URL).WithHARRecorder(filepath.Join(dir, "run.har"))
+	c.Get("/status").ExpectBodyEquals("up")
+	require.NoError(t, c.Error())
+	srv.Close()
+	c.Get("/down")
+	require.Error(t, c.Error())
+	require.NoError(t, c.Close())
+
+	r := NewReplayClient(dir)
+	r.Get("/status").ExpectStatus(http.StatusOK).ExpectBodyEquals("up")
+	require.NoError(t, r.Error())
+	rw := r.Get("/down")
+	require.Error(t, r.Error())
+	require.False(t, errors.Is(r.Error(), ErrNoRecordedResponse))
+	require.Contains(t, r.Error().Error(), "connect")
+	require.Nil(t, rw.Response())
+}
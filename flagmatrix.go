@@ -0,0 +1,78 @@
+package crest
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// WithFlagMatrix runs chain, a sequence of requests and assertions, once for
+// every combination of the values of flags, which maps header names to the
+// values to try. Each run uses a client like c that sends the headers of its
+// combination; an empty value leaves the header out. Every run is made even
+// if another failed, and each failure becomes one of c's errors, wrapped with
+// the combination it happened under. A flag without values is an error, as
+// there would be nothing to run.
+func (c *client) WithFlagMatrix(flags map[string][]string, chain func(c Client)) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	for name, values := range flags {
+		if len(values) == 0 {
+			c.errSetter(errors.Errorf("no values to try for flag %v", name))
+			return c
+		}
+	}
+	for _, combination := range flagCombinations(flags) {
+		cl := c.detached()
+		if cl.headers == nil {
+			cl.headers = make(http.Header)
+		}
+		cl.headers = cl.headers.Clone()
+		var desc []string
+		for _, flag := range combination {
+			desc = append(desc, flag.name+"="+flag.value)
+			if flag.value == "" {
+				cl.headers.Del(flag.name)
+				continue
+			}
+			cl.headers.Set(flag.name, flag.value)
+		}
+		chain(cl)
+
+		for _, err := range cl.errState.all() {
+			c.errSetter(errors.Wrapf(err, "with flags %v", strings.Join(desc, ", ")))
+		}
+	}
+	return c
+}
+
+// flagValue is the value of a flag header in a combination.
+type flagValue struct {
+	name, value string
+}
+
+// flagCombinations returns every combination of the values of flags, with
+// the flags in order of name.
+func flagCombinations(flags map[string][]string) [][]flagValue {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	combinations := [][]flagValue{nil}
+	for _, name := range names {
+		var next [][]flagValue
+		for _, combination := range combinations {
+			for _, value := range flags[name] {
+				flag := flagValue{name: name, value: value}
+				next = append(next, append(append([]flagValue(nil), combination...), flag))
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
@@ -0,0 +1,46 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithFlagMatrix(t *testing.T) {
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("X-Beta")+"/"+r.Header.Get("X-Flag-Cache")+"/"+r.Header.Get("X-Team"))
+		if r.Header.Get("X-Beta") == "on" && r.Header.Get("X-Flag-Cache") == "off" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	runs := 0
+	c := NewClient(srv.URL).WithHeader("X-Team", "core").WithHeader("X-Beta", "default")
+	c.WithFlagMatrix(map[string][]string{
+		"X-Flag-Cache": {"on", "off"},
+		"X-Beta":       {"", "on"},
+	}, func(c Client) {
+		runs++
+		c.Get("/").ExpectStatus(http.StatusOK)
+	})
+	require.Equal(t, 4, runs)
+	require.Equal(t, []string{"/on/core", "/off/core", "on/on/core", "on/off/core"}, seen)
+	errs := c.Errors()
+	require.Len(t, errs, 1)
+	require.True(t, strings.HasPrefix(errs[0].Error(), "with flags X-Beta=on, X-Flag-Cache=off: "))
+
+	runs = 0
+	c = NewClient(srv.URL).WithFlagMatrix(nil, func(c Client) { runs++ })
+	require.Equal(t, 1, runs)
+	require.NoError(t, c.Error())
+
+	runs = 0
+	c = NewClient(srv.URL).WithFlagMatrix(map[string][]string{"X-Beta": {}}, func(c Client) { runs++ })
+	require.Equal(t, 0, runs)
+	require.EqualError(t, c.Error(), "no values to try for flag X-Beta")
+}
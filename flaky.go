@@ -0,0 +1,134 @@
+package crest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// flakyRetry is how Flaky re-runs chains, set with WithFlakyRetry. Clones
+// share it, and with it the failures retried so far.
+type flakyRetry struct {
+	retries  int
+	classify func(error) bool
+
+	lock sync.Mutex
+	errs []error
+}
+
+func (f *flakyRetry) addErrors(errs []error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.errs = append(f.errs, errs...)
+}
+
+func (f *flakyRetry) errors() []error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return append([]error(nil), f.errs...)
+}
+
+// WithFlakyRetry makes Flaky run a chain up to n more times while it fails
+// in a way classify says is flaky. A nil classify treats timeouts and
+// responses with status 502, 503 or 504 as flaky.
+func (c *client) WithFlakyRetry(n int, classify func(error) bool) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if n <= 0 {
+		c.flaky = nil
+		return c
+	}
+	c.flaky = &flakyRetry{retries: n, classify: classify}
+	return c
+}
+
+// Flaky runs chain, a sequence of requests and assertions, with a client
+// like c. If it fails in a way WithFlakyRetry classifies as flaky, the whole
+// chain is run again, up to the configured number of times, and only the
+// failures of the last run become c's error. The failures retried away are
+// kept, see FlakyErrors. Without WithFlakyRetry, chain is run once with c.
+func (c *client) Flaky(chain func(c Client)) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if c.flaky == nil {
+		chain(c)
+		return c
+	}
+
+	for n := 1; ; n++ {
+		cl := c.detached()
+		var lock sync.Mutex
+		var statuses []int
+		format := c.errorFormatter
+		cl.errorFormatter = func(info FailureInfo) error {
+			lock.Lock()
+			statuses = append(statuses, info.StatusCode)
+			lock.Unlock()
+			if format != nil {
+				return format(info)
+			}
+			return info.DefaultError()
+		}
+		chain(cl)
+
+		errs := cl.errState.all()
+		if len(errs) == 0 {
+			return c
+		}
+		flaky := isFlakyFailure(errs, statuses)
+		if c.flaky.classify != nil {
+			flaky = c.flaky.classify(cl.Error())
+		}
+		if n > 1 || flaky {
+			for i, err := range errs {
+				errs[i] = errors.Wrapf(err, "attempt %d of a flaky chain", n)
+			}
+		}
+		if !flaky || n > c.flaky.retries {
+			for _, err := range errs {
+				c.errSetter(err)
+			}
+			return c
+		}
+		c.flaky.addErrors(errs)
+	}
+}
+
+// FlakyErrors returns the failures of the runs of chains that Flaky retried.
+func (c *client) FlakyErrors() []error {
+	if c.flaky == nil {
+		return nil
+	}
+	return c.flaky.errors()
+}
+
+// isFlakyFailure tells whether a run of a chain failed only because of
+// timeouts or gateway errors. statuses are the status codes of the
+// responses whose assertions failed; each of them must be a gateway error,
+// and every other failure a timeout.
+func isFlakyFailure(errs []error, statuses []int) bool {
+	for _, status := range statuses {
+		switch status {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			return false
+		}
+	}
+	others := 0
+	for _, err := range errs {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+			continue
+		}
+		others++
+	}
+	// The failed assertions account for the failures that are not timeouts.
+	return len(errs) > 0 && others <= len(statuses)
+}
@@ -0,0 +1,109 @@
+package crest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlaky(t *testing.T) {
+	var lock sync.Mutex
+	hits := make(map[string]int)
+	hitsOf := func(path string) int {
+		lock.Lock()
+		defer lock.Unlock()
+		return hits[path]
+	}
+	reset := func(paths ...string) {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, path := range paths {
+			hits[path] = 0
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path]++
+		n := hits[r.URL.Path]
+		lock.Unlock()
+		switch {
+		case r.URL.Path == "/gateway" && n < 3:
+			w.WriteHeader(http.StatusBadGateway)
+		case r.URL.Path == "/slow" && n < 2:
+			time.Sleep(50 * time.Millisecond)
+		case r.URL.Path == "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithFlakyRetry(2, nil)
+	c.Flaky(func(c Client) {
+		c.Get("/setup").ExpectStatus(http.StatusOK)
+		c.Get("/gateway").ExpectStatus(http.StatusOK).ExpectBodyEquals("ok")
+	})
+	require.NoError(t, c.Error())
+	require.Equal(t, 3, hitsOf("/setup"))
+	flaky := c.FlakyErrors()
+	require.Len(t, flaky, 2)
+	require.Contains(t, flaky[0].Error(), "attempt 1 of a flaky chain: ExpectStatus at")
+	require.Contains(t, flaky[1].Error(), "attempt 2 of a flaky chain")
+	require.Contains(t, flaky[1].Error(), "expected status code 200 but got 502")
+
+	c.Clone().WithTimeout(20 * time.Millisecond).Flaky(func(c Client) {
+		c.Get("/slow").ExpectBodyEquals("ok")
+	})
+	require.NoError(t, c.Error())
+	require.Equal(t, 2, hitsOf("/slow"))
+	require.Len(t, c.FlakyErrors(), 3)
+
+	c.Flaky(func(c Client) {
+		c.Get("/broken").ExpectStatus(http.StatusOK)
+	})
+	require.Error(t, c.Error())
+	require.True(t, strings.HasPrefix(c.Error().Error(), "ExpectStatus at"), c.Error().Error())
+	require.Equal(t, 1, hitsOf("/broken"))
+
+	reset("/gateway")
+	c = NewClient(srv.URL).WithFlakyRetry(1, func(err error) bool {
+		return strings.Contains(err.Error(), "502")
+	})
+	c.Flaky(func(c Client) {
+		c.Get("/gateway").ExpectStatus(http.StatusOK)
+	})
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "attempt 2 of a flaky chain: ExpectStatus at")
+	require.Len(t, c.FlakyErrors(), 1)
+
+	c = NewClient(srv.URL)
+	c.Flaky(func(c Client) {
+		c.Get("/broken").ExpectStatus(http.StatusOK)
+	})
+	require.Error(t, c.Error())
+	require.Nil(t, c.FlakyErrors())
+
+	// A chain is only flaky if every one of its failures is.
+	reset("/gateway", "/broken")
+	c = NewClient(srv.URL).WithErrorCollection().WithFlakyRetry(2, nil)
+	c.Flaky(func(c Client) {
+		c.Get("/gateway").ExpectStatus(http.StatusOK)
+		c.Get("/broken").ExpectStatus(http.StatusOK)
+	})
+	require.Len(t, c.Errors(), 2)
+	require.Equal(t, 1, hitsOf("/broken"))
+	require.Nil(t, c.FlakyErrors())
+
+	timeout := context.DeadlineExceeded
+	require.True(t, isFlakyFailure([]error{timeout}, nil))
+	require.True(t, isFlakyFailure([]error{errors.New("ExpectStatus"), timeout}, []int{http.StatusBadGateway}))
+	require.False(t, isFlakyFailure([]error{errors.New("ExpectStatus"), errors.New("connection refused")}, []int{http.StatusBadGateway}))
+	require.False(t, isFlakyFailure([]error{errors.New("ExpectStatus"), timeout}, []int{http.StatusNotFound}))
+}
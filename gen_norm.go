@@ -0,0 +1,217 @@
+//go:build ignore
+
+// gen_norm generates norm_tables.go from the Unicode character database.
+//
+//	go run gen_norm.go [-ucd https://www.unicode.org/Public/14.0.0/ucd]
+//
+// The -ucd flag accepts either a base URL or a local directory holding
+// UnicodeData.txt, CompositionExclusions.txt and CaseFolding.txt.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ucd    = flag.String("ucd", "https://www.unicode.org/Public/14.0.0/ucd", "base URL or directory of the Unicode character database")
+	output = flag.String("output", "norm_tables.go", "file to write")
+)
+
+var versionPattern = regexp.MustCompile(`\d+\.\d+\.\d+`)
+
+func main() {
+	flag.Parse()
+
+	classes := map[rune]uint8{}
+	decompositions := map[rune][]rune{}
+	parse("UnicodeData.txt", func(fields []string) {
+		r := parseRune(fields[0])
+		class, err := strconv.ParseUint(fields[3], 10, 8)
+		if err != nil {
+			log.Fatalf("UnicodeData.txt: combining class of %04X: %v", r, err)
+		}
+		if class != 0 {
+			classes[r] = uint8(class)
+		}
+		if fields[5] == "" || strings.HasPrefix(fields[5], "<") {
+			return
+		}
+		for _, field := range strings.Fields(fields[5]) {
+			decompositions[r] = append(decompositions[r], parseRune(field))
+		}
+	})
+
+	exclusions := map[rune]bool{}
+	parse("CompositionExclusions.txt", func(fields []string) {
+		exclusions[parseRune(fields[0])] = true
+	})
+
+	folds := map[rune]string{}
+	parse("CaseFolding.txt", func(fields []string) {
+		if status := fields[1]; status != "C" && status != "F" {
+			return
+		}
+		var folded []rune
+		for _, field := range strings.Fields(fields[2]) {
+			folded = append(folded, parseRune(field))
+		}
+		folds[parseRune(fields[0])] = string(folded)
+	})
+
+	var decomposed, classed, folded []rune
+	for r := range decompositions {
+		decomposed = append(decomposed, r)
+	}
+	for r := range classes {
+		classed = append(classed, r)
+	}
+	for r := range folds {
+		folded = append(folded, r)
+	}
+	sortRunes(decomposed)
+	sortRunes(classed)
+	sortRunes(folded)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated from the Unicode %s character database. DO NOT EDIT.\n\n", versionPattern.FindString(*ucd))
+	buf.WriteString("package crest\n\n")
+
+	buf.WriteString("// canonicalDecompositions maps a rune to its single-level canonical\n")
+	buf.WriteString("// decomposition. Hangul syllables are decomposed algorithmically instead.\n")
+	buf.WriteString("var canonicalDecompositions = map[rune][]rune{\n")
+	for _, r := range decomposed {
+		fmt.Fprintf(&buf, "\t0x%04X: {%s},\n", r, joinRunes(decompositions[r]))
+	}
+	buf.WriteString("}\n\n")
+
+	// Singletons, non-starter decompositions and the listed exclusions never
+	// recompose.
+	buf.WriteString("// canonicalCompositions maps pairs to primary composites, leaving out\n")
+	buf.WriteString("// composition exclusions.\n")
+	buf.WriteString("var canonicalCompositions = map[[2]rune]rune{\n")
+	type composition struct {
+		pair [2]rune
+		r    rune
+	}
+	var compositions []composition
+	for _, r := range decomposed {
+		d := decompositions[r]
+		if len(d) != 2 || exclusions[r] || classes[r] != 0 || classes[d[0]] != 0 {
+			continue
+		}
+		compositions = append(compositions, composition{[2]rune{d[0], d[1]}, r})
+	}
+	sort.Slice(compositions, func(i, j int) bool {
+		a, b := compositions[i].pair, compositions[j].pair
+		return a[0] < b[0] || a[0] == b[0] && a[1] < b[1]
+	})
+	for _, c := range compositions {
+		fmt.Fprintf(&buf, "\t{%s}: 0x%04X,\n", joinRunes(c.pair[:]), c.r)
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// combiningClasses lists the runes with a non-zero canonical combining\n")
+	buf.WriteString("// class, as sorted ranges.\n")
+	buf.WriteString("var combiningClasses = []struct {\n\tlo, hi rune\n\tclass  uint8\n}{\n")
+	for i := 0; i < len(classed); {
+		j := i
+		for j+1 < len(classed) && classed[j+1] == classed[j]+1 && classes[classed[j+1]] == classes[classed[i]] {
+			j++
+		}
+		fmt.Fprintf(&buf, "\t{0x%04X, 0x%04X, %d},\n", classed[i], classed[j], classes[classed[i]])
+		i = j + 1
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// caseFoldings maps a rune to its full case folding, leaving out the\n")
+	buf.WriteString("// Turkic-specific mappings.\n")
+	buf.WriteString("var caseFoldings = map[rune]string{\n")
+	for _, r := range folded {
+		fmt.Fprintf(&buf, "\t0x%04X: %+q,\n", r, folds[r])
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parse calls fn with the semicolon-separated fields of each data line in
+// the named file, skipping comments and blank lines.
+func parse(name string, fn func(fields []string)) {
+	rc, err := open(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer rc.Close()
+	scanner := bufio.NewScanner(rc)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		fn(fields)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+}
+
+func open(name string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(*ucd, "http://") && !strings.HasPrefix(*ucd, "https://") {
+		return os.Open(filepath.Join(*ucd, name))
+	}
+	resp, err := http.Get(strings.TrimSuffix(*ucd, "/") + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", name, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+func parseRune(s string) rune {
+	r, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		log.Fatalf("bad code point %q: %v", s, err)
+	}
+	return rune(r)
+}
+
+func joinRunes(runes []rune) string {
+	parts := make([]string, len(runes))
+	for i, r := range runes {
+		parts[i] = fmt.Sprintf("0x%04X", r)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func sortRunes(runes []rune) {
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+}
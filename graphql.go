@@ -0,0 +1,96 @@
+package crest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// graphQLRequest is the standard envelope of a GraphQL request sent over
+// HTTP.
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// graphQLResponse is the standard envelope of a GraphQL response.
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []GraphQLError  `json:"errors"`
+}
+
+// GraphQLError is an error in the errors list of a GraphQL response.
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e GraphQLError) String() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	path := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		path[i] = fmt.Sprint(p)
+	}
+	return fmt.Sprintf("%v (at %v)", e.Message, strings.Join(path, "."))
+}
+
+// GraphQL posts query and variables to path in the standard JSON envelope.
+// GraphQL servers answer most errors with a 200, so check the response with
+// ExpectGraphQLNoErrors.
+func (c *client) GraphQL(path, query string, variables map[string]interface{}) ResponseWrapper {
+	return c.doReqJSONTyped(http.MethodPost, path, graphQLRequest{
+		Query:     query,
+		Variables: variables,
+	}, "application/json")
+}
+
+// ExpectGraphQLNoErrors fails if the body is not a GraphQL response or its
+// errors list is not empty.
+func (r *responseWrapper) ExpectGraphQLNoErrors() ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	resp, ok := r.graphQLResponse()
+	if !ok || len(resp.Errors) == 0 {
+		return r
+	}
+	msgs := make([]string, len(resp.Errors))
+	for i, e := range resp.Errors {
+		msgs[i] = e.String()
+	}
+	r.setError(fmt.Errorf("expected no GraphQL errors but got %v", strings.Join(msgs, "; ")))
+	return r
+}
+
+// ParseGraphQLData unmarshals the data of a GraphQL response into v.
+func (r *responseWrapper) ParseGraphQLData(v interface{}) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	resp, ok := r.graphQLResponse()
+	if !ok {
+		return r
+	}
+	if len(resp.Data) == 0 || string(resp.Data) == "null" {
+		r.setError(errors.New("expected GraphQL data but there is none"))
+		return r
+	}
+	if err := json.Unmarshal(resp.Data, v); err != nil {
+		r.setError(fmt.Errorf("unmarshalling GraphQL data: %v", err))
+	}
+	return r
+}
+
+func (r *responseWrapper) graphQLResponse() (graphQLResponse, bool) {
+	var resp graphQLResponse
+	if err := json.Unmarshal([]byte(r.body), &resp); err != nil {
+		r.setError(fmt.Errorf("unmarshalling GraphQL response: %v", err))
+		return resp, false
+	}
+	return resp, true
+}
@@ -0,0 +1,57 @@
+package crest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		switch req.Query {
+		case "query { user(id: $id) { name } }":
+			w.Write([]byte(`{"data":{"user":{"name":"user` + req.Variables["id"].(string) + `"}}}`))
+		case "query { broken }":
+			w.Write([]byte(`{"data":null,"errors":[{"message":"no field broken","path":["broken"]},{"message":"denied"}]}`))
+		default:
+			w.Write([]byte("not json"))
+		}
+	}))
+	defer srv.Close()
+
+	var data struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+	c := NewClient(srv.URL)
+	c.GraphQL("/graphql", "query { user(id: $id) { name } }", map[string]interface{}{"id": "1"}).
+		ExpectStatus(http.StatusOK).
+		ExpectGraphQLNoErrors().
+		ParseGraphQLData(&data)
+	require.NoError(t, c.Error())
+	require.Equal(t, "user1", data.User.Name)
+
+	c.GraphQL("/graphql", "query { broken }", nil).ExpectStatus(http.StatusOK).ExpectGraphQLNoErrors()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected no GraphQL errors but got no field broken (at broken); denied")
+
+	c = NewClient(srv.URL)
+	c.GraphQL("/graphql", "query { broken }", nil).ParseGraphQLData(&data)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected GraphQL data but there is none")
+
+	c = NewClient(srv.URL)
+	c.GraphQL("/graphql", "other", nil).ExpectGraphQLNoErrors()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "unmarshalling GraphQL response")
+}
@@ -0,0 +1,43 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// HandlerBaseURL is the base URL of clients created by NewHandlerClient.
+const HandlerBaseURL = "http://handler.crest"
+
+// NewHandlerClient returns a client whose requests are served by h
+// in-process, without opening any sockets.
+func NewHandlerClient(h http.Handler) Client {
+	return NewCustomClient(HandlerBaseURL, &http.Client{
+		Transport: handlerTransport{handler: h},
+	})
+}
+
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	serverReq := req.Clone(req.Context())
+	serverReq.RequestURI = req.URL.RequestURI()
+	serverReq.RemoteAddr = "192.0.2.1:1234"
+	if serverReq.Body == nil {
+		serverReq.Body = http.NoBody
+	}
+	if serverReq.Host == "" {
+		serverReq.Host = req.URL.Host
+	}
+
+	rr := httptest.NewRecorder()
+	t.handler.ServeHTTP(rr, serverReq)
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	resp := rr.Result()
+	resp.Request = req
+	return resp, nil
+}
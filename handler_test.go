@@ -0,0 +1,30 @@
+package crest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandlerClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Write([]byte(r.URL.RawQuery + " " + string(bs) + " " + r.Header.Get("X-Test")))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo?redirected=1", http.StatusFound)
+	})
+
+	c := NewHandlerClient(mux).WithHeader("X-Test", "header")
+	c.PostString("/echo?a=b", "body").
+		ExpectStatus(http.StatusOK).
+		ExpectHeaderEquals("X-Method", "POST").
+		ExpectBodyEquals("a=b body header")
+	c.Get("/redirect").ExpectBodyEquals("redirected=1  header")
+	c.Get("/missing").ExpectStatus(http.StatusNotFound)
+	require.NoError(t, c.Error())
+}
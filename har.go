@@ -0,0 +1,281 @@
+package crest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// harFile is an HTTP Archive 1.2, as recorded by WithHARRecorder and read by
+// Replay.
+type harFile struct {
+	Log *harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	// Error is why no response was received, in which case the status is 0.
+	Error string `json:"_error,omitempty"`
+	// Tags are the tags of the client that sent the request, see Tag.
+	Tags []string `json:"_tags,omitempty"`
+	// DryRun is set for requests not sent because the client is in dry-run
+	// mode, which have no response.
+	DryRun bool `json:"_dryRun,omitempty"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []harHeader  `json:"cookies"`
+	Headers     []harHeader  `json:"headers"`
+	QueryString []harHeader  `json:"queryString"`
+	PostData    *harPostData `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []harHeader `json:"cookies"`
+	Headers     []harHeader `json:"headers"`
+	Content     harContent  `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func harHeaders(headers []harHeader) http.Header {
+	h := make(http.Header)
+	for _, header := range headers {
+		if strings.HasPrefix(header.Name, ":") {
+			// HTTP/2 pseudo-headers.
+			continue
+		}
+		h.Add(header.Name, header.Value)
+	}
+	return h
+}
+
+// harPairs lists values in order of name, with the values of the redacted
+// names replaced.
+func harPairs(values map[string][]string, redacted []string) []harHeader {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := []harHeader{}
+	for _, key := range keys {
+		for _, val := range values[key] {
+			if contains(redacted, http.CanonicalHeaderKey(key)) {
+				val = "REDACTED"
+			}
+			pairs = append(pairs, harHeader{Name: key, Value: val})
+		}
+	}
+	return pairs
+}
+
+func (h harFile) entries() ([]cassetteEntry, error) {
+	var entries []cassetteEntry
+	for i, e := range h.Log.Entries {
+		var reqBody []byte
+		if e.Request.PostData != nil {
+			reqBody = []byte(e.Request.PostData.Text)
+		}
+		respBody := e.Response.Content.Text
+		if e.Response.Content.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(respBody)
+			if err != nil {
+				return nil, errors.Wrapf(err, "decoding the response body of entry %d", i+1)
+			}
+			respBody = string(decoded)
+		}
+		entry, err := newCassetteEntry(e.Request.Method, e.Request.URL, harHeaders(e.Request.Headers), reqBody,
+			e.Response.Status, harHeaders(e.Response.Headers), respBody)
+		if err != nil {
+			return nil, errors.Wrapf(err, "entry %d", i+1)
+		}
+		entry.err = e.Error
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// harRecorder collects the exchanges of a client and the clients cloned from
+// it, and writes them to path when the client is closed.
+type harRecorder struct {
+	path string
+
+	lock    sync.Mutex
+	entries []harEntry
+}
+
+// WithHARRecorder records every request the client sends, including retried
+// attempts and ones that got no response, and the response it got into an
+// HTTP Archive at path, e.g. to inspect a failed run in a browser's developer
+// tools or share a reproduction. Clones of the client record into the same
+// archive. The archive is written when the client is closed; NewTestClient
+// closes it when the test ends.
+func (c *client) WithHARRecorder(path string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	har := &harRecorder{path: path}
+	if err := har.write(); err != nil {
+		c.errSetter(errors.Wrap(err, "creating HAR file"))
+		return c
+	}
+	c.har = har
+	c.lifecycle.onClose(func() error {
+		return errors.Wrap(har.write(), "writing HAR file")
+	})
+	return c
+}
+
+// add records a's request, with the values of the redacted headers
+// replaced and the tags of the client that sent it, and the response to it,
+// whose decoded body is body, or why there was none.
+func (h *harRecorder) add(a *attempt, body string, err error, redacted []string, tags []string) {
+	req := a.req
+	entry := harEntry{
+		StartedDateTime: a.start,
+		Time:            milliseconds(time.Since(a.start)),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     []harHeader{},
+			Headers:     harPairs(req.Header, redacted),
+			QueryString: harPairs(req.URL.Query(), nil),
+			HeadersSize: -1,
+		},
+		Response: harResponse{
+			Cookies:     []harHeader{},
+			Headers:     []harHeader{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: harTimings{Blocked: -1, DNS: -1, Connect: -1},
+		Tags:    tags,
+		DryRun:  a.dryRun,
+	}
+	for _, cookie := range req.Cookies() {
+		val := cookie.Value
+		if contains(redacted, "Cookie") {
+			val = "REDACTED"
+		}
+		entry.Request.Cookies = append(entry.Request.Cookies, harHeader{Name: cookie.Name, Value: val})
+	}
+	if sent := a.sent.Bytes(); len(sent) > 0 {
+		entry.Request.BodySize = len(sent)
+		entry.Request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: string(sent)}
+	}
+	entry.Timings.Wait = entry.Time
+	if dns := a.timings.get().DNS; dns > 0 {
+		entry.Timings.DNS = milliseconds(dns)
+		entry.Timings.Wait -= entry.Timings.DNS
+	}
+
+	switch {
+	case err != nil:
+		entry.Error = err.Error()
+	case a.resp != nil:
+		resp := a.resp
+		entry.Response.Status = resp.StatusCode
+		entry.Response.StatusText = strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode)))
+		entry.Response.HTTPVersion = resp.Proto
+		entry.Response.Headers = harPairs(resp.Header, nil)
+		entry.Response.RedirectURL = resp.Header.Get("Location")
+		for _, cookie := range resp.Cookies() {
+			entry.Response.Cookies = append(entry.Response.Cookies, harHeader{Name: cookie.Name, Value: cookie.Value})
+		}
+		entry.Response.Content = harContent{
+			Size:     len(body),
+			MimeType: resp.Header.Get("Content-Type"),
+			Text:     body,
+		}
+		if !utf8.ValidString(body) {
+			entry.Response.Content.Text = base64.StdEncoding.EncodeToString([]byte(body))
+			entry.Response.Content.Encoding = "base64"
+		}
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.entries = append(h.entries, entry)
+}
+
+func (h *harRecorder) write() error {
+	h.lock.Lock()
+	entries := append([]harEntry{}, h.entries...)
+	h.lock.Unlock()
+
+	bs, err := json.MarshalIndent(harFile{Log: &harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "crest"},
+		Entries: entries,
+	}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(h.path, bs, 0o644)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
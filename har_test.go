@@ -0,0 +1,90 @@
+package crest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHARRecorder(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("busy"))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"echo":` + string(body) + `}`))
+	}))
+	defer srv.Close()
+
+	harPath := filepath.Join(t.TempDir(), "run.har")
+	c := NewClient(srv.URL).WithHARRecorder(harPath).WithRetry(2, ConstantBackoff(time.Millisecond)).
+		WithHeader("Authorization", "Bearer secret").
+		WithHeader("Cookie", "session=secret")
+	c.Clone().Tag("setup").Post("/items?draft=1", map[string]int{"n": 1}).ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+
+	// The archive is only complete once the client is closed.
+	var har harFile
+	bs, err := ioutil.ReadFile(harPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(bs, &har))
+	require.Empty(t, har.Log.Entries)
+
+	require.NoError(t, c.Close())
+	bs, err = ioutil.ReadFile(harPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(bs, &har))
+	require.Equal(t, "1.2", har.Log.Version)
+	require.Len(t, har.Log.Entries, 2)
+
+	first, second := har.Log.Entries[0], har.Log.Entries[1]
+	require.Equal(t, http.StatusServiceUnavailable, first.Response.Status)
+	require.Equal(t, "Service Unavailable", first.Response.StatusText)
+	require.Equal(t, "busy", first.Response.Content.Text)
+	require.Equal(t, http.MethodPost, second.Request.Method)
+	require.Equal(t, srv.URL+"/items?draft=1", second.Request.URL)
+	require.Equal(t, []harHeader{{Name: "draft", Value: "1"}}, second.Request.QueryString)
+	require.Equal(t, `{"n":1}`, second.Request.PostData.Text)
+	require.Contains(t, second.Request.Headers, harHeader{Name: "Authorization", Value: "REDACTED"})
+	require.Equal(t, []harHeader{{Name: "session", Value: "REDACTED"}}, second.Request.Cookies)
+	require.NotContains(t, string(bs), "secret")
+	require.Equal(t, http.StatusOK, second.Response.Status)
+	require.Equal(t, []string{"setup"}, second.Tags)
+	require.Equal(t, `{"echo":{"n":1}}`, second.Response.Content.Text)
+	require.Equal(t, "application/json", second.Response.Content.MimeType)
+}
+
+func TestHARRecorderTransportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	harPath := filepath.Join(t.TempDir(), "run.har")
+	c := NewClient(url).WithHARRecorder(harPath)
+	c.Get("/")
+	require.Error(t, c.Error())
+	require.NoError(t, c.Close())
+
+	var har harFile
+	bs, err := ioutil.ReadFile(harPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(bs, &har))
+	require.Len(t, har.Log.Entries, 1)
+	require.Equal(t, 0, har.Log.Entries[0].Response.Status)
+	require.Contains(t, har.Log.Entries[0].Error, "connect")
+
+	c = NewClient(url).WithHARRecorder(filepath.Join(t.TempDir(), "missing", "run.har"))
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "creating HAR file")
+}
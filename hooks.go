@@ -0,0 +1,27 @@
+package crest
+
+import "net/http"
+
+// WithRequestHook adds a hook that is called with every request just before
+// it is sent, after the client has set its headers and auth, e.g. to sign it
+// or log it. Hooks are called in the order they were added, once per request
+// rather than once per retry, and not for replayed requests, which are sent
+// exactly as they were the first time.
+func (c *client) WithRequestHook(hook func(*http.Request)) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	// Clones share the backing array, so never append to it in place.
+	c.requestHooks = append(c.requestHooks[:len(c.requestHooks):len(c.requestHooks)], hook)
+	return c
+}
+
+// WithResponseHook adds a hook that is called with every response and its
+// decoded body once it has been read, before any assertions are made on it.
+func (c *client) WithResponseHook(hook func(resp *http.Response, body string)) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.responseHooks = append(c.responseHooks[:len(c.responseHooks):len(c.responseHooks)], hook)
+	return c
+}
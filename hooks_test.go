@@ -0,0 +1,51 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Seen", r.Header.Get("X-Signature"))
+		w.Write([]byte(r.Header.Get("Content-Type")))
+	}))
+	defer srv.Close()
+
+	var signed, seen []string
+	c := NewClient(srv.URL).
+		WithRetry(2, nil).
+		WithRequestHook(func(req *http.Request) {
+			req.Header.Set("X-Signature", req.Method+" "+req.URL.Path)
+			signed = append(signed, req.Header.Get("Content-Type"))
+		}).
+		WithResponseHook(func(resp *http.Response, body string) {
+			seen = append(seen, resp.Header.Get("X-Seen")+"|"+body)
+		})
+	other := c.Clone().WithRequestHook(func(req *http.Request) {
+		req.Header.Set("X-Signature", "other")
+	})
+
+	rw := c.PostStringTyped("/things", "x", "text/plain").ExpectHeaderEquals("X-Seen", "POST /things")
+	require.NoError(t, c.Error())
+	require.Equal(t, []string{"text/plain"}, signed)
+	require.Equal(t, []string{"POST /things|text/plain"}, seen)
+	require.Equal(t, 2, rw.Attempts())
+
+	rw.ReplayRequest().ExpectHeaderEquals("X-Seen", "POST /things")
+	require.Len(t, signed, 1)
+	require.Len(t, seen, 2)
+
+	other.Get("/").ExpectHeaderEquals("X-Seen", "other")
+	require.NoError(t, c.Error())
+	require.Len(t, signed, 2)
+}
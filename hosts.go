@@ -0,0 +1,56 @@
+package crest
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrHostNotAllowed is the cause of the error of a request, or a redirect,
+// to a host outside those set with WithAllowedHosts.
+var ErrHostNotAllowed = errors.New("host not allowed")
+
+// WithAllowedHosts makes the client refuse to send requests, including
+// redirects and requests to absolute URLs, to hosts that match none of
+// patterns, so a misconfigured suite cannot reach production. A pattern is
+// a host name, optionally with a port, in which "*" matches any run of
+// characters, e.g. "localhost", "*.staging.example.com" or "10.0.0.5:8080".
+// "*.example.com" does not match example.com itself. With no patterns every
+// host is allowed.
+func (c *client) WithAllowedHosts(patterns ...string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	allowed := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if _, err := path.Match(pattern, ""); err != nil {
+			c.errSetter(errors.Wrapf(err, "invalid host pattern %q", pattern))
+			return c
+		}
+		allowed[i] = pattern
+	}
+	c.allowedHosts = allowed
+	return c
+}
+
+// checkHost returns an error if u is outside the allowed hosts.
+func (c *client) checkHost(u *url.URL) error {
+	if len(c.allowedHosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	hostWithPort := strings.ToLower(hostPort(u))
+	for _, pattern := range c.allowedHosts {
+		name := host
+		if strings.Contains(pattern, ":") {
+			name = hostWithPort
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrHostNotAllowed, "%v is not one of %v", hostWithPort, c.allowedHosts)
+}
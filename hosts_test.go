@@ -0,0 +1,51 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowedHosts(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, "http://prod.example.com/", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	c := NewClient(srv.URL).WithAllowedHosts("LOCALHOST", "127.0.0.*")
+	c.Get("/").ExpectStatus(http.StatusOK)
+	NewClient(srv.URL).WithAllowedHosts(u.Host).Get("/").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+
+	c.Clone().WithRetry(3, nil).Get("/away")
+	require.Error(t, c.Error())
+	require.True(t, errors.Is(c.Error(), ErrHostNotAllowed))
+	require.Contains(t, c.Error().Error(), "prod.example.com:80 is not one of [localhost 127.0.0.*]")
+	require.Equal(t, int32(3), atomic.LoadInt32(&hits))
+
+	c = NewClient("http://prod.example.com").WithAllowedHosts("*.staging.example.com").WithTimeout(time.Second)
+	c.Get("/")
+	require.True(t, errors.Is(c.Error(), ErrHostNotAllowed))
+	require.Contains(t, c.Error().Error(), `not doing a GET request to URL "http://prod.example.com/"`)
+
+	req, err := http.NewRequest(http.MethodGet, "http://api.prod.example.com/", nil)
+	require.NoError(t, err)
+	c = NewClient(srv.URL).WithAllowedHosts("*.staging.example.com")
+	c.Do(req)
+	require.True(t, errors.Is(c.Error(), ErrHostNotAllowed))
+
+	c = NewClient(srv.URL).WithAllowedHosts("[")
+	require.Error(t, c.Error())
+}
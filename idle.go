@@ -0,0 +1,108 @@
+package crest
+
+import (
+	"context"
+	"io"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrReadIdleTimeout is the cause of the error of requests that received no
+// bytes for longer than the client's read idle timeout.
+var ErrReadIdleTimeout = errors.New("read idle timeout")
+
+// WithReadIdleTimeout makes requests fail once no bytes of the response have
+// arrived for d, however long the response as a whole takes, so streaming
+// endpoints can be tested without a total timeout. Zero turns it off.
+func (c *client) WithReadIdleTimeout(d time.Duration) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.readIdleTimeout = d
+	return c
+}
+
+// idleWatchdog cancels a request when it has not been touched for timeout.
+type idleWatchdog struct {
+	timeout time.Duration
+	cancel  context.CancelFunc
+	timer   *time.Timer
+
+	lock  sync.Mutex
+	fired bool
+}
+
+func newIdleWatchdog(ctx context.Context, timeout time.Duration) (context.Context, *idleWatchdog) {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &idleWatchdog{
+		timeout: timeout,
+		cancel:  cancel,
+	}
+	w.timer = time.AfterFunc(timeout, func() {
+		w.lock.Lock()
+		w.fired = true
+		w.lock.Unlock()
+		cancel()
+	})
+	return ctx, w
+}
+
+// touch restarts the countdown, unless it has already run out.
+func (w *idleWatchdog) touch() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.fired {
+		w.timer.Reset(w.timeout)
+	}
+}
+
+func (w *idleWatchdog) stop() {
+	w.timer.Stop()
+	w.cancel()
+}
+
+// err replaces err with ErrReadIdleTimeout if the watchdog caused it.
+func (w *idleWatchdog) err(err error) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.fired {
+		return errors.Wrapf(ErrReadIdleTimeout, "no bytes received for %v", w.timeout)
+	}
+	return err
+}
+
+// trace starts counting once the request has been written, and touches the
+// watchdog when the response starts to arrive.
+func (w *idleWatchdog) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			w.touch()
+		},
+		GotFirstResponseByte: w.touch,
+	}
+}
+
+func (w *idleWatchdog) wrap(body io.ReadCloser) io.ReadCloser {
+	return &idleReadCloser{ReadCloser: body, watchdog: w}
+}
+
+type idleReadCloser struct {
+	io.ReadCloser
+	watchdog *idleWatchdog
+}
+
+func (r *idleReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.watchdog.touch()
+	}
+	if err != nil && err != io.EOF {
+		err = r.watchdog.err(err)
+	}
+	return n, err
+}
@@ -0,0 +1,52 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadIdleTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/silent" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		for i := 0; i < 8; i++ {
+			if r.URL.Path == "/stall" && i == 2 {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(time.Second):
+				}
+			}
+			w.Write([]byte("data\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithReadIdleTimeout(100 * time.Millisecond)
+	c.Get("/stream").ExpectBodyEquals(strings.Repeat("data\n", 8))
+	require.NoError(t, c.Error())
+
+	c.Get("/stall")
+	require.Error(t, c.Error())
+	require.Equal(t, ErrReadIdleTimeout, errors.Cause(c.Error()))
+
+	c = NewClient(srv.URL).WithReadIdleTimeout(50 * time.Millisecond)
+	start := time.Now()
+	c.Get("/silent")
+	require.True(t, time.Since(start) < time.Second)
+	require.Equal(t, ErrReadIdleTimeout, errors.Cause(c.Error()))
+	require.Contains(t, c.Error().Error(), "no bytes received for 50ms")
+}
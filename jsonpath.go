@@ -0,0 +1,52 @@
+package crest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var jsonPathIndex = regexp.MustCompile(`\[(\d+)\]`)
+
+// splitJSONPath splits a path into the keys and indices it consists of.
+// Paths are dot-separated from the document root, e.g. "data.items.0.name",
+// and may also be written "$.data.items[0].name".
+func splitJSONPath(path string) []string {
+	path = strings.TrimPrefix(path, "$")
+	path = jsonPathIndex.ReplaceAllString(path, ".$1")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// lookupJSONPath returns the value at path in the decoded JSON value v.
+func lookupJSONPath(v interface{}, path string) (interface{}, error) {
+	at := "$"
+	for _, segment := range splitJSONPath(path) {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[segment]
+			if !ok {
+				return nil, fmt.Errorf("%v has no field %q", at, segment)
+			}
+			v = child
+			at += "." + segment
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, fmt.Errorf("%v is an array, not an object with field %q", at, segment)
+			}
+			if i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("%v has no index %d, its length is %d", at, i, len(node))
+			}
+			v = node[i]
+			at += fmt.Sprintf("[%d]", i)
+		default:
+			return nil, fmt.Errorf("%v is %v, not an object or array", at, jsonString(v))
+		}
+	}
+	return v, nil
+}
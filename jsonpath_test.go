@@ -0,0 +1,25 @@
+package crest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitJSONPath(t *testing.T) {
+	testCases := []struct {
+		path     string
+		segments []string
+	}{
+		{"", nil},
+		{"$", nil},
+		{"a", []string{"a"}},
+		{"$.a.b", []string{"a", "b"}},
+		{"a.0.b", []string{"a", "0", "b"}},
+		{"$.a[0].b[12]", []string{"a", "0", "b", "12"}},
+		{"[1]", []string{"1"}},
+	}
+	for _, testCase := range testCases {
+		require.Equal(t, testCase.segments, splitJSONPath(testCase.path), testCase.path)
+	}
+}
@@ -0,0 +1,396 @@
+package crest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// jsonSchema validates values against the schemas in doc, which local $refs
+// are resolved in. It understands JSON Schema draft-07 and the OpenAPI 3
+// dialect of it, except for format, which is not checked. Keywords added by
+// later drafts, such as prefixItems, dependentRequired and the unevaluated
+// ones, are ignored, and as in draft-07 so are the siblings of a $ref.
+type jsonSchema struct {
+	doc map[string]interface{}
+}
+
+// ExpectBodyMatchesJSONSchema parses the body as JSON and fails if it does
+// not match schema, a JSON Schema draft-07 document; format is not checked.
+// The error lists every violation found, each prefixed with the JSON path of
+// the value at fault.
+func (r *responseWrapper) ExpectBodyMatchesJSONSchema(schema string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	r.expectMatchesJSONSchema([]byte(schema))
+	return r
+}
+
+// ExpectBodyMatchesJSONSchemaFromFile is like ExpectBodyMatchesJSONSchema
+// with the schema read from the file at schemaPath.
+func (r *responseWrapper) ExpectBodyMatchesJSONSchemaFromFile(schemaPath string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	bs, err := ioutil.ReadFile(schemaPath)
+	if err != nil {
+		r.setError(errors.Wrap(err, "reading JSON schema"))
+		return r
+	}
+	r.expectMatchesJSONSchema(bs)
+	return r
+}
+
+func (r *responseWrapper) expectMatchesJSONSchema(schema []byte) {
+	var doc interface{}
+	if err := json.Unmarshal(schema, &doc); err != nil {
+		r.setError(errors.Wrap(err, "parsing JSON schema"))
+		return
+	}
+	var body interface{}
+	if err := json.Unmarshal([]byte(r.body), &body); err != nil {
+		r.setError(fmt.Errorf("unmarshalling body: %v", err))
+		return
+	}
+	root, _ := doc.(map[string]interface{})
+	if problems := (jsonSchema{doc: root}).validate(doc, body, "$", 0); len(problems) > 0 {
+		r.setError(fmt.Errorf("expected the body to match the JSON schema but %v", strings.Join(problems, "; ")))
+	}
+}
+
+// resolve follows a local $ref, e.g. "#/components/schemas/User" or
+// "#/definitions/user".
+func (s jsonSchema) resolve(v interface{}) (map[string]interface{}, error) {
+	for i := 0; i < 32; i++ {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("expected an object in the schema but got %v", v)
+		}
+		ref, ok := obj["$ref"].(string)
+		if !ok {
+			return obj, nil
+		}
+		if ref != "#" && !strings.HasPrefix(ref, "#/") {
+			return nil, errors.Errorf("unsupported $ref %q, only local references are supported", ref)
+		}
+		var cur interface{} = s.doc
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			if ref == "#" {
+				break
+			}
+			part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+			m, _ := cur.(map[string]interface{})
+			if cur, ok = m[part]; !ok {
+				return nil, errors.Errorf("unresolvable $ref %q", ref)
+			}
+		}
+		v = cur
+	}
+	return nil, errors.New("too many nested $refs")
+}
+
+// validate checks value against a JSON schema, returning every problem
+// found, each prefixed with the JSON path of the value at fault.
+func (s jsonSchema) validate(schemaValue interface{}, value interface{}, path string, depth int) []string {
+	if depth > 64 {
+		return []string{path + ": schema nests too deeply"}
+	}
+	if allowed, ok := schemaValue.(bool); ok {
+		if !allowed {
+			return []string{path + ": no value is allowed here"}
+		}
+		return nil
+	}
+	schema, err := s.resolve(schemaValue)
+	if err != nil {
+		return []string{path + ": " + err.Error()}
+	}
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	types := schemaStrings(schema["type"])
+	if typ, ok := schema["type"].(string); ok {
+		types = []string{typ}
+	}
+	if nullable, _ := schema["nullable"].(bool); nullable {
+		types = append(types, "null")
+	}
+	if len(types) > 0 && !matchesType(types, value) {
+		actual := jsonType(value)
+		if actual == "number" && contains(types, "integer") {
+			actual = fmt.Sprint(value)
+		} else if actual == "integer" {
+			actual = "number"
+		}
+		fail("expected %v but got %v", strings.Join(types, " or "), actual)
+		return problems
+	}
+
+	for _, sub := range schemaList(schema["allOf"]) {
+		problems = append(problems, s.validate(sub, value, path, depth+1)...)
+	}
+	if subs := schemaList(schema["anyOf"]); len(subs) > 0 {
+		if s.matching(subs, value, path, depth) == 0 {
+			fail("expected a value matching at least one schema of anyOf")
+		}
+	}
+	if subs := schemaList(schema["oneOf"]); len(subs) > 0 {
+		if n := s.matching(subs, value, path, depth); n != 1 {
+			fail("expected a value matching exactly one schema of oneOf but it matches %d", n)
+		}
+	}
+	if not, ok := schema["not"]; ok {
+		if len(s.validate(not, value, path, depth+1)) == 0 {
+			fail("expected a value not matching the schema of not")
+		}
+	}
+	if cond, ok := schema["if"]; ok {
+		branch, ok := schema["else"]
+		if len(s.validate(cond, value, path, depth+1)) == 0 {
+			branch, ok = schema["then"]
+		}
+		if ok {
+			problems = append(problems, s.validate(branch, value, path, depth+1)...)
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || reflect.DeepEqual(e, value)
+		}
+		if !found {
+			fail("expected one of %v but got %v", jsonString(enum), jsonString(value))
+		}
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(c, value) {
+		fail("expected %v but got %v", jsonString(c), jsonString(value))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range schemaStrings(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		if n, ok := schemaNumber(schema["minProperties"]); ok && float64(len(v)) < n {
+			fail("expected at least %v properties but got %d", n, len(v))
+		}
+		if n, ok := schemaNumber(schema["maxProperties"]); ok && float64(len(v)) > n {
+			fail("expected at most %v properties but got %d", n, len(v))
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		patterns, _ := schema["patternProperties"].(map[string]interface{})
+		dependencies, _ := schema["dependencies"].(map[string]interface{})
+		for _, name := range sortedKeys(v) {
+			if names, ok := schema["propertyNames"]; ok {
+				for _, problem := range s.validate(names, name, path, depth+1) {
+					fail("invalid property name %q: %v", name, strings.TrimPrefix(problem, path+": "))
+				}
+			}
+			if dependency, ok := dependencies[name]; ok {
+				if _, ok := dependency.([]interface{}); ok {
+					for _, required := range schemaStrings(dependency) {
+						if _, ok := v[required]; !ok {
+							fail("missing property %q, which %q requires", required, name)
+						}
+					}
+				} else {
+					problems = append(problems, s.validate(dependency, v, path, depth+1)...)
+				}
+			}
+
+			matched := false
+			if sub, ok := properties[name]; ok {
+				matched = true
+				problems = append(problems, s.validate(sub, v[name], path+"."+name, depth+1)...)
+			}
+			for _, pattern := range sortedKeys(patterns) {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					fail("invalid pattern %q in the schema", pattern)
+					continue
+				}
+				if re.MatchString(name) {
+					matched = true
+					problems = append(problems, s.validate(patterns[pattern], v[name], path+"."+name, depth+1)...)
+				}
+			}
+			if matched {
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					fail("unexpected property %q", name)
+				}
+			case map[string]interface{}:
+				problems = append(problems, s.validate(additional, v[name], path+"."+name, depth+1)...)
+			}
+		}
+	case []interface{}:
+		if n, ok := schemaNumber(schema["minItems"]); ok && float64(len(v)) < n {
+			fail("expected at least %v items but got %d", n, len(v))
+		}
+		if n, ok := schemaNumber(schema["maxItems"]); ok && float64(len(v)) > n {
+			fail("expected at most %v items but got %d", n, len(v))
+		}
+		if unique, _ := schema["uniqueItems"].(bool); unique {
+			for i := range v {
+				for j := 0; j < i; j++ {
+					if reflect.DeepEqual(v[i], v[j]) {
+						fail("expected unique items but items %d and %d are equal", j, i)
+					}
+				}
+			}
+		}
+		for i, item := range v {
+			itemPath := fmt.Sprintf("%v[%d]", path, i)
+			switch items := schema["items"].(type) {
+			case nil:
+			case []interface{}:
+				// A tuple: additionalItems applies past its end.
+				if i < len(items) {
+					problems = append(problems, s.validate(items[i], item, itemPath, depth+1)...)
+				} else if additional, ok := schema["additionalItems"]; ok {
+					problems = append(problems, s.validate(additional, item, itemPath, depth+1)...)
+				}
+			default:
+				problems = append(problems, s.validate(items, item, itemPath, depth+1)...)
+			}
+		}
+		if contains, ok := schema["contains"]; ok {
+			found := false
+			for i, item := range v {
+				found = found || len(s.validate(contains, item, fmt.Sprintf("%v[%d]", path, i), depth+1)) == 0
+			}
+			if !found {
+				fail("expected an item matching the schema of contains")
+			}
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if n, ok := schemaNumber(schema["minLength"]); ok && length < n {
+			fail("expected at least %v characters but got %v", n, length)
+		}
+		if n, ok := schemaNumber(schema["maxLength"]); ok && length > n {
+			fail("expected at most %v characters but got %v", n, length)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				fail("invalid pattern %q in the schema", pattern)
+			} else if !re.MatchString(v) {
+				fail("expected a string matching %q but got %q", pattern, v)
+			}
+		}
+	case float64:
+		// Draft-04 and OpenAPI 3.0 make the exclusive bounds booleans that
+		// modify minimum and maximum; later drafts make them numbers.
+		exclusiveMin, _ := schema["exclusiveMinimum"].(bool)
+		if n, ok := schemaNumber(schema["minimum"]); ok && exclusiveMin && v <= n {
+			fail("expected more than %v but got %v", n, v)
+		} else if ok && v < n {
+			fail("expected at least %v but got %v", n, v)
+		}
+		exclusiveMax, _ := schema["exclusiveMaximum"].(bool)
+		if n, ok := schemaNumber(schema["maximum"]); ok && exclusiveMax && v >= n {
+			fail("expected less than %v but got %v", n, v)
+		} else if ok && v > n {
+			fail("expected at most %v but got %v", n, v)
+		}
+		if n, ok := schemaNumber(schema["exclusiveMinimum"]); ok && v <= n {
+			fail("expected more than %v but got %v", n, v)
+		}
+		if n, ok := schemaNumber(schema["exclusiveMaximum"]); ok && v >= n {
+			fail("expected less than %v but got %v", n, v)
+		}
+		if n, ok := schemaNumber(schema["multipleOf"]); ok && n > 0 {
+			if q := v / n; math.Abs(q-math.Round(q)) > 1e-9 {
+				fail("expected a multiple of %v but got %v", n, v)
+			}
+		}
+	}
+	return problems
+}
+
+func (s jsonSchema) matching(subs []interface{}, value interface{}, path string, depth int) int {
+	n := 0
+	for _, sub := range subs {
+		if len(s.validate(sub, value, path, depth+1)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func matchesType(types []string, value interface{}) bool {
+	actual := jsonType(value)
+	for _, typ := range types {
+		if typ == actual || typ == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the type of a decoded JSON value, telling integers apart
+// from other numbers.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+	}
+	return "number"
+}
+
+func schemaList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+func schemaStrings(v interface{}) []string {
+	var strs []string
+	for _, item := range schemaList(v) {
+		if str, ok := item.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+func schemaNumber(v interface{}) (float64, bool) {
+	n, ok := v.(float64)
+	return n, ok
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
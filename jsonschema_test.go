@@ -0,0 +1,110 @@
+package crest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testJSONSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["id", "items"],
+  "additionalProperties": false,
+  "properties": {
+    "id": {"type": "integer", "exclusiveMinimum": 0},
+    "kind": {"const": "order"},
+    "note": {"type": ["string", "null"], "maxLength": 3},
+    "items": {"type": "array", "uniqueItems": true, "contains": {"$ref": "#/definitions/item"}, "items": {"$ref": "#/definitions/item"}},
+    "total": {"type": "number", "multipleOf": 0.5, "not": {"const": 0}}
+  },
+  "definitions": {
+    "item": {
+      "type": "object",
+      "required": ["sku"],
+      "properties": {"sku": {"type": "string", "pattern": "^[A-Z]+$"}},
+      "if": {"required": ["gift"]},
+      "then": {"required": ["message"]}
+    }
+  }
+}`
+
+func TestExpectBodyMatchesJSONSchema(t *testing.T) {
+	bodies := map[string]string{
+		"/ok":  `{"id":1,"kind":"order","note":null,"items":[{"sku":"A"},{"sku":"B","gift":true,"message":"hi"}],"total":1.5}`,
+		"/bad": `{"id":0,"kind":"refund","note":"long","items":[{"sku":"a"},{"sku":"a"},{"sku":"B","gift":true}],"total":0.3,"extra":1}`,
+		"/txt": `not json`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(bodies[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.Get("/ok").ExpectBodyMatchesJSONSchema(testJSONSchema)
+	require.NoError(t, c.Error())
+
+	c.Get("/bad").ExpectBodyMatchesJSONSchema(testJSONSchema)
+	require.Error(t, c.Error())
+	msg := c.Error().Error()
+	require.Contains(t, msg, "expected the body to match the JSON schema but")
+	for _, problem := range []string{
+		`$: unexpected property "extra"`,
+		"$.id: expected more than 0 but got 0",
+		`$.kind: expected "order" but got "refund"`,
+		"$.note: expected at most 3 characters but got 4",
+		"$.items: expected unique items but items 0 and 1 are equal",
+		`$.items[0].sku: expected a string matching "^[A-Z]+$" but got "a"`,
+		`$.items[2]: missing required property "message"`,
+		"$.total: expected a multiple of 0.5 but got 0.3",
+	} {
+		require.Contains(t, msg, problem)
+	}
+
+	c = NewClient(srv.URL)
+	c.Get("/txt").ExpectBodyMatchesJSONSchema(testJSONSchema)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "unmarshalling body")
+
+	c = NewClient(srv.URL)
+	c.Get("/ok").ExpectBodyMatchesJSONSchema(`{"type": `)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "parsing JSON schema")
+
+	c = NewClient(srv.URL)
+	c.Get("/ok").ExpectBodyMatchesJSONSchema(`false`)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "$: no value is allowed here")
+
+	// Draft-04 and OpenAPI 3.0 exclusive bounds.
+	c = NewClient(srv.URL).WithErrorCollection()
+	c.Get("/ok").ExpectBodyMatchesJSONSchema(`{"properties": {"total": {"minimum": 1.5, "exclusiveMinimum": true}}}`)
+	c.Get("/ok").ExpectBodyMatchesJSONSchema(`{"properties": {"total": {"maximum": 1.5, "exclusiveMaximum": true}}}`)
+	require.Len(t, c.Errors(), 2)
+	require.Contains(t, c.Errors()[0].Error(), "$.total: expected more than 1.5 but got 1.5")
+	require.Contains(t, c.Errors()[1].Error(), "$.total: expected less than 1.5 but got 1.5")
+}
+
+func TestExpectBodyMatchesJSONSchemaFromFile(t *testing.T) {
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	require.NoError(t, ioutil.WriteFile(schemaPath, []byte(`{"type": "array", "items": {"type": "integer"}}`), 0o644))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[1, 2, "3"]`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.Get("/").ExpectBodyMatchesJSONSchemaFromFile(schemaPath)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "$[2]: expected integer but got string")
+
+	c = NewClient(srv.URL)
+	c.Get("/").ExpectBodyMatchesJSONSchemaFromFile(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "reading JSON schema")
+}
@@ -0,0 +1,154 @@
+package crest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrClientClosed is the cause of the error of requests made after Close.
+var ErrClientClosed = errors.New("client closed")
+
+// lifecycle is shared by a client and all clients cloned from it, so closing
+// any of them stops requests in flight on all of them.
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock    sync.Mutex
+	closers []func() error
+	closed  bool
+
+	// setupLock is held while the OnFirstUse hooks run, so that requests
+	// made meanwhile wait for them.
+	setupLock sync.Mutex
+	setups    []func() error
+	setUp     bool
+	setupErr  error
+	teardowns []func() error
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (l *lifecycle) isClosed() bool {
+	return l.ctx.Err() != nil
+}
+
+// onClose registers f to be called by Close, e.g. to flush a recorder.
+func (l *lifecycle) onClose(f func() error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.closers = append(l.closers, f)
+}
+
+func (l *lifecycle) close() error {
+	l.lock.Lock()
+	if l.closed {
+		l.lock.Unlock()
+		return nil
+	}
+	l.closed = true
+	closers := l.closers
+	l.closers = nil
+	teardowns := l.teardowns
+	l.teardowns = nil
+	l.lock.Unlock()
+
+	var errs []string
+	// Teardowns may need to make requests, so they run before the client
+	// closes, in the reverse order of registration.
+	for i := len(teardowns) - 1; i >= 0; i-- {
+		if err := teardowns[i](); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	l.cancel()
+	for _, f := range closers {
+		if err := f(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("closing client: %v", errs)
+	}
+	return nil
+}
+
+// firstUse runs the OnFirstUse hooks unless they have run already, returning
+// their error.
+func (l *lifecycle) firstUse() error {
+	l.setupLock.Lock()
+	defer l.setupLock.Unlock()
+
+	if l.setUp {
+		return l.setupErr
+	}
+	l.lock.Lock()
+	setups := l.setups
+	l.lock.Unlock()
+	for _, f := range setups {
+		if err := f(); err != nil {
+			l.setupErr = err
+			break
+		}
+	}
+	l.setUp = true
+	return l.setupErr
+}
+
+// OnFirstUse registers setup to run before the first request of the client
+// or any client sharing its lifecycle, e.g. to create a test tenant. setup
+// is given a copy of the client to make its own requests with. If it fails,
+// so does every request of the client. Hooks registered after the first
+// request never run.
+func (c *client) OnFirstUse(setup func(c Client) error) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	hookClient := c.cloneConfig()
+	hookClient.inHook = true
+	c.lifecycle.lock.Lock()
+	defer c.lifecycle.lock.Unlock()
+	c.lifecycle.setups = append(c.lifecycle.setups, func() error {
+		return runHook(hookClient, setup, "setting up client")
+	})
+	return c
+}
+
+// OnClose registers teardown to run when the client or any client sharing
+// its lifecycle is closed, before requests stop being possible, e.g. to
+// delete a test tenant. teardown is given a copy of the client to make its
+// own requests with. Teardowns run in the reverse order of registration, and
+// their errors are returned by Close.
+func (c *client) OnClose(teardown func(c Client) error) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	hookClient := c.cloneConfig()
+	hookClient.inHook = true
+	c.lifecycle.lock.Lock()
+	defer c.lifecycle.lock.Unlock()
+	c.lifecycle.teardowns = append(c.lifecycle.teardowns, func() error {
+		return runHook(hookClient, teardown, "tearing down client")
+	})
+	return c
+}
+
+// runHook runs a lifecycle hook with a copy of hookClient that has an error
+// of its own.
+func runHook(hookClient *client, hook func(c Client) error, what string) error {
+	cl := hookClient.detached()
+	err := hook(cl)
+	if err == nil {
+		err = cl.Error()
+	}
+	return errors.Wrap(err, what)
+}
@@ -0,0 +1,74 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleHooks(t *testing.T) {
+	var lock sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		lock.Unlock()
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).
+		OnFirstUse(func(c Client) error {
+			c.PostString("/tenants", "t1").ExpectStatus(http.StatusCreated)
+			return nil
+		}).
+		OnClose(func(c Client) error {
+			c.Delete("/tenants/t1").ExpectStatus(http.StatusOK)
+			return nil
+		}).
+		OnClose(func(c Client) error {
+			c.Delete("/tenants/t1/users").ExpectStatus(http.StatusOK)
+			return nil
+		})
+	require.Empty(t, calls)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Clone().Get("/things")
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, c.Error())
+	require.Len(t, calls, 4)
+	require.Equal(t, "POST /tenants", calls[0])
+
+	require.NoError(t, c.Close())
+	require.Equal(t, []string{"DELETE /tenants/t1/users", "DELETE /tenants/t1"}, calls[4:])
+
+	c = NewClient(srv.URL).OnFirstUse(func(c Client) error {
+		c.Get("/setup").ExpectStatus(http.StatusCreated)
+		return nil
+	})
+	c.Get("/things")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "setting up client: ExpectStatus at")
+	c.Clone().Get("/other")
+	require.Error(t, c.Error())
+	require.Len(t, calls, 7)
+
+	c = NewClient(srv.URL).OnClose(func(Client) error {
+		return errors.New("tenant still in use")
+	})
+	err := c.Close()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "tearing down client: tenant still in use")
+}
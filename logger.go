@@ -0,0 +1,108 @@
+package crest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Logger is what WithLogger logs to. *log.Logger is a Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// LoggerFunc makes a function such as the Logf method of *testing.T a
+// Logger.
+type LoggerFunc func(format string, v ...interface{})
+
+func (f LoggerFunc) Printf(format string, v ...interface{}) {
+	f(format, v...)
+}
+
+// LogVerbosity is how much WithLogger logs about each request.
+type LogVerbosity int
+
+const (
+	// LogSummary logs the method, URL, status and duration.
+	LogSummary LogVerbosity = iota
+	// LogHeaders logs the headers too, with credentials redacted.
+	LogHeaders
+	// LogBodies logs the headers and bodies too.
+	LogBodies
+)
+
+// exchangeLogger logs the exchanges of a client, see WithLogger.
+type exchangeLogger struct {
+	logger    Logger
+	verbosity LogVerbosity
+}
+
+// WithLogger logs every request the client sends, including retried
+// attempts and ones that got no response, and the response it got, at the
+// given verbosity. Each exchange is logged with a single call to logger once
+// the response body has been read. A nil logger stops logging.
+func (c *client) WithLogger(logger Logger, verbosity LogVerbosity) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if logger == nil {
+		c.logger = nil
+		return c
+	}
+	c.logger = &exchangeLogger{logger: logger, verbosity: verbosity}
+	return c
+}
+
+// log logs a's request and the response to it, whose decoded body is body,
+// or why there was none.
+func (l *exchangeLogger) log(a *attempt, body string, err error, redacted []string) {
+	req := a.req
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v %v", req.Method, req.URL)
+	if n, ok := AttemptFromContext(req.Context()); ok && n > 1 {
+		fmt.Fprintf(&b, " (attempt %d)", n)
+	}
+	switch {
+	case a.dryRun:
+		b.WriteString(" (dry run, not sent)")
+	case err != nil:
+		fmt.Fprintf(&b, " failed after %v: %v", time.Since(a.start).Round(time.Microsecond), err)
+	default:
+		fmt.Fprintf(&b, " -> %v in %v", a.resp.Status, time.Since(a.start).Round(time.Microsecond))
+	}
+	if l.verbosity >= LogHeaders {
+		logHeaders(&b, "> ", req.Header, redacted)
+	}
+	if l.verbosity >= LogBodies {
+		if sent := a.sent.Bytes(); len(sent) > 0 {
+			fmt.Fprintf(&b, "\n%s", sent)
+		}
+	}
+	if a.resp != nil && l.verbosity >= LogHeaders {
+		logHeaders(&b, "< ", a.resp.Header, nil)
+		if l.verbosity >= LogBodies && body != "" {
+			fmt.Fprintf(&b, "\n%v", body)
+		}
+	}
+	l.logger.Printf("%v", b.String())
+}
+
+// logHeaders writes header to b, one line each starting with prefix and in
+// order of name, with the values of the redacted headers replaced.
+func logHeaders(b *strings.Builder, prefix string, header http.Header, redacted []string) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, val := range header[key] {
+			if contains(redacted, http.CanonicalHeaderKey(key)) {
+				val = "REDACTED"
+			}
+			fmt.Fprintf(b, "\n%v%v: %v", prefix, key, val)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package crest
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLogger(t *testing.T) {
+	flaky := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" {
+			flaky++
+			if flaky == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Header().Set("X-Served-By", "test")
+		w.Write([]byte("pong"))
+	}))
+	defer srv.Close()
+
+	var logged []string
+	c := NewClient(srv.URL).
+		WithHeader("Authorization", "Bearer secret").
+		WithLogger(LoggerFunc(func(format string, v ...interface{}) {
+			logged = append(logged, fmt.Sprintf(format, v...))
+		}), LogSummary)
+	c.PostString("/ping", "ping").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+	require.Len(t, logged, 1)
+	require.True(t, regexp.MustCompile(`^POST http://127\.0\.0\.1:\d+/ping -> 200 OK in \S+$`).MatchString(logged[0]), logged[0])
+
+	var buf bytes.Buffer
+	c = NewClient(srv.URL).
+		WithHeader("Authorization", "Bearer secret").
+		WithRetry(2, nil).
+		WithLogger(log.New(&buf, "", 0), LogBodies)
+	c.PostString("/flaky", "ping")
+	c.Clone().WithLogger(nil, LogSummary).Get("/ping")
+	require.NoError(t, c.Error())
+	out := buf.String()
+	require.Contains(t, out, "/flaky -> 503 Service Unavailable in ")
+	require.Contains(t, out, "/flaky (attempt 2) -> 200 OK in ")
+	require.Contains(t, out, "\n> Authorization: REDACTED\n")
+	require.Contains(t, out, "\nping\n")
+	require.Contains(t, out, "\n< X-Served-By: test\npong\n")
+	require.NotContains(t, out, "secret")
+	require.NotContains(t, out, "/ping")
+
+	buf.Reset()
+	NewClient("http://127.0.0.1:0").WithLogger(log.New(&buf, "", 0), LogHeaders).Get("/")
+	require.True(t, regexp.MustCompile(`^GET http://127\.0\.0\.1:0/ failed after \S+: `).MatchString(buf.String()), buf.String())
+}
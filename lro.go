@@ -0,0 +1,174 @@
+package crest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// LROOptions configures how FollowOperation polls a long-running operation.
+// The zero value follows both Azure and Google style operations.
+type LROOptions struct {
+	// StatusField is the JSON path of the operation's status, "status" by
+	// default.
+	StatusField string
+	// Succeeded and Failed are the statuses that end the operation, matched
+	// regardless of case. By default "Succeeded", and "Failed" or
+	// "Canceled" in either spelling.
+	Succeeded []string
+	Failed    []string
+	// DoneField is the JSON path of a boolean that is true once the
+	// operation has ended, "done" by default. An operation that is done with
+	// an "error" has failed.
+	DoneField string
+	// Interval is the wait between polls when the server sends no
+	// Retry-After, 1s by default.
+	Interval time.Duration
+	// Timeout is how long the operation may take, 5 minutes by default.
+	Timeout time.Duration
+}
+
+func (o LROOptions) withDefaults() LROOptions {
+	if o.StatusField == "" {
+		o.StatusField = "status"
+	}
+	if o.Succeeded == nil {
+		o.Succeeded = []string{"Succeeded"}
+	}
+	if o.Failed == nil {
+		o.Failed = []string{"Failed", "Canceled", "Cancelled"}
+	}
+	if o.DoneField == "" {
+		o.DoneField = "done"
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 5 * time.Minute
+	}
+	return o
+}
+
+// FollowOperation polls the long-running operation at location, a URL as
+// found in a Location or Operation-Location header, or a path, until it
+// ends, waiting as Retry-After says or opts.Interval in between. It returns
+// the wrapper of the final resource: the one at the operation's
+// "resourceLocation" if it has one, or else the last response, which is the
+// resource itself for servers that answer 202 while the operation runs and
+// the operation with its "response" for Google APIs. The operation failing
+// or not ending within opts.Timeout is an error.
+func (c *client) FollowOperation(location string, opts LROOptions) ResponseWrapper {
+	if c.errGetter() != nil {
+		return c.nop()
+	}
+	opts = opts.withDefaults()
+	deadline := time.Now().Add(opts.Timeout)
+	for {
+		rw := c.getLocation(location)
+		if c.errGetter() != nil || rw.DryRun() {
+			return rw
+		}
+		done, err := operationDone(rw, opts)
+		if err != nil {
+			c.errSetter(errors.Wrapf(err, "following operation %v", location))
+			return rw
+		}
+		if done {
+			var op struct {
+				ResourceLocation string `json:"resourceLocation"`
+			}
+			if json.Unmarshal([]byte(rw.Body()), &op) == nil && op.ResourceLocation != "" {
+				return c.getLocation(op.ResourceLocation)
+			}
+			return rw
+		}
+
+		wait := opts.Interval
+		if d, ok := retryAfter(rw.Response().Header, time.Now()); ok {
+			wait = d
+		}
+		if time.Now().Add(wait).After(deadline) {
+			c.errSetter(errors.Errorf("following operation %v: not done after %v", location, opts.Timeout))
+			return rw
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-c.lifecycle.ctx.Done():
+			timer.Stop()
+			c.errSetter(errors.Wrapf(ErrClientClosed, "following operation %v", location))
+			return c.nop()
+		}
+	}
+}
+
+// operationDone tells from a poll of an operation whether it has ended,
+// returning an error if it failed.
+func operationDone(rw ResponseWrapper, opts LROOptions) (bool, error) {
+	resp := rw.Response()
+	if resp == nil {
+		// A dry run: there is nothing to wait for.
+		return true, nil
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		return false, nil
+	}
+	if resp.StatusCode >= 300 {
+		return false, errors.Errorf("polling returned status %d", resp.StatusCode)
+	}
+	var body interface{}
+	if json.Unmarshal([]byte(rw.Body()), &body) != nil {
+		// Not an operation, so the resource itself.
+		return true, nil
+	}
+	if done, err := lookupJSONPath(body, opts.DoneField); err == nil {
+		if done != true {
+			return false, nil
+		}
+		if opErr, err := lookupJSONPath(body, "error"); err == nil && opErr != nil {
+			return true, errors.Errorf("the operation failed: %v", jsonString(opErr))
+		}
+		return true, nil
+	}
+	status, err := lookupJSONPath(body, opts.StatusField)
+	if err != nil {
+		return true, nil
+	}
+	s, _ := status.(string)
+	for _, failed := range opts.Failed {
+		if strings.EqualFold(s, failed) {
+			return true, errors.Errorf("the operation ended with status %q: %v", s, rw.Body())
+		}
+	}
+	for _, succeeded := range opts.Succeeded {
+		if strings.EqualFold(s, succeeded) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getLocation gets location, which is a URL or a path relative to the
+// client's base URL.
+func (c *client) getLocation(location string) ResponseWrapper {
+	u, err := url.Parse(location)
+	if err != nil || !u.IsAbs() {
+		return c.Get(location)
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		c.errSetter(errors.Wrap(err, "creating request"))
+		return c.nop()
+	}
+	return c.do(c.populateReq(req))
+}
@@ -0,0 +1,83 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFollowOperation(t *testing.T) {
+	polls := make(map[string]int)
+	var srvURL string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls[r.URL.Path]++
+		n := polls[r.URL.Path]
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/azure/operations/1":
+			w.Header().Set("Retry-After", "0")
+			if n < 3 {
+				w.Write([]byte(`{"status":"Running"}`))
+				return
+			}
+			w.Write([]byte(`{"status":"Succeeded","resourceLocation":"` + srvURL + `/things/1"}`))
+		case "/azure/operations/2":
+			w.Write([]byte(`{"status":"Failed","error":{"code":"Conflict"}}`))
+		case "/google/operations/1":
+			if n < 2 {
+				w.Write([]byte(`{"name":"operations/1","done":false}`))
+				return
+			}
+			w.Write([]byte(`{"name":"operations/1","done":true,"response":{"id":"g1"}}`))
+		case "/google/operations/2":
+			w.Write([]byte(`{"done":true,"error":{"code":9,"message":"precondition"}}`))
+		case "/jobs/1":
+			if n < 2 {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			w.Write([]byte(`{"id":"job1"}`))
+		case "/jobs/slow":
+			w.WriteHeader(http.StatusAccepted)
+		case "/things/1":
+			w.Write([]byte(`{"id":"thing1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	opts := LROOptions{Interval: time.Millisecond}
+	c := NewClient(srv.URL)
+	c.FollowOperation(srv.URL+"/azure/operations/1", opts).ExpectJSONPath("id", "thing1")
+	c.FollowOperation("/google/operations/1", opts).ExpectJSONPath("response.id", "g1")
+	c.FollowOperation("/jobs/1", opts).ExpectStatus(http.StatusOK).ExpectJSONPath("id", "job1")
+	require.NoError(t, c.Error())
+	require.Equal(t, 3, polls["/azure/operations/1"])
+	require.Equal(t, 2, polls["/google/operations/1"])
+
+	c = NewClient(srv.URL)
+	c.FollowOperation("/azure/operations/2", opts)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), `following operation /azure/operations/2: the operation ended with status "Failed"`)
+
+	c = NewClient(srv.URL)
+	c.FollowOperation("/google/operations/2", opts)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), `the operation failed: {"code":9,"message":"precondition"}`)
+
+	c = NewClient(srv.URL)
+	c.FollowOperation("/missing", opts)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "polling returned status 404")
+
+	c = NewClient(srv.URL)
+	c.FollowOperation("/jobs/slow", LROOptions{Interval: 10 * time.Millisecond, Timeout: 35 * time.Millisecond})
+	require.Error(t, c.Error())
+	require.True(t, strings.HasSuffix(c.Error().Error(), "not done after 35ms"), c.Error().Error())
+}
@@ -0,0 +1,45 @@
+package crest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MatrixError holds the failures of a block that was run once per variant,
+// keyed by variant name.
+type MatrixError struct {
+	Failures map[string]error
+}
+
+func (m *MatrixError) Error() string {
+	names := make([]string, 0, len(m.Failures))
+	for name := range m.Failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%v: %v", name, m.Failures[name]))
+	}
+	return fmt.Sprintf("%d of the variants failed:\n  %v", len(names), strings.Join(lines, "\n  "))
+}
+
+// runMatrix runs f once per variant on a detached copy of c, so a failure in
+// one variant does not stop the others.
+func (c *client) runMatrix(variants []string, configure func(variant string, cl *client), f func(variant string, cl Client)) error {
+	failures := make(map[string]error)
+	for _, variant := range variants {
+		cl := c.detached()
+		configure(variant, cl)
+		f(variant, cl)
+		if err := cl.Error(); err != nil {
+			failures[variant] = err
+		}
+	}
+	if len(failures) > 0 {
+		return &MatrixError{Failures: failures}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptLanguage(t *testing.T) {
+	require.Equal(t, "de", acceptLanguage([]string{"de"}))
+	require.Equal(t, "de-CH, de;q=0.9, en;q=0.8", acceptLanguage([]string{"de-CH", "de", "en"}))
+}
+
+func TestForEachLocale(t *testing.T) {
+	greetings := map[string]string{"en": "hello", "fr": "bonjour", "de": "hello"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(greetings[r.Header.Get("Accept-Language")]))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	err := c.ForEachLocale([]string{"en", "fr", "de"}, func(locale string, c Client) {
+		c.Get("/").ExpectBodyNotEquals("hello")
+	})
+	require.Error(t, err)
+	matrixErr, ok := err.(*MatrixError)
+	require.True(t, ok)
+	require.Len(t, matrixErr.Failures, 2)
+	require.Contains(t, matrixErr.Failures, "en")
+	require.Contains(t, matrixErr.Failures, "de")
+	require.Contains(t, err.Error(), "2 of the variants failed")
+	require.NoError(t, c.Error())
+
+	err = c.ForEachLocale([]string{"fr"}, func(locale string, c Client) {
+		c.Get("/").ExpectBodyEquals("bonjour")
+	})
+	require.NoError(t, err)
+}
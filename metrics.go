@@ -0,0 +1,69 @@
+package crest
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Observation is what a client tells the Recorder set with WithMetrics
+// about a request.
+type Observation struct {
+	Method string
+	// PathTemplate is the path relative to the base URL before the
+	// parameters set with WithPathParam were filled in, e.g.
+	// "/orders/{id}", so that it can label metrics without a label value
+	// per resource. It is the path itself for requests without parameters.
+	PathTemplate string
+	// Status is the status code of the response, or 0 if there was none.
+	Status int
+	// Latency is how long the request took, including retries and reading
+	// the response body.
+	Latency time.Duration
+	// Tags are the tags of the client that sent the request, see Tag, e.g.
+	// to leave setup requests out of latency objectives.
+	Tags []string
+}
+
+// HasTag reports whether the request was tagged with tag.
+func (o Observation) HasTag(tag string) bool {
+	return contains(o.Tags, tag)
+}
+
+// Recorder receives an Observation of every request, e.g. to export
+// Prometheus metrics from a long-running API monitor. It may be called
+// concurrently.
+type Recorder interface {
+	Observe(Observation)
+}
+
+// WithMetrics reports every request the client sends to rec once it is
+// done, however many attempts it took. Requests that are not sent, e.g.
+// because the client already failed, are not reported. A nil rec stops
+// reporting.
+func (c *client) WithMetrics(rec Recorder) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.metrics = rec
+	return c
+}
+
+// observe reports req, sent at start, and rw, the response it got.
+func (c *client) observe(req *http.Request, start time.Time, rw ResponseWrapper) {
+	obs := Observation{
+		Method:       req.Method,
+		PathTemplate: c.relativePath(req.URL),
+		Latency:      time.Since(start),
+		Tags:         append([]string(nil), c.tags...),
+	}
+	if c.pathTemplate != "" {
+		if u, err := url.Parse(c.buildPath(c.apiVersion.applyPath(c.pathTemplate))); err == nil {
+			obs.PathTemplate = c.relativePath(u)
+		}
+	}
+	if resp := rw.Response(); resp != nil {
+		obs.Status = resp.StatusCode
+	}
+	c.metrics.Observe(obs)
+}
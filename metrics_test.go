@@ -0,0 +1,83 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type observations struct {
+	lock sync.Mutex
+	obs  []Observation
+}
+
+func (o *observations) Observe(obs Observation) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.obs = append(o.obs, obs)
+}
+
+func TestWithMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/slow":
+			time.Sleep(20 * time.Millisecond)
+		case "/api/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	rec := &observations{}
+	c := NewClient(srv.URL + "/api").WithMetrics(rec)
+	c.Request(http.MethodGet, "/orders/{id}").WithPathParam("id", "42").Do()
+	c.Group("/things").Request(http.MethodPost, "/{id}").WithPathParam("id", "a b").WithBody("x").Do()
+	c.Get("/slow")
+	c.Get("/missing")
+	c.Clone().WithMetrics(nil).Get("/orders/1")
+	require.NoError(t, c.Error())
+
+	require.Len(t, rec.obs, 4)
+	for i, want := range []Observation{
+		{Method: http.MethodGet, PathTemplate: "/orders/{id}", Status: http.StatusOK},
+		{Method: http.MethodPost, PathTemplate: "/things/{id}", Status: http.StatusOK},
+		{Method: http.MethodGet, PathTemplate: "/slow", Status: http.StatusOK},
+		{Method: http.MethodGet, PathTemplate: "/missing", Status: http.StatusNotFound},
+	} {
+		require.True(t, rec.obs[i].Latency > 0)
+		got := rec.obs[i]
+		got.Latency = 0
+		require.Equal(t, want, got)
+	}
+	require.True(t, rec.obs[2].Latency >= 20*time.Millisecond)
+
+	rec = &observations{}
+	c = NewClient("http://127.0.0.1:0").WithMetrics(rec)
+	c.Get("/down")
+	c.Get("/not-sent")
+	require.Error(t, c.Error())
+	require.Len(t, rec.obs, 1)
+	require.Equal(t, 0, rec.obs[0].Status)
+	require.Equal(t, "/down", rec.obs[0].PathTemplate)
+}
+
+func TestWithMetricsTags(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	rec := &observations{}
+	c := NewClient(srv.URL).WithMetrics(rec)
+	c.Clone().Tag("setup").Post("/fixtures", nil)
+	c.Tag("critical-path").Get("/checkout")
+	require.NoError(t, c.Error())
+
+	require.Len(t, rec.obs, 2)
+	require.Equal(t, []string{"setup"}, rec.obs[0].Tags)
+	require.True(t, rec.obs[0].HasTag("setup"))
+	require.False(t, rec.obs[1].HasTag("setup"))
+	require.True(t, rec.obs[1].HasTag("critical-path"))
+}
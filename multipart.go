@@ -0,0 +1,98 @@
+package crest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// Part is one part of a multipart response body, e.g. one response of a
+// batch API or one range of a multipart/byteranges response.
+type Part struct {
+	Header http.Header
+	Body   string
+
+	wrapper ResponseWrapper
+}
+
+// Expect returns a wrapper for assertions on the part's headers and body.
+// Failures are reported like failures of assertions on the whole response.
+func (p Part) Expect() ResponseWrapper {
+	if p.wrapper == nil {
+		return &nopResponseWrapper{}
+	}
+	return p.wrapper
+}
+
+// ParseMultipartBody splits a multipart/* body into its parts.
+func (r *responseWrapper) ParseMultipartBody() ([]Part, error) {
+	if err := r.error(); err != nil {
+		return nil, err
+	}
+	parts, err := r.parseMultipart()
+	if err != nil {
+		r.setError(err)
+		return nil, err
+	}
+	return parts, nil
+}
+
+func (r *responseWrapper) parseMultipart() ([]Part, error) {
+	mediaType, params, err := mime.ParseMediaType(r.resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing content type: %v", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("expected a multipart body, but the content type is %v", mediaType)
+	}
+	if params["boundary"] == "" {
+		return nil, fmt.Errorf("expected a multipart body, but the content type has no boundary")
+	}
+
+	var parts []Part
+	mr := multipart.NewReader(strings.NewReader(r.body), params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading part %d: %v", len(parts)+1, err)
+		}
+		body, err := ioutil.ReadAll(p)
+		if err != nil {
+			return nil, fmt.Errorf("reading part %d: %v", len(parts)+1, err)
+		}
+		part := Part{
+			Header: http.Header(p.Header),
+			Body:   string(body),
+		}
+		part.wrapper = r.partWrapper(part)
+		parts = append(parts, part)
+	}
+}
+
+// partWrapper wraps a part as if it were a response of its own, with the
+// status of the whole response.
+func (r *responseWrapper) partWrapper(part Part) ResponseWrapper {
+	resp := &http.Response{
+		Status:        r.resp.Status,
+		StatusCode:    r.resp.StatusCode,
+		Proto:         r.resp.Proto,
+		ProtoMajor:    r.resp.ProtoMajor,
+		ProtoMinor:    r.resp.ProtoMinor,
+		Header:        part.Header.Clone(),
+		Body:          ioutil.NopCloser(strings.NewReader(part.Body)),
+		ContentLength: int64(len(part.Body)),
+		Request:       r.resp.Request,
+	}
+	rw := newResponseWrapperWithSettings(resp, r.error, r.setError, r.settings)
+	rw.report = r.report
+	rw.req = r.req
+	rw.sentBody = r.sentBody
+	return rw
+}
@@ -0,0 +1,60 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMultipartBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/plain" {
+			w.Write([]byte("plain"))
+			return
+		}
+		w.Header().Set("Content-Type", "multipart/mixed; boundary=batch")
+		w.Write([]byte(strings.Join([]string{
+			"--batch",
+			"Content-Type: application/json",
+			"X-Item: 1",
+			"",
+			`{"id":1}`,
+			"--batch",
+			"Content-Type: text/plain",
+			"Content-Transfer-Encoding: quoted-printable",
+			"",
+			"caf=C3=A9",
+			"--batch--",
+			"",
+		}, "\r\n")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	parts, err := c.Get("/batch").ExpectStatus(http.StatusOK).ParseMultipartBody()
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	require.Equal(t, "1", parts[0].Header.Get("X-Item"))
+	require.Equal(t, `{"id":1}`, parts[0].Body)
+	require.Equal(t, "caf=C3=A9", parts[1].Body)
+	parts[0].Expect().
+		ExpectStatus(http.StatusOK).
+		ExpectHeaderEquals("Content-Type", "application/json").
+		ExpectJSONPath("id", 1)
+	require.NoError(t, c.Error())
+
+	parts[1].Expect().ExpectBodyEquals("café")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "ExpectBodyEquals at multipart_test.go:")
+
+	c = NewClient(srv.URL)
+	parts, err = c.Get("/plain").ParseMultipartBody()
+	require.Nil(t, parts)
+	require.EqualError(t, err, "expected a multipart body, but the content type is text/plain")
+	require.Error(t, c.Error())
+
+	require.Equal(t, &nopResponseWrapper{}, Part{}.Expect())
+}
@@ -0,0 +1,119 @@
+package crest
+
+//go:generate go run gen_norm.go
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	hangulSBase  = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+func combiningClass(r rune) uint8 {
+	i := sort.Search(len(combiningClasses), func(i int) bool {
+		return combiningClasses[i].hi >= r
+	})
+	if i < len(combiningClasses) && combiningClasses[i].lo <= r {
+		return combiningClasses[i].class
+	}
+	return 0
+}
+
+func decomposeRune(r rune, out []rune) []rune {
+	if s := r - hangulSBase; s >= 0 && s < hangulSCount {
+		out = append(out, hangulLBase+s/hangulNCount, hangulVBase+(s%hangulNCount)/hangulTCount)
+		if t := s % hangulTCount; t != 0 {
+			out = append(out, hangulTBase+t)
+		}
+		return out
+	}
+	if parts, ok := canonicalDecompositions[r]; ok {
+		for _, part := range parts {
+			out = decomposeRune(part, out)
+		}
+		return out
+	}
+	return append(out, r)
+}
+
+func composePair(a, b rune) (rune, bool) {
+	if l, v := a-hangulLBase, b-hangulVBase; l >= 0 && l < hangulLCount && v >= 0 && v < hangulVCount {
+		return hangulSBase + (l*hangulVCount+v)*hangulTCount, true
+	}
+	if s, t := a-hangulSBase, b-hangulTBase; s >= 0 && s < hangulSCount && s%hangulTCount == 0 && t > 0 && t < hangulTCount {
+		return a + t, true
+	}
+	r, ok := canonicalCompositions[[2]rune{a, b}]
+	return r, ok
+}
+
+// nfc returns s in Unicode Normalization Form C, so that visually identical
+// strings built from precomposed or combining characters compare equal.
+func nfc(s string) string {
+	runes := make([]rune, 0, len(s))
+	for _, r := range s {
+		runes = decomposeRune(r, runes)
+	}
+
+	// Canonical ordering: stable sort each run of non-starters by class.
+	for i := 0; i < len(runes); {
+		if combiningClass(runes[i]) == 0 {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && combiningClass(runes[j]) != 0 {
+			j++
+		}
+		run := runes[i:j]
+		sort.SliceStable(run, func(a, b int) bool {
+			return combiningClass(run[a]) < combiningClass(run[b])
+		})
+		i = j
+	}
+
+	// Canonical composition.
+	starter := -1
+	var lastClass uint8
+	out := runes[:0]
+	for _, r := range runes {
+		class := combiningClass(r)
+		if starter >= 0 && (lastClass < class || (lastClass == 0 && len(out)-1 == starter)) {
+			if composed, ok := composePair(out[starter], r); ok {
+				out[starter] = composed
+				continue
+			}
+		}
+		if class == 0 {
+			starter = len(out)
+		}
+		lastClass = class
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+// foldCase applies full Unicode case folding to s, so that "Straße" and
+// "STRASSE" fold alike.
+func foldCase(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		if folded, ok := caseFoldings[r]; ok {
+			b.WriteString(folded)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
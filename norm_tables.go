@@ -0,0 +1,4940 @@
+// Code generated from the Unicode 14.0.0 character database. DO NOT EDIT.
+
+package crest
+
+// canonicalDecompositions maps a rune to its single-level canonical
+// decomposition. Hangul syllables are decomposed algorithmically instead.
+var canonicalDecompositions = map[rune][]rune{
+	0x00C0:  {0x0041, 0x0300},
+	0x00C1:  {0x0041, 0x0301},
+	0x00C2:  {0x0041, 0x0302},
+	0x00C3:  {0x0041, 0x0303},
+	0x00C4:  {0x0041, 0x0308},
+	0x00C5:  {0x0041, 0x030A},
+	0x00C7:  {0x0043, 0x0327},
+	0x00C8:  {0x0045, 0x0300},
+	0x00C9:  {0x0045, 0x0301},
+	0x00CA:  {0x0045, 0x0302},
+	0x00CB:  {0x0045, 0x0308},
+	0x00CC:  {0x0049, 0x0300},
+	0x00CD:  {0x0049, 0x0301},
+	0x00CE:  {0x0049, 0x0302},
+	0x00CF:  {0x0049, 0x0308},
+	0x00D1:  {0x004E, 0x0303},
+	0x00D2:  {0x004F, 0x0300},
+	0x00D3:  {0x004F, 0x0301},
+	0x00D4:  {0x004F, 0x0302},
+	0x00D5:  {0x004F, 0x0303},
+	0x00D6:  {0x004F, 0x0308},
+	0x00D9:  {0x0055, 0x0300},
+	0x00DA:  {0x0055, 0x0301},
+	0x00DB:  {0x0055, 0x0302},
+	0x00DC:  {0x0055, 0x0308},
+	0x00DD:  {0x0059, 0x0301},
+	0x00E0:  {0x0061, 0x0300},
+	0x00E1:  {0x0061, 0x0301},
+	0x00E2:  {0x0061, 0x0302},
+	0x00E3:  {0x0061, 0x0303},
+	0x00E4:  {0x0061, 0x0308},
+	0x00E5:  {0x0061, 0x030A},
+	0x00E7:  {0x0063, 0x0327},
+	0x00E8:  {0x0065, 0x0300},
+	0x00E9:  {0x0065, 0x0301},
+	0x00EA:  {0x0065, 0x0302},
+	0x00EB:  {0x0065, 0x0308},
+	0x00EC:  {0x0069, 0x0300},
+	0x00ED:  {0x0069, 0x0301},
+	0x00EE:  {0x0069, 0x0302},
+	0x00EF:  {0x0069, 0x0308},
+	0x00F1:  {0x006E, 0x0303},
+	0x00F2:  {0x006F, 0x0300},
+	0x00F3:  {0x006F, 0x0301},
+	0x00F4:  {0x006F, 0x0302},
+	0x00F5:  {0x006F, 0x0303},
+	0x00F6:  {0x006F, 0x0308},
+	0x00F9:  {0x0075, 0x0300},
+	0x00FA:  {0x0075, 0x0301},
+	0x00FB:  {0x0075, 0x0302},
+	0x00FC:  {0x0075, 0x0308},
+	0x00FD:  {0x0079, 0x0301},
+	0x00FF:  {0x0079, 0x0308},
+	0x0100:  {0x0041, 0x0304},
+	0x0101:  {0x0061, 0x0304},
+	0x0102:  {0x0041, 0x0306},
+	0x0103:  {0x0061, 0x0306},
+	0x0104:  {0x0041, 0x0328},
+	0x0105:  {0x0061, 0x0328},
+	0x0106:  {0x0043, 0x0301},
+	0x0107:  {0x0063, 0x0301},
+	0x0108:  {0x0043, 0x0302},
+	0x0109:  {0x0063, 0x0302},
+	0x010A:  {0x0043, 0x0307},
+	0x010B:  {0x0063, 0x0307},
+	0x010C:  {0x0043, 0x030C},
+	0x010D:  {0x0063, 0x030C},
+	0x010E:  {0x0044, 0x030C},
+	0x010F:  {0x0064, 0x030C},
+	0x0112:  {0x0045, 0x0304},
+	0x0113:  {0x0065, 0x0304},
+	0x0114:  {0x0045, 0x0306},
+	0x0115:  {0x0065, 0x0306},
+	0x0116:  {0x0045, 0x0307},
+	0x0117:  {0x0065, 0x0307},
+	0x0118:  {0x0045, 0x0328},
+	0x0119:  {0x0065, 0x0328},
+	0x011A:  {0x0045, 0x030C},
+	0x011B:  {0x0065, 0x030C},
+	0x011C:  {0x0047, 0x0302},
+	0x011D:  {0x0067, 0x0302},
+	0x011E:  {0x0047, 0x0306},
+	0x011F:  {0x0067, 0x0306},
+	0x0120:  {0x0047, 0x0307},
+	0x0121:  {0x0067, 0x0307},
+	0x0122:  {0x0047, 0x0327},
+	0x0123:  {0x0067, 0x0327},
+	0x0124:  {0x0048, 0x0302},
+	0x0125:  {0x0068, 0x0302},
+	0x0128:  {0x0049, 0x0303},
+	0x0129:  {0x0069, 0x0303},
+	0x012A:  {0x0049, 0x0304},
+	0x012B:  {0x0069, 0x0304},
+	0x012C:  {0x0049, 0x0306},
+	0x012D:  {0x0069, 0x0306},
+	0x012E:  {0x0049, 0x0328},
+	0x012F:  {0x0069, 0x0328},
+	0x0130:  {0x0049, 0x0307},
+	0x0134:  {0x004A, 0x0302},
+	0x0135:  {0x006A, 0x0302},
+	0x0136:  {0x004B, 0x0327},
+	0x0137:  {0x006B, 0x0327},
+	0x0139:  {0x004C, 0x0301},
+	0x013A:  {0x006C, 0x0301},
+	0x013B:  {0x004C, 0x0327},
+	0x013C:  {0x006C, 0x0327},
+	0x013D:  {0x004C, 0x030C},
+	0x013E:  {0x006C, 0x030C},
+	0x0143:  {0x004E, 0x0301},
+	0x0144:  {0x006E, 0x0301},
+	0x0145:  {0x004E, 0x0327},
+	0x0146:  {0x006E, 0x0327},
+	0x0147:  {0x004E, 0x030C},
+	0x0148:  {0x006E, 0x030C},
+	0x014C:  {0x004F, 0x0304},
+	0x014D:  {0x006F, 0x0304},
+	0x014E:  {0x004F, 0x0306},
+	0x014F:  {0x006F, 0x0306},
+	0x0150:  {0x004F, 0x030B},
+	0x0151:  {0x006F, 0x030B},
+	0x0154:  {0x0052, 0x0301},
+	0x0155:  {0x0072, 0x0301},
+	0x0156:  {0x0052, 0x0327},
+	0x0157:  {0x0072, 0x0327},
+	0x0158:  {0x0052, 0x030C},
+	0x0159:  {0x0072, 0x030C},
+	0x015A:  {0x0053, 0x0301},
+	0x015B:  {0x0073, 0x0301},
+	0x015C:  {0x0053, 0x0302},
+	0x015D:  {0x0073, 0x0302},
+	0x015E:  {0x0053, 0x0327},
+	0x015F:  {0x0073, 0x0327},
+	0x0160:  {0x0053, 0x030C},
+	0x0161:  {0x0073, 0x030C},
+	0x0162:  {0x0054, 0x0327},
+	0x0163:  {0x0074, 0x0327},
+	0x0164:  {0x0054, 0x030C},
+	0x0165:  {0x0074, 0x030C},
+	0x0168:  {0x0055, 0x0303},
+	0x0169:  {0x0075, 0x0303},
+	0x016A:  {0x0055, 0x0304},
+	0x016B:  {0x0075, 0x0304},
+	0x016C:  {0x0055, 0x0306},
+	0x016D:  {0x0075, 0x0306},
+	0x016E:  {0x0055, 0x030A},
+	0x016F:  {0x0075, 0x030A},
+	0x0170:  {0x0055, 0x030B},
+	0x0171:  {0x0075, 0x030B},
+	0x0172:  {0x0055, 0x0328},
+	0x0173:  {0x0075, 0x0328},
+	0x0174:  {0x0057, 0x0302},
+	0x0175:  {0x0077, 0x0302},
+	0x0176:  {0x0059, 0x0302},
+	0x0177:  {0x0079, 0x0302},
+	0x0178:  {0x0059, 0x0308},
+	0x0179:  {0x005A, 0x0301},
+	0x017A:  {0x007A, 0x0301},
+	0x017B:  {0x005A, 0x0307},
+	0x017C:  {0x007A, 0x0307},
+	0x017D:  {0x005A, 0x030C},
+	0x017E:  {0x007A, 0x030C},
+	0x01A0:  {0x004F, 0x031B},
+	0x01A1:  {0x006F, 0x031B},
+	0x01AF:  {0x0055, 0x031B},
+	0x01B0:  {0x0075, 0x031B},
+	0x01CD:  {0x0041, 0x030C},
+	0x01CE:  {0x0061, 0x030C},
+	0x01CF:  {0x0049, 0x030C},
+	0x01D0:  {0x0069, 0x030C},
+	0x01D1:  {0x004F, 0x030C},
+	0x01D2:  {0x006F, 0x030C},
+	0x01D3:  {0x0055, 0x030C},
+	0x01D4:  {0x0075, 0x030C},
+	0x01D5:  {0x00DC, 0x0304},
+	0x01D6:  {0x00FC, 0x0304},
+	0x01D7:  {0x00DC, 0x0301},
+	0x01D8:  {0x00FC, 0x0301},
+	0x01D9:  {0x00DC, 0x030C},
+	0x01DA:  {0x00FC, 0x030C},
+	0x01DB:  {0x00DC, 0x0300},
+	0x01DC:  {0x00FC, 0x0300},
+	0x01DE:  {0x00C4, 0x0304},
+	0x01DF:  {0x00E4, 0x0304},
+	0x01E0:  {0x0226, 0x0304},
+	0x01E1:  {0x0227, 0x0304},
+	0x01E2:  {0x00C6, 0x0304},
+	0x01E3:  {0x00E6, 0x0304},
+	0x01E6:  {0x0047, 0x030C},
+	0x01E7:  {0x0067, 0x030C},
+	0x01E8:  {0x004B, 0x030C},
+	0x01E9:  {0x006B, 0x030C},
+	0x01EA:  {0x004F, 0x0328},
+	0x01EB:  {0x006F, 0x0328},
+	0x01EC:  {0x01EA, 0x0304},
+	0x01ED:  {0x01EB, 0x0304},
+	0x01EE:  {0x01B7, 0x030C},
+	0x01EF:  {0x0292, 0x030C},
+	0x01F0:  {0x006A, 0x030C},
+	0x01F4:  {0x0047, 0x0301},
+	0x01F5:  {0x0067, 0x0301},
+	0x01F8:  {0x004E, 0x0300},
+	0x01F9:  {0x006E, 0x0300},
+	0x01FA:  {0x00C5, 0x0301},
+	0x01FB:  {0x00E5, 0x0301},
+	0x01FC:  {0x00C6, 0x0301},
+	0x01FD:  {0x00E6, 0x0301},
+	0x01FE:  {0x00D8, 0x0301},
+	0x01FF:  {0x00F8, 0x0301},
+	0x0200:  {0x0041, 0x030F},
+	0x0201:  {0x0061, 0x030F},
+	0x0202:  {0x0041, 0x0311},
+	0x0203:  {0x0061, 0x0311},
+	0x0204:  {0x0045, 0x030F},
+	0x0205:  {0x0065, 0x030F},
+	0x0206:  {0x0045, 0x0311},
+	0x0207:  {0x0065, 0x0311},
+	0x0208:  {0x0049, 0x030F},
+	0x0209:  {0x0069, 0x030F},
+	0x020A:  {0x0049, 0x0311},
+	0x020B:  {0x0069, 0x0311},
+	0x020C:  {0x004F, 0x030F},
+	0x020D:  {0x006F, 0x030F},
+	0x020E:  {0x004F, 0x0311},
+	0x020F:  {0x006F, 0x0311},
+	0x0210:  {0x0052, 0x030F},
+	0x0211:  {0x0072, 0x030F},
+	0x0212:  {0x0052, 0x0311},
+	0x0213:  {0x0072, 0x0311},
+	0x0214:  {0x0055, 0x030F},
+	0x0215:  {0x0075, 0x030F},
+	0x0216:  {0x0055, 0x0311},
+	0x0217:  {0x0075, 0x0311},
+	0x0218:  {0x0053, 0x0326},
+	0x0219:  {0x0073, 0x0326},
+	0x021A:  {0x0054, 0x0326},
+	0x021B:  {0x0074, 0x0326},
+	0x021E:  {0x0048, 0x030C},
+	0x021F:  {0x0068, 0x030C},
+	0x0226:  {0x0041, 0x0307},
+	0x0227:  {0x0061, 0x0307},
+	0x0228:  {0x0045, 0x0327},
+	0x0229:  {0x0065, 0x0327},
+	0x022A:  {0x00D6, 0x0304},
+	0x022B:  {0x00F6, 0x0304},
+	0x022C:  {0x00D5, 0x0304},
+	0x022D:  {0x00F5, 0x0304},
+	0x022E:  {0x004F, 0x0307},
+	0x022F:  {0x006F, 0x0307},
+	0x0230:  {0x022E, 0x0304},
+	0x0231:  {0x022F, 0x0304},
+	0x0232:  {0x0059, 0x0304},
+	0x0233:  {0x0079, 0x0304},
+	0x0340:  {0x0300},
+	0x0341:  {0x0301},
+	0x0343:  {0x0313},
+	0x0344:  {0x0308, 0x0301},
+	0x0374:  {0x02B9},
+	0x037E:  {0x003B},
+	0x0385:  {0x00A8, 0x0301},
+	0x0386:  {0x0391, 0x0301},
+	0x0387:  {0x00B7},
+	0x0388:  {0x0395, 0x0301},
+	0x0389:  {0x0397, 0x0301},
+	0x038A:  {0x0399, 0x0301},
+	0x038C:  {0x039F, 0x0301},
+	0x038E:  {0x03A5, 0x0301},
+	0x038F:  {0x03A9, 0x0301},
+	0x0390:  {0x03CA, 0x0301},
+	0x03AA:  {0x0399, 0x0308},
+	0x03AB:  {0x03A5, 0x0308},
+	0x03AC:  {0x03B1, 0x0301},
+	0x03AD:  {0x03B5, 0x0301},
+	0x03AE:  {0x03B7, 0x0301},
+	0x03AF:  {0x03B9, 0x0301},
+	0x03B0:  {0x03CB, 0x0301},
+	0x03CA:  {0x03B9, 0x0308},
+	0x03CB:  {0x03C5, 0x0308},
+	0x03CC:  {0x03BF, 0x0301},
+	0x03CD:  {0x03C5, 0x0301},
+	0x03CE:  {0x03C9, 0x0301},
+	0x03D3:  {0x03D2, 0x0301},
+	0x03D4:  {0x03D2, 0x0308},
+	0x0400:  {0x0415, 0x0300},
+	0x0401:  {0x0415, 0x0308},
+	0x0403:  {0x0413, 0x0301},
+	0x0407:  {0x0406, 0x0308},
+	0x040C:  {0x041A, 0x0301},
+	0x040D:  {0x0418, 0x0300},
+	0x040E:  {0x0423, 0x0306},
+	0x0419:  {0x0418, 0x0306},
+	0x0439:  {0x0438, 0x0306},
+	0x0450:  {0x0435, 0x0300},
+	0x0451:  {0x0435, 0x0308},
+	0x0453:  {0x0433, 0x0301},
+	0x0457:  {0x0456, 0x0308},
+	0x045C:  {0x043A, 0x0301},
+	0x045D:  {0x0438, 0x0300},
+	0x045E:  {0x0443, 0x0306},
+	0x0476:  {0x0474, 0x030F},
+	0x0477:  {0x0475, 0x030F},
+	0x04C1:  {0x0416, 0x0306},
+	0x04C2:  {0x0436, 0x0306},
+	0x04D0:  {0x0410, 0x0306},
+	0x04D1:  {0x0430, 0x0306},
+	0x04D2:  {0x0410, 0x0308},
+	0x04D3:  {0x0430, 0x0308},
+	0x04D6:  {0x0415, 0x0306},
+	0x04D7:  {0x0435, 0x0306},
+	0x04DA:  {0x04D8, 0x0308},
+	0x04DB:  {0x04D9, 0x0308},
+	0x04DC:  {0x0416, 0x0308},
+	0x04DD:  {0x0436, 0x0308},
+	0x04DE:  {0x0417, 0x0308},
+	0x04DF:  {0x0437, 0x0308},
+	0x04E2:  {0x0418, 0x0304},
+	0x04E3:  {0x0438, 0x0304},
+	0x04E4:  {0x0418, 0x0308},
+	0x04E5:  {0x0438, 0x0308},
+	0x04E6:  {0x041E, 0x0308},
+	0x04E7:  {0x043E, 0x0308},
+	0x04EA:  {0x04E8, 0x0308},
+	0x04EB:  {0x04E9, 0x0308},
+	0x04EC:  {0x042D, 0x0308},
+	0x04ED:  {0x044D, 0x0308},
+	0x04EE:  {0x0423, 0x0304},
+	0x04EF:  {0x0443, 0x0304},
+	0x04F0:  {0x0423, 0x0308},
+	0x04F1:  {0x0443, 0x0308},
+	0x04F2:  {0x0423, 0x030B},
+	0x04F3:  {0x0443, 0x030B},
+	0x04F4:  {0x0427, 0x0308},
+	0x04F5:  {0x0447, 0x0308},
+	0x04F8:  {0x042B, 0x0308},
+	0x04F9:  {0x044B, 0x0308},
+	0x0622:  {0x0627, 0x0653},
+	0x0623:  {0x0627, 0x0654},
+	0x0624:  {0x0648, 0x0654},
+	0x0625:  {0x0627, 0x0655},
+	0x0626:  {0x064A, 0x0654},
+	0x06C0:  {0x06D5, 0x0654},
+	0x06C2:  {0x06C1, 0x0654},
+	0x06D3:  {0x06D2, 0x0654},
+	0x0929:  {0x0928, 0x093C},
+	0x0931:  {0x0930, 0x093C},
+	0x0934:  {0x0933, 0x093C},
+	0x0958:  {0x0915, 0x093C},
+	0x0959:  {0x0916, 0x093C},
+	0x095A:  {0x0917, 0x093C},
+	0x095B:  {0x091C, 0x093C},
+	0x095C:  {0x0921, 0x093C},
+	0x095D:  {0x0922, 0x093C},
+	0x095E:  {0x092B, 0x093C},
+	0x095F:  {0x092F, 0x093C},
+	0x09CB:  {0x09C7, 0x09BE},
+	0x09CC:  {0x09C7, 0x09D7},
+	0x09DC:  {0x09A1, 0x09BC},
+	0x09DD:  {0x09A2, 0x09BC},
+	0x09DF:  {0x09AF, 0x09BC},
+	0x0A33:  {0x0A32, 0x0A3C},
+	0x0A36:  {0x0A38, 0x0A3C},
+	0x0A59:  {0x0A16, 0x0A3C},
+	0x0A5A:  {0x0A17, 0x0A3C},
+	0x0A5B:  {0x0A1C, 0x0A3C},
+	0x0A5E:  {0x0A2B, 0x0A3C},
+	0x0B48:  {0x0B47, 0x0B56},
+	0x0B4B:  {0x0B47, 0x0B3E},
+	0x0B4C:  {0x0B47, 0x0B57},
+	0x0B5C:  {0x0B21, 0x0B3C},
+	0x0B5D:  {0x0B22, 0x0B3C},
+	0x0B94:  {0x0B92, 0x0BD7},
+	0x0BCA:  {0x0BC6, 0x0BBE},
+	0x0BCB:  {0x0BC7, 0x0BBE},
+	0x0BCC:  {0x0BC6, 0x0BD7},
+	0x0C48:  {0x0C46, 0x0C56},
+	0x0CC0:  {0x0CBF, 0x0CD5},
+	0x0CC7:  {0x0CC6, 0x0CD5},
+	0x0CC8:  {0x0CC6, 0x0CD6},
+	0x0CCA:  {0x0CC6, 0x0CC2},
+	0x0CCB:  {0x0CCA, 0x0CD5},
+	0x0D4A:  {0x0D46, 0x0D3E},
+	0x0D4B:  {0x0D47, 0x0D3E},
+	0x0D4C:  {0x0D46, 0x0D57},
+	0x0DDA:  {0x0DD9, 0x0DCA},
+	0x0DDC:  {0x0DD9, 0x0DCF},
+	0x0DDD:  {0x0DDC, 0x0DCA},
+	0x0DDE:  {0x0DD9, 0x0DDF},
+	0x0F43:  {0x0F42, 0x0FB7},
+	0x0F4D:  {0x0F4C, 0x0FB7},
+	0x0F52:  {0x0F51, 0x0FB7},
+	0x0F57:  {0x0F56, 0x0FB7},
+	0x0F5C:  {0x0F5B, 0x0FB7},
+	0x0F69:  {0x0F40, 0x0FB5},
+	0x0F73:  {0x0F71, 0x0F72},
+	0x0F75:  {0x0F71, 0x0F74},
+	0x0F76:  {0x0FB2, 0x0F80},
+	0x0F78:  {0x0FB3, 0x0F80},
+	0x0F81:  {0x0F71, 0x0F80},
+	0x0F93:  {0x0F92, 0x0FB7},
+	0x0F9D:  {0x0F9C, 0x0FB7},
+	0x0FA2:  {0x0FA1, 0x0FB7},
+	0x0FA7:  {0x0FA6, 0x0FB7},
+	0x0FAC:  {0x0FAB, 0x0FB7},
+	0x0FB9:  {0x0F90, 0x0FB5},
+	0x1026:  {0x1025, 0x102E},
+	0x1B06:  {0x1B05, 0x1B35},
+	0x1B08:  {0x1B07, 0x1B35},
+	0x1B0A:  {0x1B09, 0x1B35},
+	0x1B0C:  {0x1B0B, 0x1B35},
+	0x1B0E:  {0x1B0D, 0x1B35},
+	0x1B12:  {0x1B11, 0x1B35},
+	0x1B3B:  {0x1B3A, 0x1B35},
+	0x1B3D:  {0x1B3C, 0x1B35},
+	0x1B40:  {0x1B3E, 0x1B35},
+	0x1B41:  {0x1B3F, 0x1B35},
+	0x1B43:  {0x1B42, 0x1B35},
+	0x1E00:  {0x0041, 0x0325},
+	0x1E01:  {0x0061, 0x0325},
+	0x1E02:  {0x0042, 0x0307},
+	0x1E03:  {0x0062, 0x0307},
+	0x1E04:  {0x0042, 0x0323},
+	0x1E05:  {0x0062, 0x0323},
+	0x1E06:  {0x0042, 0x0331},
+	0x1E07:  {0x0062, 0x0331},
+	0x1E08:  {0x00C7, 0x0301},
+	0x1E09:  {0x00E7, 0x0301},
+	0x1E0A:  {0x0044, 0x0307},
+	0x1E0B:  {0x0064, 0x0307},
+	0x1E0C:  {0x0044, 0x0323},
+	0x1E0D:  {0x0064, 0x0323},
+	0x1E0E:  {0x0044, 0x0331},
+	0x1E0F:  {0x0064, 0x0331},
+	0x1E10:  {0x0044, 0x0327},
+	0x1E11:  {0x0064, 0x0327},
+	0x1E12:  {0x0044, 0x032D},
+	0x1E13:  {0x0064, 0x032D},
+	0x1E14:  {0x0112, 0x0300},
+	0x1E15:  {0x0113, 0x0300},
+	0x1E16:  {0x0112, 0x0301},
+	0x1E17:  {0x0113, 0x0301},
+	0x1E18:  {0x0045, 0x032D},
+	0x1E19:  {0x0065, 0x032D},
+	0x1E1A:  {0x0045, 0x0330},
+	0x1E1B:  {0x0065, 0x0330},
+	0x1E1C:  {0x0228, 0x0306},
+	0x1E1D:  {0x0229, 0x0306},
+	0x1E1E:  {0x0046, 0x0307},
+	0x1E1F:  {0x0066, 0x0307},
+	0x1E20:  {0x0047, 0x0304},
+	0x1E21:  {0x0067, 0x0304},
+	0x1E22:  {0x0048, 0x0307},
+	0x1E23:  {0x0068, 0x0307},
+	0x1E24:  {0x0048, 0x0323},
+	0x1E25:  {0x0068, 0x0323},
+	0x1E26:  {0x0048, 0x0308},
+	0x1E27:  {0x0068, 0x0308},
+	0x1E28:  {0x0048, 0x0327},
+	0x1E29:  {0x0068, 0x0327},
+	0x1E2A:  {0x0048, 0x032E},
+	0x1E2B:  {0x0068, 0x032E},
+	0x1E2C:  {0x0049, 0x0330},
+	0x1E2D:  {0x0069, 0x0330},
+	0x1E2E:  {0x00CF, 0x0301},
+	0x1E2F:  {0x00EF, 0x0301},
+	0x1E30:  {0x004B, 0x0301},
+	0x1E31:  {0x006B, 0x0301},
+	0x1E32:  {0x004B, 0x0323},
+	0x1E33:  {0x006B, 0x0323},
+	0x1E34:  {0x004B, 0x0331},
+	0x1E35:  {0x006B, 0x0331},
+	0x1E36:  {0x004C, 0x0323},
+	0x1E37:  {0x006C, 0x0323},
+	0x1E38:  {0x1E36, 0x0304},
+	0x1E39:  {0x1E37, 0x0304},
+	0x1E3A:  {0x004C, 0x0331},
+	0x1E3B:  {0x006C, 0x0331},
+	0x1E3C:  {0x004C, 0x032D},
+	0x1E3D:  {0x006C, 0x032D},
+	0x1E3E:  {0x004D, 0x0301},
+	0x1E3F:  {0x006D, 0x0301},
+	0x1E40:  {0x004D, 0x0307},
+	0x1E41:  {0x006D, 0x0307},
+	0x1E42:  {0x004D, 0x0323},
+	0x1E43:  {0x006D, 0x0323},
+	0x1E44:  {0x004E, 0x0307},
+	0x1E45:  {0x006E, 0x0307},
+	0x1E46:  {0x004E, 0x0323},
+	0x1E47:  {0x006E, 0x0323},
+	0x1E48:  {0x004E, 0x0331},
+	0x1E49:  {0x006E, 0x0331},
+	0x1E4A:  {0x004E, 0x032D},
+	0x1E4B:  {0x006E, 0x032D},
+	0x1E4C:  {0x00D5, 0x0301},
+	0x1E4D:  {0x00F5, 0x0301},
+	0x1E4E:  {0x00D5, 0x0308},
+	0x1E4F:  {0x00F5, 0x0308},
+	0x1E50:  {0x014C, 0x0300},
+	0x1E51:  {0x014D, 0x0300},
+	0x1E52:  {0x014C, 0x0301},
+	0x1E53:  {0x014D, 0x0301},
+	0x1E54:  {0x0050, 0x0301},
+	0x1E55:  {0x0070, 0x0301},
+	0x1E56:  {0x0050, 0x0307},
+	0x1E57:  {0x0070, 0x0307},
+	0x1E58:  {0x0052, 0x0307},
+	0x1E59:  {0x0072, 0x0307},
+	0x1E5A:  {0x0052, 0x0323},
+	0x1E5B:  {0x0072, 0x0323},
+	0x1E5C:  {0x1E5A, 0x0304},
+	0x1E5D:  {0x1E5B, 0x0304},
+	0x1E5E:  {0x0052, 0x0331},
+	0x1E5F:  {0x0072, 0x0331},
+	0x1E60:  {0x0053, 0x0307},
+	0x1E61:  {0x0073, 0x0307},
+	0x1E62:  {0x0053, 0x0323},
+	0x1E63:  {0x0073, 0x0323},
+	0x1E64:  {0x015A, 0x0307},
+	0x1E65:  {0x015B, 0x0307},
+	0x1E66:  {0x0160, 0x0307},
+	0x1E67:  {0x0161, 0x0307},
+	0x1E68:  {0x1E62, 0x0307},
+	0x1E69:  {0x1E63, 0x0307},
+	0x1E6A:  {0x0054, 0x0307},
+	0x1E6B:  {0x0074, 0x0307},
+	0x1E6C:  {0x0054, 0x0323},
+	0x1E6D:  {0x0074, 0x0323},
+	0x1E6E:  {0x0054, 0x0331},
+	0x1E6F:  {0x0074, 0x0331},
+	0x1E70:  {0x0054, 0x032D},
+	0x1E71:  {0x0074, 0x032D},
+	0x1E72:  {0x0055, 0x0324},
+	0x1E73:  {0x0075, 0x0324},
+	0x1E74:  {0x0055, 0x0330},
+	0x1E75:  {0x0075, 0x0330},
+	0x1E76:  {0x0055, 0x032D},
+	0x1E77:  {0x0075, 0x032D},
+	0x1E78:  {0x0168, 0x0301},
+	0x1E79:  {0x0169, 0x0301},
+	0x1E7A:  {0x016A, 0x0308},
+	0x1E7B:  {0x016B, 0x0308},
+	0x1E7C:  {0x0056, 0x0303},
+	0x1E7D:  {0x0076, 0x0303},
+	0x1E7E:  {0x0056, 0x0323},
+	0x1E7F:  {0x0076, 0x0323},
+	0x1E80:  {0x0057, 0x0300},
+	0x1E81:  {0x0077, 0x0300},
+	0x1E82:  {0x0057, 0x0301},
+	0x1E83:  {0x0077, 0x0301},
+	0x1E84:  {0x0057, 0x0308},
+	0x1E85:  {0x0077, 0x0308},
+	0x1E86:  {0x0057, 0x0307},
+	0x1E87:  {0x0077, 0x0307},
+	0x1E88:  {0x0057, 0x0323},
+	0x1E89:  {0x0077, 0x0323},
+	0x1E8A:  {0x0058, 0x0307},
+	0x1E8B:  {0x0078, 0x0307},
+	0x1E8C:  {0x0058, 0x0308},
+	0x1E8D:  {0x0078, 0x0308},
+	0x1E8E:  {0x0059, 0x0307},
+	0x1E8F:  {0x0079, 0x0307},
+	0x1E90:  {0x005A, 0x0302},
+	0x1E91:  {0x007A, 0x0302},
+	0x1E92:  {0x005A, 0x0323},
+	0x1E93:  {0x007A, 0x0323},
+	0x1E94:  {0x005A, 0x0331},
+	0x1E95:  {0x007A, 0x0331},
+	0x1E96:  {0x0068, 0x0331},
+	0x1E97:  {0x0074, 0x0308},
+	0x1E98:  {0x0077, 0x030A},
+	0x1E99:  {0x0079, 0x030A},
+	0x1E9B:  {0x017F, 0x0307},
+	0x1EA0:  {0x0041, 0x0323},
+	0x1EA1:  {0x0061, 0x0323},
+	0x1EA2:  {0x0041, 0x0309},
+	0x1EA3:  {0x0061, 0x0309},
+	0x1EA4:  {0x00C2, 0x0301},
+	0x1EA5:  {0x00E2, 0x0301},
+	0x1EA6:  {0x00C2, 0x0300},
+	0x1EA7:  {0x00E2, 0x0300},
+	0x1EA8:  {0x00C2, 0x0309},
+	0x1EA9:  {0x00E2, 0x0309},
+	0x1EAA:  {0x00C2, 0x0303},
+	0x1EAB:  {0x00E2, 0x0303},
+	0x1EAC:  {0x1EA0, 0x0302},
+	0x1EAD:  {0x1EA1, 0x0302},
+	0x1EAE:  {0x0102, 0x0301},
+	0x1EAF:  {0x0103, 0x0301},
+	0x1EB0:  {0x0102, 0x0300},
+	0x1EB1:  {0x0103, 0x0300},
+	0x1EB2:  {0x0102, 0x0309},
+	0x1EB3:  {0x0103, 0x0309},
+	0x1EB4:  {0x0102, 0x0303},
+	0x1EB5:  {0x0103, 0x0303},
+	0x1EB6:  {0x1EA0, 0x0306},
+	0x1EB7:  {0x1EA1, 0x0306},
+	0x1EB8:  {0x0045, 0x0323},
+	0x1EB9:  {0x0065, 0x0323},
+	0x1EBA:  {0x0045, 0x0309},
+	0x1EBB:  {0x0065, 0x0309},
+	0x1EBC:  {0x0045, 0x0303},
+	0x1EBD:  {0x0065, 0x0303},
+	0x1EBE:  {0x00CA, 0x0301},
+	0x1EBF:  {0x00EA, 0x0301},
+	0x1EC0:  {0x00CA, 0x0300},
+	0x1EC1:  {0x00EA, 0x0300},
+	0x1EC2:  {0x00CA, 0x0309},
+	0x1EC3:  {0x00EA, 0x0309},
+	0x1EC4:  {0x00CA, 0x0303},
+	0x1EC5:  {0x00EA, 0x0303},
+	0x1EC6:  {0x1EB8, 0x0302},
+	0x1EC7:  {0x1EB9, 0x0302},
+	0x1EC8:  {0x0049, 0x0309},
+	0x1EC9:  {0x0069, 0x0309},
+	0x1ECA:  {0x0049, 0x0323},
+	0x1ECB:  {0x0069, 0x0323},
+	0x1ECC:  {0x004F, 0x0323},
+	0x1ECD:  {0x006F, 0x0323},
+	0x1ECE:  {0x004F, 0x0309},
+	0x1ECF:  {0x006F, 0x0309},
+	0x1ED0:  {0x00D4, 0x0301},
+	0x1ED1:  {0x00F4, 0x0301},
+	0x1ED2:  {0x00D4, 0x0300},
+	0x1ED3:  {0x00F4, 0x0300},
+	0x1ED4:  {0x00D4, 0x0309},
+	0x1ED5:  {0x00F4, 0x0309},
+	0x1ED6:  {0x00D4, 0x0303},
+	0x1ED7:  {0x00F4, 0x0303},
+	0x1ED8:  {0x1ECC, 0x0302},
+	0x1ED9:  {0x1ECD, 0x0302},
+	0x1EDA:  {0x01A0, 0x0301},
+	0x1EDB:  {0x01A1, 0x0301},
+	0x1EDC:  {0x01A0, 0x0300},
+	0x1EDD:  {0x01A1, 0x0300},
+	0x1EDE:  {0x01A0, 0x0309},
+	0x1EDF:  {0x01A1, 0x0309},
+	0x1EE0:  {0x01A0, 0x0303},
+	0x1EE1:  {0x01A1, 0x0303},
+	0x1EE2:  {0x01A0, 0x0323},
+	0x1EE3:  {0x01A1, 0x0323},
+	0x1EE4:  {0x0055, 0x0323},
+	0x1EE5:  {0x0075, 0x0323},
+	0x1EE6:  {0x0055, 0x0309},
+	0x1EE7:  {0x0075, 0x0309},
+	0x1EE8:  {0x01AF, 0x0301},
+	0x1EE9:  {0x01B0, 0x0301},
+	0x1EEA:  {0x01AF, 0x0300},
+	0x1EEB:  {0x01B0, 0x0300},
+	0x1EEC:  {0x01AF, 0x0309},
+	0x1EED:  {0x01B0, 0x0309},
+	0x1EEE:  {0x01AF, 0x0303},
+	0x1EEF:  {0x01B0, 0x0303},
+	0x1EF0:  {0x01AF, 0x0323},
+	0x1EF1:  {0x01B0, 0x0323},
+	0x1EF2:  {0x0059, 0x0300},
+	0x1EF3:  {0x0079, 0x0300},
+	0x1EF4:  {0x0059, 0x0323},
+	0x1EF5:  {0x0079, 0x0323},
+	0x1EF6:  {0x0059, 0x0309},
+	0x1EF7:  {0x0079, 0x0309},
+	0x1EF8:  {0x0059, 0x0303},
+	0x1EF9:  {0x0079, 0x0303},
+	0x1F00:  {0x03B1, 0x0313},
+	0x1F01:  {0x03B1, 0x0314},
+	0x1F02:  {0x1F00, 0x0300},
+	0x1F03:  {0x1F01, 0x0300},
+	0x1F04:  {0x1F00, 0x0301},
+	0x1F05:  {0x1F01, 0x0301},
+	0x1F06:  {0x1F00, 0x0342},
+	0x1F07:  {0x1F01, 0x0342},
+	0x1F08:  {0x0391, 0x0313},
+	0x1F09:  {0x0391, 0x0314},
+	0x1F0A:  {0x1F08, 0x0300},
+	0x1F0B:  {0x1F09, 0x0300},
+	0x1F0C:  {0x1F08, 0x0301},
+	0x1F0D:  {0x1F09, 0x0301},
+	0x1F0E:  {0x1F08, 0x0342},
+	0x1F0F:  {0x1F09, 0x0342},
+	0x1F10:  {0x03B5, 0x0313},
+	0x1F11:  {0x03B5, 0x0314},
+	0x1F12:  {0x1F10, 0x0300},
+	0x1F13:  {0x1F11, 0x0300},
+	0x1F14:  {0x1F10, 0x0301},
+	0x1F15:  {0x1F11, 0x0301},
+	0x1F18:  {0x0395, 0x0313},
+	0x1F19:  {0x0395, 0x0314},
+	0x1F1A:  {0x1F18, 0x0300},
+	0x1F1B:  {0x1F19, 0x0300},
+	0x1F1C:  {0x1F18, 0x0301},
+	0x1F1D:  {0x1F19, 0x0301},
+	0x1F20:  {0x03B7, 0x0313},
+	0x1F21:  {0x03B7, 0x0314},
+	0x1F22:  {0x1F20, 0x0300},
+	0x1F23:  {0x1F21, 0x0300},
+	0x1F24:  {0x1F20, 0x0301},
+	0x1F25:  {0x1F21, 0x0301},
+	0x1F26:  {0x1F20, 0x0342},
+	0x1F27:  {0x1F21, 0x0342},
+	0x1F28:  {0x0397, 0x0313},
+	0x1F29:  {0x0397, 0x0314},
+	0x1F2A:  {0x1F28, 0x0300},
+	0x1F2B:  {0x1F29, 0x0300},
+	0x1F2C:  {0x1F28, 0x0301},
+	0x1F2D:  {0x1F29, 0x0301},
+	0x1F2E:  {0x1F28, 0x0342},
+	0x1F2F:  {0x1F29, 0x0342},
+	0x1F30:  {0x03B9, 0x0313},
+	0x1F31:  {0x03B9, 0x0314},
+	0x1F32:  {0x1F30, 0x0300},
+	0x1F33:  {0x1F31, 0x0300},
+	0x1F34:  {0x1F30, 0x0301},
+	0x1F35:  {0x1F31, 0x0301},
+	0x1F36:  {0x1F30, 0x0342},
+	0x1F37:  {0x1F31, 0x0342},
+	0x1F38:  {0x0399, 0x0313},
+	0x1F39:  {0x0399, 0x0314},
+	0x1F3A:  {0x1F38, 0x0300},
+	0x1F3B:  {0x1F39, 0x0300},
+	0x1F3C:  {0x1F38, 0x0301},
+	0x1F3D:  {0x1F39, 0x0301},
+	0x1F3E:  {0x1F38, 0x0342},
+	0x1F3F:  {0x1F39, 0x0342},
+	0x1F40:  {0x03BF, 0x0313},
+	0x1F41:  {0x03BF, 0x0314},
+	0x1F42:  {0x1F40, 0x0300},
+	0x1F43:  {0x1F41, 0x0300},
+	0x1F44:  {0x1F40, 0x0301},
+	0x1F45:  {0x1F41, 0x0301},
+	0x1F48:  {0x039F, 0x0313},
+	0x1F49:  {0x039F, 0x0314},
+	0x1F4A:  {0x1F48, 0x0300},
+	0x1F4B:  {0x1F49, 0x0300},
+	0x1F4C:  {0x1F48, 0x0301},
+	0x1F4D:  {0x1F49, 0x0301},
+	0x1F50:  {0x03C5, 0x0313},
+	0x1F51:  {0x03C5, 0x0314},
+	0x1F52:  {0x1F50, 0x0300},
+	0x1F53:  {0x1F51, 0x0300},
+	0x1F54:  {0x1F50, 0x0301},
+	0x1F55:  {0x1F51, 0x0301},
+	0x1F56:  {0x1F50, 0x0342},
+	0x1F57:  {0x1F51, 0x0342},
+	0x1F59:  {0x03A5, 0x0314},
+	0x1F5B:  {0x1F59, 0x0300},
+	0x1F5D:  {0x1F59, 0x0301},
+	0x1F5F:  {0x1F59, 0x0342},
+	0x1F60:  {0x03C9, 0x0313},
+	0x1F61:  {0x03C9, 0x0314},
+	0x1F62:  {0x1F60, 0x0300},
+	0x1F63:  {0x1F61, 0x0300},
+	0x1F64:  {0x1F60, 0x0301},
+	0x1F65:  {0x1F61, 0x0301},
+	0x1F66:  {0x1F60, 0x0342},
+	0x1F67:  {0x1F61, 0x0342},
+	0x1F68:  {0x03A9, 0x0313},
+	0x1F69:  {0x03A9, 0x0314},
+	0x1F6A:  {0x1F68, 0x0300},
+	0x1F6B:  {0x1F69, 0x0300},
+	0x1F6C:  {0x1F68, 0x0301},
+	0x1F6D:  {0x1F69, 0x0301},
+	0x1F6E:  {0x1F68, 0x0342},
+	0x1F6F:  {0x1F69, 0x0342},
+	0x1F70:  {0x03B1, 0x0300},
+	0x1F71:  {0x03AC},
+	0x1F72:  {0x03B5, 0x0300},
+	0x1F73:  {0x03AD},
+	0x1F74:  {0x03B7, 0x0300},
+	0x1F75:  {0x03AE},
+	0x1F76:  {0x03B9, 0x0300},
+	0x1F77:  {0x03AF},
+	0x1F78:  {0x03BF, 0x0300},
+	0x1F79:  {0x03CC},
+	0x1F7A:  {0x03C5, 0x0300},
+	0x1F7B:  {0x03CD},
+	0x1F7C:  {0x03C9, 0x0300},
+	0x1F7D:  {0x03CE},
+	0x1F80:  {0x1F00, 0x0345},
+	0x1F81:  {0x1F01, 0x0345},
+	0x1F82:  {0x1F02, 0x0345},
+	0x1F83:  {0x1F03, 0x0345},
+	0x1F84:  {0x1F04, 0x0345},
+	0x1F85:  {0x1F05, 0x0345},
+	0x1F86:  {0x1F06, 0x0345},
+	0x1F87:  {0x1F07, 0x0345},
+	0x1F88:  {0x1F08, 0x0345},
+	0x1F89:  {0x1F09, 0x0345},
+	0x1F8A:  {0x1F0A, 0x0345},
+	0x1F8B:  {0x1F0B, 0x0345},
+	0x1F8C:  {0x1F0C, 0x0345},
+	0x1F8D:  {0x1F0D, 0x0345},
+	0x1F8E:  {0x1F0E, 0x0345},
+	0x1F8F:  {0x1F0F, 0x0345},
+	0x1F90:  {0x1F20, 0x0345},
+	0x1F91:  {0x1F21, 0x0345},
+	0x1F92:  {0x1F22, 0x0345},
+	0x1F93:  {0x1F23, 0x0345},
+	0x1F94:  {0x1F24, 0x0345},
+	0x1F95:  {0x1F25, 0x0345},
+	0x1F96:  {0x1F26, 0x0345},
+	0x1F97:  {0x1F27, 0x0345},
+	0x1F98:  {0x1F28, 0x0345},
+	0x1F99:  {0x1F29, 0x0345},
+	0x1F9A:  {0x1F2A, 0x0345},
+	0x1F9B:  {0x1F2B, 0x0345},
+	0x1F9C:  {0x1F2C, 0x0345},
+	0x1F9D:  {0x1F2D, 0x0345},
+	0x1F9E:  {0x1F2E, 0x0345},
+	0x1F9F:  {0x1F2F, 0x0345},
+	0x1FA0:  {0x1F60, 0x0345},
+	0x1FA1:  {0x1F61, 0x0345},
+	0x1FA2:  {0x1F62, 0x0345},
+	0x1FA3:  {0x1F63, 0x0345},
+	0x1FA4:  {0x1F64, 0x0345},
+	0x1FA5:  {0x1F65, 0x0345},
+	0x1FA6:  {0x1F66, 0x0345},
+	0x1FA7:  {0x1F67, 0x0345},
+	0x1FA8:  {0x1F68, 0x0345},
+	0x1FA9:  {0x1F69, 0x0345},
+	0x1FAA:  {0x1F6A, 0x0345},
+	0x1FAB:  {0x1F6B, 0x0345},
+	0x1FAC:  {0x1F6C, 0x0345},
+	0x1FAD:  {0x1F6D, 0x0345},
+	0x1FAE:  {0x1F6E, 0x0345},
+	0x1FAF:  {0x1F6F, 0x0345},
+	0x1FB0:  {0x03B1, 0x0306},
+	0x1FB1:  {0x03B1, 0x0304},
+	0x1FB2:  {0x1F70, 0x0345},
+	0x1FB3:  {0x03B1, 0x0345},
+	0x1FB4:  {0x03AC, 0x0345},
+	0x1FB6:  {0x03B1, 0x0342},
+	0x1FB7:  {0x1FB6, 0x0345},
+	0x1FB8:  {0x0391, 0x0306},
+	0x1FB9:  {0x0391, 0x0304},
+	0x1FBA:  {0x0391, 0x0300},
+	0x1FBB:  {0x0386},
+	0x1FBC:  {0x0391, 0x0345},
+	0x1FBE:  {0x03B9},
+	0x1FC1:  {0x00A8, 0x0342},
+	0x1FC2:  {0x1F74, 0x0345},
+	0x1FC3:  {0x03B7, 0x0345},
+	0x1FC4:  {0x03AE, 0x0345},
+	0x1FC6:  {0x03B7, 0x0342},
+	0x1FC7:  {0x1FC6, 0x0345},
+	0x1FC8:  {0x0395, 0x0300},
+	0x1FC9:  {0x0388},
+	0x1FCA:  {0x0397, 0x0300},
+	0x1FCB:  {0x0389},
+	0x1FCC:  {0x0397, 0x0345},
+	0x1FCD:  {0x1FBF, 0x0300},
+	0x1FCE:  {0x1FBF, 0x0301},
+	0x1FCF:  {0x1FBF, 0x0342},
+	0x1FD0:  {0x03B9, 0x0306},
+	0x1FD1:  {0x03B9, 0x0304},
+	0x1FD2:  {0x03CA, 0x0300},
+	0x1FD3:  {0x0390},
+	0x1FD6:  {0x03B9, 0x0342},
+	0x1FD7:  {0x03CA, 0x0342},
+	0x1FD8:  {0x0399, 0x0306},
+	0x1FD9:  {0x0399, 0x0304},
+	0x1FDA:  {0x0399, 0x0300},
+	0x1FDB:  {0x038A},
+	0x1FDD:  {0x1FFE, 0x0300},
+	0x1FDE:  {0x1FFE, 0x0301},
+	0x1FDF:  {0x1FFE, 0x0342},
+	0x1FE0:  {0x03C5, 0x0306},
+	0x1FE1:  {0x03C5, 0x0304},
+	0x1FE2:  {0x03CB, 0x0300},
+	0x1FE3:  {0x03B0},
+	0x1FE4:  {0x03C1, 0x0313},
+	0x1FE5:  {0x03C1, 0x0314},
+	0x1FE6:  {0x03C5, 0x0342},
+	0x1FE7:  {0x03CB, 0x0342},
+	0x1FE8:  {0x03A5, 0x0306},
+	0x1FE9:  {0x03A5, 0x0304},
+	0x1FEA:  {0x03A5, 0x0300},
+	0x1FEB:  {0x038E},
+	0x1FEC:  {0x03A1, 0x0314},
+	0x1FED:  {0x00A8, 0x0300},
+	0x1FEE:  {0x0385},
+	0x1FEF:  {0x0060},
+	0x1FF2:  {0x1F7C, 0x0345},
+	0x1FF3:  {0x03C9, 0x0345},
+	0x1FF4:  {0x03CE, 0x0345},
+	0x1FF6:  {0x03C9, 0x0342},
+	0x1FF7:  {0x1FF6, 0x0345},
+	0x1FF8:  {0x039F, 0x0300},
+	0x1FF9:  {0x038C},
+	0x1FFA:  {0x03A9, 0x0300},
+	0x1FFB:  {0x038F},
+	0x1FFC:  {0x03A9, 0x0345},
+	0x1FFD:  {0x00B4},
+	0x2000:  {0x2002},
+	0x2001:  {0x2003},
+	0x2126:  {0x03A9},
+	0x212A:  {0x004B},
+	0x212B:  {0x00C5},
+	0x219A:  {0x2190, 0x0338},
+	0x219B:  {0x2192, 0x0338},
+	0x21AE:  {0x2194, 0x0338},
+	0x21CD:  {0x21D0, 0x0338},
+	0x21CE:  {0x21D4, 0x0338},
+	0x21CF:  {0x21D2, 0x0338},
+	0x2204:  {0x2203, 0x0338},
+	0x2209:  {0x2208, 0x0338},
+	0x220C:  {0x220B, 0x0338},
+	0x2224:  {0x2223, 0x0338},
+	0x2226:  {0x2225, 0x0338},
+	0x2241:  {0x223C, 0x0338},
+	0x2244:  {0x2243, 0x0338},
+	0x2247:  {0x2245, 0x0338},
+	0x2249:  {0x2248, 0x0338},
+	0x2260:  {0x003D, 0x0338},
+	0x2262:  {0x2261, 0x0338},
+	0x226D:  {0x224D, 0x0338},
+	0x226E:  {0x003C, 0x0338},
+	0x226F:  {0x003E, 0x0338},
+	0x2270:  {0x2264, 0x0338},
+	0x2271:  {0x2265, 0x0338},
+	0x2274:  {0x2272, 0x0338},
+	0x2275:  {0x2273, 0x0338},
+	0x2278:  {0x2276, 0x0338},
+	0x2279:  {0x2277, 0x0338},
+	0x2280:  {0x227A, 0x0338},
+	0x2281:  {0x227B, 0x0338},
+	0x2284:  {0x2282, 0x0338},
+	0x2285:  {0x2283, 0x0338},
+	0x2288:  {0x2286, 0x0338},
+	0x2289:  {0x2287, 0x0338},
+	0x22AC:  {0x22A2, 0x0338},
+	0x22AD:  {0x22A8, 0x0338},
+	0x22AE:  {0x22A9, 0x0338},
+	0x22AF:  {0x22AB, 0x0338},
+	0x22E0:  {0x227C, 0x0338},
+	0x22E1:  {0x227D, 0x0338},
+	0x22E2:  {0x2291, 0x0338},
+	0x22E3:  {0x2292, 0x0338},
+	0x22EA:  {0x22B2, 0x0338},
+	0x22EB:  {0x22B3, 0x0338},
+	0x22EC:  {0x22B4, 0x0338},
+	0x22ED:  {0x22B5, 0x0338},
+	0x2329:  {0x3008},
+	0x232A:  {0x3009},
+	0x2ADC:  {0x2ADD, 0x0338},
+	0x304C:  {0x304B, 0x3099},
+	0x304E:  {0x304D, 0x3099},
+	0x3050:  {0x304F, 0x3099},
+	0x3052:  {0x3051, 0x3099},
+	0x3054:  {0x3053, 0x3099},
+	0x3056:  {0x3055, 0x3099},
+	0x3058:  {0x3057, 0x3099},
+	0x305A:  {0x3059, 0x3099},
+	0x305C:  {0x305B, 0x3099},
+	0x305E:  {0x305D, 0x3099},
+	0x3060:  {0x305F, 0x3099},
+	0x3062:  {0x3061, 0x3099},
+	0x3065:  {0x3064, 0x3099},
+	0x3067:  {0x3066, 0x3099},
+	0x3069:  {0x3068, 0x3099},
+	0x3070:  {0x306F, 0x3099},
+	0x3071:  {0x306F, 0x309A},
+	0x3073:  {0x3072, 0x3099},
+	0x3074:  {0x3072, 0x309A},
+	0x3076:  {0x3075, 0x3099},
+	0x3077:  {0x3075, 0x309A},
+	0x3079:  {0x3078, 0x3099},
+	0x307A:  {0x3078, 0x309A},
+	0x307C:  {0x307B, 0x3099},
+	0x307D:  {0x307B, 0x309A},
+	0x3094:  {0x3046, 0x3099},
+	0x309E:  {0x309D, 0x3099},
+	0x30AC:  {0x30AB, 0x3099},
+	0x30AE:  {0x30AD, 0x3099},
+	0x30B0:  {0x30AF, 0x3099},
+	0x30B2:  {0x30B1, 0x3099},
+	0x30B4:  {0x30B3, 0x3099},
+	0x30B6:  {0x30B5, 0x3099},
+	0x30B8:  {0x30B7, 0x3099},
+	0x30BA:  {0x30B9, 0x3099},
+	0x30BC:  {0x30BB, 0x3099},
+	0x30BE:  {0x30BD, 0x3099},
+	0x30C0:  {0x30BF, 0x3099},
+	0x30C2:  {0x30C1, 0x3099},
+	0x30C5:  {0x30C4, 0x3099},
+	0x30C7:  {0x30C6, 0x3099},
+	0x30C9:  {0x30C8, 0x3099},
+	0x30D0:  {0x30CF, 0x3099},
+	0x30D1:  {0x30CF, 0x309A},
+	0x30D3:  {0x30D2, 0x3099},
+	0x30D4:  {0x30D2, 0x309A},
+	0x30D6:  {0x30D5, 0x3099},
+	0x30D7:  {0x30D5, 0x309A},
+	0x30D9:  {0x30D8, 0x3099},
+	0x30DA:  {0x30D8, 0x309A},
+	0x30DC:  {0x30DB, 0x3099},
+	0x30DD:  {0x30DB, 0x309A},
+	0x30F4:  {0x30A6, 0x3099},
+	0x30F7:  {0x30EF, 0x3099},
+	0x30F8:  {0x30F0, 0x3099},
+	0x30F9:  {0x30F1, 0x3099},
+	0x30FA:  {0x30F2, 0x3099},
+	0x30FE:  {0x30FD, 0x3099},
+	0xF900:  {0x8C48},
+	0xF901:  {0x66F4},
+	0xF902:  {0x8ECA},
+	0xF903:  {0x8CC8},
+	0xF904:  {0x6ED1},
+	0xF905:  {0x4E32},
+	0xF906:  {0x53E5},
+	0xF907:  {0x9F9C},
+	0xF908:  {0x9F9C},
+	0xF909:  {0x5951},
+	0xF90A:  {0x91D1},
+	0xF90B:  {0x5587},
+	0xF90C:  {0x5948},
+	0xF90D:  {0x61F6},
+	0xF90E:  {0x7669},
+	0xF90F:  {0x7F85},
+	0xF910:  {0x863F},
+	0xF911:  {0x87BA},
+	0xF912:  {0x88F8},
+	0xF913:  {0x908F},
+	0xF914:  {0x6A02},
+	0xF915:  {0x6D1B},
+	0xF916:  {0x70D9},
+	0xF917:  {0x73DE},
+	0xF918:  {0x843D},
+	0xF919:  {0x916A},
+	0xF91A:  {0x99F1},
+	0xF91B:  {0x4E82},
+	0xF91C:  {0x5375},
+	0xF91D:  {0x6B04},
+	0xF91E:  {0x721B},
+	0xF91F:  {0x862D},
+	0xF920:  {0x9E1E},
+	0xF921:  {0x5D50},
+	0xF922:  {0x6FEB},
+	0xF923:  {0x85CD},
+	0xF924:  {0x8964},
+	0xF925:  {0x62C9},
+	0xF926:  {0x81D8},
+	0xF927:  {0x881F},
+	0xF928:  {0x5ECA},
+	0xF929:  {0x6717},
+	0xF92A:  {0x6D6A},
+	0xF92B:  {0x72FC},
+	0xF92C:  {0x90CE},
+	0xF92D:  {0x4F86},
+	0xF92E:  {0x51B7},
+	0xF92F:  {0x52DE},
+	0xF930:  {0x64C4},
+	0xF931:  {0x6AD3},
+	0xF932:  {0x7210},
+	0xF933:  {0x76E7},
+	0xF934:  {0x8001},
+	0xF935:  {0x8606},
+	0xF936:  {0x865C},
+	0xF937:  {0x8DEF},
+	0xF938:  {0x9732},
+	0xF939:  {0x9B6F},
+	0xF93A:  {0x9DFA},
+	0xF93B:  {0x788C},
+	0xF93C:  {0x797F},
+	0xF93D:  {0x7DA0},
+	0xF93E:  {0x83C9},
+	0xF93F:  {0x9304},
+	0xF940:  {0x9E7F},
+	0xF941:  {0x8AD6},
+	0xF942:  {0x58DF},
+	0xF943:  {0x5F04},
+	0xF944:  {0x7C60},
+	0xF945:  {0x807E},
+	0xF946:  {0x7262},
+	0xF947:  {0x78CA},
+	0xF948:  {0x8CC2},
+	0xF949:  {0x96F7},
+	0xF94A:  {0x58D8},
+	0xF94B:  {0x5C62},
+	0xF94C:  {0x6A13},
+	0xF94D:  {0x6DDA},
+	0xF94E:  {0x6F0F},
+	0xF94F:  {0x7D2F},
+	0xF950:  {0x7E37},
+	0xF951:  {0x964B},
+	0xF952:  {0x52D2},
+	0xF953:  {0x808B},
+	0xF954:  {0x51DC},
+	0xF955:  {0x51CC},
+	0xF956:  {0x7A1C},
+	0xF957:  {0x7DBE},
+	0xF958:  {0x83F1},
+	0xF959:  {0x9675},
+	0xF95A:  {0x8B80},
+	0xF95B:  {0x62CF},
+	0xF95C:  {0x6A02},
+	0xF95D:  {0x8AFE},
+	0xF95E:  {0x4E39},
+	0xF95F:  {0x5BE7},
+	0xF960:  {0x6012},
+	0xF961:  {0x7387},
+	0xF962:  {0x7570},
+	0xF963:  {0x5317},
+	0xF964:  {0x78FB},
+	0xF965:  {0x4FBF},
+	0xF966:  {0x5FA9},
+	0xF967:  {0x4E0D},
+	0xF968:  {0x6CCC},
+	0xF969:  {0x6578},
+	0xF96A:  {0x7D22},
+	0xF96B:  {0x53C3},
+	0xF96C:  {0x585E},
+	0xF96D:  {0x7701},
+	0xF96E:  {0x8449},
+	0xF96F:  {0x8AAA},
+	0xF970:  {0x6BBA},
+	0xF971:  {0x8FB0},
+	0xF972:  {0x6C88},
+	0xF973:  {0x62FE},
+	0xF974:  {0x82E5},
+	0xF975:  {0x63A0},
+	0xF976:  {0x7565},
+	0xF977:  {0x4EAE},
+	0xF978:  {0x5169},
+	0xF979:  {0x51C9},
+	0xF97A:  {0x6881},
+	0xF97B:  {0x7CE7},
+	0xF97C:  {0x826F},
+	0xF97D:  {0x8AD2},
+	0xF97E:  {0x91CF},
+	0xF97F:  {0x52F5},
+	0xF980:  {0x5442},
+	0xF981:  {0x5973},
+	0xF982:  {0x5EEC},
+	0xF983:  {0x65C5},
+	0xF984:  {0x6FFE},
+	0xF985:  {0x792A},
+	0xF986:  {0x95AD},
+	0xF987:  {0x9A6A},
+	0xF988:  {0x9E97},
+	0xF989:  {0x9ECE},
+	0xF98A:  {0x529B},
+	0xF98B:  {0x66C6},
+	0xF98C:  {0x6B77},
+	0xF98D:  {0x8F62},
+	0xF98E:  {0x5E74},
+	0xF98F:  {0x6190},
+	0xF990:  {0x6200},
+	0xF991:  {0x649A},
+	0xF992:  {0x6F23},
+	0xF993:  {0x7149},
+	0xF994:  {0x7489},
+	0xF995:  {0x79CA},
+	0xF996:  {0x7DF4},
+	0xF997:  {0x806F},
+	0xF998:  {0x8F26},
+	0xF999:  {0x84EE},
+	0xF99A:  {0x9023},
+	0xF99B:  {0x934A},
+	0xF99C:  {0x5217},
+	0xF99D:  {0x52A3},
+	0xF99E:  {0x54BD},
+	0xF99F:  {0x70C8},
+	0xF9A0:  {0x88C2},
+	0xF9A1:  {0x8AAA},
+	0xF9A2:  {0x5EC9},
+	0xF9A3:  {0x5FF5},
+	0xF9A4:  {0x637B},
+	0xF9A5:  {0x6BAE},
+	0xF9A6:  {0x7C3E},
+	0xF9A7:  {0x7375},
+	0xF9A8:  {0x4EE4},
+	0xF9A9:  {0x56F9},
+	0xF9AA:  {0x5BE7},
+	0xF9AB:  {0x5DBA},
+	0xF9AC:  {0x601C},
+	0xF9AD:  {0x73B2},
+	0xF9AE:  {0x7469},
+	0xF9AF:  {0x7F9A},
+	0xF9B0:  {0x8046},
+	0xF9B1:  {0x9234},
+	0xF9B2:  {0x96F6},
+	0xF9B3:  {0x9748},
+	0xF9B4:  {0x9818},
+	0xF9B5:  {0x4F8B},
+	0xF9B6:  {0x79AE},
+	0xF9B7:  {0x91B4},
+	0xF9B8:  {0x96B8},
+	0xF9B9:  {0x60E1},
+	0xF9BA:  {0x4E86},
+	0xF9BB:  {0x50DA},
+	0xF9BC:  {0x5BEE},
+	0xF9BD:  {0x5C3F},
+	0xF9BE:  {0x6599},
+	0xF9BF:  {0x6A02},
+	0xF9C0:  {0x71CE},
+	0xF9C1:  {0x7642},
+	0xF9C2:  {0x84FC},
+	0xF9C3:  {0x907C},
+	0xF9C4:  {0x9F8D},
+	0xF9C5:  {0x6688},
+	0xF9C6:  {0x962E},
+	0xF9C7:  {0x5289},
+	0xF9C8:  {0x677B},
+	0xF9C9:  {0x67F3},
+	0xF9CA:  {0x6D41},
+	0xF9CB:  {0x6E9C},
+	0xF9CC:  {0x7409},
+	0xF9CD:  {0x7559},
+	0xF9CE:  {0x786B},
+	0xF9CF:  {0x7D10},
+	0xF9D0:  {0x985E},
+	0xF9D1:  {0x516D},
+	0xF9D2:  {0x622E},
+	0xF9D3:  {0x9678},
+	0xF9D4:  {0x502B},
+	0xF9D5:  {0x5D19},
+	0xF9D6:  {0x6DEA},
+	0xF9D7:  {0x8F2A},
+	0xF9D8:  {0x5F8B},
+	0xF9D9:  {0x6144},
+	0xF9DA:  {0x6817},
+	0xF9DB:  {0x7387},
+	0xF9DC:  {0x9686},
+	0xF9DD:  {0x5229},
+	0xF9DE:  {0x540F},
+	0xF9DF:  {0x5C65},
+	0xF9E0:  {0x6613},
+	0xF9E1:  {0x674E},
+	0xF9E2:  {0x68A8},
+	0xF9E3:  {0x6CE5},
+	0xF9E4:  {0x7406},
+	0xF9E5:  {0x75E2},
+	0xF9E6:  {0x7F79},
+	0xF9E7:  {0x88CF},
+	0xF9E8:  {0x88E1},
+	0xF9E9:  {0x91CC},
+	0xF9EA:  {0x96E2},
+	0xF9EB:  {0x533F},
+	0xF9EC:  {0x6EBA},
+	0xF9ED:  {0x541D},
+	0xF9EE:  {0x71D0},
+	0xF9EF:  {0x7498},
+	0xF9F0:  {0x85FA},
+	0xF9F1:  {0x96A3},
+	0xF9F2:  {0x9C57},
+	0xF9F3:  {0x9E9F},
+	0xF9F4:  {0x6797},
+	0xF9F5:  {0x6DCB},
+	0xF9F6:  {0x81E8},
+	0xF9F7:  {0x7ACB},
+	0xF9F8:  {0x7B20},
+	0xF9F9:  {0x7C92},
+	0xF9FA:  {0x72C0},
+	0xF9FB:  {0x7099},
+	0xF9FC:  {0x8B58},
+	0xF9FD:  {0x4EC0},
+	0xF9FE:  {0x8336},
+	0xF9FF:  {0x523A},
+	0xFA00:  {0x5207},
+	0xFA01:  {0x5EA6},
+	0xFA02:  {0x62D3},
+	0xFA03:  {0x7CD6},
+	0xFA04:  {0x5B85},
+	0xFA05:  {0x6D1E},
+	0xFA06:  {0x66B4},
+	0xFA07:  {0x8F3B},
+	0xFA08:  {0x884C},
+	0xFA09:  {0x964D},
+	0xFA0A:  {0x898B},
+	0xFA0B:  {0x5ED3},
+	0xFA0C:  {0x5140},
+	0xFA0D:  {0x55C0},
+	0xFA10:  {0x585A},
+	0xFA12:  {0x6674},
+	0xFA15:  {0x51DE},
+	0xFA16:  {0x732A},
+	0xFA17:  {0x76CA},
+	0xFA18:  {0x793C},
+	0xFA19:  {0x795E},
+	0xFA1A:  {0x7965},
+	0xFA1B:  {0x798F},
+	0xFA1C:  {0x9756},
+	0xFA1D:  {0x7CBE},
+	0xFA1E:  {0x7FBD},
+	0xFA20:  {0x8612},
+	0xFA22:  {0x8AF8},
+	0xFA25:  {0x9038},
+	0xFA26:  {0x90FD},
+	0xFA2A:  {0x98EF},
+	0xFA2B:  {0x98FC},
+	0xFA2C:  {0x9928},
+	0xFA2D:  {0x9DB4},
+	0xFA2E:  {0x90DE},
+	0xFA2F:  {0x96B7},
+	0xFA30:  {0x4FAE},
+	0xFA31:  {0x50E7},
+	0xFA32:  {0x514D},
+	0xFA33:  {0x52C9},
+	0xFA34:  {0x52E4},
+	0xFA35:  {0x5351},
+	0xFA36:  {0x559D},
+	0xFA37:  {0x5606},
+	0xFA38:  {0x5668},
+	0xFA39:  {0x5840},
+	0xFA3A:  {0x58A8},
+	0xFA3B:  {0x5C64},
+	0xFA3C:  {0x5C6E},
+	0xFA3D:  {0x6094},
+	0xFA3E:  {0x6168},
+	0xFA3F:  {0x618E},
+	0xFA40:  {0x61F2},
+	0xFA41:  {0x654F},
+	0xFA42:  {0x65E2},
+	0xFA43:  {0x6691},
+	0xFA44:  {0x6885},
+	0xFA45:  {0x6D77},
+	0xFA46:  {0x6E1A},
+	0xFA47:  {0x6F22},
+	0xFA48:  {0x716E},
+	0xFA49:  {0x722B},
+	0xFA4A:  {0x7422},
+	0xFA4B:  {0x7891},
+	0xFA4C:  {0x793E},
+	0xFA4D:  {0x7949},
+	0xFA4E:  {0x7948},
+	0xFA4F:  {0x7950},
+	0xFA50:  {0x7956},
+	0xFA51:  {0x795D},
+	0xFA52:  {0x798D},
+	0xFA53:  {0x798E},
+	0xFA54:  {0x7A40},
+	0xFA55:  {0x7A81},
+	0xFA56:  {0x7BC0},
+	0xFA57:  {0x7DF4},
+	0xFA58:  {0x7E09},
+	0xFA59:  {0x7E41},
+	0xFA5A:  {0x7F72},
+	0xFA5B:  {0x8005},
+	0xFA5C:  {0x81ED},
+	0xFA5D:  {0x8279},
+	0xFA5E:  {0x8279},
+	0xFA5F:  {0x8457},
+	0xFA60:  {0x8910},
+	0xFA61:  {0x8996},
+	0xFA62:  {0x8B01},
+	0xFA63:  {0x8B39},
+	0xFA64:  {0x8CD3},
+	0xFA65:  {0x8D08},
+	0xFA66:  {0x8FB6},
+	0xFA67:  {0x9038},
+	0xFA68:  {0x96E3},
+	0xFA69:  {0x97FF},
+	0xFA6A:  {0x983B},
+	0xFA6B:  {0x6075},
+	0xFA6C:  {0x242EE},
+	0xFA6D:  {0x8218},
+	0xFA70:  {0x4E26},
+	0xFA71:  {0x51B5},
+	0xFA72:  {0x5168},
+	0xFA73:  {0x4F80},
+	0xFA74:  {0x5145},
+	0xFA75:  {0x5180},
+	0xFA76:  {0x52C7},
+	0xFA77:  {0x52FA},
+	0xFA78:  {0x559D},
+	0xFA79:  {0x5555},
+	0xFA7A:  {0x5599},
+	0xFA7B:  {0x55E2},
+	0xFA7C:  {0x585A},
+	0xFA7D:  {0x58B3},
+	0xFA7E:  {0x5944},
+	0xFA7F:  {0x5954},
+	0xFA80:  {0x5A62},
+	0xFA81:  {0x5B28},
+	0xFA82:  {0x5ED2},
+	0xFA83:  {0x5ED9},
+	0xFA84:  {0x5F69},
+	0xFA85:  {0x5FAD},
+	0xFA86:  {0x60D8},
+	0xFA87:  {0x614E},
+	0xFA88:  {0x6108},
+	0xFA89:  {0x618E},
+	0xFA8A:  {0x6160},
+	0xFA8B:  {0x61F2},
+	0xFA8C:  {0x6234},
+	0xFA8D:  {0x63C4},
+	0xFA8E:  {0x641C},
+	0xFA8F:  {0x6452},
+	0xFA90:  {0x6556},
+	0xFA91:  {0x6674},
+	0xFA92:  {0x6717},
+	0xFA93:  {0x671B},
+	0xFA94:  {0x6756},
+	0xFA95:  {0x6B79},
+	0xFA96:  {0x6BBA},
+	0xFA97:  {0x6D41},
+	0xFA98:  {0x6EDB},
+	0xFA99:  {0x6ECB},
+	0xFA9A:  {0x6F22},
+	0xFA9B:  {0x701E},
+	0xFA9C:  {0x716E},
+	0xFA9D:  {0x77A7},
+	0xFA9E:  {0x7235},
+	0xFA9F:  {0x72AF},
+	0xFAA0:  {0x732A},
+	0xFAA1:  {0x7471},
+	0xFAA2:  {0x7506},
+	0xFAA3:  {0x753B},
+	0xFAA4:  {0x761D},
+	0xFAA5:  {0x761F},
+	0xFAA6:  {0x76CA},
+	0xFAA7:  {0x76DB},
+	0xFAA8:  {0x76F4},
+	0xFAA9:  {0x774A},
+	0xFAAA:  {0x7740},
+	0xFAAB:  {0x78CC},
+	0xFAAC:  {0x7AB1},
+	0xFAAD:  {0x7BC0},
+	0xFAAE:  {0x7C7B},
+	0xFAAF:  {0x7D5B},
+	0xFAB0:  {0x7DF4},
+	0xFAB1:  {0x7F3E},
+	0xFAB2:  {0x8005},
+	0xFAB3:  {0x8352},
+	0xFAB4:  {0x83EF},
+	0xFAB5:  {0x8779},
+	0xFAB6:  {0x8941},
+	0xFAB7:  {0x8986},
+	0xFAB8:  {0x8996},
+	0xFAB9:  {0x8ABF},
+	0xFABA:  {0x8AF8},
+	0xFABB:  {0x8ACB},
+	0xFABC:  {0x8B01},
+	0xFABD:  {0x8AFE},
+	0xFABE:  {0x8AED},
+	0xFABF:  {0x8B39},
+	0xFAC0:  {0x8B8A},
+	0xFAC1:  {0x8D08},
+	0xFAC2:  {0x8F38},
+	0xFAC3:  {0x9072},
+	0xFAC4:  {0x9199},
+	0xFAC5:  {0x9276},
+	0xFAC6:  {0x967C},
+	0xFAC7:  {0x96E3},
+	0xFAC8:  {0x9756},
+	0xFAC9:  {0x97DB},
+	0xFACA:  {0x97FF},
+	0xFACB:  {0x980B},
+	0xFACC:  {0x983B},
+	0xFACD:  {0x9B12},
+	0xFACE:  {0x9F9C},
+	0xFACF:  {0x2284A},
+	0xFAD0:  {0x22844},
+	0xFAD1:  {0x233D5},
+	0xFAD2:  {0x3B9D},
+	0xFAD3:  {0x4018},
+	0xFAD4:  {0x4039},
+	0xFAD5:  {0x25249},
+	0xFAD6:  {0x25CD0},
+	0xFAD7:  {0x27ED3},
+	0xFAD8:  {0x9F43},
+	0xFAD9:  {0x9F8E},
+	0xFB1D:  {0x05D9, 0x05B4},
+	0xFB1F:  {0x05F2, 0x05B7},
+	0xFB2A:  {0x05E9, 0x05C1},
+	0xFB2B:  {0x05E9, 0x05C2},
+	0xFB2C:  {0xFB49, 0x05C1},
+	0xFB2D:  {0xFB49, 0x05C2},
+	0xFB2E:  {0x05D0, 0x05B7},
+	0xFB2F:  {0x05D0, 0x05B8},
+	0xFB30:  {0x05D0, 0x05BC},
+	0xFB31:  {0x05D1, 0x05BC},
+	0xFB32:  {0x05D2, 0x05BC},
+	0xFB33:  {0x05D3, 0x05BC},
+	0xFB34:  {0x05D4, 0x05BC},
+	0xFB35:  {0x05D5, 0x05BC},
+	0xFB36:  {0x05D6, 0x05BC},
+	0xFB38:  {0x05D8, 0x05BC},
+	0xFB39:  {0x05D9, 0x05BC},
+	0xFB3A:  {0x05DA, 0x05BC},
+	0xFB3B:  {0x05DB, 0x05BC},
+	0xFB3C:  {0x05DC, 0x05BC},
+	0xFB3E:  {0x05DE, 0x05BC},
+	0xFB40:  {0x05E0, 0x05BC},
+	0xFB41:  {0x05E1, 0x05BC},
+	0xFB43:  {0x05E3, 0x05BC},
+	0xFB44:  {0x05E4, 0x05BC},
+	0xFB46:  {0x05E6, 0x05BC},
+	0xFB47:  {0x05E7, 0x05BC},
+	0xFB48:  {0x05E8, 0x05BC},
+	0xFB49:  {0x05E9, 0x05BC},
+	0xFB4A:  {0x05EA, 0x05BC},
+	0xFB4B:  {0x05D5, 0x05B9},
+	0xFB4C:  {0x05D1, 0x05BF},
+	0xFB4D:  {0x05DB, 0x05BF},
+	0xFB4E:  {0x05E4, 0x05BF},
+	0x1109A: {0x11099, 0x110BA},
+	0x1109C: {0x1109B, 0x110BA},
+	0x110AB: {0x110A5, 0x110BA},
+	0x1112E: {0x11131, 0x11127},
+	0x1112F: {0x11132, 0x11127},
+	0x1134B: {0x11347, 0x1133E},
+	0x1134C: {0x11347, 0x11357},
+	0x114BB: {0x114B9, 0x114BA},
+	0x114BC: {0x114B9, 0x114B0},
+	0x114BE: {0x114B9, 0x114BD},
+	0x115BA: {0x115B8, 0x115AF},
+	0x115BB: {0x115B9, 0x115AF},
+	0x11938: {0x11935, 0x11930},
+	0x1D15E: {0x1D157, 0x1D165},
+	0x1D15F: {0x1D158, 0x1D165},
+	0x1D160: {0x1D15F, 0x1D16E},
+	0x1D161: {0x1D15F, 0x1D16F},
+	0x1D162: {0x1D15F, 0x1D170},
+	0x1D163: {0x1D15F, 0x1D171},
+	0x1D164: {0x1D15F, 0x1D172},
+	0x1D1BB: {0x1D1B9, 0x1D165},
+	0x1D1BC: {0x1D1BA, 0x1D165},
+	0x1D1BD: {0x1D1BB, 0x1D16E},
+	0x1D1BE: {0x1D1BC, 0x1D16E},
+	0x1D1BF: {0x1D1BB, 0x1D16F},
+	0x1D1C0: {0x1D1BC, 0x1D16F},
+	0x2F800: {0x4E3D},
+	0x2F801: {0x4E38},
+	0x2F802: {0x4E41},
+	0x2F803: {0x20122},
+	0x2F804: {0x4F60},
+	0x2F805: {0x4FAE},
+	0x2F806: {0x4FBB},
+	0x2F807: {0x5002},
+	0x2F808: {0x507A},
+	0x2F809: {0x5099},
+	0x2F80A: {0x50E7},
+	0x2F80B: {0x50CF},
+	0x2F80C: {0x349E},
+	0x2F80D: {0x2063A},
+	0x2F80E: {0x514D},
+	0x2F80F: {0x5154},
+	0x2F810: {0x5164},
+	0x2F811: {0x5177},
+	0x2F812: {0x2051C},
+	0x2F813: {0x34B9},
+	0x2F814: {0x5167},
+	0x2F815: {0x518D},
+	0x2F816: {0x2054B},
+	0x2F817: {0x5197},
+	0x2F818: {0x51A4},
+	0x2F819: {0x4ECC},
+	0x2F81A: {0x51AC},
+	0x2F81B: {0x51B5},
+	0x2F81C: {0x291DF},
+	0x2F81D: {0x51F5},
+	0x2F81E: {0x5203},
+	0x2F81F: {0x34DF},
+	0x2F820: {0x523B},
+	0x2F821: {0x5246},
+	0x2F822: {0x5272},
+	0x2F823: {0x5277},
+	0x2F824: {0x3515},
+	0x2F825: {0x52C7},
+	0x2F826: {0x52C9},
+	0x2F827: {0x52E4},
+	0x2F828: {0x52FA},
+	0x2F829: {0x5305},
+	0x2F82A: {0x5306},
+	0x2F82B: {0x5317},
+	0x2F82C: {0x5349},
+	0x2F82D: {0x5351},
+	0x2F82E: {0x535A},
+	0x2F82F: {0x5373},
+	0x2F830: {0x537D},
+	0x2F831: {0x537F},
+	0x2F832: {0x537F},
+	0x2F833: {0x537F},
+	0x2F834: {0x20A2C},
+	0x2F835: {0x7070},
+	0x2F836: {0x53CA},
+	0x2F837: {0x53DF},
+	0x2F838: {0x20B63},
+	0x2F839: {0x53EB},
+	0x2F83A: {0x53F1},
+	0x2F83B: {0x5406},
+	0x2F83C: {0x549E},
+	0x2F83D: {0x5438},
+	0x2F83E: {0x5448},
+	0x2F83F: {0x5468},
+	0x2F840: {0x54A2},
+	0x2F841: {0x54F6},
+	0x2F842: {0x5510},
+	0x2F843: {0x5553},
+	0x2F844: {0x5563},
+	0x2F845: {0x5584},
+	0x2F846: {0x5584},
+	0x2F847: {0x5599},
+	0x2F848: {0x55AB},
+	0x2F849: {0x55B3},
+	0x2F84A: {0x55C2},
+	0x2F84B: {0x5716},
+	0x2F84C: {0x5606},
+	0x2F84D: {0x5717},
+	0x2F84E: {0x5651},
+	0x2F84F: {0x5674},
+	0x2F850: {0x5207},
+	0x2F851: {0x58EE},
+	0x2F852: {0x57CE},
+	0x2F853: {0x57F4},
+	0x2F854: {0x580D},
+	0x2F855: {0x578B},
+	0x2F856: {0x5832},
+	0x2F857: {0x5831},
+	0x2F858: {0x58AC},
+	0x2F859: {0x214E4},
+	0x2F85A: {0x58F2},
+	0x2F85B: {0x58F7},
+	0x2F85C: {0x5906},
+	0x2F85D: {0x591A},
+	0x2F85E: {0x5922},
+	0x2F85F: {0x5962},
+	0x2F860: {0x216A8},
+	0x2F861: {0x216EA},
+	0x2F862: {0x59EC},
+	0x2F863: {0x5A1B},
+	0x2F864: {0x5A27},
+	0x2F865: {0x59D8},
+	0x2F866: {0x5A66},
+	0x2F867: {0x36EE},
+	0x2F868: {0x36FC},
+	0x2F869: {0x5B08},
+	0x2F86A: {0x5B3E},
+	0x2F86B: {0x5B3E},
+	0x2F86C: {0x219C8},
+	0x2F86D: {0x5BC3},
+	0x2F86E: {0x5BD8},
+	0x2F86F: {0x5BE7},
+	0x2F870: {0x5BF3},
+	0x2F871: {0x21B18},
+	0x2F872: {0x5BFF},
+	0x2F873: {0x5C06},
+	0x2F874: {0x5F53},
+	0x2F875: {0x5C22},
+	0x2F876: {0x3781},
+	0x2F877: {0x5C60},
+	0x2F878: {0x5C6E},
+	0x2F879: {0x5CC0},
+	0x2F87A: {0x5C8D},
+	0x2F87B: {0x21DE4},
+	0x2F87C: {0x5D43},
+	0x2F87D: {0x21DE6},
+	0x2F87E: {0x5D6E},
+	0x2F87F: {0x5D6B},
+	0x2F880: {0x5D7C},
+	0x2F881: {0x5DE1},
+	0x2F882: {0x5DE2},
+	0x2F883: {0x382F},
+	0x2F884: {0x5DFD},
+	0x2F885: {0x5E28},
+	0x2F886: {0x5E3D},
+	0x2F887: {0x5E69},
+	0x2F888: {0x3862},
+	0x2F889: {0x22183},
+	0x2F88A: {0x387C},
+	0x2F88B: {0x5EB0},
+	0x2F88C: {0x5EB3},
+	0x2F88D: {0x5EB6},
+	0x2F88E: {0x5ECA},
+	0x2F88F: {0x2A392},
+	0x2F890: {0x5EFE},
+	0x2F891: {0x22331},
+	0x2F892: {0x22331},
+	0x2F893: {0x8201},
+	0x2F894: {0x5F22},
+	0x2F895: {0x5F22},
+	0x2F896: {0x38C7},
+	0x2F897: {0x232B8},
+	0x2F898: {0x261DA},
+	0x2F899: {0x5F62},
+	0x2F89A: {0x5F6B},
+	0x2F89B: {0x38E3},
+	0x2F89C: {0x5F9A},
+	0x2F89D: {0x5FCD},
+	0x2F89E: {0x5FD7},
+	0x2F89F: {0x5FF9},
+	0x2F8A0: {0x6081},
+	0x2F8A1: {0x393A},
+	0x2F8A2: {0x391C},
+	0x2F8A3: {0x6094},
+	0x2F8A4: {0x226D4},
+	0x2F8A5: {0x60C7},
+	0x2F8A6: {0x6148},
+	0x2F8A7: {0x614C},
+	0x2F8A8: {0x614E},
+	0x2F8A9: {0x614C},
+	0x2F8AA: {0x617A},
+	0x2F8AB: {0x618E},
+	0x2F8AC: {0x61B2},
+	0x2F8AD: {0x61A4},
+	0x2F8AE: {0x61AF},
+	0x2F8AF: {0x61DE},
+	0x2F8B0: {0x61F2},
+	0x2F8B1: {0x61F6},
+	0x2F8B2: {0x6210},
+	0x2F8B3: {0x621B},
+	0x2F8B4: {0x625D},
+	0x2F8B5: {0x62B1},
+	0x2F8B6: {0x62D4},
+	0x2F8B7: {0x6350},
+	0x2F8B8: {0x22B0C},
+	0x2F8B9: {0x633D},
+	0x2F8BA: {0x62FC},
+	0x2F8BB: {0x6368},
+	0x2F8BC: {0x6383},
+	0x2F8BD: {0x63E4},
+	0x2F8BE: {0x22BF1},
+	0x2F8BF: {0x6422},
+	0x2F8C0: {0x63C5},
+	0x2F8C1: {0x63A9},
+	0x2F8C2: {0x3A2E},
+	0x2F8C3: {0x6469},
+	0x2F8C4: {0x647E},
+	0x2F8C5: {0x649D},
+	0x2F8C6: {0x6477},
+	0x2F8C7: {0x3A6C},
+	0x2F8C8: {0x654F},
+	0x2F8C9: {0x656C},
+	0x2F8CA: {0x2300A},
+	0x2F8CB: {0x65E3},
+	0x2F8CC: {0x66F8},
+	0x2F8CD: {0x6649},
+	0x2F8CE: {0x3B19},
+	0x2F8CF: {0x6691},
+	0x2F8D0: {0x3B08},
+	0x2F8D1: {0x3AE4},
+	0x2F8D2: {0x5192},
+	0x2F8D3: {0x5195},
+	0x2F8D4: {0x6700},
+	0x2F8D5: {0x669C},
+	0x2F8D6: {0x80AD},
+	0x2F8D7: {0x43D9},
+	0x2F8D8: {0x6717},
+	0x2F8D9: {0x671B},
+	0x2F8DA: {0x6721},
+	0x2F8DB: {0x675E},
+	0x2F8DC: {0x6753},
+	0x2F8DD: {0x233C3},
+	0x2F8DE: {0x3B49},
+	0x2F8DF: {0x67FA},
+	0x2F8E0: {0x6785},
+	0x2F8E1: {0x6852},
+	0x2F8E2: {0x6885},
+	0x2F8E3: {0x2346D},
+	0x2F8E4: {0x688E},
+	0x2F8E5: {0x681F},
+	0x2F8E6: {0x6914},
+	0x2F8E7: {0x3B9D},
+	0x2F8E8: {0x6942},
+	0x2F8E9: {0x69A3},
+	0x2F8EA: {0x69EA},
+	0x2F8EB: {0x6AA8},
+	0x2F8EC: {0x236A3},
+	0x2F8ED: {0x6ADB},
+	0x2F8EE: {0x3C18},
+	0x2F8EF: {0x6B21},
+	0x2F8F0: {0x238A7},
+	0x2F8F1: {0x6B54},
+	0x2F8F2: {0x3C4E},
+	0x2F8F3: {0x6B72},
+	0x2F8F4: {0x6B9F},
+	0x2F8F5: {0x6BBA},
+	0x2F8F6: {0x6BBB},
+	0x2F8F7: {0x23A8D},
+	0x2F8F8: {0x21D0B},
+	0x2F8F9: {0x23AFA},
+	0x2F8FA: {0x6C4E},
+	0x2F8FB: {0x23CBC},
+	0x2F8FC: {0x6CBF},
+	0x2F8FD: {0x6CCD},
+	0x2F8FE: {0x6C67},
+	0x2F8FF: {0x6D16},
+	0x2F900: {0x6D3E},
+	0x2F901: {0x6D77},
+	0x2F902: {0x6D41},
+	0x2F903: {0x6D69},
+	0x2F904: {0x6D78},
+	0x2F905: {0x6D85},
+	0x2F906: {0x23D1E},
+	0x2F907: {0x6D34},
+	0x2F908: {0x6E2F},
+	0x2F909: {0x6E6E},
+	0x2F90A: {0x3D33},
+	0x2F90B: {0x6ECB},
+	0x2F90C: {0x6EC7},
+	0x2F90D: {0x23ED1},
+	0x2F90E: {0x6DF9},
+	0x2F90F: {0x6F6E},
+	0x2F910: {0x23F5E},
+	0x2F911: {0x23F8E},
+	0x2F912: {0x6FC6},
+	0x2F913: {0x7039},
+	0x2F914: {0x701E},
+	0x2F915: {0x701B},
+	0x2F916: {0x3D96},
+	0x2F917: {0x704A},
+	0x2F918: {0x707D},
+	0x2F919: {0x7077},
+	0x2F91A: {0x70AD},
+	0x2F91B: {0x20525},
+	0x2F91C: {0x7145},
+	0x2F91D: {0x24263},
+	0x2F91E: {0x719C},
+	0x2F91F: {0x243AB},
+	0x2F920: {0x7228},
+	0x2F921: {0x7235},
+	0x2F922: {0x7250},
+	0x2F923: {0x24608},
+	0x2F924: {0x7280},
+	0x2F925: {0x7295},
+	0x2F926: {0x24735},
+	0x2F927: {0x24814},
+	0x2F928: {0x737A},
+	0x2F929: {0x738B},
+	0x2F92A: {0x3EAC},
+	0x2F92B: {0x73A5},
+	0x2F92C: {0x3EB8},
+	0x2F92D: {0x3EB8},
+	0x2F92E: {0x7447},
+	0x2F92F: {0x745C},
+	0x2F930: {0x7471},
+	0x2F931: {0x7485},
+	0x2F932: {0x74CA},
+	0x2F933: {0x3F1B},
+	0x2F934: {0x7524},
+	0x2F935: {0x24C36},
+	0x2F936: {0x753E},
+	0x2F937: {0x24C92},
+	0x2F938: {0x7570},
+	0x2F939: {0x2219F},
+	0x2F93A: {0x7610},
+	0x2F93B: {0x24FA1},
+	0x2F93C: {0x24FB8},
+	0x2F93D: {0x25044},
+	0x2F93E: {0x3FFC},
+	0x2F93F: {0x4008},
+	0x2F940: {0x76F4},
+	0x2F941: {0x250F3},
+	0x2F942: {0x250F2},
+	0x2F943: {0x25119},
+	0x2F944: {0x25133},
+	0x2F945: {0x771E},
+	0x2F946: {0x771F},
+	0x2F947: {0x771F},
+	0x2F948: {0x774A},
+	0x2F949: {0x4039},
+	0x2F94A: {0x778B},
+	0x2F94B: {0x4046},
+	0x2F94C: {0x4096},
+	0x2F94D: {0x2541D},
+	0x2F94E: {0x784E},
+	0x2F94F: {0x788C},
+	0x2F950: {0x78CC},
+	0x2F951: {0x40E3},
+	0x2F952: {0x25626},
+	0x2F953: {0x7956},
+	0x2F954: {0x2569A},
+	0x2F955: {0x256C5},
+	0x2F956: {0x798F},
+	0x2F957: {0x79EB},
+	0x2F958: {0x412F},
+	0x2F959: {0x7A40},
+	0x2F95A: {0x7A4A},
+	0x2F95B: {0x7A4F},
+	0x2F95C: {0x2597C},
+	0x2F95D: {0x25AA7},
+	0x2F95E: {0x25AA7},
+	0x2F95F: {0x7AEE},
+	0x2F960: {0x4202},
+	0x2F961: {0x25BAB},
+	0x2F962: {0x7BC6},
+	0x2F963: {0x7BC9},
+	0x2F964: {0x4227},
+	0x2F965: {0x25C80},
+	0x2F966: {0x7CD2},
+	0x2F967: {0x42A0},
+	0x2F968: {0x7CE8},
+	0x2F969: {0x7CE3},
+	0x2F96A: {0x7D00},
+	0x2F96B: {0x25F86},
+	0x2F96C: {0x7D63},
+	0x2F96D: {0x4301},
+	0x2F96E: {0x7DC7},
+	0x2F96F: {0x7E02},
+	0x2F970: {0x7E45},
+	0x2F971: {0x4334},
+	0x2F972: {0x26228},
+	0x2F973: {0x26247},
+	0x2F974: {0x4359},
+	0x2F975: {0x262D9},
+	0x2F976: {0x7F7A},
+	0x2F977: {0x2633E},
+	0x2F978: {0x7F95},
+	0x2F979: {0x7FFA},
+	0x2F97A: {0x8005},
+	0x2F97B: {0x264DA},
+	0x2F97C: {0x26523},
+	0x2F97D: {0x8060},
+	0x2F97E: {0x265A8},
+	0x2F97F: {0x8070},
+	0x2F980: {0x2335F},
+	0x2F981: {0x43D5},
+	0x2F982: {0x80B2},
+	0x2F983: {0x8103},
+	0x2F984: {0x440B},
+	0x2F985: {0x813E},
+	0x2F986: {0x5AB5},
+	0x2F987: {0x267A7},
+	0x2F988: {0x267B5},
+	0x2F989: {0x23393},
+	0x2F98A: {0x2339C},
+	0x2F98B: {0x8201},
+	0x2F98C: {0x8204},
+	0x2F98D: {0x8F9E},
+	0x2F98E: {0x446B},
+	0x2F98F: {0x8291},
+	0x2F990: {0x828B},
+	0x2F991: {0x829D},
+	0x2F992: {0x52B3},
+	0x2F993: {0x82B1},
+	0x2F994: {0x82B3},
+	0x2F995: {0x82BD},
+	0x2F996: {0x82E6},
+	0x2F997: {0x26B3C},
+	0x2F998: {0x82E5},
+	0x2F999: {0x831D},
+	0x2F99A: {0x8363},
+	0x2F99B: {0x83AD},
+	0x2F99C: {0x8323},
+	0x2F99D: {0x83BD},
+	0x2F99E: {0x83E7},
+	0x2F99F: {0x8457},
+	0x2F9A0: {0x8353},
+	0x2F9A1: {0x83CA},
+	0x2F9A2: {0x83CC},
+	0x2F9A3: {0x83DC},
+	0x2F9A4: {0x26C36},
+	0x2F9A5: {0x26D6B},
+	0x2F9A6: {0x26CD5},
+	0x2F9A7: {0x452B},
+	0x2F9A8: {0x84F1},
+	0x2F9A9: {0x84F3},
+	0x2F9AA: {0x8516},
+	0x2F9AB: {0x273CA},
+	0x2F9AC: {0x8564},
+	0x2F9AD: {0x26F2C},
+	0x2F9AE: {0x455D},
+	0x2F9AF: {0x4561},
+	0x2F9B0: {0x26FB1},
+	0x2F9B1: {0x270D2},
+	0x2F9B2: {0x456B},
+	0x2F9B3: {0x8650},
+	0x2F9B4: {0x865C},
+	0x2F9B5: {0x8667},
+	0x2F9B6: {0x8669},
+	0x2F9B7: {0x86A9},
+	0x2F9B8: {0x8688},
+	0x2F9B9: {0x870E},
+	0x2F9BA: {0x86E2},
+	0x2F9BB: {0x8779},
+	0x2F9BC: {0x8728},
+	0x2F9BD: {0x876B},
+	0x2F9BE: {0x8786},
+	0x2F9BF: {0x45D7},
+	0x2F9C0: {0x87E1},
+	0x2F9C1: {0x8801},
+	0x2F9C2: {0x45F9},
+	0x2F9C3: {0x8860},
+	0x2F9C4: {0x8863},
+	0x2F9C5: {0x27667},
+	0x2F9C6: {0x88D7},
+	0x2F9C7: {0x88DE},
+	0x2F9C8: {0x4635},
+	0x2F9C9: {0x88FA},
+	0x2F9CA: {0x34BB},
+	0x2F9CB: {0x278AE},
+	0x2F9CC: {0x27966},
+	0x2F9CD: {0x46BE},
+	0x2F9CE: {0x46C7},
+	0x2F9CF: {0x8AA0},
+	0x2F9D0: {0x8AED},
+	0x2F9D1: {0x8B8A},
+	0x2F9D2: {0x8C55},
+	0x2F9D3: {0x27CA8},
+	0x2F9D4: {0x8CAB},
+	0x2F9D5: {0x8CC1},
+	0x2F9D6: {0x8D1B},
+	0x2F9D7: {0x8D77},
+	0x2F9D8: {0x27F2F},
+	0x2F9D9: {0x20804},
+	0x2F9DA: {0x8DCB},
+	0x2F9DB: {0x8DBC},
+	0x2F9DC: {0x8DF0},
+	0x2F9DD: {0x208DE},
+	0x2F9DE: {0x8ED4},
+	0x2F9DF: {0x8F38},
+	0x2F9E0: {0x285D2},
+	0x2F9E1: {0x285ED},
+	0x2F9E2: {0x9094},
+	0x2F9E3: {0x90F1},
+	0x2F9E4: {0x9111},
+	0x2F9E5: {0x2872E},
+	0x2F9E6: {0x911B},
+	0x2F9E7: {0x9238},
+	0x2F9E8: {0x92D7},
+	0x2F9E9: {0x92D8},
+	0x2F9EA: {0x927C},
+	0x2F9EB: {0x93F9},
+	0x2F9EC: {0x9415},
+	0x2F9ED: {0x28BFA},
+	0x2F9EE: {0x958B},
+	0x2F9EF: {0x4995},
+	0x2F9F0: {0x95B7},
+	0x2F9F1: {0x28D77},
+	0x2F9F2: {0x49E6},
+	0x2F9F3: {0x96C3},
+	0x2F9F4: {0x5DB2},
+	0x2F9F5: {0x9723},
+	0x2F9F6: {0x29145},
+	0x2F9F7: {0x2921A},
+	0x2F9F8: {0x4A6E},
+	0x2F9F9: {0x4A76},
+	0x2F9FA: {0x97E0},
+	0x2F9FB: {0x2940A},
+	0x2F9FC: {0x4AB2},
+	0x2F9FD: {0x29496},
+	0x2F9FE: {0x980B},
+	0x2F9FF: {0x980B},
+	0x2FA00: {0x9829},
+	0x2FA01: {0x295B6},
+	0x2FA02: {0x98E2},
+	0x2FA03: {0x4B33},
+	0x2FA04: {0x9929},
+	0x2FA05: {0x99A7},
+	0x2FA06: {0x99C2},
+	0x2FA07: {0x99FE},
+	0x2FA08: {0x4BCE},
+	0x2FA09: {0x29B30},
+	0x2FA0A: {0x9B12},
+	0x2FA0B: {0x9C40},
+	0x2FA0C: {0x9CFD},
+	0x2FA0D: {0x4CCE},
+	0x2FA0E: {0x4CED},
+	0x2FA0F: {0x9D67},
+	0x2FA10: {0x2A0CE},
+	0x2FA11: {0x4CF8},
+	0x2FA12: {0x2A105},
+	0x2FA13: {0x2A20E},
+	0x2FA14: {0x2A291},
+	0x2FA15: {0x9EBB},
+	0x2FA16: {0x4D56},
+	0x2FA17: {0x9EF9},
+	0x2FA18: {0x9EFE},
+	0x2FA19: {0x9F05},
+	0x2FA1A: {0x9F0F},
+	0x2FA1B: {0x9F16},
+	0x2FA1C: {0x9F3B},
+	0x2FA1D: {0x2A600},
+}
+
+// canonicalCompositions maps pairs to primary composites, leaving out
+// composition exclusions.
+var canonicalCompositions = map[[2]rune]rune{
+	{0x003C, 0x0338}:   0x226E,
+	{0x003D, 0x0338}:   0x2260,
+	{0x003E, 0x0338}:   0x226F,
+	{0x0041, 0x0300}:   0x00C0,
+	{0x0041, 0x0301}:   0x00C1,
+	{0x0041, 0x0302}:   0x00C2,
+	{0x0041, 0x0303}:   0x00C3,
+	{0x0041, 0x0304}:   0x0100,
+	{0x0041, 0x0306}:   0x0102,
+	{0x0041, 0x0307}:   0x0226,
+	{0x0041, 0x0308}:   0x00C4,
+	{0x0041, 0x0309}:   0x1EA2,
+	{0x0041, 0x030A}:   0x00C5,
+	{0x0041, 0x030C}:   0x01CD,
+	{0x0041, 0x030F}:   0x0200,
+	{0x0041, 0x0311}:   0x0202,
+	{0x0041, 0x0323}:   0x1EA0,
+	{0x0041, 0x0325}:   0x1E00,
+	{0x0041, 0x0328}:   0x0104,
+	{0x0042, 0x0307}:   0x1E02,
+	{0x0042, 0x0323}:   0x1E04,
+	{0x0042, 0x0331}:   0x1E06,
+	{0x0043, 0x0301}:   0x0106,
+	{0x0043, 0x0302}:   0x0108,
+	{0x0043, 0x0307}:   0x010A,
+	{0x0043, 0x030C}:   0x010C,
+	{0x0043, 0x0327}:   0x00C7,
+	{0x0044, 0x0307}:   0x1E0A,
+	{0x0044, 0x030C}:   0x010E,
+	{0x0044, 0x0323}:   0x1E0C,
+	{0x0044, 0x0327}:   0x1E10,
+	{0x0044, 0x032D}:   0x1E12,
+	{0x0044, 0x0331}:   0x1E0E,
+	{0x0045, 0x0300}:   0x00C8,
+	{0x0045, 0x0301}:   0x00C9,
+	{0x0045, 0x0302}:   0x00CA,
+	{0x0045, 0x0303}:   0x1EBC,
+	{0x0045, 0x0304}:   0x0112,
+	{0x0045, 0x0306}:   0x0114,
+	{0x0045, 0x0307}:   0x0116,
+	{0x0045, 0x0308}:   0x00CB,
+	{0x0045, 0x0309}:   0x1EBA,
+	{0x0045, 0x030C}:   0x011A,
+	{0x0045, 0x030F}:   0x0204,
+	{0x0045, 0x0311}:   0x0206,
+	{0x0045, 0x0323}:   0x1EB8,
+	{0x0045, 0x0327}:   0x0228,
+	{0x0045, 0x0328}:   0x0118,
+	{0x0045, 0x032D}:   0x1E18,
+	{0x0045, 0x0330}:   0x1E1A,
+	{0x0046, 0x0307}:   0x1E1E,
+	{0x0047, 0x0301}:   0x01F4,
+	{0x0047, 0x0302}:   0x011C,
+	{0x0047, 0x0304}:   0x1E20,
+	{0x0047, 0x0306}:   0x011E,
+	{0x0047, 0x0307}:   0x0120,
+	{0x0047, 0x030C}:   0x01E6,
+	{0x0047, 0x0327}:   0x0122,
+	{0x0048, 0x0302}:   0x0124,
+	{0x0048, 0x0307}:   0x1E22,
+	{0x0048, 0x0308}:   0x1E26,
+	{0x0048, 0x030C}:   0x021E,
+	{0x0048, 0x0323}:   0x1E24,
+	{0x0048, 0x0327}:   0x1E28,
+	{0x0048, 0x032E}:   0x1E2A,
+	{0x0049, 0x0300}:   0x00CC,
+	{0x0049, 0x0301}:   0x00CD,
+	{0x0049, 0x0302}:   0x00CE,
+	{0x0049, 0x0303}:   0x0128,
+	{0x0049, 0x0304}:   0x012A,
+	{0x0049, 0x0306}:   0x012C,
+	{0x0049, 0x0307}:   0x0130,
+	{0x0049, 0x0308}:   0x00CF,
+	{0x0049, 0x0309}:   0x1EC8,
+	{0x0049, 0x030C}:   0x01CF,
+	{0x0049, 0x030F}:   0x0208,
+	{0x0049, 0x0311}:   0x020A,
+	{0x0049, 0x0323}:   0x1ECA,
+	{0x0049, 0x0328}:   0x012E,
+	{0x0049, 0x0330}:   0x1E2C,
+	{0x004A, 0x0302}:   0x0134,
+	{0x004B, 0x0301}:   0x1E30,
+	{0x004B, 0x030C}:   0x01E8,
+	{0x004B, 0x0323}:   0x1E32,
+	{0x004B, 0x0327}:   0x0136,
+	{0x004B, 0x0331}:   0x1E34,
+	{0x004C, 0x0301}:   0x0139,
+	{0x004C, 0x030C}:   0x013D,
+	{0x004C, 0x0323}:   0x1E36,
+	{0x004C, 0x0327}:   0x013B,
+	{0x004C, 0x032D}:   0x1E3C,
+	{0x004C, 0x0331}:   0x1E3A,
+	{0x004D, 0x0301}:   0x1E3E,
+	{0x004D, 0x0307}:   0x1E40,
+	{0x004D, 0x0323}:   0x1E42,
+	{0x004E, 0x0300}:   0x01F8,
+	{0x004E, 0x0301}:   0x0143,
+	{0x004E, 0x0303}:   0x00D1,
+	{0x004E, 0x0307}:   0x1E44,
+	{0x004E, 0x030C}:   0x0147,
+	{0x004E, 0x0323}:   0x1E46,
+	{0x004E, 0x0327}:   0x0145,
+	{0x004E, 0x032D}:   0x1E4A,
+	{0x004E, 0x0331}:   0x1E48,
+	{0x004F, 0x0300}:   0x00D2,
+	{0x004F, 0x0301}:   0x00D3,
+	{0x004F, 0x0302}:   0x00D4,
+	{0x004F, 0x0303}:   0x00D5,
+	{0x004F, 0x0304}:   0x014C,
+	{0x004F, 0x0306}:   0x014E,
+	{0x004F, 0x0307}:   0x022E,
+	{0x004F, 0x0308}:   0x00D6,
+	{0x004F, 0x0309}:   0x1ECE,
+	{0x004F, 0x030B}:   0x0150,
+	{0x004F, 0x030C}:   0x01D1,
+	{0x004F, 0x030F}:   0x020C,
+	{0x004F, 0x0311}:   0x020E,
+	{0x004F, 0x031B}:   0x01A0,
+	{0x004F, 0x0323}:   0x1ECC,
+	{0x004F, 0x0328}:   0x01EA,
+	{0x0050, 0x0301}:   0x1E54,
+	{0x0050, 0x0307}:   0x1E56,
+	{0x0052, 0x0301}:   0x0154,
+	{0x0052, 0x0307}:   0x1E58,
+	{0x0052, 0x030C}:   0x0158,
+	{0x0052, 0x030F}:   0x0210,
+	{0x0052, 0x0311}:   0x0212,
+	{0x0052, 0x0323}:   0x1E5A,
+	{0x0052, 0x0327}:   0x0156,
+	{0x0052, 0x0331}:   0x1E5E,
+	{0x0053, 0x0301}:   0x015A,
+	{0x0053, 0x0302}:   0x015C,
+	{0x0053, 0x0307}:   0x1E60,
+	{0x0053, 0x030C}:   0x0160,
+	{0x0053, 0x0323}:   0x1E62,
+	{0x0053, 0x0326}:   0x0218,
+	{0x0053, 0x0327}:   0x015E,
+	{0x0054, 0x0307}:   0x1E6A,
+	{0x0054, 0x030C}:   0x0164,
+	{0x0054, 0x0323}:   0x1E6C,
+	{0x0054, 0x0326}:   0x021A,
+	{0x0054, 0x0327}:   0x0162,
+	{0x0054, 0x032D}:   0x1E70,
+	{0x0054, 0x0331}:   0x1E6E,
+	{0x0055, 0x0300}:   0x00D9,
+	{0x0055, 0x0301}:   0x00DA,
+	{0x0055, 0x0302}:   0x00DB,
+	{0x0055, 0x0303}:   0x0168,
+	{0x0055, 0x0304}:   0x016A,
+	{0x0055, 0x0306}:   0x016C,
+	{0x0055, 0x0308}:   0x00DC,
+	{0x0055, 0x0309}:   0x1EE6,
+	{0x0055, 0x030A}:   0x016E,
+	{0x0055, 0x030B}:   0x0170,
+	{0x0055, 0x030C}:   0x01D3,
+	{0x0055, 0x030F}:   0x0214,
+	{0x0055, 0x0311}:   0x0216,
+	{0x0055, 0x031B}:   0x01AF,
+	{0x0055, 0x0323}:   0x1EE4,
+	{0x0055, 0x0324}:   0x1E72,
+	{0x0055, 0x0328}:   0x0172,
+	{0x0055, 0x032D}:   0x1E76,
+	{0x0055, 0x0330}:   0x1E74,
+	{0x0056, 0x0303}:   0x1E7C,
+	{0x0056, 0x0323}:   0x1E7E,
+	{0x0057, 0x0300}:   0x1E80,
+	{0x0057, 0x0301}:   0x1E82,
+	{0x0057, 0x0302}:   0x0174,
+	{0x0057, 0x0307}:   0x1E86,
+	{0x0057, 0x0308}:   0x1E84,
+	{0x0057, 0x0323}:   0x1E88,
+	{0x0058, 0x0307}:   0x1E8A,
+	{0x0058, 0x0308}:   0x1E8C,
+	{0x0059, 0x0300}:   0x1EF2,
+	{0x0059, 0x0301}:   0x00DD,
+	{0x0059, 0x0302}:   0x0176,
+	{0x0059, 0x0303}:   0x1EF8,
+	{0x0059, 0x0304}:   0x0232,
+	{0x0059, 0x0307}:   0x1E8E,
+	{0x0059, 0x0308}:   0x0178,
+	{0x0059, 0x0309}:   0x1EF6,
+	{0x0059, 0x0323}:   0x1EF4,
+	{0x005A, 0x0301}:   0x0179,
+	{0x005A, 0x0302}:   0x1E90,
+	{0x005A, 0x0307}:   0x017B,
+	{0x005A, 0x030C}:   0x017D,
+	{0x005A, 0x0323}:   0x1E92,
+	{0x005A, 0x0331}:   0x1E94,
+	{0x0061, 0x0300}:   0x00E0,
+	{0x0061, 0x0301}:   0x00E1,
+	{0x0061, 0x0302}:   0x00E2,
+	{0x0061, 0x0303}:   0x00E3,
+	{0x0061, 0x0304}:   0x0101,
+	{0x0061, 0x0306}:   0x0103,
+	{0x0061, 0x0307}:   0x0227,
+	{0x0061, 0x0308}:   0x00E4,
+	{0x0061, 0x0309}:   0x1EA3,
+	{0x0061, 0x030A}:   0x00E5,
+	{0x0061, 0x030C}:   0x01CE,
+	{0x0061, 0x030F}:   0x0201,
+	{0x0061, 0x0311}:   0x0203,
+	{0x0061, 0x0323}:   0x1EA1,
+	{0x0061, 0x0325}:   0x1E01,
+	{0x0061, 0x0328}:   0x0105,
+	{0x0062, 0x0307}:   0x1E03,
+	{0x0062, 0x0323}:   0x1E05,
+	{0x0062, 0x0331}:   0x1E07,
+	{0x0063, 0x0301}:   0x0107,
+	{0x0063, 0x0302}:   0x0109,
+	{0x0063, 0x0307}:   0x010B,
+	{0x0063, 0x030C}:   0x010D,
+	{0x0063, 0x0327}:   0x00E7,
+	{0x0064, 0x0307}:   0x1E0B,
+	{0x0064, 0x030C}:   0x010F,
+	{0x0064, 0x0323}:   0x1E0D,
+	{0x0064, 0x0327}:   0x1E11,
+	{0x0064, 0x032D}:   0x1E13,
+	{0x0064, 0x0331}:   0x1E0F,
+	{0x0065, 0x0300}:   0x00E8,
+	{0x0065, 0x0301}:   0x00E9,
+	{0x0065, 0x0302}:   0x00EA,
+	{0x0065, 0x0303}:   0x1EBD,
+	{0x0065, 0x0304}:   0x0113,
+	{0x0065, 0x0306}:   0x0115,
+	{0x0065, 0x0307}:   0x0117,
+	{0x0065, 0x0308}:   0x00EB,
+	{0x0065, 0x0309}:   0x1EBB,
+	{0x0065, 0x030C}:   0x011B,
+	{0x0065, 0x030F}:   0x0205,
+	{0x0065, 0x0311}:   0x0207,
+	{0x0065, 0x0323}:   0x1EB9,
+	{0x0065, 0x0327}:   0x0229,
+	{0x0065, 0x0328}:   0x0119,
+	{0x0065, 0x032D}:   0x1E19,
+	{0x0065, 0x0330}:   0x1E1B,
+	{0x0066, 0x0307}:   0x1E1F,
+	{0x0067, 0x0301}:   0x01F5,
+	{0x0067, 0x0302}:   0x011D,
+	{0x0067, 0x0304}:   0x1E21,
+	{0x0067, 0x0306}:   0x011F,
+	{0x0067, 0x0307}:   0x0121,
+	{0x0067, 0x030C}:   0x01E7,
+	{0x0067, 0x0327}:   0x0123,
+	{0x0068, 0x0302}:   0x0125,
+	{0x0068, 0x0307}:   0x1E23,
+	{0x0068, 0x0308}:   0x1E27,
+	{0x0068, 0x030C}:   0x021F,
+	{0x0068, 0x0323}:   0x1E25,
+	{0x0068, 0x0327}:   0x1E29,
+	{0x0068, 0x032E}:   0x1E2B,
+	{0x0068, 0x0331}:   0x1E96,
+	{0x0069, 0x0300}:   0x00EC,
+	{0x0069, 0x0301}:   0x00ED,
+	{0x0069, 0x0302}:   0x00EE,
+	{0x0069, 0x0303}:   0x0129,
+	{0x0069, 0x0304}:   0x012B,
+	{0x0069, 0x0306}:   0x012D,
+	{0x0069, 0x0308}:   0x00EF,
+	{0x0069, 0x0309}:   0x1EC9,
+	{0x0069, 0x030C}:   0x01D0,
+	{0x0069, 0x030F}:   0x0209,
+	{0x0069, 0x0311}:   0x020B,
+	{0x0069, 0x0323}:   0x1ECB,
+	{0x0069, 0x0328}:   0x012F,
+	{0x0069, 0x0330}:   0x1E2D,
+	{0x006A, 0x0302}:   0x0135,
+	{0x006A, 0x030C}:   0x01F0,
+	{0x006B, 0x0301}:   0x1E31,
+	{0x006B, 0x030C}:   0x01E9,
+	{0x006B, 0x0323}:   0x1E33,
+	{0x006B, 0x0327}:   0x0137,
+	{0x006B, 0x0331}:   0x1E35,
+	{0x006C, 0x0301}:   0x013A,
+	{0x006C, 0x030C}:   0x013E,
+	{0x006C, 0x0323}:   0x1E37,
+	{0x006C, 0x0327}:   0x013C,
+	{0x006C, 0x032D}:   0x1E3D,
+	{0x006C, 0x0331}:   0x1E3B,
+	{0x006D, 0x0301}:   0x1E3F,
+	{0x006D, 0x0307}:   0x1E41,
+	{0x006D, 0x0323}:   0x1E43,
+	{0x006E, 0x0300}:   0x01F9,
+	{0x006E, 0x0301}:   0x0144,
+	{0x006E, 0x0303}:   0x00F1,
+	{0x006E, 0x0307}:   0x1E45,
+	{0x006E, 0x030C}:   0x0148,
+	{0x006E, 0x0323}:   0x1E47,
+	{0x006E, 0x0327}:   0x0146,
+	{0x006E, 0x032D}:   0x1E4B,
+	{0x006E, 0x0331}:   0x1E49,
+	{0x006F, 0x0300}:   0x00F2,
+	{0x006F, 0x0301}:   0x00F3,
+	{0x006F, 0x0302}:   0x00F4,
+	{0x006F, 0x0303}:   0x00F5,
+	{0x006F, 0x0304}:   0x014D,
+	{0x006F, 0x0306}:   0x014F,
+	{0x006F, 0x0307}:   0x022F,
+	{0x006F, 0x0308}:   0x00F6,
+	{0x006F, 0x0309}:   0x1ECF,
+	{0x006F, 0x030B}:   0x0151,
+	{0x006F, 0x030C}:   0x01D2,
+	{0x006F, 0x030F}:   0x020D,
+	{0x006F, 0x0311}:   0x020F,
+	{0x006F, 0x031B}:   0x01A1,
+	{0x006F, 0x0323}:   0x1ECD,
+	{0x006F, 0x0328}:   0x01EB,
+	{0x0070, 0x0301}:   0x1E55,
+	{0x0070, 0x0307}:   0x1E57,
+	{0x0072, 0x0301}:   0x0155,
+	{0x0072, 0x0307}:   0x1E59,
+	{0x0072, 0x030C}:   0x0159,
+	{0x0072, 0x030F}:   0x0211,
+	{0x0072, 0x0311}:   0x0213,
+	{0x0072, 0x0323}:   0x1E5B,
+	{0x0072, 0x0327}:   0x0157,
+	{0x0072, 0x0331}:   0x1E5F,
+	{0x0073, 0x0301}:   0x015B,
+	{0x0073, 0x0302}:   0x015D,
+	{0x0073, 0x0307}:   0x1E61,
+	{0x0073, 0x030C}:   0x0161,
+	{0x0073, 0x0323}:   0x1E63,
+	{0x0073, 0x0326}:   0x0219,
+	{0x0073, 0x0327}:   0x015F,
+	{0x0074, 0x0307}:   0x1E6B,
+	{0x0074, 0x0308}:   0x1E97,
+	{0x0074, 0x030C}:   0x0165,
+	{0x0074, 0x0323}:   0x1E6D,
+	{0x0074, 0x0326}:   0x021B,
+	{0x0074, 0x0327}:   0x0163,
+	{0x0074, 0x032D}:   0x1E71,
+	{0x0074, 0x0331}:   0x1E6F,
+	{0x0075, 0x0300}:   0x00F9,
+	{0x0075, 0x0301}:   0x00FA,
+	{0x0075, 0x0302}:   0x00FB,
+	{0x0075, 0x0303}:   0x0169,
+	{0x0075, 0x0304}:   0x016B,
+	{0x0075, 0x0306}:   0x016D,
+	{0x0075, 0x0308}:   0x00FC,
+	{0x0075, 0x0309}:   0x1EE7,
+	{0x0075, 0x030A}:   0x016F,
+	{0x0075, 0x030B}:   0x0171,
+	{0x0075, 0x030C}:   0x01D4,
+	{0x0075, 0x030F}:   0x0215,
+	{0x0075, 0x0311}:   0x0217,
+	{0x0075, 0x031B}:   0x01B0,
+	{0x0075, 0x0323}:   0x1EE5,
+	{0x0075, 0x0324}:   0x1E73,
+	{0x0075, 0x0328}:   0x0173,
+	{0x0075, 0x032D}:   0x1E77,
+	{0x0075, 0x0330}:   0x1E75,
+	{0x0076, 0x0303}:   0x1E7D,
+	{0x0076, 0x0323}:   0x1E7F,
+	{0x0077, 0x0300}:   0x1E81,
+	{0x0077, 0x0301}:   0x1E83,
+	{0x0077, 0x0302}:   0x0175,
+	{0x0077, 0x0307}:   0x1E87,
+	{0x0077, 0x0308}:   0x1E85,
+	{0x0077, 0x030A}:   0x1E98,
+	{0x0077, 0x0323}:   0x1E89,
+	{0x0078, 0x0307}:   0x1E8B,
+	{0x0078, 0x0308}:   0x1E8D,
+	{0x0079, 0x0300}:   0x1EF3,
+	{0x0079, 0x0301}:   0x00FD,
+	{0x0079, 0x0302}:   0x0177,
+	{0x0079, 0x0303}:   0x1EF9,
+	{0x0079, 0x0304}:   0x0233,
+	{0x0079, 0x0307}:   0x1E8F,
+	{0x0079, 0x0308}:   0x00FF,
+	{0x0079, 0x0309}:   0x1EF7,
+	{0x0079, 0x030A}:   0x1E99,
+	{0x0079, 0x0323}:   0x1EF5,
+	{0x007A, 0x0301}:   0x017A,
+	{0x007A, 0x0302}:   0x1E91,
+	{0x007A, 0x0307}:   0x017C,
+	{0x007A, 0x030C}:   0x017E,
+	{0x007A, 0x0323}:   0x1E93,
+	{0x007A, 0x0331}:   0x1E95,
+	{0x00A8, 0x0300}:   0x1FED,
+	{0x00A8, 0x0301}:   0x0385,
+	{0x00A8, 0x0342}:   0x1FC1,
+	{0x00C2, 0x0300}:   0x1EA6,
+	{0x00C2, 0x0301}:   0x1EA4,
+	{0x00C2, 0x0303}:   0x1EAA,
+	{0x00C2, 0x0309}:   0x1EA8,
+	{0x00C4, 0x0304}:   0x01DE,
+	{0x00C5, 0x0301}:   0x01FA,
+	{0x00C6, 0x0301}:   0x01FC,
+	{0x00C6, 0x0304}:   0x01E2,
+	{0x00C7, 0x0301}:   0x1E08,
+	{0x00CA, 0x0300}:   0x1EC0,
+	{0x00CA, 0x0301}:   0x1EBE,
+	{0x00CA, 0x0303}:   0x1EC4,
+	{0x00CA, 0x0309}:   0x1EC2,
+	{0x00CF, 0x0301}:   0x1E2E,
+	{0x00D4, 0x0300}:   0x1ED2,
+	{0x00D4, 0x0301}:   0x1ED0,
+	{0x00D4, 0x0303}:   0x1ED6,
+	{0x00D4, 0x0309}:   0x1ED4,
+	{0x00D5, 0x0301}:   0x1E4C,
+	{0x00D5, 0x0304}:   0x022C,
+	{0x00D5, 0x0308}:   0x1E4E,
+	{0x00D6, 0x0304}:   0x022A,
+	{0x00D8, 0x0301}:   0x01FE,
+	{0x00DC, 0x0300}:   0x01DB,
+	{0x00DC, 0x0301}:   0x01D7,
+	{0x00DC, 0x0304}:   0x01D5,
+	{0x00DC, 0x030C}:   0x01D9,
+	{0x00E2, 0x0300}:   0x1EA7,
+	{0x00E2, 0x0301}:   0x1EA5,
+	{0x00E2, 0x0303}:   0x1EAB,
+	{0x00E2, 0x0309}:   0x1EA9,
+	{0x00E4, 0x0304}:   0x01DF,
+	{0x00E5, 0x0301}:   0x01FB,
+	{0x00E6, 0x0301}:   0x01FD,
+	{0x00E6, 0x0304}:   0x01E3,
+	{0x00E7, 0x0301}:   0x1E09,
+	{0x00EA, 0x0300}:   0x1EC1,
+	{0x00EA, 0x0301}:   0x1EBF,
+	{0x00EA, 0x0303}:   0x1EC5,
+	{0x00EA, 0x0309}:   0x1EC3,
+	{0x00EF, 0x0301}:   0x1E2F,
+	{0x00F4, 0x0300}:   0x1ED3,
+	{0x00F4, 0x0301}:   0x1ED1,
+	{0x00F4, 0x0303}:   0x1ED7,
+	{0x00F4, 0x0309}:   0x1ED5,
+	{0x00F5, 0x0301}:   0x1E4D,
+	{0x00F5, 0x0304}:   0x022D,
+	{0x00F5, 0x0308}:   0x1E4F,
+	{0x00F6, 0x0304}:   0x022B,
+	{0x00F8, 0x0301}:   0x01FF,
+	{0x00FC, 0x0300}:   0x01DC,
+	{0x00FC, 0x0301}:   0x01D8,
+	{0x00FC, 0x0304}:   0x01D6,
+	{0x00FC, 0x030C}:   0x01DA,
+	{0x0102, 0x0300}:   0x1EB0,
+	{0x0102, 0x0301}:   0x1EAE,
+	{0x0102, 0x0303}:   0x1EB4,
+	{0x0102, 0x0309}:   0x1EB2,
+	{0x0103, 0x0300}:   0x1EB1,
+	{0x0103, 0x0301}:   0x1EAF,
+	{0x0103, 0x0303}:   0x1EB5,
+	{0x0103, 0x0309}:   0x1EB3,
+	{0x0112, 0x0300}:   0x1E14,
+	{0x0112, 0x0301}:   0x1E16,
+	{0x0113, 0x0300}:   0x1E15,
+	{0x0113, 0x0301}:   0x1E17,
+	{0x014C, 0x0300}:   0x1E50,
+	{0x014C, 0x0301}:   0x1E52,
+	{0x014D, 0x0300}:   0x1E51,
+	{0x014D, 0x0301}:   0x1E53,
+	{0x015A, 0x0307}:   0x1E64,
+	{0x015B, 0x0307}:   0x1E65,
+	{0x0160, 0x0307}:   0x1E66,
+	{0x0161, 0x0307}:   0x1E67,
+	{0x0168, 0x0301}:   0x1E78,
+	{0x0169, 0x0301}:   0x1E79,
+	{0x016A, 0x0308}:   0x1E7A,
+	{0x016B, 0x0308}:   0x1E7B,
+	{0x017F, 0x0307}:   0x1E9B,
+	{0x01A0, 0x0300}:   0x1EDC,
+	{0x01A0, 0x0301}:   0x1EDA,
+	{0x01A0, 0x0303}:   0x1EE0,
+	{0x01A0, 0x0309}:   0x1EDE,
+	{0x01A0, 0x0323}:   0x1EE2,
+	{0x01A1, 0x0300}:   0x1EDD,
+	{0x01A1, 0x0301}:   0x1EDB,
+	{0x01A1, 0x0303}:   0x1EE1,
+	{0x01A1, 0x0309}:   0x1EDF,
+	{0x01A1, 0x0323}:   0x1EE3,
+	{0x01AF, 0x0300}:   0x1EEA,
+	{0x01AF, 0x0301}:   0x1EE8,
+	{0x01AF, 0x0303}:   0x1EEE,
+	{0x01AF, 0x0309}:   0x1EEC,
+	{0x01AF, 0x0323}:   0x1EF0,
+	{0x01B0, 0x0300}:   0x1EEB,
+	{0x01B0, 0x0301}:   0x1EE9,
+	{0x01B0, 0x0303}:   0x1EEF,
+	{0x01B0, 0x0309}:   0x1EED,
+	{0x01B0, 0x0323}:   0x1EF1,
+	{0x01B7, 0x030C}:   0x01EE,
+	{0x01EA, 0x0304}:   0x01EC,
+	{0x01EB, 0x0304}:   0x01ED,
+	{0x0226, 0x0304}:   0x01E0,
+	{0x0227, 0x0304}:   0x01E1,
+	{0x0228, 0x0306}:   0x1E1C,
+	{0x0229, 0x0306}:   0x1E1D,
+	{0x022E, 0x0304}:   0x0230,
+	{0x022F, 0x0304}:   0x0231,
+	{0x0292, 0x030C}:   0x01EF,
+	{0x0391, 0x0300}:   0x1FBA,
+	{0x0391, 0x0301}:   0x0386,
+	{0x0391, 0x0304}:   0x1FB9,
+	{0x0391, 0x0306}:   0x1FB8,
+	{0x0391, 0x0313}:   0x1F08,
+	{0x0391, 0x0314}:   0x1F09,
+	{0x0391, 0x0345}:   0x1FBC,
+	{0x0395, 0x0300}:   0x1FC8,
+	{0x0395, 0x0301}:   0x0388,
+	{0x0395, 0x0313}:   0x1F18,
+	{0x0395, 0x0314}:   0x1F19,
+	{0x0397, 0x0300}:   0x1FCA,
+	{0x0397, 0x0301}:   0x0389,
+	{0x0397, 0x0313}:   0x1F28,
+	{0x0397, 0x0314}:   0x1F29,
+	{0x0397, 0x0345}:   0x1FCC,
+	{0x0399, 0x0300}:   0x1FDA,
+	{0x0399, 0x0301}:   0x038A,
+	{0x0399, 0x0304}:   0x1FD9,
+	{0x0399, 0x0306}:   0x1FD8,
+	{0x0399, 0x0308}:   0x03AA,
+	{0x0399, 0x0313}:   0x1F38,
+	{0x0399, 0x0314}:   0x1F39,
+	{0x039F, 0x0300}:   0x1FF8,
+	{0x039F, 0x0301}:   0x038C,
+	{0x039F, 0x0313}:   0x1F48,
+	{0x039F, 0x0314}:   0x1F49,
+	{0x03A1, 0x0314}:   0x1FEC,
+	{0x03A5, 0x0300}:   0x1FEA,
+	{0x03A5, 0x0301}:   0x038E,
+	{0x03A5, 0x0304}:   0x1FE9,
+	{0x03A5, 0x0306}:   0x1FE8,
+	{0x03A5, 0x0308}:   0x03AB,
+	{0x03A5, 0x0314}:   0x1F59,
+	{0x03A9, 0x0300}:   0x1FFA,
+	{0x03A9, 0x0301}:   0x038F,
+	{0x03A9, 0x0313}:   0x1F68,
+	{0x03A9, 0x0314}:   0x1F69,
+	{0x03A9, 0x0345}:   0x1FFC,
+	{0x03AC, 0x0345}:   0x1FB4,
+	{0x03AE, 0x0345}:   0x1FC4,
+	{0x03B1, 0x0300}:   0x1F70,
+	{0x03B1, 0x0301}:   0x03AC,
+	{0x03B1, 0x0304}:   0x1FB1,
+	{0x03B1, 0x0306}:   0x1FB0,
+	{0x03B1, 0x0313}:   0x1F00,
+	{0x03B1, 0x0314}:   0x1F01,
+	{0x03B1, 0x0342}:   0x1FB6,
+	{0x03B1, 0x0345}:   0x1FB3,
+	{0x03B5, 0x0300}:   0x1F72,
+	{0x03B5, 0x0301}:   0x03AD,
+	{0x03B5, 0x0313}:   0x1F10,
+	{0x03B5, 0x0314}:   0x1F11,
+	{0x03B7, 0x0300}:   0x1F74,
+	{0x03B7, 0x0301}:   0x03AE,
+	{0x03B7, 0x0313}:   0x1F20,
+	{0x03B7, 0x0314}:   0x1F21,
+	{0x03B7, 0x0342}:   0x1FC6,
+	{0x03B7, 0x0345}:   0x1FC3,
+	{0x03B9, 0x0300}:   0x1F76,
+	{0x03B9, 0x0301}:   0x03AF,
+	{0x03B9, 0x0304}:   0x1FD1,
+	{0x03B9, 0x0306}:   0x1FD0,
+	{0x03B9, 0x0308}:   0x03CA,
+	{0x03B9, 0x0313}:   0x1F30,
+	{0x03B9, 0x0314}:   0x1F31,
+	{0x03B9, 0x0342}:   0x1FD6,
+	{0x03BF, 0x0300}:   0x1F78,
+	{0x03BF, 0x0301}:   0x03CC,
+	{0x03BF, 0x0313}:   0x1F40,
+	{0x03BF, 0x0314}:   0x1F41,
+	{0x03C1, 0x0313}:   0x1FE4,
+	{0x03C1, 0x0314}:   0x1FE5,
+	{0x03C5, 0x0300}:   0x1F7A,
+	{0x03C5, 0x0301}:   0x03CD,
+	{0x03C5, 0x0304}:   0x1FE1,
+	{0x03C5, 0x0306}:   0x1FE0,
+	{0x03C5, 0x0308}:   0x03CB,
+	{0x03C5, 0x0313}:   0x1F50,
+	{0x03C5, 0x0314}:   0x1F51,
+	{0x03C5, 0x0342}:   0x1FE6,
+	{0x03C9, 0x0300}:   0x1F7C,
+	{0x03C9, 0x0301}:   0x03CE,
+	{0x03C9, 0x0313}:   0x1F60,
+	{0x03C9, 0x0314}:   0x1F61,
+	{0x03C9, 0x0342}:   0x1FF6,
+	{0x03C9, 0x0345}:   0x1FF3,
+	{0x03CA, 0x0300}:   0x1FD2,
+	{0x03CA, 0x0301}:   0x0390,
+	{0x03CA, 0x0342}:   0x1FD7,
+	{0x03CB, 0x0300}:   0x1FE2,
+	{0x03CB, 0x0301}:   0x03B0,
+	{0x03CB, 0x0342}:   0x1FE7,
+	{0x03CE, 0x0345}:   0x1FF4,
+	{0x03D2, 0x0301}:   0x03D3,
+	{0x03D2, 0x0308}:   0x03D4,
+	{0x0406, 0x0308}:   0x0407,
+	{0x0410, 0x0306}:   0x04D0,
+	{0x0410, 0x0308}:   0x04D2,
+	{0x0413, 0x0301}:   0x0403,
+	{0x0415, 0x0300}:   0x0400,
+	{0x0415, 0x0306}:   0x04D6,
+	{0x0415, 0x0308}:   0x0401,
+	{0x0416, 0x0306}:   0x04C1,
+	{0x0416, 0x0308}:   0x04DC,
+	{0x0417, 0x0308}:   0x04DE,
+	{0x0418, 0x0300}:   0x040D,
+	{0x0418, 0x0304}:   0x04E2,
+	{0x0418, 0x0306}:   0x0419,
+	{0x0418, 0x0308}:   0x04E4,
+	{0x041A, 0x0301}:   0x040C,
+	{0x041E, 0x0308}:   0x04E6,
+	{0x0423, 0x0304}:   0x04EE,
+	{0x0423, 0x0306}:   0x040E,
+	{0x0423, 0x0308}:   0x04F0,
+	{0x0423, 0x030B}:   0x04F2,
+	{0x0427, 0x0308}:   0x04F4,
+	{0x042B, 0x0308}:   0x04F8,
+	{0x042D, 0x0308}:   0x04EC,
+	{0x0430, 0x0306}:   0x04D1,
+	{0x0430, 0x0308}:   0x04D3,
+	{0x0433, 0x0301}:   0x0453,
+	{0x0435, 0x0300}:   0x0450,
+	{0x0435, 0x0306}:   0x04D7,
+	{0x0435, 0x0308}:   0x0451,
+	{0x0436, 0x0306}:   0x04C2,
+	{0x0436, 0x0308}:   0x04DD,
+	{0x0437, 0x0308}:   0x04DF,
+	{0x0438, 0x0300}:   0x045D,
+	{0x0438, 0x0304}:   0x04E3,
+	{0x0438, 0x0306}:   0x0439,
+	{0x0438, 0x0308}:   0x04E5,
+	{0x043A, 0x0301}:   0x045C,
+	{0x043E, 0x0308}:   0x04E7,
+	{0x0443, 0x0304}:   0x04EF,
+	{0x0443, 0x0306}:   0x045E,
+	{0x0443, 0x0308}:   0x04F1,
+	{0x0443, 0x030B}:   0x04F3,
+	{0x0447, 0x0308}:   0x04F5,
+	{0x044B, 0x0308}:   0x04F9,
+	{0x044D, 0x0308}:   0x04ED,
+	{0x0456, 0x0308}:   0x0457,
+	{0x0474, 0x030F}:   0x0476,
+	{0x0475, 0x030F}:   0x0477,
+	{0x04D8, 0x0308}:   0x04DA,
+	{0x04D9, 0x0308}:   0x04DB,
+	{0x04E8, 0x0308}:   0x04EA,
+	{0x04E9, 0x0308}:   0x04EB,
+	{0x0627, 0x0653}:   0x0622,
+	{0x0627, 0x0654}:   0x0623,
+	{0x0627, 0x0655}:   0x0625,
+	{0x0648, 0x0654}:   0x0624,
+	{0x064A, 0x0654}:   0x0626,
+	{0x06C1, 0x0654}:   0x06C2,
+	{0x06D2, 0x0654}:   0x06D3,
+	{0x06D5, 0x0654}:   0x06C0,
+	{0x0928, 0x093C}:   0x0929,
+	{0x0930, 0x093C}:   0x0931,
+	{0x0933, 0x093C}:   0x0934,
+	{0x09C7, 0x09BE}:   0x09CB,
+	{0x09C7, 0x09D7}:   0x09CC,
+	{0x0B47, 0x0B3E}:   0x0B4B,
+	{0x0B47, 0x0B56}:   0x0B48,
+	{0x0B47, 0x0B57}:   0x0B4C,
+	{0x0B92, 0x0BD7}:   0x0B94,
+	{0x0BC6, 0x0BBE}:   0x0BCA,
+	{0x0BC6, 0x0BD7}:   0x0BCC,
+	{0x0BC7, 0x0BBE}:   0x0BCB,
+	{0x0C46, 0x0C56}:   0x0C48,
+	{0x0CBF, 0x0CD5}:   0x0CC0,
+	{0x0CC6, 0x0CC2}:   0x0CCA,
+	{0x0CC6, 0x0CD5}:   0x0CC7,
+	{0x0CC6, 0x0CD6}:   0x0CC8,
+	{0x0CCA, 0x0CD5}:   0x0CCB,
+	{0x0D46, 0x0D3E}:   0x0D4A,
+	{0x0D46, 0x0D57}:   0x0D4C,
+	{0x0D47, 0x0D3E}:   0x0D4B,
+	{0x0DD9, 0x0DCA}:   0x0DDA,
+	{0x0DD9, 0x0DCF}:   0x0DDC,
+	{0x0DD9, 0x0DDF}:   0x0DDE,
+	{0x0DDC, 0x0DCA}:   0x0DDD,
+	{0x1025, 0x102E}:   0x1026,
+	{0x1B05, 0x1B35}:   0x1B06,
+	{0x1B07, 0x1B35}:   0x1B08,
+	{0x1B09, 0x1B35}:   0x1B0A,
+	{0x1B0B, 0x1B35}:   0x1B0C,
+	{0x1B0D, 0x1B35}:   0x1B0E,
+	{0x1B11, 0x1B35}:   0x1B12,
+	{0x1B3A, 0x1B35}:   0x1B3B,
+	{0x1B3C, 0x1B35}:   0x1B3D,
+	{0x1B3E, 0x1B35}:   0x1B40,
+	{0x1B3F, 0x1B35}:   0x1B41,
+	{0x1B42, 0x1B35}:   0x1B43,
+	{0x1E36, 0x0304}:   0x1E38,
+	{0x1E37, 0x0304}:   0x1E39,
+	{0x1E5A, 0x0304}:   0x1E5C,
+	{0x1E5B, 0x0304}:   0x1E5D,
+	{0x1E62, 0x0307}:   0x1E68,
+	{0x1E63, 0x0307}:   0x1E69,
+	{0x1EA0, 0x0302}:   0x1EAC,
+	{0x1EA0, 0x0306}:   0x1EB6,
+	{0x1EA1, 0x0302}:   0x1EAD,
+	{0x1EA1, 0x0306}:   0x1EB7,
+	{0x1EB8, 0x0302}:   0x1EC6,
+	{0x1EB9, 0x0302}:   0x1EC7,
+	{0x1ECC, 0x0302}:   0x1ED8,
+	{0x1ECD, 0x0302}:   0x1ED9,
+	{0x1F00, 0x0300}:   0x1F02,
+	{0x1F00, 0x0301}:   0x1F04,
+	{0x1F00, 0x0342}:   0x1F06,
+	{0x1F00, 0x0345}:   0x1F80,
+	{0x1F01, 0x0300}:   0x1F03,
+	{0x1F01, 0x0301}:   0x1F05,
+	{0x1F01, 0x0342}:   0x1F07,
+	{0x1F01, 0x0345}:   0x1F81,
+	{0x1F02, 0x0345}:   0x1F82,
+	{0x1F03, 0x0345}:   0x1F83,
+	{0x1F04, 0x0345}:   0x1F84,
+	{0x1F05, 0x0345}:   0x1F85,
+	{0x1F06, 0x0345}:   0x1F86,
+	{0x1F07, 0x0345}:   0x1F87,
+	{0x1F08, 0x0300}:   0x1F0A,
+	{0x1F08, 0x0301}:   0x1F0C,
+	{0x1F08, 0x0342}:   0x1F0E,
+	{0x1F08, 0x0345}:   0x1F88,
+	{0x1F09, 0x0300}:   0x1F0B,
+	{0x1F09, 0x0301}:   0x1F0D,
+	{0x1F09, 0x0342}:   0x1F0F,
+	{0x1F09, 0x0345}:   0x1F89,
+	{0x1F0A, 0x0345}:   0x1F8A,
+	{0x1F0B, 0x0345}:   0x1F8B,
+	{0x1F0C, 0x0345}:   0x1F8C,
+	{0x1F0D, 0x0345}:   0x1F8D,
+	{0x1F0E, 0x0345}:   0x1F8E,
+	{0x1F0F, 0x0345}:   0x1F8F,
+	{0x1F10, 0x0300}:   0x1F12,
+	{0x1F10, 0x0301}:   0x1F14,
+	{0x1F11, 0x0300}:   0x1F13,
+	{0x1F11, 0x0301}:   0x1F15,
+	{0x1F18, 0x0300}:   0x1F1A,
+	{0x1F18, 0x0301}:   0x1F1C,
+	{0x1F19, 0x0300}:   0x1F1B,
+	{0x1F19, 0x0301}:   0x1F1D,
+	{0x1F20, 0x0300}:   0x1F22,
+	{0x1F20, 0x0301}:   0x1F24,
+	{0x1F20, 0x0342}:   0x1F26,
+	{0x1F20, 0x0345}:   0x1F90,
+	{0x1F21, 0x0300}:   0x1F23,
+	{0x1F21, 0x0301}:   0x1F25,
+	{0x1F21, 0x0342}:   0x1F27,
+	{0x1F21, 0x0345}:   0x1F91,
+	{0x1F22, 0x0345}:   0x1F92,
+	{0x1F23, 0x0345}:   0x1F93,
+	{0x1F24, 0x0345}:   0x1F94,
+	{0x1F25, 0x0345}:   0x1F95,
+	{0x1F26, 0x0345}:   0x1F96,
+	{0x1F27, 0x0345}:   0x1F97,
+	{0x1F28, 0x0300}:   0x1F2A,
+	{0x1F28, 0x0301}:   0x1F2C,
+	{0x1F28, 0x0342}:   0x1F2E,
+	{0x1F28, 0x0345}:   0x1F98,
+	{0x1F29, 0x0300}:   0x1F2B,
+	{0x1F29, 0x0301}:   0x1F2D,
+	{0x1F29, 0x0342}:   0x1F2F,
+	{0x1F29, 0x0345}:   0x1F99,
+	{0x1F2A, 0x0345}:   0x1F9A,
+	{0x1F2B, 0x0345}:   0x1F9B,
+	{0x1F2C, 0x0345}:   0x1F9C,
+	{0x1F2D, 0x0345}:   0x1F9D,
+	{0x1F2E, 0x0345}:   0x1F9E,
+	{0x1F2F, 0x0345}:   0x1F9F,
+	{0x1F30, 0x0300}:   0x1F32,
+	{0x1F30, 0x0301}:   0x1F34,
+	{0x1F30, 0x0342}:   0x1F36,
+	{0x1F31, 0x0300}:   0x1F33,
+	{0x1F31, 0x0301}:   0x1F35,
+	{0x1F31, 0x0342}:   0x1F37,
+	{0x1F38, 0x0300}:   0x1F3A,
+	{0x1F38, 0x0301}:   0x1F3C,
+	{0x1F38, 0x0342}:   0x1F3E,
+	{0x1F39, 0x0300}:   0x1F3B,
+	{0x1F39, 0x0301}:   0x1F3D,
+	{0x1F39, 0x0342}:   0x1F3F,
+	{0x1F40, 0x0300}:   0x1F42,
+	{0x1F40, 0x0301}:   0x1F44,
+	{0x1F41, 0x0300}:   0x1F43,
+	{0x1F41, 0x0301}:   0x1F45,
+	{0x1F48, 0x0300}:   0x1F4A,
+	{0x1F48, 0x0301}:   0x1F4C,
+	{0x1F49, 0x0300}:   0x1F4B,
+	{0x1F49, 0x0301}:   0x1F4D,
+	{0x1F50, 0x0300}:   0x1F52,
+	{0x1F50, 0x0301}:   0x1F54,
+	{0x1F50, 0x0342}:   0x1F56,
+	{0x1F51, 0x0300}:   0x1F53,
+	{0x1F51, 0x0301}:   0x1F55,
+	{0x1F51, 0x0342}:   0x1F57,
+	{0x1F59, 0x0300}:   0x1F5B,
+	{0x1F59, 0x0301}:   0x1F5D,
+	{0x1F59, 0x0342}:   0x1F5F,
+	{0x1F60, 0x0300}:   0x1F62,
+	{0x1F60, 0x0301}:   0x1F64,
+	{0x1F60, 0x0342}:   0x1F66,
+	{0x1F60, 0x0345}:   0x1FA0,
+	{0x1F61, 0x0300}:   0x1F63,
+	{0x1F61, 0x0301}:   0x1F65,
+	{0x1F61, 0x0342}:   0x1F67,
+	{0x1F61, 0x0345}:   0x1FA1,
+	{0x1F62, 0x0345}:   0x1FA2,
+	{0x1F63, 0x0345}:   0x1FA3,
+	{0x1F64, 0x0345}:   0x1FA4,
+	{0x1F65, 0x0345}:   0x1FA5,
+	{0x1F66, 0x0345}:   0x1FA6,
+	{0x1F67, 0x0345}:   0x1FA7,
+	{0x1F68, 0x0300}:   0x1F6A,
+	{0x1F68, 0x0301}:   0x1F6C,
+	{0x1F68, 0x0342}:   0x1F6E,
+	{0x1F68, 0x0345}:   0x1FA8,
+	{0x1F69, 0x0300}:   0x1F6B,
+	{0x1F69, 0x0301}:   0x1F6D,
+	{0x1F69, 0x0342}:   0x1F6F,
+	{0x1F69, 0x0345}:   0x1FA9,
+	{0x1F6A, 0x0345}:   0x1FAA,
+	{0x1F6B, 0x0345}:   0x1FAB,
+	{0x1F6C, 0x0345}:   0x1FAC,
+	{0x1F6D, 0x0345}:   0x1FAD,
+	{0x1F6E, 0x0345}:   0x1FAE,
+	{0x1F6F, 0x0345}:   0x1FAF,
+	{0x1F70, 0x0345}:   0x1FB2,
+	{0x1F74, 0x0345}:   0x1FC2,
+	{0x1F7C, 0x0345}:   0x1FF2,
+	{0x1FB6, 0x0345}:   0x1FB7,
+	{0x1FBF, 0x0300}:   0x1FCD,
+	{0x1FBF, 0x0301}:   0x1FCE,
+	{0x1FBF, 0x0342}:   0x1FCF,
+	{0x1FC6, 0x0345}:   0x1FC7,
+	{0x1FF6, 0x0345}:   0x1FF7,
+	{0x1FFE, 0x0300}:   0x1FDD,
+	{0x1FFE, 0x0301}:   0x1FDE,
+	{0x1FFE, 0x0342}:   0x1FDF,
+	{0x2190, 0x0338}:   0x219A,
+	{0x2192, 0x0338}:   0x219B,
+	{0x2194, 0x0338}:   0x21AE,
+	{0x21D0, 0x0338}:   0x21CD,
+	{0x21D2, 0x0338}:   0x21CF,
+	{0x21D4, 0x0338}:   0x21CE,
+	{0x2203, 0x0338}:   0x2204,
+	{0x2208, 0x0338}:   0x2209,
+	{0x220B, 0x0338}:   0x220C,
+	{0x2223, 0x0338}:   0x2224,
+	{0x2225, 0x0338}:   0x2226,
+	{0x223C, 0x0338}:   0x2241,
+	{0x2243, 0x0338}:   0x2244,
+	{0x2245, 0x0338}:   0x2247,
+	{0x2248, 0x0338}:   0x2249,
+	{0x224D, 0x0338}:   0x226D,
+	{0x2261, 0x0338}:   0x2262,
+	{0x2264, 0x0338}:   0x2270,
+	{0x2265, 0x0338}:   0x2271,
+	{0x2272, 0x0338}:   0x2274,
+	{0x2273, 0x0338}:   0x2275,
+	{0x2276, 0x0338}:   0x2278,
+	{0x2277, 0x0338}:   0x2279,
+	{0x227A, 0x0338}:   0x2280,
+	{0x227B, 0x0338}:   0x2281,
+	{0x227C, 0x0338}:   0x22E0,
+	{0x227D, 0x0338}:   0x22E1,
+	{0x2282, 0x0338}:   0x2284,
+	{0x2283, 0x0338}:   0x2285,
+	{0x2286, 0x0338}:   0x2288,
+	{0x2287, 0x0338}:   0x2289,
+	{0x2291, 0x0338}:   0x22E2,
+	{0x2292, 0x0338}:   0x22E3,
+	{0x22A2, 0x0338}:   0x22AC,
+	{0x22A8, 0x0338}:   0x22AD,
+	{0x22A9, 0x0338}:   0x22AE,
+	{0x22AB, 0x0338}:   0x22AF,
+	{0x22B2, 0x0338}:   0x22EA,
+	{0x22B3, 0x0338}:   0x22EB,
+	{0x22B4, 0x0338}:   0x22EC,
+	{0x22B5, 0x0338}:   0x22ED,
+	{0x3046, 0x3099}:   0x3094,
+	{0x304B, 0x3099}:   0x304C,
+	{0x304D, 0x3099}:   0x304E,
+	{0x304F, 0x3099}:   0x3050,
+	{0x3051, 0x3099}:   0x3052,
+	{0x3053, 0x3099}:   0x3054,
+	{0x3055, 0x3099}:   0x3056,
+	{0x3057, 0x3099}:   0x3058,
+	{0x3059, 0x3099}:   0x305A,
+	{0x305B, 0x3099}:   0x305C,
+	{0x305D, 0x3099}:   0x305E,
+	{0x305F, 0x3099}:   0x3060,
+	{0x3061, 0x3099}:   0x3062,
+	{0x3064, 0x3099}:   0x3065,
+	{0x3066, 0x3099}:   0x3067,
+	{0x3068, 0x3099}:   0x3069,
+	{0x306F, 0x3099}:   0x3070,
+	{0x306F, 0x309A}:   0x3071,
+	{0x3072, 0x3099}:   0x3073,
+	{0x3072, 0x309A}:   0x3074,
+	{0x3075, 0x3099}:   0x3076,
+	{0x3075, 0x309A}:   0x3077,
+	{0x3078, 0x3099}:   0x3079,
+	{0x3078, 0x309A}:   0x307A,
+	{0x307B, 0x3099}:   0x307C,
+	{0x307B, 0x309A}:   0x307D,
+	{0x309D, 0x3099}:   0x309E,
+	{0x30A6, 0x3099}:   0x30F4,
+	{0x30AB, 0x3099}:   0x30AC,
+	{0x30AD, 0x3099}:   0x30AE,
+	{0x30AF, 0x3099}:   0x30B0,
+	{0x30B1, 0x3099}:   0x30B2,
+	{0x30B3, 0x3099}:   0x30B4,
+	{0x30B5, 0x3099}:   0x30B6,
+	{0x30B7, 0x3099}:   0x30B8,
+	{0x30B9, 0x3099}:   0x30BA,
+	{0x30BB, 0x3099}:   0x30BC,
+	{0x30BD, 0x3099}:   0x30BE,
+	{0x30BF, 0x3099}:   0x30C0,
+	{0x30C1, 0x3099}:   0x30C2,
+	{0x30C4, 0x3099}:   0x30C5,
+	{0x30C6, 0x3099}:   0x30C7,
+	{0x30C8, 0x3099}:   0x30C9,
+	{0x30CF, 0x3099}:   0x30D0,
+	{0x30CF, 0x309A}:   0x30D1,
+	{0x30D2, 0x3099}:   0x30D3,
+	{0x30D2, 0x309A}:   0x30D4,
+	{0x30D5, 0x3099}:   0x30D6,
+	{0x30D5, 0x309A}:   0x30D7,
+	{0x30D8, 0x3099}:   0x30D9,
+	{0x30D8, 0x309A}:   0x30DA,
+	{0x30DB, 0x3099}:   0x30DC,
+	{0x30DB, 0x309A}:   0x30DD,
+	{0x30EF, 0x3099}:   0x30F7,
+	{0x30F0, 0x3099}:   0x30F8,
+	{0x30F1, 0x3099}:   0x30F9,
+	{0x30F2, 0x3099}:   0x30FA,
+	{0x30FD, 0x3099}:   0x30FE,
+	{0x11099, 0x110BA}: 0x1109A,
+	{0x1109B, 0x110BA}: 0x1109C,
+	{0x110A5, 0x110BA}: 0x110AB,
+	{0x11131, 0x11127}: 0x1112E,
+	{0x11132, 0x11127}: 0x1112F,
+	{0x11347, 0x1133E}: 0x1134B,
+	{0x11347, 0x11357}: 0x1134C,
+	{0x114B9, 0x114B0}: 0x114BC,
+	{0x114B9, 0x114BA}: 0x114BB,
+	{0x114B9, 0x114BD}: 0x114BE,
+	{0x115B8, 0x115AF}: 0x115BA,
+	{0x115B9, 0x115AF}: 0x115BB,
+	{0x11935, 0x11930}: 0x11938,
+}
+
+// combiningClasses lists the runes with a non-zero canonical combining
+// class, as sorted ranges.
+var combiningClasses = []struct {
+	lo, hi rune
+	class  uint8
+}{
+	{0x0300, 0x0314, 230},
+	{0x0315, 0x0315, 232},
+	{0x0316, 0x0319, 220},
+	{0x031A, 0x031A, 232},
+	{0x031B, 0x031B, 216},
+	{0x031C, 0x0320, 220},
+	{0x0321, 0x0322, 202},
+	{0x0323, 0x0326, 220},
+	{0x0327, 0x0328, 202},
+	{0x0329, 0x0333, 220},
+	{0x0334, 0x0338, 1},
+	{0x0339, 0x033C, 220},
+	{0x033D, 0x0344, 230},
+	{0x0345, 0x0345, 240},
+	{0x0346, 0x0346, 230},
+	{0x0347, 0x0349, 220},
+	{0x034A, 0x034C, 230},
+	{0x034D, 0x034E, 220},
+	{0x0350, 0x0352, 230},
+	{0x0353, 0x0356, 220},
+	{0x0357, 0x0357, 230},
+	{0x0358, 0x0358, 232},
+	{0x0359, 0x035A, 220},
+	{0x035B, 0x035B, 230},
+	{0x035C, 0x035C, 233},
+	{0x035D, 0x035E, 234},
+	{0x035F, 0x035F, 233},
+	{0x0360, 0x0361, 234},
+	{0x0362, 0x0362, 233},
+	{0x0363, 0x036F, 230},
+	{0x0483, 0x0487, 230},
+	{0x0591, 0x0591, 220},
+	{0x0592, 0x0595, 230},
+	{0x0596, 0x0596, 220},
+	{0x0597, 0x0599, 230},
+	{0x059A, 0x059A, 222},
+	{0x059B, 0x059B, 220},
+	{0x059C, 0x05A1, 230},
+	{0x05A2, 0x05A7, 220},
+	{0x05A8, 0x05A9, 230},
+	{0x05AA, 0x05AA, 220},
+	{0x05AB, 0x05AC, 230},
+	{0x05AD, 0x05AD, 222},
+	{0x05AE, 0x05AE, 228},
+	{0x05AF, 0x05AF, 230},
+	{0x05B0, 0x05B0, 10},
+	{0x05B1, 0x05B1, 11},
+	{0x05B2, 0x05B2, 12},
+	{0x05B3, 0x05B3, 13},
+	{0x05B4, 0x05B4, 14},
+	{0x05B5, 0x05B5, 15},
+	{0x05B6, 0x05B6, 16},
+	{0x05B7, 0x05B7, 17},
+	{0x05B8, 0x05B8, 18},
+	{0x05B9, 0x05BA, 19},
+	{0x05BB, 0x05BB, 20},
+	{0x05BC, 0x05BC, 21},
+	{0x05BD, 0x05BD, 22},
+	{0x05BF, 0x05BF, 23},
+	{0x05C1, 0x05C1, 24},
+	{0x05C2, 0x05C2, 25},
+	{0x05C4, 0x05C4, 230},
+	{0x05C5, 0x05C5, 220},
+	{0x05C7, 0x05C7, 18},
+	{0x0610, 0x0617, 230},
+	{0x0618, 0x0618, 30},
+	{0x0619, 0x0619, 31},
+	{0x061A, 0x061A, 32},
+	{0x064B, 0x064B, 27},
+	{0x064C, 0x064C, 28},
+	{0x064D, 0x064D, 29},
+	{0x064E, 0x064E, 30},
+	{0x064F, 0x064F, 31},
+	{0x0650, 0x0650, 32},
+	{0x0651, 0x0651, 33},
+	{0x0652, 0x0652, 34},
+	{0x0653, 0x0654, 230},
+	{0x0655, 0x0656, 220},
+	{0x0657, 0x065B, 230},
+	{0x065C, 0x065C, 220},
+	{0x065D, 0x065E, 230},
+	{0x065F, 0x065F, 220},
+	{0x0670, 0x0670, 35},
+	{0x06D6, 0x06DC, 230},
+	{0x06DF, 0x06E2, 230},
+	{0x06E3, 0x06E3, 220},
+	{0x06E4, 0x06E4, 230},
+	{0x06E7, 0x06E8, 230},
+	{0x06EA, 0x06EA, 220},
+	{0x06EB, 0x06EC, 230},
+	{0x06ED, 0x06ED, 220},
+	{0x0711, 0x0711, 36},
+	{0x0730, 0x0730, 230},
+	{0x0731, 0x0731, 220},
+	{0x0732, 0x0733, 230},
+	{0x0734, 0x0734, 220},
+	{0x0735, 0x0736, 230},
+	{0x0737, 0x0739, 220},
+	{0x073A, 0x073A, 230},
+	{0x073B, 0x073C, 220},
+	{0x073D, 0x073D, 230},
+	{0x073E, 0x073E, 220},
+	{0x073F, 0x0741, 230},
+	{0x0742, 0x0742, 220},
+	{0x0743, 0x0743, 230},
+	{0x0744, 0x0744, 220},
+	{0x0745, 0x0745, 230},
+	{0x0746, 0x0746, 220},
+	{0x0747, 0x0747, 230},
+	{0x0748, 0x0748, 220},
+	{0x0749, 0x074A, 230},
+	{0x07EB, 0x07F1, 230},
+	{0x07F2, 0x07F2, 220},
+	{0x07F3, 0x07F3, 230},
+	{0x07FD, 0x07FD, 220},
+	{0x0816, 0x0819, 230},
+	{0x081B, 0x0823, 230},
+	{0x0825, 0x0827, 230},
+	{0x0829, 0x082D, 230},
+	{0x0859, 0x085B, 220},
+	{0x0898, 0x0898, 230},
+	{0x0899, 0x089B, 220},
+	{0x089C, 0x089F, 230},
+	{0x08CA, 0x08CE, 230},
+	{0x08CF, 0x08D3, 220},
+	{0x08D4, 0x08E1, 230},
+	{0x08E3, 0x08E3, 220},
+	{0x08E4, 0x08E5, 230},
+	{0x08E6, 0x08E6, 220},
+	{0x08E7, 0x08E8, 230},
+	{0x08E9, 0x08E9, 220},
+	{0x08EA, 0x08EC, 230},
+	{0x08ED, 0x08EF, 220},
+	{0x08F0, 0x08F0, 27},
+	{0x08F1, 0x08F1, 28},
+	{0x08F2, 0x08F2, 29},
+	{0x08F3, 0x08F5, 230},
+	{0x08F6, 0x08F6, 220},
+	{0x08F7, 0x08F8, 230},
+	{0x08F9, 0x08FA, 220},
+	{0x08FB, 0x08FF, 230},
+	{0x093C, 0x093C, 7},
+	{0x094D, 0x094D, 9},
+	{0x0951, 0x0951, 230},
+	{0x0952, 0x0952, 220},
+	{0x0953, 0x0954, 230},
+	{0x09BC, 0x09BC, 7},
+	{0x09CD, 0x09CD, 9},
+	{0x09FE, 0x09FE, 230},
+	{0x0A3C, 0x0A3C, 7},
+	{0x0A4D, 0x0A4D, 9},
+	{0x0ABC, 0x0ABC, 7},
+	{0x0ACD, 0x0ACD, 9},
+	{0x0B3C, 0x0B3C, 7},
+	{0x0B4D, 0x0B4D, 9},
+	{0x0BCD, 0x0BCD, 9},
+	{0x0C3C, 0x0C3C, 7},
+	{0x0C4D, 0x0C4D, 9},
+	{0x0C55, 0x0C55, 84},
+	{0x0C56, 0x0C56, 91},
+	{0x0CBC, 0x0CBC, 7},
+	{0x0CCD, 0x0CCD, 9},
+	{0x0D3B, 0x0D3C, 9},
+	{0x0D4D, 0x0D4D, 9},
+	{0x0DCA, 0x0DCA, 9},
+	{0x0E38, 0x0E39, 103},
+	{0x0E3A, 0x0E3A, 9},
+	{0x0E48, 0x0E4B, 107},
+	{0x0EB8, 0x0EB9, 118},
+	{0x0EBA, 0x0EBA, 9},
+	{0x0EC8, 0x0ECB, 122},
+	{0x0F18, 0x0F19, 220},
+	{0x0F35, 0x0F35, 220},
+	{0x0F37, 0x0F37, 220},
+	{0x0F39, 0x0F39, 216},
+	{0x0F71, 0x0F71, 129},
+	{0x0F72, 0x0F72, 130},
+	{0x0F74, 0x0F74, 132},
+	{0x0F7A, 0x0F7D, 130},
+	{0x0F80, 0x0F80, 130},
+	{0x0F82, 0x0F83, 230},
+	{0x0F84, 0x0F84, 9},
+	{0x0F86, 0x0F87, 230},
+	{0x0FC6, 0x0FC6, 220},
+	{0x1037, 0x1037, 7},
+	{0x1039, 0x103A, 9},
+	{0x108D, 0x108D, 220},
+	{0x135D, 0x135F, 230},
+	{0x1714, 0x1715, 9},
+	{0x1734, 0x1734, 9},
+	{0x17D2, 0x17D2, 9},
+	{0x17DD, 0x17DD, 230},
+	{0x18A9, 0x18A9, 228},
+	{0x1939, 0x1939, 222},
+	{0x193A, 0x193A, 230},
+	{0x193B, 0x193B, 220},
+	{0x1A17, 0x1A17, 230},
+	{0x1A18, 0x1A18, 220},
+	{0x1A60, 0x1A60, 9},
+	{0x1A75, 0x1A7C, 230},
+	{0x1A7F, 0x1A7F, 220},
+	{0x1AB0, 0x1AB4, 230},
+	{0x1AB5, 0x1ABA, 220},
+	{0x1ABB, 0x1ABC, 230},
+	{0x1ABD, 0x1ABD, 220},
+	{0x1ABF, 0x1AC0, 220},
+	{0x1AC1, 0x1AC2, 230},
+	{0x1AC3, 0x1AC4, 220},
+	{0x1AC5, 0x1AC9, 230},
+	{0x1ACA, 0x1ACA, 220},
+	{0x1ACB, 0x1ACE, 230},
+	{0x1B34, 0x1B34, 7},
+	{0x1B44, 0x1B44, 9},
+	{0x1B6B, 0x1B6B, 230},
+	{0x1B6C, 0x1B6C, 220},
+	{0x1B6D, 0x1B73, 230},
+	{0x1BAA, 0x1BAB, 9},
+	{0x1BE6, 0x1BE6, 7},
+	{0x1BF2, 0x1BF3, 9},
+	{0x1C37, 0x1C37, 7},
+	{0x1CD0, 0x1CD2, 230},
+	{0x1CD4, 0x1CD4, 1},
+	{0x1CD5, 0x1CD9, 220},
+	{0x1CDA, 0x1CDB, 230},
+	{0x1CDC, 0x1CDF, 220},
+	{0x1CE0, 0x1CE0, 230},
+	{0x1CE2, 0x1CE8, 1},
+	{0x1CED, 0x1CED, 220},
+	{0x1CF4, 0x1CF4, 230},
+	{0x1CF8, 0x1CF9, 230},
+	{0x1DC0, 0x1DC1, 230},
+	{0x1DC2, 0x1DC2, 220},
+	{0x1DC3, 0x1DC9, 230},
+	{0x1DCA, 0x1DCA, 220},
+	{0x1DCB, 0x1DCC, 230},
+	{0x1DCD, 0x1DCD, 234},
+	{0x1DCE, 0x1DCE, 214},
+	{0x1DCF, 0x1DCF, 220},
+	{0x1DD0, 0x1DD0, 202},
+	{0x1DD1, 0x1DF5, 230},
+	{0x1DF6, 0x1DF6, 232},
+	{0x1DF7, 0x1DF8, 228},
+	{0x1DF9, 0x1DF9, 220},
+	{0x1DFA, 0x1DFA, 218},
+	{0x1DFB, 0x1DFB, 230},
+	{0x1DFC, 0x1DFC, 233},
+	{0x1DFD, 0x1DFD, 220},
+	{0x1DFE, 0x1DFE, 230},
+	{0x1DFF, 0x1DFF, 220},
+	{0x20D0, 0x20D1, 230},
+	{0x20D2, 0x20D3, 1},
+	{0x20D4, 0x20D7, 230},
+	{0x20D8, 0x20DA, 1},
+	{0x20DB, 0x20DC, 230},
+	{0x20E1, 0x20E1, 230},
+	{0x20E5, 0x20E6, 1},
+	{0x20E7, 0x20E7, 230},
+	{0x20E8, 0x20E8, 220},
+	{0x20E9, 0x20E9, 230},
+	{0x20EA, 0x20EB, 1},
+	{0x20EC, 0x20EF, 220},
+	{0x20F0, 0x20F0, 230},
+	{0x2CEF, 0x2CF1, 230},
+	{0x2D7F, 0x2D7F, 9},
+	{0x2DE0, 0x2DFF, 230},
+	{0x302A, 0x302A, 218},
+	{0x302B, 0x302B, 228},
+	{0x302C, 0x302C, 232},
+	{0x302D, 0x302D, 222},
+	{0x302E, 0x302F, 224},
+	{0x3099, 0x309A, 8},
+	{0xA66F, 0xA66F, 230},
+	{0xA674, 0xA67D, 230},
+	{0xA69E, 0xA69F, 230},
+	{0xA6F0, 0xA6F1, 230},
+	{0xA806, 0xA806, 9},
+	{0xA82C, 0xA82C, 9},
+	{0xA8C4, 0xA8C4, 9},
+	{0xA8E0, 0xA8F1, 230},
+	{0xA92B, 0xA92D, 220},
+	{0xA953, 0xA953, 9},
+	{0xA9B3, 0xA9B3, 7},
+	{0xA9C0, 0xA9C0, 9},
+	{0xAAB0, 0xAAB0, 230},
+	{0xAAB2, 0xAAB3, 230},
+	{0xAAB4, 0xAAB4, 220},
+	{0xAAB7, 0xAAB8, 230},
+	{0xAABE, 0xAABF, 230},
+	{0xAAC1, 0xAAC1, 230},
+	{0xAAF6, 0xAAF6, 9},
+	{0xABED, 0xABED, 9},
+	{0xFB1E, 0xFB1E, 26},
+	{0xFE20, 0xFE26, 230},
+	{0xFE27, 0xFE2D, 220},
+	{0xFE2E, 0xFE2F, 230},
+	{0x101FD, 0x101FD, 220},
+	{0x102E0, 0x102E0, 220},
+	{0x10376, 0x1037A, 230},
+	{0x10A0D, 0x10A0D, 220},
+	{0x10A0F, 0x10A0F, 230},
+	{0x10A38, 0x10A38, 230},
+	{0x10A39, 0x10A39, 1},
+	{0x10A3A, 0x10A3A, 220},
+	{0x10A3F, 0x10A3F, 9},
+	{0x10AE5, 0x10AE5, 230},
+	{0x10AE6, 0x10AE6, 220},
+	{0x10D24, 0x10D27, 230},
+	{0x10EAB, 0x10EAC, 230},
+	{0x10F46, 0x10F47, 220},
+	{0x10F48, 0x10F4A, 230},
+	{0x10F4B, 0x10F4B, 220},
+	{0x10F4C, 0x10F4C, 230},
+	{0x10F4D, 0x10F50, 220},
+	{0x10F82, 0x10F82, 230},
+	{0x10F83, 0x10F83, 220},
+	{0x10F84, 0x10F84, 230},
+	{0x10F85, 0x10F85, 220},
+	{0x11046, 0x11046, 9},
+	{0x11070, 0x11070, 9},
+	{0x1107F, 0x1107F, 9},
+	{0x110B9, 0x110B9, 9},
+	{0x110BA, 0x110BA, 7},
+	{0x11100, 0x11102, 230},
+	{0x11133, 0x11134, 9},
+	{0x11173, 0x11173, 7},
+	{0x111C0, 0x111C0, 9},
+	{0x111CA, 0x111CA, 7},
+	{0x11235, 0x11235, 9},
+	{0x11236, 0x11236, 7},
+	{0x112E9, 0x112E9, 7},
+	{0x112EA, 0x112EA, 9},
+	{0x1133B, 0x1133C, 7},
+	{0x1134D, 0x1134D, 9},
+	{0x11366, 0x1136C, 230},
+	{0x11370, 0x11374, 230},
+	{0x11442, 0x11442, 9},
+	{0x11446, 0x11446, 7},
+	{0x1145E, 0x1145E, 230},
+	{0x114C2, 0x114C2, 9},
+	{0x114C3, 0x114C3, 7},
+	{0x115BF, 0x115BF, 9},
+	{0x115C0, 0x115C0, 7},
+	{0x1163F, 0x1163F, 9},
+	{0x116B6, 0x116B6, 9},
+	{0x116B7, 0x116B7, 7},
+	{0x1172B, 0x1172B, 9},
+	{0x11839, 0x11839, 9},
+	{0x1183A, 0x1183A, 7},
+	{0x1193D, 0x1193E, 9},
+	{0x11943, 0x11943, 7},
+	{0x119E0, 0x119E0, 9},
+	{0x11A34, 0x11A34, 9},
+	{0x11A47, 0x11A47, 9},
+	{0x11A99, 0x11A99, 9},
+	{0x11C3F, 0x11C3F, 9},
+	{0x11D42, 0x11D42, 7},
+	{0x11D44, 0x11D45, 9},
+	{0x11D97, 0x11D97, 9},
+	{0x16AF0, 0x16AF4, 1},
+	{0x16B30, 0x16B36, 230},
+	{0x16FF0, 0x16FF1, 6},
+	{0x1BC9E, 0x1BC9E, 1},
+	{0x1D165, 0x1D166, 216},
+	{0x1D167, 0x1D169, 1},
+	{0x1D16D, 0x1D16D, 226},
+	{0x1D16E, 0x1D172, 216},
+	{0x1D17B, 0x1D182, 220},
+	{0x1D185, 0x1D189, 230},
+	{0x1D18A, 0x1D18B, 220},
+	{0x1D1AA, 0x1D1AD, 230},
+	{0x1D242, 0x1D244, 230},
+	{0x1E000, 0x1E006, 230},
+	{0x1E008, 0x1E018, 230},
+	{0x1E01B, 0x1E021, 230},
+	{0x1E023, 0x1E024, 230},
+	{0x1E026, 0x1E02A, 230},
+	{0x1E130, 0x1E136, 230},
+	{0x1E2AE, 0x1E2AE, 230},
+	{0x1E2EC, 0x1E2EF, 230},
+	{0x1E8D0, 0x1E8D6, 220},
+	{0x1E944, 0x1E949, 230},
+	{0x1E94A, 0x1E94A, 7},
+}
+
+// caseFoldings maps a rune to its full case folding, leaving out the
+// Turkic-specific mappings.
+var caseFoldings = map[rune]string{
+	0x0041:  "a",
+	0x0042:  "b",
+	0x0043:  "c",
+	0x0044:  "d",
+	0x0045:  "e",
+	0x0046:  "f",
+	0x0047:  "g",
+	0x0048:  "h",
+	0x0049:  "i",
+	0x004A:  "j",
+	0x004B:  "k",
+	0x004C:  "l",
+	0x004D:  "m",
+	0x004E:  "n",
+	0x004F:  "o",
+	0x0050:  "p",
+	0x0051:  "q",
+	0x0052:  "r",
+	0x0053:  "s",
+	0x0054:  "t",
+	0x0055:  "u",
+	0x0056:  "v",
+	0x0057:  "w",
+	0x0058:  "x",
+	0x0059:  "y",
+	0x005A:  "z",
+	0x00B5:  "\u03bc",
+	0x00C0:  "\u00e0",
+	0x00C1:  "\u00e1",
+	0x00C2:  "\u00e2",
+	0x00C3:  "\u00e3",
+	0x00C4:  "\u00e4",
+	0x00C5:  "\u00e5",
+	0x00C6:  "\u00e6",
+	0x00C7:  "\u00e7",
+	0x00C8:  "\u00e8",
+	0x00C9:  "\u00e9",
+	0x00CA:  "\u00ea",
+	0x00CB:  "\u00eb",
+	0x00CC:  "\u00ec",
+	0x00CD:  "\u00ed",
+	0x00CE:  "\u00ee",
+	0x00CF:  "\u00ef",
+	0x00D0:  "\u00f0",
+	0x00D1:  "\u00f1",
+	0x00D2:  "\u00f2",
+	0x00D3:  "\u00f3",
+	0x00D4:  "\u00f4",
+	0x00D5:  "\u00f5",
+	0x00D6:  "\u00f6",
+	0x00D8:  "\u00f8",
+	0x00D9:  "\u00f9",
+	0x00DA:  "\u00fa",
+	0x00DB:  "\u00fb",
+	0x00DC:  "\u00fc",
+	0x00DD:  "\u00fd",
+	0x00DE:  "\u00fe",
+	0x00DF:  "ss",
+	0x0100:  "\u0101",
+	0x0102:  "\u0103",
+	0x0104:  "\u0105",
+	0x0106:  "\u0107",
+	0x0108:  "\u0109",
+	0x010A:  "\u010b",
+	0x010C:  "\u010d",
+	0x010E:  "\u010f",
+	0x0110:  "\u0111",
+	0x0112:  "\u0113",
+	0x0114:  "\u0115",
+	0x0116:  "\u0117",
+	0x0118:  "\u0119",
+	0x011A:  "\u011b",
+	0x011C:  "\u011d",
+	0x011E:  "\u011f",
+	0x0120:  "\u0121",
+	0x0122:  "\u0123",
+	0x0124:  "\u0125",
+	0x0126:  "\u0127",
+	0x0128:  "\u0129",
+	0x012A:  "\u012b",
+	0x012C:  "\u012d",
+	0x012E:  "\u012f",
+	0x0130:  "i\u0307",
+	0x0132:  "\u0133",
+	0x0134:  "\u0135",
+	0x0136:  "\u0137",
+	0x0139:  "\u013a",
+	0x013B:  "\u013c",
+	0x013D:  "\u013e",
+	0x013F:  "\u0140",
+	0x0141:  "\u0142",
+	0x0143:  "\u0144",
+	0x0145:  "\u0146",
+	0x0147:  "\u0148",
+	0x0149:  "\u02bcn",
+	0x014A:  "\u014b",
+	0x014C:  "\u014d",
+	0x014E:  "\u014f",
+	0x0150:  "\u0151",
+	0x0152:  "\u0153",
+	0x0154:  "\u0155",
+	0x0156:  "\u0157",
+	0x0158:  "\u0159",
+	0x015A:  "\u015b",
+	0x015C:  "\u015d",
+	0x015E:  "\u015f",
+	0x0160:  "\u0161",
+	0x0162:  "\u0163",
+	0x0164:  "\u0165",
+	0x0166:  "\u0167",
+	0x0168:  "\u0169",
+	0x016A:  "\u016b",
+	0x016C:  "\u016d",
+	0x016E:  "\u016f",
+	0x0170:  "\u0171",
+	0x0172:  "\u0173",
+	0x0174:  "\u0175",
+	0x0176:  "\u0177",
+	0x0178:  "\u00ff",
+	0x0179:  "\u017a",
+	0x017B:  "\u017c",
+	0x017D:  "\u017e",
+	0x017F:  "s",
+	0x0181:  "\u0253",
+	0x0182:  "\u0183",
+	0x0184:  "\u0185",
+	0x0186:  "\u0254",
+	0x0187:  "\u0188",
+	0x0189:  "\u0256",
+	0x018A:  "\u0257",
+	0x018B:  "\u018c",
+	0x018E:  "\u01dd",
+	0x018F:  "\u0259",
+	0x0190:  "\u025b",
+	0x0191:  "\u0192",
+	0x0193:  "\u0260",
+	0x0194:  "\u0263",
+	0x0196:  "\u0269",
+	0x0197:  "\u0268",
+	0x0198:  "\u0199",
+	0x019C:  "\u026f",
+	0x019D:  "\u0272",
+	0x019F:  "\u0275",
+	0x01A0:  "\u01a1",
+	0x01A2:  "\u01a3",
+	0x01A4:  "\u01a5",
+	0x01A6:  "\u0280",
+	0x01A7:  "\u01a8",
+	0x01A9:  "\u0283",
+	0x01AC:  "\u01ad",
+	0x01AE:  "\u0288",
+	0x01AF:  "\u01b0",
+	0x01B1:  "\u028a",
+	0x01B2:  "\u028b",
+	0x01B3:  "\u01b4",
+	0x01B5:  "\u01b6",
+	0x01B7:  "\u0292",
+	0x01B8:  "\u01b9",
+	0x01BC:  "\u01bd",
+	0x01C4:  "\u01c6",
+	0x01C5:  "\u01c6",
+	0x01C7:  "\u01c9",
+	0x01C8:  "\u01c9",
+	0x01CA:  "\u01cc",
+	0x01CB:  "\u01cc",
+	0x01CD:  "\u01ce",
+	0x01CF:  "\u01d0",
+	0x01D1:  "\u01d2",
+	0x01D3:  "\u01d4",
+	0x01D5:  "\u01d6",
+	0x01D7:  "\u01d8",
+	0x01D9:  "\u01da",
+	0x01DB:  "\u01dc",
+	0x01DE:  "\u01df",
+	0x01E0:  "\u01e1",
+	0x01E2:  "\u01e3",
+	0x01E4:  "\u01e5",
+	0x01E6:  "\u01e7",
+	0x01E8:  "\u01e9",
+	0x01EA:  "\u01eb",
+	0x01EC:  "\u01ed",
+	0x01EE:  "\u01ef",
+	0x01F0:  "j\u030c",
+	0x01F1:  "\u01f3",
+	0x01F2:  "\u01f3",
+	0x01F4:  "\u01f5",
+	0x01F6:  "\u0195",
+	0x01F7:  "\u01bf",
+	0x01F8:  "\u01f9",
+	0x01FA:  "\u01fb",
+	0x01FC:  "\u01fd",
+	0x01FE:  "\u01ff",
+	0x0200:  "\u0201",
+	0x0202:  "\u0203",
+	0x0204:  "\u0205",
+	0x0206:  "\u0207",
+	0x0208:  "\u0209",
+	0x020A:  "\u020b",
+	0x020C:  "\u020d",
+	0x020E:  "\u020f",
+	0x0210:  "\u0211",
+	0x0212:  "\u0213",
+	0x0214:  "\u0215",
+	0x0216:  "\u0217",
+	0x0218:  "\u0219",
+	0x021A:  "\u021b",
+	0x021C:  "\u021d",
+	0x021E:  "\u021f",
+	0x0220:  "\u019e",
+	0x0222:  "\u0223",
+	0x0224:  "\u0225",
+	0x0226:  "\u0227",
+	0x0228:  "\u0229",
+	0x022A:  "\u022b",
+	0x022C:  "\u022d",
+	0x022E:  "\u022f",
+	0x0230:  "\u0231",
+	0x0232:  "\u0233",
+	0x023A:  "\u2c65",
+	0x023B:  "\u023c",
+	0x023D:  "\u019a",
+	0x023E:  "\u2c66",
+	0x0241:  "\u0242",
+	0x0243:  "\u0180",
+	0x0244:  "\u0289",
+	0x0245:  "\u028c",
+	0x0246:  "\u0247",
+	0x0248:  "\u0249",
+	0x024A:  "\u024b",
+	0x024C:  "\u024d",
+	0x024E:  "\u024f",
+	0x0345:  "\u03b9",
+	0x0370:  "\u0371",
+	0x0372:  "\u0373",
+	0x0376:  "\u0377",
+	0x037F:  "\u03f3",
+	0x0386:  "\u03ac",
+	0x0388:  "\u03ad",
+	0x0389:  "\u03ae",
+	0x038A:  "\u03af",
+	0x038C:  "\u03cc",
+	0x038E:  "\u03cd",
+	0x038F:  "\u03ce",
+	0x0390:  "\u03b9\u0308\u0301",
+	0x0391:  "\u03b1",
+	0x0392:  "\u03b2",
+	0x0393:  "\u03b3",
+	0x0394:  "\u03b4",
+	0x0395:  "\u03b5",
+	0x0396:  "\u03b6",
+	0x0397:  "\u03b7",
+	0x0398:  "\u03b8",
+	0x0399:  "\u03b9",
+	0x039A:  "\u03ba",
+	0x039B:  "\u03bb",
+	0x039C:  "\u03bc",
+	0x039D:  "\u03bd",
+	0x039E:  "\u03be",
+	0x039F:  "\u03bf",
+	0x03A0:  "\u03c0",
+	0x03A1:  "\u03c1",
+	0x03A3:  "\u03c3",
+	0x03A4:  "\u03c4",
+	0x03A5:  "\u03c5",
+	0x03A6:  "\u03c6",
+	0x03A7:  "\u03c7",
+	0x03A8:  "\u03c8",
+	0x03A9:  "\u03c9",
+	0x03AA:  "\u03ca",
+	0x03AB:  "\u03cb",
+	0x03B0:  "\u03c5\u0308\u0301",
+	0x03C2:  "\u03c3",
+	0x03CF:  "\u03d7",
+	0x03D0:  "\u03b2",
+	0x03D1:  "\u03b8",
+	0x03D5:  "\u03c6",
+	0x03D6:  "\u03c0",
+	0x03D8:  "\u03d9",
+	0x03DA:  "\u03db",
+	0x03DC:  "\u03dd",
+	0x03DE:  "\u03df",
+	0x03E0:  "\u03e1",
+	0x03E2:  "\u03e3",
+	0x03E4:  "\u03e5",
+	0x03E6:  "\u03e7",
+	0x03E8:  "\u03e9",
+	0x03EA:  "\u03eb",
+	0x03EC:  "\u03ed",
+	0x03EE:  "\u03ef",
+	0x03F0:  "\u03ba",
+	0x03F1:  "\u03c1",
+	0x03F4:  "\u03b8",
+	0x03F5:  "\u03b5",
+	0x03F7:  "\u03f8",
+	0x03F9:  "\u03f2",
+	0x03FA:  "\u03fb",
+	0x03FD:  "\u037b",
+	0x03FE:  "\u037c",
+	0x03FF:  "\u037d",
+	0x0400:  "\u0450",
+	0x0401:  "\u0451",
+	0x0402:  "\u0452",
+	0x0403:  "\u0453",
+	0x0404:  "\u0454",
+	0x0405:  "\u0455",
+	0x0406:  "\u0456",
+	0x0407:  "\u0457",
+	0x0408:  "\u0458",
+	0x0409:  "\u0459",
+	0x040A:  "\u045a",
+	0x040B:  "\u045b",
+	0x040C:  "\u045c",
+	0x040D:  "\u045d",
+	0x040E:  "\u045e",
+	0x040F:  "\u045f",
+	0x0410:  "\u0430",
+	0x0411:  "\u0431",
+	0x0412:  "\u0432",
+	0x0413:  "\u0433",
+	0x0414:  "\u0434",
+	0x0415:  "\u0435",
+	0x0416:  "\u0436",
+	0x0417:  "\u0437",
+	0x0418:  "\u0438",
+	0x0419:  "\u0439",
+	0x041A:  "\u043a",
+	0x041B:  "\u043b",
+	0x041C:  "\u043c",
+	0x041D:  "\u043d",
+	0x041E:  "\u043e",
+	0x041F:  "\u043f",
+	0x0420:  "\u0440",
+	0x0421:  "\u0441",
+	0x0422:  "\u0442",
+	0x0423:  "\u0443",
+	0x0424:  "\u0444",
+	0x0425:  "\u0445",
+	0x0426:  "\u0446",
+	0x0427:  "\u0447",
+	0x0428:  "\u0448",
+	0x0429:  "\u0449",
+	0x042A:  "\u044a",
+	0x042B:  "\u044b",
+	0x042C:  "\u044c",
+	0x042D:  "\u044d",
+	0x042E:  "\u044e",
+	0x042F:  "\u044f",
+	0x0460:  "\u0461",
+	0x0462:  "\u0463",
+	0x0464:  "\u0465",
+	0x0466:  "\u0467",
+	0x0468:  "\u0469",
+	0x046A:  "\u046b",
+	0x046C:  "\u046d",
+	0x046E:  "\u046f",
+	0x0470:  "\u0471",
+	0x0472:  "\u0473",
+	0x0474:  "\u0475",
+	0x0476:  "\u0477",
+	0x0478:  "\u0479",
+	0x047A:  "\u047b",
+	0x047C:  "\u047d",
+	0x047E:  "\u047f",
+	0x0480:  "\u0481",
+	0x048A:  "\u048b",
+	0x048C:  "\u048d",
+	0x048E:  "\u048f",
+	0x0490:  "\u0491",
+	0x0492:  "\u0493",
+	0x0494:  "\u0495",
+	0x0496:  "\u0497",
+	0x0498:  "\u0499",
+	0x049A:  "\u049b",
+	0x049C:  "\u049d",
+	0x049E:  "\u049f",
+	0x04A0:  "\u04a1",
+	0x04A2:  "\u04a3",
+	0x04A4:  "\u04a5",
+	0x04A6:  "\u04a7",
+	0x04A8:  "\u04a9",
+	0x04AA:  "\u04ab",
+	0x04AC:  "\u04ad",
+	0x04AE:  "\u04af",
+	0x04B0:  "\u04b1",
+	0x04B2:  "\u04b3",
+	0x04B4:  "\u04b5",
+	0x04B6:  "\u04b7",
+	0x04B8:  "\u04b9",
+	0x04BA:  "\u04bb",
+	0x04BC:  "\u04bd",
+	0x04BE:  "\u04bf",
+	0x04C0:  "\u04cf",
+	0x04C1:  "\u04c2",
+	0x04C3:  "\u04c4",
+	0x04C5:  "\u04c6",
+	0x04C7:  "\u04c8",
+	0x04C9:  "\u04ca",
+	0x04CB:  "\u04cc",
+	0x04CD:  "\u04ce",
+	0x04D0:  "\u04d1",
+	0x04D2:  "\u04d3",
+	0x04D4:  "\u04d5",
+	0x04D6:  "\u04d7",
+	0x04D8:  "\u04d9",
+	0x04DA:  "\u04db",
+	0x04DC:  "\u04dd",
+	0x04DE:  "\u04df",
+	0x04E0:  "\u04e1",
+	0x04E2:  "\u04e3",
+	0x04E4:  "\u04e5",
+	0x04E6:  "\u04e7",
+	0x04E8:  "\u04e9",
+	0x04EA:  "\u04eb",
+	0x04EC:  "\u04ed",
+	0x04EE:  "\u04ef",
+	0x04F0:  "\u04f1",
+	0x04F2:  "\u04f3",
+	0x04F4:  "\u04f5",
+	0x04F6:  "\u04f7",
+	0x04F8:  "\u04f9",
+	0x04FA:  "\u04fb",
+	0x04FC:  "\u04fd",
+	0x04FE:  "\u04ff",
+	0x0500:  "\u0501",
+	0x0502:  "\u0503",
+	0x0504:  "\u0505",
+	0x0506:  "\u0507",
+	0x0508:  "\u0509",
+	0x050A:  "\u050b",
+	0x050C:  "\u050d",
+	0x050E:  "\u050f",
+	0x0510:  "\u0511",
+	0x0512:  "\u0513",
+	0x0514:  "\u0515",
+	0x0516:  "\u0517",
+	0x0518:  "\u0519",
+	0x051A:  "\u051b",
+	0x051C:  "\u051d",
+	0x051E:  "\u051f",
+	0x0520:  "\u0521",
+	0x0522:  "\u0523",
+	0x0524:  "\u0525",
+	0x0526:  "\u0527",
+	0x0528:  "\u0529",
+	0x052A:  "\u052b",
+	0x052C:  "\u052d",
+	0x052E:  "\u052f",
+	0x0531:  "\u0561",
+	0x0532:  "\u0562",
+	0x0533:  "\u0563",
+	0x0534:  "\u0564",
+	0x0535:  "\u0565",
+	0x0536:  "\u0566",
+	0x0537:  "\u0567",
+	0x0538:  "\u0568",
+	0x0539:  "\u0569",
+	0x053A:  "\u056a",
+	0x053B:  "\u056b",
+	0x053C:  "\u056c",
+	0x053D:  "\u056d",
+	0x053E:  "\u056e",
+	0x053F:  "\u056f",
+	0x0540:  "\u0570",
+	0x0541:  "\u0571",
+	0x0542:  "\u0572",
+	0x0543:  "\u0573",
+	0x0544:  "\u0574",
+	0x0545:  "\u0575",
+	0x0546:  "\u0576",
+	0x0547:  "\u0577",
+	0x0548:  "\u0578",
+	0x0549:  "\u0579",
+	0x054A:  "\u057a",
+	0x054B:  "\u057b",
+	0x054C:  "\u057c",
+	0x054D:  "\u057d",
+	0x054E:  "\u057e",
+	0x054F:  "\u057f",
+	0x0550:  "\u0580",
+	0x0551:  "\u0581",
+	0x0552:  "\u0582",
+	0x0553:  "\u0583",
+	0x0554:  "\u0584",
+	0x0555:  "\u0585",
+	0x0556:  "\u0586",
+	0x0587:  "\u0565\u0582",
+	0x10A0:  "\u2d00",
+	0x10A1:  "\u2d01",
+	0x10A2:  "\u2d02",
+	0x10A3:  "\u2d03",
+	0x10A4:  "\u2d04",
+	0x10A5:  "\u2d05",
+	0x10A6:  "\u2d06",
+	0x10A7:  "\u2d07",
+	0x10A8:  "\u2d08",
+	0x10A9:  "\u2d09",
+	0x10AA:  "\u2d0a",
+	0x10AB:  "\u2d0b",
+	0x10AC:  "\u2d0c",
+	0x10AD:  "\u2d0d",
+	0x10AE:  "\u2d0e",
+	0x10AF:  "\u2d0f",
+	0x10B0:  "\u2d10",
+	0x10B1:  "\u2d11",
+	0x10B2:  "\u2d12",
+	0x10B3:  "\u2d13",
+	0x10B4:  "\u2d14",
+	0x10B5:  "\u2d15",
+	0x10B6:  "\u2d16",
+	0x10B7:  "\u2d17",
+	0x10B8:  "\u2d18",
+	0x10B9:  "\u2d19",
+	0x10BA:  "\u2d1a",
+	0x10BB:  "\u2d1b",
+	0x10BC:  "\u2d1c",
+	0x10BD:  "\u2d1d",
+	0x10BE:  "\u2d1e",
+	0x10BF:  "\u2d1f",
+	0x10C0:  "\u2d20",
+	0x10C1:  "\u2d21",
+	0x10C2:  "\u2d22",
+	0x10C3:  "\u2d23",
+	0x10C4:  "\u2d24",
+	0x10C5:  "\u2d25",
+	0x10C7:  "\u2d27",
+	0x10CD:  "\u2d2d",
+	0x13F8:  "\u13f0",
+	0x13F9:  "\u13f1",
+	0x13FA:  "\u13f2",
+	0x13FB:  "\u13f3",
+	0x13FC:  "\u13f4",
+	0x13FD:  "\u13f5",
+	0x1C80:  "\u0432",
+	0x1C81:  "\u0434",
+	0x1C82:  "\u043e",
+	0x1C83:  "\u0441",
+	0x1C84:  "\u0442",
+	0x1C85:  "\u0442",
+	0x1C86:  "\u044a",
+	0x1C87:  "\u0463",
+	0x1C88:  "\ua64b",
+	0x1C90:  "\u10d0",
+	0x1C91:  "\u10d1",
+	0x1C92:  "\u10d2",
+	0x1C93:  "\u10d3",
+	0x1C94:  "\u10d4",
+	0x1C95:  "\u10d5",
+	0x1C96:  "\u10d6",
+	0x1C97:  "\u10d7",
+	0x1C98:  "\u10d8",
+	0x1C99:  "\u10d9",
+	0x1C9A:  "\u10da",
+	0x1C9B:  "\u10db",
+	0x1C9C:  "\u10dc",
+	0x1C9D:  "\u10dd",
+	0x1C9E:  "\u10de",
+	0x1C9F:  "\u10df",
+	0x1CA0:  "\u10e0",
+	0x1CA1:  "\u10e1",
+	0x1CA2:  "\u10e2",
+	0x1CA3:  "\u10e3",
+	0x1CA4:  "\u10e4",
+	0x1CA5:  "\u10e5",
+	0x1CA6:  "\u10e6",
+	0x1CA7:  "\u10e7",
+	0x1CA8:  "\u10e8",
+	0x1CA9:  "\u10e9",
+	0x1CAA:  "\u10ea",
+	0x1CAB:  "\u10eb",
+	0x1CAC:  "\u10ec",
+	0x1CAD:  "\u10ed",
+	0x1CAE:  "\u10ee",
+	0x1CAF:  "\u10ef",
+	0x1CB0:  "\u10f0",
+	0x1CB1:  "\u10f1",
+	0x1CB2:  "\u10f2",
+	0x1CB3:  "\u10f3",
+	0x1CB4:  "\u10f4",
+	0x1CB5:  "\u10f5",
+	0x1CB6:  "\u10f6",
+	0x1CB7:  "\u10f7",
+	0x1CB8:  "\u10f8",
+	0x1CB9:  "\u10f9",
+	0x1CBA:  "\u10fa",
+	0x1CBD:  "\u10fd",
+	0x1CBE:  "\u10fe",
+	0x1CBF:  "\u10ff",
+	0x1E00:  "\u1e01",
+	0x1E02:  "\u1e03",
+	0x1E04:  "\u1e05",
+	0x1E06:  "\u1e07",
+	0x1E08:  "\u1e09",
+	0x1E0A:  "\u1e0b",
+	0x1E0C:  "\u1e0d",
+	0x1E0E:  "\u1e0f",
+	0x1E10:  "\u1e11",
+	0x1E12:  "\u1e13",
+	0x1E14:  "\u1e15",
+	0x1E16:  "\u1e17",
+	0x1E18:  "\u1e19",
+	0x1E1A:  "\u1e1b",
+	0x1E1C:  "\u1e1d",
+	0x1E1E:  "\u1e1f",
+	0x1E20:  "\u1e21",
+	0x1E22:  "\u1e23",
+	0x1E24:  "\u1e25",
+	0x1E26:  "\u1e27",
+	0x1E28:  "\u1e29",
+	0x1E2A:  "\u1e2b",
+	0x1E2C:  "\u1e2d",
+	0x1E2E:  "\u1e2f",
+	0x1E30:  "\u1e31",
+	0x1E32:  "\u1e33",
+	0x1E34:  "\u1e35",
+	0x1E36:  "\u1e37",
+	0x1E38:  "\u1e39",
+	0x1E3A:  "\u1e3b",
+	0x1E3C:  "\u1e3d",
+	0x1E3E:  "\u1e3f",
+	0x1E40:  "\u1e41",
+	0x1E42:  "\u1e43",
+	0x1E44:  "\u1e45",
+	0x1E46:  "\u1e47",
+	0x1E48:  "\u1e49",
+	0x1E4A:  "\u1e4b",
+	0x1E4C:  "\u1e4d",
+	0x1E4E:  "\u1e4f",
+	0x1E50:  "\u1e51",
+	0x1E52:  "\u1e53",
+	0x1E54:  "\u1e55",
+	0x1E56:  "\u1e57",
+	0x1E58:  "\u1e59",
+	0x1E5A:  "\u1e5b",
+	0x1E5C:  "\u1e5d",
+	0x1E5E:  "\u1e5f",
+	0x1E60:  "\u1e61",
+	0x1E62:  "\u1e63",
+	0x1E64:  "\u1e65",
+	0x1E66:  "\u1e67",
+	0x1E68:  "\u1e69",
+	0x1E6A:  "\u1e6b",
+	0x1E6C:  "\u1e6d",
+	0x1E6E:  "\u1e6f",
+	0x1E70:  "\u1e71",
+	0x1E72:  "\u1e73",
+	0x1E74:  "\u1e75",
+	0x1E76:  "\u1e77",
+	0x1E78:  "\u1e79",
+	0x1E7A:  "\u1e7b",
+	0x1E7C:  "\u1e7d",
+	0x1E7E:  "\u1e7f",
+	0x1E80:  "\u1e81",
+	0x1E82:  "\u1e83",
+	0x1E84:  "\u1e85",
+	0x1E86:  "\u1e87",
+	0x1E88:  "\u1e89",
+	0x1E8A:  "\u1e8b",
+	0x1E8C:  "\u1e8d",
+	0x1E8E:  "\u1e8f",
+	0x1E90:  "\u1e91",
+	0x1E92:  "\u1e93",
+	0x1E94:  "\u1e95",
+	0x1E96:  "h\u0331",
+	0x1E97:  "t\u0308",
+	0x1E98:  "w\u030a",
+	0x1E99:  "y\u030a",
+	0x1E9A:  "a\u02be",
+	0x1E9B:  "\u1e61",
+	0x1E9E:  "ss",
+	0x1EA0:  "\u1ea1",
+	0x1EA2:  "\u1ea3",
+	0x1EA4:  "\u1ea5",
+	0x1EA6:  "\u1ea7",
+	0x1EA8:  "\u1ea9",
+	0x1EAA:  "\u1eab",
+	0x1EAC:  "\u1ead",
+	0x1EAE:  "\u1eaf",
+	0x1EB0:  "\u1eb1",
+	0x1EB2:  "\u1eb3",
+	0x1EB4:  "\u1eb5",
+	0x1EB6:  "\u1eb7",
+	0x1EB8:  "\u1eb9",
+	0x1EBA:  "\u1ebb",
+	0x1EBC:  "\u1ebd",
+	0x1EBE:  "\u1ebf",
+	0x1EC0:  "\u1ec1",
+	0x1EC2:  "\u1ec3",
+	0x1EC4:  "\u1ec5",
+	0x1EC6:  "\u1ec7",
+	0x1EC8:  "\u1ec9",
+	0x1ECA:  "\u1ecb",
+	0x1ECC:  "\u1ecd",
+	0x1ECE:  "\u1ecf",
+	0x1ED0:  "\u1ed1",
+	0x1ED2:  "\u1ed3",
+	0x1ED4:  "\u1ed5",
+	0x1ED6:  "\u1ed7",
+	0x1ED8:  "\u1ed9",
+	0x1EDA:  "\u1edb",
+	0x1EDC:  "\u1edd",
+	0x1EDE:  "\u1edf",
+	0x1EE0:  "\u1ee1",
+	0x1EE2:  "\u1ee3",
+	0x1EE4:  "\u1ee5",
+	0x1EE6:  "\u1ee7",
+	0x1EE8:  "\u1ee9",
+	0x1EEA:  "\u1eeb",
+	0x1EEC:  "\u1eed",
+	0x1EEE:  "\u1eef",
+	0x1EF0:  "\u1ef1",
+	0x1EF2:  "\u1ef3",
+	0x1EF4:  "\u1ef5",
+	0x1EF6:  "\u1ef7",
+	0x1EF8:  "\u1ef9",
+	0x1EFA:  "\u1efb",
+	0x1EFC:  "\u1efd",
+	0x1EFE:  "\u1eff",
+	0x1F08:  "\u1f00",
+	0x1F09:  "\u1f01",
+	0x1F0A:  "\u1f02",
+	0x1F0B:  "\u1f03",
+	0x1F0C:  "\u1f04",
+	0x1F0D:  "\u1f05",
+	0x1F0E:  "\u1f06",
+	0x1F0F:  "\u1f07",
+	0x1F18:  "\u1f10",
+	0x1F19:  "\u1f11",
+	0x1F1A:  "\u1f12",
+	0x1F1B:  "\u1f13",
+	0x1F1C:  "\u1f14",
+	0x1F1D:  "\u1f15",
+	0x1F28:  "\u1f20",
+	0x1F29:  "\u1f21",
+	0x1F2A:  "\u1f22",
+	0x1F2B:  "\u1f23",
+	0x1F2C:  "\u1f24",
+	0x1F2D:  "\u1f25",
+	0x1F2E:  "\u1f26",
+	0x1F2F:  "\u1f27",
+	0x1F38:  "\u1f30",
+	0x1F39:  "\u1f31",
+	0x1F3A:  "\u1f32",
+	0x1F3B:  "\u1f33",
+	0x1F3C:  "\u1f34",
+	0x1F3D:  "\u1f35",
+	0x1F3E:  "\u1f36",
+	0x1F3F:  "\u1f37",
+	0x1F48:  "\u1f40",
+	0x1F49:  "\u1f41",
+	0x1F4A:  "\u1f42",
+	0x1F4B:  "\u1f43",
+	0x1F4C:  "\u1f44",
+	0x1F4D:  "\u1f45",
+	0x1F50:  "\u03c5\u0313",
+	0x1F52:  "\u03c5\u0313\u0300",
+	0x1F54:  "\u03c5\u0313\u0301",
+	0x1F56:  "\u03c5\u0313\u0342",
+	0x1F59:  "\u1f51",
+	0x1F5B:  "\u1f53",
+	0x1F5D:  "\u1f55",
+	0x1F5F:  "\u1f57",
+	0x1F68:  "\u1f60",
+	0x1F69:  "\u1f61",
+	0x1F6A:  "\u1f62",
+	0x1F6B:  "\u1f63",
+	0x1F6C:  "\u1f64",
+	0x1F6D:  "\u1f65",
+	0x1F6E:  "\u1f66",
+	0x1F6F:  "\u1f67",
+	0x1F80:  "\u1f00\u03b9",
+	0x1F81:  "\u1f01\u03b9",
+	0x1F82:  "\u1f02\u03b9",
+	0x1F83:  "\u1f03\u03b9",
+	0x1F84:  "\u1f04\u03b9",
+	0x1F85:  "\u1f05\u03b9",
+	0x1F86:  "\u1f06\u03b9",
+	0x1F87:  "\u1f07\u03b9",
+	0x1F88:  "\u1f00\u03b9",
+	0x1F89:  "\u1f01\u03b9",
+	0x1F8A:  "\u1f02\u03b9",
+	0x1F8B:  "\u1f03\u03b9",
+	0x1F8C:  "\u1f04\u03b9",
+	0x1F8D:  "\u1f05\u03b9",
+	0x1F8E:  "\u1f06\u03b9",
+	0x1F8F:  "\u1f07\u03b9",
+	0x1F90:  "\u1f20\u03b9",
+	0x1F91:  "\u1f21\u03b9",
+	0x1F92:  "\u1f22\u03b9",
+	0x1F93:  "\u1f23\u03b9",
+	0x1F94:  "\u1f24\u03b9",
+	0x1F95:  "\u1f25\u03b9",
+	0x1F96:  "\u1f26\u03b9",
+	0x1F97:  "\u1f27\u03b9",
+	0x1F98:  "\u1f20\u03b9",
+	0x1F99:  "\u1f21\u03b9",
+	0x1F9A:  "\u1f22\u03b9",
+	0x1F9B:  "\u1f23\u03b9",
+	0x1F9C:  "\u1f24\u03b9",
+	0x1F9D:  "\u1f25\u03b9",
+	0x1F9E:  "\u1f26\u03b9",
+	0x1F9F:  "\u1f27\u03b9",
+	0x1FA0:  "\u1f60\u03b9",
+	0x1FA1:  "\u1f61\u03b9",
+	0x1FA2:  "\u1f62\u03b9",
+	0x1FA3:  "\u1f63\u03b9",
+	0x1FA4:  "\u1f64\u03b9",
+	0x1FA5:  "\u1f65\u03b9",
+	0x1FA6:  "\u1f66\u03b9",
+	0x1FA7:  "\u1f67\u03b9",
+	0x1FA8:  "\u1f60\u03b9",
+	0x1FA9:  "\u1f61\u03b9",
+	0x1FAA:  "\u1f62\u03b9",
+	0x1FAB:  "\u1f63\u03b9",
+	0x1FAC:  "\u1f64\u03b9",
+	0x1FAD:  "\u1f65\u03b9",
+	0x1FAE:  "\u1f66\u03b9",
+	0x1FAF:  "\u1f67\u03b9",
+	0x1FB2:  "\u1f70\u03b9",
+	0x1FB3:  "\u03b1\u03b9",
+	0x1FB4:  "\u03ac\u03b9",
+	0x1FB6:  "\u03b1\u0342",
+	0x1FB7:  "\u03b1\u0342\u03b9",
+	0x1FB8:  "\u1fb0",
+	0x1FB9:  "\u1fb1",
+	0x1FBA:  "\u1f70",
+	0x1FBB:  "\u1f71",
+	0x1FBC:  "\u03b1\u03b9",
+	0x1FBE:  "\u03b9",
+	0x1FC2:  "\u1f74\u03b9",
+	0x1FC3:  "\u03b7\u03b9",
+	0x1FC4:  "\u03ae\u03b9",
+	0x1FC6:  "\u03b7\u0342",
+	0x1FC7:  "\u03b7\u0342\u03b9",
+	0x1FC8:  "\u1f72",
+	0x1FC9:  "\u1f73",
+	0x1FCA:  "\u1f74",
+	0x1FCB:  "\u1f75",
+	0x1FCC:  "\u03b7\u03b9",
+	0x1FD2:  "\u03b9\u0308\u0300",
+	0x1FD3:  "\u03b9\u0308\u0301",
+	0x1FD6:  "\u03b9\u0342",
+	0x1FD7:  "\u03b9\u0308\u0342",
+	0x1FD8:  "\u1fd0",
+	0x1FD9:  "\u1fd1",
+	0x1FDA:  "\u1f76",
+	0x1FDB:  "\u1f77",
+	0x1FE2:  "\u03c5\u0308\u0300",
+	0x1FE3:  "\u03c5\u0308\u0301",
+	0x1FE4:  "\u03c1\u0313",
+	0x1FE6:  "\u03c5\u0342",
+	0x1FE7:  "\u03c5\u0308\u0342",
+	0x1FE8:  "\u1fe0",
+	0x1FE9:  "\u1fe1",
+	0x1FEA:  "\u1f7a",
+	0x1FEB:  "\u1f7b",
+	0x1FEC:  "\u1fe5",
+	0x1FF2:  "\u1f7c\u03b9",
+	0x1FF3:  "\u03c9\u03b9",
+	0x1FF4:  "\u03ce\u03b9",
+	0x1FF6:  "\u03c9\u0342",
+	0x1FF7:  "\u03c9\u0342\u03b9",
+	0x1FF8:  "\u1f78",
+	0x1FF9:  "\u1f79",
+	0x1FFA:  "\u1f7c",
+	0x1FFB:  "\u1f7d",
+	0x1FFC:  "\u03c9\u03b9",
+	0x2126:  "\u03c9",
+	0x212A:  "k",
+	0x212B:  "\u00e5",
+	0x2132:  "\u214e",
+	0x2160:  "\u2170",
+	0x2161:  "\u2171",
+	0x2162:  "\u2172",
+	0x2163:  "\u2173",
+	0x2164:  "\u2174",
+	0x2165:  "\u2175",
+	0x2166:  "\u2176",
+	0x2167:  "\u2177",
+	0x2168:  "\u2178",
+	0x2169:  "\u2179",
+	0x216A:  "\u217a",
+	0x216B:  "\u217b",
+	0x216C:  "\u217c",
+	0x216D:  "\u217d",
+	0x216E:  "\u217e",
+	0x216F:  "\u217f",
+	0x2183:  "\u2184",
+	0x24B6:  "\u24d0",
+	0x24B7:  "\u24d1",
+	0x24B8:  "\u24d2",
+	0x24B9:  "\u24d3",
+	0x24BA:  "\u24d4",
+	0x24BB:  "\u24d5",
+	0x24BC:  "\u24d6",
+	0x24BD:  "\u24d7",
+	0x24BE:  "\u24d8",
+	0x24BF:  "\u24d9",
+	0x24C0:  "\u24da",
+	0x24C1:  "\u24db",
+	0x24C2:  "\u24dc",
+	0x24C3:  "\u24dd",
+	0x24C4:  "\u24de",
+	0x24C5:  "\u24df",
+	0x24C6:  "\u24e0",
+	0x24C7:  "\u24e1",
+	0x24C8:  "\u24e2",
+	0x24C9:  "\u24e3",
+	0x24CA:  "\u24e4",
+	0x24CB:  "\u24e5",
+	0x24CC:  "\u24e6",
+	0x24CD:  "\u24e7",
+	0x24CE:  "\u24e8",
+	0x24CF:  "\u24e9",
+	0x2C00:  "\u2c30",
+	0x2C01:  "\u2c31",
+	0x2C02:  "\u2c32",
+	0x2C03:  "\u2c33",
+	0x2C04:  "\u2c34",
+	0x2C05:  "\u2c35",
+	0x2C06:  "\u2c36",
+	0x2C07:  "\u2c37",
+	0x2C08:  "\u2c38",
+	0x2C09:  "\u2c39",
+	0x2C0A:  "\u2c3a",
+	0x2C0B:  "\u2c3b",
+	0x2C0C:  "\u2c3c",
+	0x2C0D:  "\u2c3d",
+	0x2C0E:  "\u2c3e",
+	0x2C0F:  "\u2c3f",
+	0x2C10:  "\u2c40",
+	0x2C11:  "\u2c41",
+	0x2C12:  "\u2c42",
+	0x2C13:  "\u2c43",
+	0x2C14:  "\u2c44",
+	0x2C15:  "\u2c45",
+	0x2C16:  "\u2c46",
+	0x2C17:  "\u2c47",
+	0x2C18:  "\u2c48",
+	0x2C19:  "\u2c49",
+	0x2C1A:  "\u2c4a",
+	0x2C1B:  "\u2c4b",
+	0x2C1C:  "\u2c4c",
+	0x2C1D:  "\u2c4d",
+	0x2C1E:  "\u2c4e",
+	0x2C1F:  "\u2c4f",
+	0x2C20:  "\u2c50",
+	0x2C21:  "\u2c51",
+	0x2C22:  "\u2c52",
+	0x2C23:  "\u2c53",
+	0x2C24:  "\u2c54",
+	0x2C25:  "\u2c55",
+	0x2C26:  "\u2c56",
+	0x2C27:  "\u2c57",
+	0x2C28:  "\u2c58",
+	0x2C29:  "\u2c59",
+	0x2C2A:  "\u2c5a",
+	0x2C2B:  "\u2c5b",
+	0x2C2C:  "\u2c5c",
+	0x2C2D:  "\u2c5d",
+	0x2C2E:  "\u2c5e",
+	0x2C2F:  "\u2c5f",
+	0x2C60:  "\u2c61",
+	0x2C62:  "\u026b",
+	0x2C63:  "\u1d7d",
+	0x2C64:  "\u027d",
+	0x2C67:  "\u2c68",
+	0x2C69:  "\u2c6a",
+	0x2C6B:  "\u2c6c",
+	0x2C6D:  "\u0251",
+	0x2C6E:  "\u0271",
+	0x2C6F:  "\u0250",
+	0x2C70:  "\u0252",
+	0x2C72:  "\u2c73",
+	0x2C75:  "\u2c76",
+	0x2C7E:  "\u023f",
+	0x2C7F:  "\u0240",
+	0x2C80:  "\u2c81",
+	0x2C82:  "\u2c83",
+	0x2C84:  "\u2c85",
+	0x2C86:  "\u2c87",
+	0x2C88:  "\u2c89",
+	0x2C8A:  "\u2c8b",
+	0x2C8C:  "\u2c8d",
+	0x2C8E:  "\u2c8f",
+	0x2C90:  "\u2c91",
+	0x2C92:  "\u2c93",
+	0x2C94:  "\u2c95",
+	0x2C96:  "\u2c97",
+	0x2C98:  "\u2c99",
+	0x2C9A:  "\u2c9b",
+	0x2C9C:  "\u2c9d",
+	0x2C9E:  "\u2c9f",
+	0x2CA0:  "\u2ca1",
+	0x2CA2:  "\u2ca3",
+	0x2CA4:  "\u2ca5",
+	0x2CA6:  "\u2ca7",
+	0x2CA8:  "\u2ca9",
+	0x2CAA:  "\u2cab",
+	0x2CAC:  "\u2cad",
+	0x2CAE:  "\u2caf",
+	0x2CB0:  "\u2cb1",
+	0x2CB2:  "\u2cb3",
+	0x2CB4:  "\u2cb5",
+	0x2CB6:  "\u2cb7",
+	0x2CB8:  "\u2cb9",
+	0x2CBA:  "\u2cbb",
+	0x2CBC:  "\u2cbd",
+	0x2CBE:  "\u2cbf",
+	0x2CC0:  "\u2cc1",
+	0x2CC2:  "\u2cc3",
+	0x2CC4:  "\u2cc5",
+	0x2CC6:  "\u2cc7",
+	0x2CC8:  "\u2cc9",
+	0x2CCA:  "\u2ccb",
+	0x2CCC:  "\u2ccd",
+	0x2CCE:  "\u2ccf",
+	0x2CD0:  "\u2cd1",
+	0x2CD2:  "\u2cd3",
+	0x2CD4:  "\u2cd5",
+	0x2CD6:  "\u2cd7",
+	0x2CD8:  "\u2cd9",
+	0x2CDA:  "\u2cdb",
+	0x2CDC:  "\u2cdd",
+	0x2CDE:  "\u2cdf",
+	0x2CE0:  "\u2ce1",
+	0x2CE2:  "\u2ce3",
+	0x2CEB:  "\u2cec",
+	0x2CED:  "\u2cee",
+	0x2CF2:  "\u2cf3",
+	0xA640:  "\ua641",
+	0xA642:  "\ua643",
+	0xA644:  "\ua645",
+	0xA646:  "\ua647",
+	0xA648:  "\ua649",
+	0xA64A:  "\ua64b",
+	0xA64C:  "\ua64d",
+	0xA64E:  "\ua64f",
+	0xA650:  "\ua651",
+	0xA652:  "\ua653",
+	0xA654:  "\ua655",
+	0xA656:  "\ua657",
+	0xA658:  "\ua659",
+	0xA65A:  "\ua65b",
+	0xA65C:  "\ua65d",
+	0xA65E:  "\ua65f",
+	0xA660:  "\ua661",
+	0xA662:  "\ua663",
+	0xA664:  "\ua665",
+	0xA666:  "\ua667",
+	0xA668:  "\ua669",
+	0xA66A:  "\ua66b",
+	0xA66C:  "\ua66d",
+	0xA680:  "\ua681",
+	0xA682:  "\ua683",
+	0xA684:  "\ua685",
+	0xA686:  "\ua687",
+	0xA688:  "\ua689",
+	0xA68A:  "\ua68b",
+	0xA68C:  "\ua68d",
+	0xA68E:  "\ua68f",
+	0xA690:  "\ua691",
+	0xA692:  "\ua693",
+	0xA694:  "\ua695",
+	0xA696:  "\ua697",
+	0xA698:  "\ua699",
+	0xA69A:  "\ua69b",
+	0xA722:  "\ua723",
+	0xA724:  "\ua725",
+	0xA726:  "\ua727",
+	0xA728:  "\ua729",
+	0xA72A:  "\ua72b",
+	0xA72C:  "\ua72d",
+	0xA72E:  "\ua72f",
+	0xA732:  "\ua733",
+	0xA734:  "\ua735",
+	0xA736:  "\ua737",
+	0xA738:  "\ua739",
+	0xA73A:  "\ua73b",
+	0xA73C:  "\ua73d",
+	0xA73E:  "\ua73f",
+	0xA740:  "\ua741",
+	0xA742:  "\ua743",
+	0xA744:  "\ua745",
+	0xA746:  "\ua747",
+	0xA748:  "\ua749",
+	0xA74A:  "\ua74b",
+	0xA74C:  "\ua74d",
+	0xA74E:  "\ua74f",
+	0xA750:  "\ua751",
+	0xA752:  "\ua753",
+	0xA754:  "\ua755",
+	0xA756:  "\ua757",
+	0xA758:  "\ua759",
+	0xA75A:  "\ua75b",
+	0xA75C:  "\ua75d",
+	0xA75E:  "\ua75f",
+	0xA760:  "\ua761",
+	0xA762:  "\ua763",
+	0xA764:  "\ua765",
+	0xA766:  "\ua767",
+	0xA768:  "\ua769",
+	0xA76A:  "\ua76b",
+	0xA76C:  "\ua76d",
+	0xA76E:  "\ua76f",
+	0xA779:  "\ua77a",
+	0xA77B:  "\ua77c",
+	0xA77D:  "\u1d79",
+	0xA77E:  "\ua77f",
+	0xA780:  "\ua781",
+	0xA782:  "\ua783",
+	0xA784:  "\ua785",
+	0xA786:  "\ua787",
+	0xA78B:  "\ua78c",
+	0xA78D:  "\u0265",
+	0xA790:  "\ua791",
+	0xA792:  "\ua793",
+	0xA796:  "\ua797",
+	0xA798:  "\ua799",
+	0xA79A:  "\ua79b",
+	0xA79C:  "\ua79d",
+	0xA79E:  "\ua79f",
+	0xA7A0:  "\ua7a1",
+	0xA7A2:  "\ua7a3",
+	0xA7A4:  "\ua7a5",
+	0xA7A6:  "\ua7a7",
+	0xA7A8:  "\ua7a9",
+	0xA7AA:  "\u0266",
+	0xA7AB:  "\u025c",
+	0xA7AC:  "\u0261",
+	0xA7AD:  "\u026c",
+	0xA7AE:  "\u026a",
+	0xA7B0:  "\u029e",
+	0xA7B1:  "\u0287",
+	0xA7B2:  "\u029d",
+	0xA7B3:  "\uab53",
+	0xA7B4:  "\ua7b5",
+	0xA7B6:  "\ua7b7",
+	0xA7B8:  "\ua7b9",
+	0xA7BA:  "\ua7bb",
+	0xA7BC:  "\ua7bd",
+	0xA7BE:  "\ua7bf",
+	0xA7C0:  "\ua7c1",
+	0xA7C2:  "\ua7c3",
+	0xA7C4:  "\ua794",
+	0xA7C5:  "\u0282",
+	0xA7C6:  "\u1d8e",
+	0xA7C7:  "\ua7c8",
+	0xA7C9:  "\ua7ca",
+	0xA7D0:  "\ua7d1",
+	0xA7D6:  "\ua7d7",
+	0xA7D8:  "\ua7d9",
+	0xA7F5:  "\ua7f6",
+	0xAB70:  "\u13a0",
+	0xAB71:  "\u13a1",
+	0xAB72:  "\u13a2",
+	0xAB73:  "\u13a3",
+	0xAB74:  "\u13a4",
+	0xAB75:  "\u13a5",
+	0xAB76:  "\u13a6",
+	0xAB77:  "\u13a7",
+	0xAB78:  "\u13a8",
+	0xAB79:  "\u13a9",
+	0xAB7A:  "\u13aa",
+	0xAB7B:  "\u13ab",
+	0xAB7C:  "\u13ac",
+	0xAB7D:  "\u13ad",
+	0xAB7E:  "\u13ae",
+	0xAB7F:  "\u13af",
+	0xAB80:  "\u13b0",
+	0xAB81:  "\u13b1",
+	0xAB82:  "\u13b2",
+	0xAB83:  "\u13b3",
+	0xAB84:  "\u13b4",
+	0xAB85:  "\u13b5",
+	0xAB86:  "\u13b6",
+	0xAB87:  "\u13b7",
+	0xAB88:  "\u13b8",
+	0xAB89:  "\u13b9",
+	0xAB8A:  "\u13ba",
+	0xAB8B:  "\u13bb",
+	0xAB8C:  "\u13bc",
+	0xAB8D:  "\u13bd",
+	0xAB8E:  "\u13be",
+	0xAB8F:  "\u13bf",
+	0xAB90:  "\u13c0",
+	0xAB91:  "\u13c1",
+	0xAB92:  "\u13c2",
+	0xAB93:  "\u13c3",
+	0xAB94:  "\u13c4",
+	0xAB95:  "\u13c5",
+	0xAB96:  "\u13c6",
+	0xAB97:  "\u13c7",
+	0xAB98:  "\u13c8",
+	0xAB99:  "\u13c9",
+	0xAB9A:  "\u13ca",
+	0xAB9B:  "\u13cb",
+	0xAB9C:  "\u13cc",
+	0xAB9D:  "\u13cd",
+	0xAB9E:  "\u13ce",
+	0xAB9F:  "\u13cf",
+	0xABA0:  "\u13d0",
+	0xABA1:  "\u13d1",
+	0xABA2:  "\u13d2",
+	0xABA3:  "\u13d3",
+	0xABA4:  "\u13d4",
+	0xABA5:  "\u13d5",
+	0xABA6:  "\u13d6",
+	0xABA7:  "\u13d7",
+	0xABA8:  "\u13d8",
+	0xABA9:  "\u13d9",
+	0xABAA:  "\u13da",
+	0xABAB:  "\u13db",
+	0xABAC:  "\u13dc",
+	0xABAD:  "\u13dd",
+	0xABAE:  "\u13de",
+	0xABAF:  "\u13df",
+	0xABB0:  "\u13e0",
+	0xABB1:  "\u13e1",
+	0xABB2:  "\u13e2",
+	0xABB3:  "\u13e3",
+	0xABB4:  "\u13e4",
+	0xABB5:  "\u13e5",
+	0xABB6:  "\u13e6",
+	0xABB7:  "\u13e7",
+	0xABB8:  "\u13e8",
+	0xABB9:  "\u13e9",
+	0xABBA:  "\u13ea",
+	0xABBB:  "\u13eb",
+	0xABBC:  "\u13ec",
+	0xABBD:  "\u13ed",
+	0xABBE:  "\u13ee",
+	0xABBF:  "\u13ef",
+	0xFB00:  "ff",
+	0xFB01:  "fi",
+	0xFB02:  "fl",
+	0xFB03:  "ffi",
+	0xFB04:  "ffl",
+	0xFB05:  "st",
+	0xFB06:  "st",
+	0xFB13:  "\u0574\u0576",
+	0xFB14:  "\u0574\u0565",
+	0xFB15:  "\u0574\u056b",
+	0xFB16:  "\u057e\u0576",
+	0xFB17:  "\u0574\u056d",
+	0xFF21:  "\uff41",
+	0xFF22:  "\uff42",
+	0xFF23:  "\uff43",
+	0xFF24:  "\uff44",
+	0xFF25:  "\uff45",
+	0xFF26:  "\uff46",
+	0xFF27:  "\uff47",
+	0xFF28:  "\uff48",
+	0xFF29:  "\uff49",
+	0xFF2A:  "\uff4a",
+	0xFF2B:  "\uff4b",
+	0xFF2C:  "\uff4c",
+	0xFF2D:  "\uff4d",
+	0xFF2E:  "\uff4e",
+	0xFF2F:  "\uff4f",
+	0xFF30:  "\uff50",
+	0xFF31:  "\uff51",
+	0xFF32:  "\uff52",
+	0xFF33:  "\uff53",
+	0xFF34:  "\uff54",
+	0xFF35:  "\uff55",
+	0xFF36:  "\uff56",
+	0xFF37:  "\uff57",
+	0xFF38:  "\uff58",
+	0xFF39:  "\uff59",
+	0xFF3A:  "\uff5a",
+	0x10400: "\U00010428",
+	0x10401: "\U00010429",
+	0x10402: "\U0001042a",
+	0x10403: "\U0001042b",
+	0x10404: "\U0001042c",
+	0x10405: "\U0001042d",
+	0x10406: "\U0001042e",
+	0x10407: "\U0001042f",
+	0x10408: "\U00010430",
+	0x10409: "\U00010431",
+	0x1040A: "\U00010432",
+	0x1040B: "\U00010433",
+	0x1040C: "\U00010434",
+	0x1040D: "\U00010435",
+	0x1040E: "\U00010436",
+	0x1040F: "\U00010437",
+	0x10410: "\U00010438",
+	0x10411: "\U00010439",
+	0x10412: "\U0001043a",
+	0x10413: "\U0001043b",
+	0x10414: "\U0001043c",
+	0x10415: "\U0001043d",
+	0x10416: "\U0001043e",
+	0x10417: "\U0001043f",
+	0x10418: "\U00010440",
+	0x10419: "\U00010441",
+	0x1041A: "\U00010442",
+	0x1041B: "\U00010443",
+	0x1041C: "\U00010444",
+	0x1041D: "\U00010445",
+	0x1041E: "\U00010446",
+	0x1041F: "\U00010447",
+	0x10420: "\U00010448",
+	0x10421: "\U00010449",
+	0x10422: "\U0001044a",
+	0x10423: "\U0001044b",
+	0x10424: "\U0001044c",
+	0x10425: "\U0001044d",
+	0x10426: "\U0001044e",
+	0x10427: "\U0001044f",
+	0x104B0: "\U000104d8",
+	0x104B1: "\U000104d9",
+	0x104B2: "\U000104da",
+	0x104B3: "\U000104db",
+	0x104B4: "\U000104dc",
+	0x104B5: "\U000104dd",
+	0x104B6: "\U000104de",
+	0x104B7: "\U000104df",
+	0x104B8: "\U000104e0",
+	0x104B9: "\U000104e1",
+	0x104BA: "\U000104e2",
+	0x104BB: "\U000104e3",
+	0x104BC: "\U000104e4",
+	0x104BD: "\U000104e5",
+	0x104BE: "\U000104e6",
+	0x104BF: "\U000104e7",
+	0x104C0: "\U000104e8",
+	0x104C1: "\U000104e9",
+	0x104C2: "\U000104ea",
+	0x104C3: "\U000104eb",
+	0x104C4: "\U000104ec",
+	0x104C5: "\U000104ed",
+	0x104C6: "\U000104ee",
+	0x104C7: "\U000104ef",
+	0x104C8: "\U000104f0",
+	0x104C9: "\U000104f1",
+	0x104CA: "\U000104f2",
+	0x104CB: "\U000104f3",
+	0x104CC: "\U000104f4",
+	0x104CD: "\U000104f5",
+	0x104CE: "\U000104f6",
+	0x104CF: "\U000104f7",
+	0x104D0: "\U000104f8",
+	0x104D1: "\U000104f9",
+	0x104D2: "\U000104fa",
+	0x104D3: "\U000104fb",
+	0x10570: "\U00010597",
+	0x10571: "\U00010598",
+	0x10572: "\U00010599",
+	0x10573: "\U0001059a",
+	0x10574: "\U0001059b",
+	0x10575: "\U0001059c",
+	0x10576: "\U0001059d",
+	0x10577: "\U0001059e",
+	0x10578: "\U0001059f",
+	0x10579: "\U000105a0",
+	0x1057A: "\U000105a1",
+	0x1057C: "\U000105a3",
+	0x1057D: "\U000105a4",
+	0x1057E: "\U000105a5",
+	0x1057F: "\U000105a6",
+	0x10580: "\U000105a7",
+	0x10581: "\U000105a8",
+	0x10582: "\U000105a9",
+	0x10583: "\U000105aa",
+	0x10584: "\U000105ab",
+	0x10585: "\U000105ac",
+	0x10586: "\U000105ad",
+	0x10587: "\U000105ae",
+	0x10588: "\U000105af",
+	0x10589: "\U000105b0",
+	0x1058A: "\U000105b1",
+	0x1058C: "\U000105b3",
+	0x1058D: "\U000105b4",
+	0x1058E: "\U000105b5",
+	0x1058F: "\U000105b6",
+	0x10590: "\U000105b7",
+	0x10591: "\U000105b8",
+	0x10592: "\U000105b9",
+	0x10594: "\U000105bb",
+	0x10595: "\U000105bc",
+	0x10C80: "\U00010cc0",
+	0x10C81: "\U00010cc1",
+	0x10C82: "\U00010cc2",
+	0x10C83: "\U00010cc3",
+	0x10C84: "\U00010cc4",
+	0x10C85: "\U00010cc5",
+	0x10C86: "\U00010cc6",
+	0x10C87: "\U00010cc7",
+	0x10C88: "\U00010cc8",
+	0x10C89: "\U00010cc9",
+	0x10C8A: "\U00010cca",
+	0x10C8B: "\U00010ccb",
+	0x10C8C: "\U00010ccc",
+	0x10C8D: "\U00010ccd",
+	0x10C8E: "\U00010cce",
+	0x10C8F: "\U00010ccf",
+	0x10C90: "\U00010cd0",
+	0x10C91: "\U00010cd1",
+	0x10C92: "\U00010cd2",
+	0x10C93: "\U00010cd3",
+	0x10C94: "\U00010cd4",
+	0x10C95: "\U00010cd5",
+	0x10C96: "\U00010cd6",
+	0x10C97: "\U00010cd7",
+	0x10C98: "\U00010cd8",
+	0x10C99: "\U00010cd9",
+	0x10C9A: "\U00010cda",
+	0x10C9B: "\U00010cdb",
+	0x10C9C: "\U00010cdc",
+	0x10C9D: "\U00010cdd",
+	0x10C9E: "\U00010cde",
+	0x10C9F: "\U00010cdf",
+	0x10CA0: "\U00010ce0",
+	0x10CA1: "\U00010ce1",
+	0x10CA2: "\U00010ce2",
+	0x10CA3: "\U00010ce3",
+	0x10CA4: "\U00010ce4",
+	0x10CA5: "\U00010ce5",
+	0x10CA6: "\U00010ce6",
+	0x10CA7: "\U00010ce7",
+	0x10CA8: "\U00010ce8",
+	0x10CA9: "\U00010ce9",
+	0x10CAA: "\U00010cea",
+	0x10CAB: "\U00010ceb",
+	0x10CAC: "\U00010cec",
+	0x10CAD: "\U00010ced",
+	0x10CAE: "\U00010cee",
+	0x10CAF: "\U00010cef",
+	0x10CB0: "\U00010cf0",
+	0x10CB1: "\U00010cf1",
+	0x10CB2: "\U00010cf2",
+	0x118A0: "\U000118c0",
+	0x118A1: "\U000118c1",
+	0x118A2: "\U000118c2",
+	0x118A3: "\U000118c3",
+	0x118A4: "\U000118c4",
+	0x118A5: "\U000118c5",
+	0x118A6: "\U000118c6",
+	0x118A7: "\U000118c7",
+	0x118A8: "\U000118c8",
+	0x118A9: "\U000118c9",
+	0x118AA: "\U000118ca",
+	0x118AB: "\U000118cb",
+	0x118AC: "\U000118cc",
+	0x118AD: "\U000118cd",
+	0x118AE: "\U000118ce",
+	0x118AF: "\U000118cf",
+	0x118B0: "\U000118d0",
+	0x118B1: "\U000118d1",
+	0x118B2: "\U000118d2",
+	0x118B3: "\U000118d3",
+	0x118B4: "\U000118d4",
+	0x118B5: "\U000118d5",
+	0x118B6: "\U000118d6",
+	0x118B7: "\U000118d7",
+	0x118B8: "\U000118d8",
+	0x118B9: "\U000118d9",
+	0x118BA: "\U000118da",
+	0x118BB: "\U000118db",
+	0x118BC: "\U000118dc",
+	0x118BD: "\U000118dd",
+	0x118BE: "\U000118de",
+	0x118BF: "\U000118df",
+	0x16E40: "\U00016e60",
+	0x16E41: "\U00016e61",
+	0x16E42: "\U00016e62",
+	0x16E43: "\U00016e63",
+	0x16E44: "\U00016e64",
+	0x16E45: "\U00016e65",
+	0x16E46: "\U00016e66",
+	0x16E47: "\U00016e67",
+	0x16E48: "\U00016e68",
+	0x16E49: "\U00016e69",
+	0x16E4A: "\U00016e6a",
+	0x16E4B: "\U00016e6b",
+	0x16E4C: "\U00016e6c",
+	0x16E4D: "\U00016e6d",
+	0x16E4E: "\U00016e6e",
+	0x16E4F: "\U00016e6f",
+	0x16E50: "\U00016e70",
+	0x16E51: "\U00016e71",
+	0x16E52: "\U00016e72",
+	0x16E53: "\U00016e73",
+	0x16E54: "\U00016e74",
+	0x16E55: "\U00016e75",
+	0x16E56: "\U00016e76",
+	0x16E57: "\U00016e77",
+	0x16E58: "\U00016e78",
+	0x16E59: "\U00016e79",
+	0x16E5A: "\U00016e7a",
+	0x16E5B: "\U00016e7b",
+	0x16E5C: "\U00016e7c",
+	0x16E5D: "\U00016e7d",
+	0x16E5E: "\U00016e7e",
+	0x16E5F: "\U00016e7f",
+	0x1E900: "\U0001e922",
+	0x1E901: "\U0001e923",
+	0x1E902: "\U0001e924",
+	0x1E903: "\U0001e925",
+	0x1E904: "\U0001e926",
+	0x1E905: "\U0001e927",
+	0x1E906: "\U0001e928",
+	0x1E907: "\U0001e929",
+	0x1E908: "\U0001e92a",
+	0x1E909: "\U0001e92b",
+	0x1E90A: "\U0001e92c",
+	0x1E90B: "\U0001e92d",
+	0x1E90C: "\U0001e92e",
+	0x1E90D: "\U0001e92f",
+	0x1E90E: "\U0001e930",
+	0x1E90F: "\U0001e931",
+	0x1E910: "\U0001e932",
+	0x1E911: "\U0001e933",
+	0x1E912: "\U0001e934",
+	0x1E913: "\U0001e935",
+	0x1E914: "\U0001e936",
+	0x1E915: "\U0001e937",
+	0x1E916: "\U0001e938",
+	0x1E917: "\U0001e939",
+	0x1E918: "\U0001e93a",
+	0x1E919: "\U0001e93b",
+	0x1E91A: "\U0001e93c",
+	0x1E91B: "\U0001e93d",
+	0x1E91C: "\U0001e93e",
+	0x1E91D: "\U0001e93f",
+	0x1E91E: "\U0001e940",
+	0x1E91F: "\U0001e941",
+	0x1E920: "\U0001e942",
+	0x1E921: "\U0001e943",
+}
@@ -0,0 +1,38 @@
+package crest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNFC(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{"plain ascii", "plain ascii"},
+		{"cafe\u0301", "caf\u00E9"},
+		{"caf\u00E9", "caf\u00E9"},
+		{"A\u030A", "\u00C5"},
+		{"\u212B", "\u00C5"},
+		{"q\u0323\u0307", "q\u0323\u0307"},
+		{"q\u0307\u0323", "q\u0323\u0307"},
+		{"s\u0323\u0307", "\u1E69"},
+		{"s\u0307\u0323", "\u1E69"},
+		{"\u1100\u1161\u11A8", "\uAC01"},
+		{"\uAC00\u11A8", "\uAC01"},
+		{"\u0958", "\u0915\u093C"},
+	}
+	for _, testCase := range testCases {
+		require.Equal(t, testCase.expected, nfc(testCase.in), "in = %+q", testCase.in)
+	}
+}
+
+func TestFoldCase(t *testing.T) {
+	require.Equal(t, foldCase("STRASSE"), foldCase("strasse"))
+	require.Equal(t, foldCase("ΣΊΣΥΦΟΣ"), foldCase("σίσυφος"))
+	require.Equal(t, foldCase("Straße"), foldCase("STRASSE"))
+	require.Equal(t, foldCase("\uFB03"), foldCase("FFI"))
+	require.NotEqual(t, foldCase("Straße"), foldCase("STRASE"))
+}
@@ -0,0 +1,94 @@
+package crest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// UseOAuth2ClientCredentials sends a bearer token obtained from the OAuth2
+// token endpoint at tokenURL with the client credentials grant. The token is
+// cached until shortly before it expires, and fetched again if a request is
+// answered with 401, in which case the request is sent once more with the
+// new token. The token requests share only the transport and timeout of the
+// client, and are not made in dry-run mode, where requests go without a
+// token.
+func (c *client) UseOAuth2ClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	// The token endpoint is not part of the API, so none of the headers,
+	// versioning, hooks, signing or recording of the client apply to it.
+	tokenClient := NewCustomClient(c.baseURL, c.httpClient).(*client)
+	tokenClient.dial = c.dial
+	tokenClient.dialTransport = c.dialTransport
+	tokenClient.timeout = c.timeout
+	// RFC 6749 section 2.3.1 has the credentials form-encoded before they
+	// are used as the basic auth user and password.
+	tokenClient.useBasicAuth = true
+	tokenClient.basicAuthUser = url.QueryEscape(clientID)
+	tokenClient.basicAuthPass = url.QueryEscape(clientSecret)
+	c.auth = newBearerSession(func() (string, time.Time, error) {
+		return tokenClient.detached().clientCredentialsToken(tokenURL, scopes)
+	})
+	c.auth.refreshOnUnauthorized = true
+	c.auth.remote = true
+	return c
+}
+
+// clientCredentialsToken requests an access token from the token endpoint
+// at tokenURL.
+func (c *client) clientCredentialsToken(tokenURL string, scopes []string) (string, time.Time, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "creating token request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	issued := time.Now()
+	rw := c.do(c.populateReq(req))
+	if err := c.Error(); err != nil {
+		return "", time.Time{}, errors.Wrap(err, "requesting OAuth2 token")
+	}
+	if rw.DryRun() {
+		return "", time.Time{}, errors.Wrap(ErrDryRun, "requesting OAuth2 token")
+	}
+	if rw.Response().StatusCode != http.StatusOK {
+		return "", time.Time{}, errors.Errorf("requesting OAuth2 token: got status %v: %v", rw.Response().Status, rw.Body())
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal([]byte(rw.Body()), &token); err != nil {
+		return "", time.Time{}, errors.Wrap(err, "unmarshalling OAuth2 token")
+	}
+	if token.AccessToken == "" {
+		return "", time.Time{}, errors.New("the OAuth2 token response has no access_token")
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return "", time.Time{}, errors.Errorf("unsupported OAuth2 token type %q", token.TokenType)
+	}
+	var expires time.Time
+	if token.ExpiresIn > 0 {
+		// Renew a little early so that no request is sent with a token
+		// about to expire.
+		lifetime := time.Duration(token.ExpiresIn) * time.Second
+		expires = issued.Add(lifetime - lifetime/10)
+	}
+	return token.AccessToken, expires, nil
+}
@@ -0,0 +1,96 @@
+package crest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUseOAuth2ClientCredentials(t *testing.T) {
+	tokens := 0
+	revoked := make(map[string]bool)
+	rejectAll := false
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			user, pass, ok := r.BasicAuth()
+			if !ok || user != "my+client" || pass != "s%3Dcret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+			require.Equal(t, "read write", r.PostForm.Get("scope"))
+			require.Empty(t, r.URL.RawQuery)
+			require.Equal(t, "application/json", r.Header.Get("Accept"))
+			require.Empty(t, r.Header.Get("X-Hooked"))
+			tokens++
+			fmt.Fprintf(w, `{"access_token":"token%d","token_type":"Bearer","expires_in":3600}`, tokens)
+		case "/revoke":
+			revoked[r.Header.Get("Authorization")] = true
+		default:
+			if rejectAll || r.Header.Get("Authorization") == "" || revoked[r.Header.Get("Authorization")] {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			bs, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(bs))
+			w.Write([]byte(r.Header.Get("Authorization")))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithQueryParam("api", "1").
+		UseOAuth2ClientCredentials(srv.URL+"/token", "my client", "s=cret", "read", "write")
+	c.Get("/things").ExpectBodyEquals("Bearer token1")
+	c.Clone().Get("/things").ExpectBodyEquals("Bearer token1")
+	require.NoError(t, c.Error())
+	require.Equal(t, 1, tokens)
+
+	c.Get("/revoke")
+	c.PostString("/things", "again").ExpectStatus(http.StatusOK).ExpectBodyEquals("Bearer token2")
+	require.NoError(t, c.Error())
+	require.Equal(t, 2, tokens)
+	require.Equal(t, []string{"", "", "again"}, bodies)
+
+	rejectAll = true
+	c.Get("/things").ExpectStatus(http.StatusUnauthorized)
+	require.NoError(t, c.Error())
+	require.Equal(t, 3, tokens)
+
+	// The token request gets none of the per-request settings of the client.
+	rejectAll = false
+	rec := NewTrafficRecorder().Strict().Expect("GET /things")
+	c = NewClient(srv.URL).
+		WithAPIVersion("v2", VersionInAccept("acme")).
+		WithRequestHook(func(req *http.Request) { req.Header.Set("X-Hooked", "yes") }).
+		WithTrafficRecorder(rec).
+		UseOAuth2ClientCredentials(srv.URL+"/token", "my client", "s=cret", "read", "write")
+	c.Get("/things").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+	require.Equal(t, []string{"GET /things"}, rec.Calls())
+	c = NewClient(srv.URL).WithAPIVersion("v2", VersionInQuery("api")).
+		UseOAuth2ClientCredentials(srv.URL+"/token", "my client", "s=cret", "read", "write")
+	c.Get("/things").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+	require.Equal(t, 5, tokens)
+
+	// Dry runs do not fetch a token.
+	c = NewClient(srv.URL).WithDryRun(true).
+		UseOAuth2ClientCredentials(srv.URL+"/token", "my client", "s=cret", "read", "write")
+	rw := c.Get("/things")
+	require.NoError(t, c.Error())
+	require.True(t, rw.DryRun())
+	require.Equal(t, 5, tokens)
+
+	c = NewClient(srv.URL).UseOAuth2ClientCredentials(srv.URL+"/token", "my client", "wrong")
+	c.Get("/things")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), `requesting OAuth2 token: got status 401 Unauthorized: {"error":"invalid_client"}`)
+}
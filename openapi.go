@@ -0,0 +1,238 @@
+package crest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// openAPISpec is a loaded OpenAPI 3 document. Schemas are kept as decoded
+// JSON and interpreted when validating.
+type openAPISpec struct {
+	jsonSchema
+	paths []openAPIPath
+	// basePaths are the paths of the document's servers, which the paths of
+	// operations are relative to.
+	basePaths []string
+}
+
+type openAPIPath struct {
+	template string
+	segments []string
+	item     map[string]interface{}
+}
+
+// WithOpenAPISpec loads the OpenAPI 3 document at specPath for
+// ExpectMatchesSpec to check responses against. Only documents in JSON are
+// supported.
+func (c *client) WithOpenAPISpec(specPath string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	spec, err := loadOpenAPISpec(specPath)
+	if err != nil {
+		c.errSetter(errors.Wrapf(err, "loading OpenAPI document %v", specPath))
+		return c
+	}
+	c.spec = spec
+	return c
+}
+
+func loadOpenAPISpec(specPath string) (*openAPISpec, error) {
+	bs, err := ioutil.ReadFile(specPath)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(specPath))
+	if trimmed := bytes.TrimSpace(bs); ext == ".yaml" || ext == ".yml" || len(trimmed) > 0 && trimmed[0] != '{' {
+		return nil, errors.New("YAML documents are not supported; convert the document to JSON")
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(bs, &doc); err != nil {
+		return nil, errors.Wrap(err, "parsing JSON")
+	}
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, errors.Errorf("unsupported OpenAPI version %q", doc["openapi"])
+	}
+
+	spec := &openAPISpec{jsonSchema: jsonSchema{doc: doc}}
+	paths, _ := doc["paths"].(map[string]interface{})
+	for template, item := range paths {
+		item, _ := item.(map[string]interface{})
+		spec.paths = append(spec.paths, openAPIPath{
+			template: template,
+			segments: pathSegments(template),
+			item:     item,
+		})
+	}
+	// Prefer literal segments over parameters, as OpenAPI requires.
+	sort.Slice(spec.paths, func(i, j int) bool {
+		return spec.paths[i].before(spec.paths[j])
+	})
+	servers, _ := doc["servers"].([]interface{})
+	for _, server := range servers {
+		server, _ := server.(map[string]interface{})
+		raw, _ := server["url"].(string)
+		if u, err := url.Parse(raw); err == nil && strings.Trim(u.Path, "/") != "" {
+			spec.basePaths = append(spec.basePaths, strings.TrimSuffix(u.Path, "/"))
+		}
+	}
+	return spec, nil
+}
+
+// before reports whether p is tried before q: templates with fewer
+// parameters come first, then those with a literal segment where the other
+// first has a parameter, and otherwise they are in order of template.
+func (p openAPIPath) before(q openAPIPath) bool {
+	if np, nq := strings.Count(p.template, "{"), strings.Count(q.template, "{"); np != nq {
+		return np < nq
+	}
+	for i := 0; i < len(p.segments) && i < len(q.segments); i++ {
+		pLiteral, qLiteral := !strings.HasPrefix(p.segments[i], "{"), !strings.HasPrefix(q.segments[i], "{")
+		if pLiteral != qLiteral {
+			return pLiteral
+		}
+	}
+	return p.template < q.template
+}
+
+// operation finds the operation for method and path, which is relative to
+// the client's root base URL. It returns the template of the matched path.
+func (s *openAPISpec) operation(method, path string) (map[string]interface{}, string, error) {
+	candidates := []string{path}
+	for _, base := range s.basePaths {
+		if strings.HasPrefix(path, base+"/") {
+			candidates = append(candidates, strings.TrimPrefix(path, base))
+		}
+	}
+	for _, candidate := range candidates {
+		segments := pathSegments(candidate)
+		for _, p := range s.paths {
+			if !(endpointLimit{template: p.segments}).matches(segments) {
+				continue
+			}
+			op, ok := p.item[strings.ToLower(method)].(map[string]interface{})
+			if !ok {
+				return nil, p.template, errors.Errorf("the spec has no %v operation for %v", method, p.template)
+			}
+			return op, p.template, nil
+		}
+	}
+	return nil, "", errors.Errorf("the spec has no path matching %v", path)
+}
+
+// ExpectMatchesSpec fails if the response does not match what the OpenAPI
+// document loaded with WithOpenAPISpec says the operation requested returns:
+// its status must be listed, the headers it marks as required present, and
+// a JSON body must match the schema of its media type.
+func (r *responseWrapper) ExpectMatchesSpec() ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	spec := r.settings.spec
+	if spec == nil {
+		r.setError(errors.New("expected a response matching the OpenAPI spec, but the client has none"))
+		return r
+	}
+	if r.req == nil {
+		r.setError(errors.New("expected a response matching the OpenAPI spec, but the request is unknown"))
+		return r
+	}
+	path := r.req.URL.Path
+	if base, err := url.Parse(r.settings.specRoot); err == nil && base.Host == r.req.URL.Host {
+		path = strings.TrimPrefix(path, strings.TrimSuffix(base.Path, "/"))
+	}
+	op, template, err := spec.operation(r.req.Method, path)
+	if err == nil {
+		err = spec.checkResponse(op, r.resp, r.body)
+	}
+	if err != nil {
+		if template == "" {
+			template = path
+		}
+		r.setError(fmt.Errorf("expected the response to match the OpenAPI spec for %v %v but %v", r.req.Method, template, err))
+	}
+	return r
+}
+
+func (s *openAPISpec) checkResponse(op map[string]interface{}, resp *http.Response, body string) error {
+	responses, _ := op["responses"].(map[string]interface{})
+	code := strconv.Itoa(resp.StatusCode)
+	def, ok := responses[code]
+	if !ok {
+		def, ok = responses[code[:1]+"XX"]
+	}
+	if !ok {
+		def, ok = responses["default"]
+	}
+	if !ok {
+		return errors.Errorf("status %v is not documented", resp.StatusCode)
+	}
+	response, err := s.resolve(def)
+	if err != nil {
+		return err
+	}
+
+	headers, _ := response["headers"].(map[string]interface{})
+	for name, header := range headers {
+		header, err := s.resolve(header)
+		if err != nil {
+			return err
+		}
+		if required, _ := header["required"].(bool); required && resp.Header.Get(name) == "" {
+			return errors.Errorf("required header %v is missing", name)
+		}
+	}
+
+	content, _ := response["content"].(map[string]interface{})
+	if len(content) == 0 {
+		if body != "" {
+			return errors.New("the spec documents no body, but there is one")
+		}
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return errors.Errorf("the content type %q is not one of %v", resp.Header.Get("Content-Type"), sortedKeys(content))
+	}
+	media, ok := content[mediaType]
+	if !ok {
+		media, ok = content[strings.SplitN(mediaType, "/", 2)[0]+"/*"]
+	}
+	if !ok {
+		media, ok = content["*/*"]
+	}
+	if !ok {
+		return errors.Errorf("the content type %v is not one of %v", mediaType, sortedKeys(content))
+	}
+	media, err = s.resolve(media)
+	if err != nil {
+		return err
+	}
+	schema, ok := media.(map[string]interface{})["schema"]
+	if !ok || !isJSONMediaType(mediaType) {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		return errors.Wrap(err, "unmarshalling body")
+	}
+	if problems := s.validate(schema, value, "$", 0); len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,163 @@
+package crest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSpec = `{
+  "openapi": "3.0.3",
+  "servers": [{"url": "https://api.example.com/v1"}],
+  "paths": {
+    "/users/{id}": {
+      "get": {
+        "responses": {
+          "200": {
+            "headers": {"X-Request-Id": {"required": true, "schema": {"type": "string"}}},
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/User"}}}
+          },
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      }
+    },
+    "/users/me": {
+      "get": {"responses": {"2XX": {"content": {"text/plain": {}}}}}
+    },
+    "/users": {
+      "post": {"responses": {"201": {"description": "created"}}}
+    }
+  },
+  "components": {
+    "schemas": {
+      "User": {
+        "type": "object",
+        "required": ["id", "name"],
+        "additionalProperties": false,
+        "properties": {
+          "id": {"type": "integer", "minimum": 1},
+          "name": {"type": "string", "minLength": 1},
+          "role": {"type": "string", "enum": ["admin", "member"]},
+          "email": {"type": "string", "nullable": true, "pattern": "@"},
+          "tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+        }
+      }
+    },
+    "responses": {
+      "NotFound": {"content": {"application/problem+json": {"schema": {"type": "object", "required": ["title"]}}}}
+    }
+  }
+}`
+
+func TestExpectMatchesSpec(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "openapi.json")
+	require.NoError(t, ioutil.WriteFile(specPath, []byte(testSpec), 0o644))
+
+	bodies := map[string]string{
+		"/v1/users/1":    `{"id":1,"name":"a","role":"admin","email":null,"tags":["x"]}`,
+		"/v1/users/2":    `{"id":1.5,"name":"","role":"owner","email":"nope","tags":["x",2,"z"],"extra":true}`,
+		"/v1/users/3":    `{"name":"a"}`,
+		"/v1/users/me":   `me`,
+		"/v1/users/none": `{"title":"not found"}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		case r.URL.Path == "/v1/users/me":
+			w.Header().Set("Content-Type", "text/plain")
+		case r.URL.Path == "/v1/users/none":
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			if r.URL.Path != "/v1/users/3" {
+				w.Header().Set("X-Request-Id", "1")
+			}
+		}
+		w.Write([]byte(bodies[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithOpenAPISpec(specPath)
+	v1 := c.Group("/v1")
+	v1.Get("/users/1").ExpectMatchesSpec()
+	v1.Get("/users/me").ExpectMatchesSpec()
+	v1.Get("/users/none").ExpectMatchesSpec()
+	v1.Post("/users", nil).ExpectMatchesSpec()
+	require.NoError(t, c.Error())
+
+	v1.Get("/users/2").ExpectMatchesSpec()
+	require.Error(t, c.Error())
+	msg := c.Error().Error()
+	require.Contains(t, msg, "expected the response to match the OpenAPI spec for GET /users/{id} but")
+	for _, problem := range []string{
+		`$: unexpected property "extra"`,
+		"$.email: expected a string matching \"@\" but got \"nope\"",
+		"$.id: expected integer but got 1.5",
+		"$.name: expected at least 1 characters but got 0",
+		`$.role: expected one of ["admin","member"] but got "owner"`,
+		"$.tags: expected at most 2 items but got 3",
+		"$.tags[1]: expected string but got number",
+	} {
+		require.Contains(t, msg, problem)
+	}
+
+	c = NewClient(srv.URL).WithOpenAPISpec(specPath)
+	c.Get("/v1/users/3").ExpectMatchesSpec()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "required header X-Request-Id is missing")
+
+	c = NewClient(srv.URL).WithOpenAPISpec(specPath)
+	c.Delete("/v1/users/1").ExpectMatchesSpec()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "the spec has no DELETE operation for /users/{id}")
+
+	c = NewClient(srv.URL).WithOpenAPISpec(specPath)
+	c.Get("/v1/orders").ExpectMatchesSpec()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "the spec has no path matching /v1/orders")
+
+	c = NewClient(srv.URL)
+	c.Get("/v1/users/1").ExpectMatchesSpec()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "the client has none")
+
+	yamlPath := filepath.Join(t.TempDir(), "openapi.yaml")
+	require.NoError(t, ioutil.WriteFile(yamlPath, []byte("openapi: 3.0.3\n"), 0o644))
+	c = NewClient(srv.URL).WithOpenAPISpec(yamlPath)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "YAML documents are not supported")
+
+	require.NoError(t, ioutil.WriteFile(specPath, []byte("openapi: 3.0.3\n"), 0o644))
+	c = NewClient(srv.URL).WithOpenAPISpec(specPath)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "YAML documents are not supported")
+}
+
+func TestOpenAPIPathOrder(t *testing.T) {
+	specPath := filepath.Join(t.TempDir(), "openapi.json")
+	require.NoError(t, ioutil.WriteFile(specPath, []byte(`{
+  "openapi": "3.0.3",
+  "paths": {
+    "/{kind}/special/tags": {"get": {"responses": {"200": {}}}},
+    "/items/{id}/tags": {"get": {"responses": {"200": {}}}},
+    "/items/{id}/{field}": {"get": {"responses": {"200": {}}}},
+    "/{kind}/{id}/tags": {"get": {"responses": {"200": {}}}}
+  }
+}`), 0o644))
+	for i := 0; i < 10; i++ {
+		spec, err := loadOpenAPISpec(specPath)
+		require.NoError(t, err)
+		_, template, err := spec.operation(http.MethodGet, "/items/special/tags")
+		require.NoError(t, err)
+		require.Equal(t, "/items/{id}/tags", template)
+		_, template, err = spec.operation(http.MethodGet, "/users/special/tags")
+		require.NoError(t, err)
+		require.Equal(t, "/{kind}/special/tags", template)
+	}
+}
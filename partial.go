@@ -0,0 +1,71 @@
+package crest
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// PartialBody is a JSON object containing only the fields that were set on
+// it, which is what a PATCH request usually wants to send. A field set to nil
+// is sent as an explicit null.
+type PartialBody struct {
+	fields map[string]interface{}
+	err    error
+}
+
+func Partial(fields map[string]interface{}) *PartialBody {
+	p := &PartialBody{
+		fields: make(map[string]interface{}, len(fields)),
+	}
+	for key, value := range fields {
+		p.fields[key] = value
+	}
+	return p
+}
+
+// PartialFrom marshals v and keeps only the named top-level fields of the
+// resulting object.
+func PartialFrom(v interface{}, fields ...string) *PartialBody {
+	p := Partial(nil)
+	bs, err := json.Marshal(v)
+	if err != nil {
+		p.err = errors.Wrap(err, "marshalling partial body source")
+		return p
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(bs, &all); err != nil {
+		p.err = errors.Wrap(err, "partial body source is not a JSON object")
+		return p
+	}
+	for _, field := range fields {
+		value, ok := all[field]
+		if !ok {
+			p.err = errors.Errorf("partial body source has no field %q", field)
+			return p
+		}
+		p.fields[field] = value
+	}
+	return p
+}
+
+func (p *PartialBody) Set(key string, value interface{}) *PartialBody {
+	p.fields[key] = value
+	return p
+}
+
+func (p *PartialBody) Null(key string) *PartialBody {
+	return p.Set(key, nil)
+}
+
+func (p *PartialBody) Unset(key string) *PartialBody {
+	delete(p.fields, key)
+	return p
+}
+
+func (p *PartialBody) MarshalJSON() ([]byte, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return json.Marshal(p.fields)
+}
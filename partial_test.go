@@ -0,0 +1,38 @@
+package crest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartial(t *testing.T) {
+	p := Partial(map[string]interface{}{"name": "new name"}).
+		Null("description").
+		Set("count", 0).
+		Set("gone", true).
+		Unset("gone")
+	bs, err := json.Marshal(p)
+	require.NoError(t, err)
+	require.Equal(t, `{"count":0,"description":null,"name":"new name"}`, string(bs))
+}
+
+func TestPartialFrom(t *testing.T) {
+	type Item struct {
+		Name        string  `json:"name"`
+		Description *string `json:"description"`
+		Count       int     `json:"count"`
+	}
+	item := Item{Name: "name"}
+
+	bs, err := json.Marshal(PartialFrom(item, "description", "count"))
+	require.NoError(t, err)
+	require.Equal(t, `{"count":0,"description":null}`, string(bs))
+
+	_, err = json.Marshal(PartialFrom(item, "missing"))
+	require.Error(t, err)
+
+	_, err = json.Marshal(PartialFrom("not an object", "name"))
+	require.Error(t, err)
+}
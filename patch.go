@@ -0,0 +1,58 @@
+package crest
+
+import "encoding/json"
+
+// PatchOp is a single RFC 6902 JSON Patch operation.
+type PatchOp struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+func (o PatchOp) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"op":   o.Op,
+		"path": o.Path,
+	}
+	switch o.Op {
+	case "add", "replace", "test":
+		m["value"] = o.Value
+	case "move", "copy":
+		m["from"] = o.From
+	}
+	return json.Marshal(m)
+}
+
+// PatchOps builds a list of JSON Patch operations:
+//
+//	crest.JSONPatch().Replace("/name", "new").Remove("/tags/0")
+type PatchOps []PatchOp
+
+func JSONPatch() PatchOps {
+	return PatchOps{}
+}
+
+func (p PatchOps) Add(path string, value interface{}) PatchOps {
+	return append(p, PatchOp{Op: "add", Path: path, Value: value})
+}
+
+func (p PatchOps) Remove(path string) PatchOps {
+	return append(p, PatchOp{Op: "remove", Path: path})
+}
+
+func (p PatchOps) Replace(path string, value interface{}) PatchOps {
+	return append(p, PatchOp{Op: "replace", Path: path, Value: value})
+}
+
+func (p PatchOps) Move(from, path string) PatchOps {
+	return append(p, PatchOp{Op: "move", From: from, Path: path})
+}
+
+func (p PatchOps) Copy(from, path string) PatchOps {
+	return append(p, PatchOp{Op: "copy", From: from, Path: path})
+}
+
+func (p PatchOps) Test(path string, value interface{}) PatchOps {
+	return append(p, PatchOp{Op: "test", Path: path, Value: value})
+}
@@ -0,0 +1,49 @@
+package crest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatchOps(t *testing.T) {
+	ops := JSONPatch().
+		Add("/tags/-", "new").
+		Remove("/old").
+		Replace("/name", nil).
+		Move("/a", "/b").
+		Copy("/c", "/d").
+		Test("/version", 3)
+	bs, err := json.Marshal(ops)
+	require.NoError(t, err)
+	expected := `[` +
+		`{"op":"add","path":"/tags/-","value":"new"},` +
+		`{"op":"remove","path":"/old"},` +
+		`{"op":"replace","path":"/name","value":null},` +
+		`{"from":"/a","op":"move","path":"/b"},` +
+		`{"from":"/c","op":"copy","path":"/d"},` +
+		`{"op":"test","path":"/version","value":3}` +
+		`]`
+	require.Equal(t, expected, string(bs))
+}
+
+func TestClientPatchVariants(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Header.Get("Content-Type") + " " + string(bs)))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.PatchMerge("/", Partial(nil).Null("name")).
+		ExpectBodyEquals(`application/merge-patch+json {"name":null}`)
+	c.PatchJSONPatch("/", JSONPatch().Remove("/name")).
+		ExpectBodyEquals(`application/json-patch+json [{"op":"remove","path":"/name"}]`)
+	c.PatchJSONPatch("/", nil).
+		ExpectBodyEquals(`application/json-patch+json []`)
+	require.NoError(t, c.Error())
+}
@@ -0,0 +1,64 @@
+package crest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PathEncoding controls how a client encodes the URLs it sends, since
+// backends disagree on what they accept. The zero value is the default:
+// path parameters are escaped as a single segment, %2F is sent as is, and
+// spaces in query strings become +.
+type PathEncoding struct {
+	// RawPathParams inserts path parameters as they are, so a "/" in a value
+	// splits it into several segments.
+	RawPathParams bool
+	// DecodeSlashes sends %2F in paths as "/", for servers and proxies that
+	// reject encoded slashes.
+	DecodeSlashes bool
+	// SpaceAsPercent20 encodes spaces in query strings as %20 rather than +.
+	SpaceAsPercent20 bool
+}
+
+// WithPathEncoding sets how the client encodes paths, path parameters and
+// query strings.
+func (c *client) WithPathEncoding(encoding PathEncoding) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.pathEncoding = encoding
+	return c
+}
+
+// expandPath replaces each {name} in path with the value of the path
+// parameter name.
+func (e PathEncoding) expandPath(path string, params map[string]string) string {
+	for name, value := range params {
+		if !e.RawPathParams {
+			value = url.PathEscape(value)
+		}
+		path = strings.ReplaceAll(path, "{"+name+"}", value)
+	}
+	return path
+}
+
+// encodeQuery encodes query the way Values.Encode does, with spaces as %20
+// if asked to.
+func (e PathEncoding) encodeQuery(query url.Values) string {
+	encoded := query.Encode()
+	if e.SpaceAsPercent20 {
+		// Encode escapes a literal + as %2B, so every + left is a space.
+		encoded = strings.ReplaceAll(encoded, "+", "%20")
+	}
+	return encoded
+}
+
+// applyReq rewrites the escaped path of req if slashes are to be decoded.
+func (e PathEncoding) applyReq(req *http.Request) {
+	if !e.DecodeSlashes || req.URL.RawPath == "" {
+		return
+	}
+	// The result still decodes to Path, so URL.EscapedPath uses it.
+	req.URL.RawPath = strings.NewReplacer("%2F", "/", "%2f", "/").Replace(req.URL.RawPath)
+}
@@ -0,0 +1,43 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathEncoding(t *testing.T) {
+	var uris []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uris = append(uris, r.RequestURI)
+	}))
+	defer srv.Close()
+
+	request := func(c Client) {
+		c.Request(http.MethodGet, "/files/{id}/meta").
+			WithPathParam("id", "a/b c+d").
+			WithQueryParam("q", "x y+z").
+			Do()
+		c.Get("/raw/a%2Fb")
+	}
+
+	c := NewClient(srv.URL)
+	request(c)
+	request(NewClient(srv.URL).WithPathEncoding(PathEncoding{RawPathParams: true, DecodeSlashes: true, SpaceAsPercent20: true}))
+	require.NoError(t, c.Error())
+	require.Equal(t, []string{
+		"/files/a%2Fb%20c+d/meta?q=x+y%2Bz",
+		"/raw/a%2Fb",
+		"/files/a/b%20c+d/meta?q=x%20y%2Bz",
+		"/raw/a/b",
+	}, uris)
+
+	curl := NewClient("http://example.com").
+		Request(http.MethodGet, "/users/{user}/repos/{repo}").
+		WithPathParam("user", "ann").
+		WithPathParam("repo", "x/y").
+		AsCurl()
+	require.Equal(t, `curl 'http://example.com/users/ann/repos/x%2Fy' --compressed`, curl)
+}
@@ -0,0 +1,93 @@
+package crest
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PollOptions configures how PollGet polls.
+type PollOptions struct {
+	// Interval is the wait between polls, 1s by default.
+	Interval time.Duration
+	// MaxAttempts is how many times to poll before giving up, 10 by
+	// default.
+	MaxAttempts int
+	// Until makes assertions on each response, e.g.
+	//
+	//	func(rw ResponseWrapper) { rw.ExpectJSONPath("state", "ready") }
+	//
+	// and polling stops once they all pass. By default it stops at the
+	// first 2xx response.
+	Until func(rw ResponseWrapper)
+}
+
+func (o PollOptions) withDefaults() PollOptions {
+	if o.Interval <= 0 {
+		o.Interval = time.Second
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 10
+	}
+	if o.Until == nil {
+		o.Until = func(rw ResponseWrapper) { rw.ExpectStatus2xx() }
+	}
+	return o
+}
+
+// PollGet gets path every opts.Interval until the assertions of opts.Until
+// pass on the response, for APIs that reach a state eventually. It returns
+// the wrapper of the last response. Failures of the polls before the last
+// are not errors; if the assertions still fail after opts.MaxAttempts polls,
+// the failures of the last poll become c's errors.
+func (c *client) PollGet(path string, opts PollOptions) ResponseWrapper {
+	if c.errGetter() != nil {
+		return c.nop()
+	}
+	opts = opts.withDefaults()
+	for n := 1; ; n++ {
+		cl := c.detached()
+		rw := cl.Get(path)
+		if cl.errGetter() == nil {
+			opts.Until(rw)
+		}
+		errs := cl.errState.all()
+		if len(errs) == 0 {
+			return c.adopt(rw)
+		}
+		if n >= opts.MaxAttempts {
+			for _, err := range errs {
+				c.errSetter(errors.Wrapf(err, "polling %v: still failing after %d attempts", path, n))
+			}
+			return c.adopt(rw)
+		}
+
+		timer := time.NewTimer(opts.Interval)
+		select {
+		case <-timer.C:
+		case <-c.lifecycle.ctx.Done():
+			timer.Stop()
+			c.errSetter(errors.Wrapf(ErrClientClosed, "polling %v", path))
+			return c.nop()
+		}
+	}
+}
+
+// adopt makes rw, the wrapper of a response to a client detached from c,
+// report failed assertions as c's errors.
+func (c *client) adopt(rw ResponseWrapper) ResponseWrapper {
+	r, ok := rw.(*responseWrapper)
+	if !ok {
+		return rw
+	}
+	adopted := *r
+	adopted.error = c.errGetter
+	adopted.report = c.Error
+	adopted.setError = func(err error) {
+		c.reportFailure(err, adopted.req, adopted.sentBody, &adopted)
+	}
+	adopted.replay = func() ResponseWrapper {
+		return c.replay(adopted.req, adopted.sentBody.Bytes())
+	}
+	return &adopted
+}
@@ -0,0 +1,58 @@
+package crest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPollGet(t *testing.T) {
+	polls := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls[r.URL.Path]++
+		n := polls[r.URL.Path]
+		switch r.URL.Path {
+		case "/jobs/1":
+			state := "running"
+			if n >= 3 {
+				state = "ready"
+			}
+			fmt.Fprintf(w, `{"state":%q,"polls":%d}`, state, n)
+		case "/created":
+			if n < 2 {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		default:
+			w.Write([]byte(`{"state":"running"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	rw := c.PollGet("/jobs/1", PollOptions{
+		Interval: time.Millisecond,
+		Until:    func(rw ResponseWrapper) { rw.ExpectJSONPath("state", "ready") },
+	})
+	rw.ExpectJSONPath("polls", 3)
+	c.PollGet("/created", PollOptions{Interval: time.Millisecond}).ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+	require.Equal(t, 2, polls["/created"])
+
+	rw.ExpectJSONPath("polls", 4)
+	require.Error(t, c.Error())
+
+	c = NewClient(srv.URL)
+	c.PollGet("/stuck", PollOptions{
+		Interval:    time.Millisecond,
+		MaxAttempts: 3,
+		Until:       func(rw ResponseWrapper) { rw.ExpectJSONPath("state", "ready") },
+	})
+	require.Equal(t, 3, polls["/stuck"])
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "polling /stuck: still failing after 3 attempts")
+	require.Len(t, c.Errors(), 1)
+}
@@ -0,0 +1,61 @@
+package crest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithProxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("origin"))
+	}))
+	defer srv.Close()
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.True(t, r.URL.IsAbs())
+		proxied = append(proxied, r.URL.String()+" "+r.Header.Get("Proxy-Authorization"))
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.Header().Set("Via", "1.1 test-proxy")
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+
+	c := NewClient(srv.URL).WithProxy("http://user:pass@" + proxy.Listener.Addr().String())
+	c.Get("/things").
+		ExpectBodyEquals("origin").
+		ExpectHeaderEquals("Via", "1.1 test-proxy").
+		ExpectProxied()
+	require.NoError(t, c.Error())
+	require.Equal(t, []string{srv.URL + "/things Basic dXNlcjpwYXNz"}, proxied)
+
+	c.Get("/things").ExpectNotProxied()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected the request not to go through a proxy, but it went through "+proxy.Listener.Addr().String())
+
+	c = NewClient(srv.URL).WithProxy("http://" + proxy.Listener.Addr().String()).WithProxy("")
+	c.Get("/things").ExpectBodyEquals("origin").ExpectNotProxied()
+	require.NoError(t, c.Error())
+	require.Len(t, proxied, 2)
+
+	// The environment never proxies requests to localhost.
+	c = NewClient(srv.URL).WithProxyFromEnv()
+	c.Get("/things").ExpectNotProxied()
+	c.Get("/things").ExpectProxied()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected the request to go through a proxy, but it went directly to "+srv.Listener.Addr().String())
+
+	c = NewClient(srv.URL).WithProxy("proxy:3128")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), `invalid proxy URL "proxy:3128"`)
+}
@@ -0,0 +1,163 @@
+package crest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// RangeReader returns a reader of the resource at path that fetches only the
+// bytes asked for, with a Range request per ReadAt, e.g. to read the central
+// directory of a large zip file. The reader also has a Size() (int64, error)
+// method, as archive/zip needs. Failures are returned from ReadAt rather
+// than recorded on c, and a server that ignores Range is one.
+func (c *client) RangeReader(path string) io.ReaderAt {
+	return &rangeReader{client: c, path: path, size: -1}
+}
+
+type rangeReader struct {
+	client *client
+	path   string
+
+	lock sync.Mutex
+	// size is the size of the resource, or -1 until a response tells it.
+	size int64
+}
+
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if off < 0 {
+		return 0, errors.Errorf("negative offset %d", off)
+	}
+	if size, ok := r.knownSize(); ok && off >= size {
+		return 0, io.EOF
+	}
+	body, err := r.fetch(off, off+int64(len(p))-1)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, body)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Size returns the size of the resource, asking the server for it unless a
+// read has told it already.
+func (r *rangeReader) Size() (int64, error) {
+	if size, ok := r.knownSize(); ok {
+		return size, nil
+	}
+	if _, err := r.fetch(0, 0); err != nil {
+		return 0, err
+	}
+	size, ok := r.knownSize()
+	if !ok {
+		return 0, errors.New("the server did not tell the size of the resource")
+	}
+	return size, nil
+}
+
+func (r *rangeReader) knownSize() (int64, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.size, r.size >= 0
+}
+
+// fetch gets the bytes from first to last inclusive, or fewer at the end of
+// the resource.
+func (r *rangeReader) fetch(first, last int64) ([]byte, error) {
+	c := r.client.detached()
+	if err := c.errGetter(); err != nil {
+		return nil, err
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.buildPath(c.apiVersion.applyPath(r.path)), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating request")
+	}
+	// Set before the client's settings, so that no Accept-Encoding is added:
+	// ranges of an encoded body are not ranges of the resource.
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", first, last))
+	rw := c.do(c.populateReq(req))
+	if err := c.Error(); err != nil {
+		return nil, err
+	}
+	if rw.DryRun() {
+		return nil, errors.Wrapf(ErrDryRun, "reading bytes %d-%d of %v", first, last, r.path)
+	}
+
+	resp := rw.Response()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		if _, _, size, ok := parseContentRange(resp.Header.Get("Content-Range")); ok {
+			r.setSize(size)
+		}
+		return nil, io.EOF
+	case http.StatusOK:
+		return nil, errors.Errorf("reading bytes %d-%d of %v: the server does not support range requests", first, last, r.path)
+	default:
+		return nil, errors.Errorf("reading bytes %d-%d of %v: got status %v", first, last, r.path, resp.Status)
+	}
+	start, end, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if !ok || start != first || end-start+1 != int64(len(rw.Body())) {
+		return nil, errors.Errorf("reading bytes %d-%d of %v: unexpected Content-Range %q for %d bytes", first, last, r.path, resp.Header.Get("Content-Range"), len(rw.Body()))
+	}
+	r.setSize(size)
+	return []byte(rw.Body()), nil
+}
+
+func (r *rangeReader) setSize(size int64) {
+	if size < 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.size = size
+}
+
+// parseContentRange parses a Content-Range header such as "bytes 0-99/1234"
+// or "bytes */1234". The size is -1 if the server did not know it, and start
+// and end are -1 if no range was satisfiable.
+func parseContentRange(header string) (start, end, size int64, ok bool) {
+	spec := strings.TrimPrefix(header, "bytes ")
+	slash := strings.IndexByte(spec, '/')
+	if spec == header || slash < 0 {
+		return 0, 0, 0, false
+	}
+	size = -1
+	if total := spec[slash+1:]; total != "*" {
+		var err error
+		if size, err = strconv.ParseInt(total, 10, 64); err != nil {
+			return 0, 0, 0, false
+		}
+	}
+	if spec[:slash] == "*" {
+		return -1, -1, size, true
+	}
+	dash := strings.IndexByte(spec[:slash], '-')
+	if dash < 0 {
+		return 0, 0, 0, false
+	}
+	start, err1 := strconv.ParseInt(spec[:dash], 10, 64)
+	end, err2 := strconv.ParseInt(spec[dash+1:slash], 10, 64)
+	if err1 != nil || err2 != nil || end < start {
+		return 0, 0, 0, false
+	}
+	return start, end, size, true
+}
@@ -0,0 +1,94 @@
+package crest
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeReader(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		w.Write([]byte(strings.Repeat(name, 1000)))
+	}
+	require.NoError(t, zw.Close())
+
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		require.Empty(t, r.Header.Get("Accept-Encoding"))
+		switch r.URL.Path {
+		case "/archive.zip":
+			http.ServeContent(w, r, "archive.zip", time.Time{}, bytes.NewReader(archive.Bytes()))
+		case "/no-ranges":
+			w.Write([]byte("whole"))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	ra := c.RangeReader("/archive.zip")
+	size, err := ra.(interface{ Size() (int64, error) }).Size()
+	require.NoError(t, err)
+	require.Equal(t, int64(archive.Len()), size)
+
+	zr, err := zip.NewReader(ra, size)
+	require.NoError(t, err)
+	require.Len(t, zr.File, 2)
+	rc, err := zr.File[1].Open()
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.Equal(t, strings.Repeat("b.txt", 1000), string(content))
+	require.Equal(t, "bytes=0-0", ranges[0])
+	require.NoError(t, c.Error())
+
+	p := make([]byte, 10)
+	n, err := ra.ReadAt(p, size-4)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, archive.Bytes()[size-4:], p[:n])
+	n, err = ra.ReadAt(p, size)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, 0, n)
+
+	_, err = NewClient(srv.URL).RangeReader("/archive.zip").ReadAt(p, size+5)
+	require.Equal(t, io.EOF, err)
+
+	_, err = c.RangeReader("/no-ranges").ReadAt(p, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "the server does not support range requests")
+	require.NoError(t, c.Error())
+}
+
+func TestParseContentRange(t *testing.T) {
+	testCases := []struct {
+		header           string
+		start, end, size int64
+		ok               bool
+	}{
+		{"bytes 0-99/1234", 0, 99, 1234, true},
+		{"bytes 5-5/*", 5, 5, -1, true},
+		{"bytes */1234", -1, -1, 1234, true},
+		{"bytes 9-5/10", 0, 0, 0, false},
+		{"items 0-1/2", 0, 0, 0, false},
+		{"bytes 0-1", 0, 0, 0, false},
+	}
+	for _, testCase := range testCases {
+		start, end, size, ok := parseContentRange(testCase.header)
+		require.Equal(t, testCase.ok, ok, testCase.header)
+		if ok {
+			require.Equal(t, []int64{testCase.start, testCase.end, testCase.size}, []int64{start, end, size}, testCase.header)
+		}
+	}
+}
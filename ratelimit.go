@@ -0,0 +1,182 @@
+package crest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// rateLimiter allows at most n requests in any window of length per. It is
+// guarded by the lock of the rateLimits it belongs to.
+type rateLimiter struct {
+	n    int
+	per  time.Duration
+	sent []time.Time
+}
+
+func newRateLimiter(n int, per time.Duration) *rateLimiter {
+	return &rateLimiter{n: n, per: per}
+}
+
+// earliest returns the earliest time at or after now that a request is
+// allowed.
+func (l *rateLimiter) earliest(now time.Time) time.Time {
+	if len(l.sent) < l.n {
+		return now
+	}
+	if free := l.sent[len(l.sent)-l.n].Add(l.per); free.After(now) {
+		return free
+	}
+	return now
+}
+
+func (l *rateLimiter) record(at time.Time) {
+	l.sent = append(l.sent, at)
+	if len(l.sent) > l.n {
+		l.sent = l.sent[len(l.sent)-l.n:]
+	}
+}
+
+// endpointLimit limits the requests to paths matching a template, in which
+// a "{name}" segment matches any single segment and a final "*" matches the
+// rest of the path.
+type endpointLimit struct {
+	template []string
+	limiter  *rateLimiter
+}
+
+func (e endpointLimit) matches(segments []string) bool {
+	for i, part := range e.template {
+		if part == "*" && i == len(e.template)-1 {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			continue
+		}
+		if part != segments[i] {
+			return false
+		}
+	}
+	return len(segments) == len(e.template)
+}
+
+func pathSegments(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// rateLimits are shared by a client and its clones, since the quotas they
+// enforce belong to the server rather than to any one client.
+type rateLimits struct {
+	lock      sync.Mutex
+	global    *rateLimiter
+	endpoints []endpointLimit
+
+	// now and sleep stand in for the clock in tests; nil means time.Now and
+	// sleepContext.
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// reserve records a request for path at the earliest time every limit that
+// applies to it allows: the global one and the first matching endpoint one.
+// It returns how long to wait until then.
+func (r *rateLimits) reserve(path string) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var limiters []*rateLimiter
+	if r.global != nil {
+		limiters = append(limiters, r.global)
+	}
+	segments := pathSegments(path)
+	for _, endpoint := range r.endpoints {
+		if endpoint.matches(segments) {
+			limiters = append(limiters, endpoint.limiter)
+			break
+		}
+	}
+
+	now := time.Now()
+	if r.now != nil {
+		now = r.now()
+	}
+	at := now
+	for _, limiter := range limiters {
+		if t := limiter.earliest(now); t.After(at) {
+			at = t
+		}
+	}
+	for _, limiter := range limiters {
+		limiter.record(at)
+	}
+	return at.Sub(now)
+}
+
+// wait blocks until every limit that applies to path allows another request.
+func (r *rateLimits) wait(ctx context.Context, path string) error {
+	delay := r.reserve(path)
+	if delay <= 0 {
+		return nil
+	}
+	if r.sleep != nil {
+		return r.sleep(ctx, delay)
+	}
+	return sleepContext(ctx, delay)
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithRateLimit makes the client and its clones send at most n requests per
+// the given duration, waiting as needed.
+func (c *client) WithRateLimit(n int, per time.Duration) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if n <= 0 || per <= 0 {
+		c.errSetter(errors.Errorf("invalid rate limit of %v requests per %v", n, per))
+		return c
+	}
+	c.limits.lock.Lock()
+	defer c.limits.lock.Unlock()
+
+	c.limits.global = newRateLimiter(n, per)
+	return c
+}
+
+// WithEndpointRateLimit limits requests to paths matching template, e.g.
+// "/search" or "/users/{id}/posts", on top of any limit set with
+// WithRateLimit. Templates are matched against paths relative to the client's
+// base URL; when several match, the first one added applies.
+func (c *client) WithEndpointRateLimit(template string, n int, per time.Duration) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if n <= 0 || per <= 0 {
+		c.errSetter(errors.Errorf("invalid rate limit of %v requests per %v for %v", n, per, template))
+		return c
+	}
+	c.limits.lock.Lock()
+	defer c.limits.lock.Unlock()
+
+	c.limits.endpoints = append(c.limits.endpoints, endpointLimit{
+		template: pathSegments(template),
+		limiter:  newRateLimiter(n, per),
+	})
+	return c
+}
@@ -0,0 +1,132 @@
+package crest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock stands in for the clock of rate limits, sleeping by moving time
+// forward.
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newFakeClock(c Client) *fakeClock {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	limits := c.(*client).limits
+	limits.now = clock.Now
+	limits.sleep = clock.Sleep
+	return clock
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.now = f.now.Add(d)
+	return nil
+}
+
+func TestEndpointRateLimit(t *testing.T) {
+	var clock *fakeClock
+	var lock sync.Mutex
+	times := make(map[string][]time.Time)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		times[r.URL.Path] = append(times[r.URL.Path], clock.Now())
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL+"/api").
+		WithEndpointRateLimit("/search", 2, 100*time.Millisecond).
+		WithEndpointRateLimit("/users/{id}", 1, 100*time.Millisecond)
+	clock = newFakeClock(c)
+	clone := c.Clone()
+
+	start := clock.Now()
+	for i := 0; i < 3; i++ {
+		c.Get("/search?q=x")
+		clone.Get("/users/1")
+		c.Get("/other")
+	}
+	require.NoError(t, c.Error())
+	require.Equal(t, 200*time.Millisecond, clock.Now().Sub(start))
+
+	search := times["/api/search"]
+	require.Len(t, search, 3)
+	require.Equal(t, search[0], search[1])
+	require.Equal(t, 100*time.Millisecond, search[2].Sub(search[0]))
+	users := times["/api/users/1"]
+	require.Len(t, users, 3)
+	require.Equal(t, 100*time.Millisecond, users[1].Sub(users[0]))
+	require.Equal(t, 100*time.Millisecond, users[2].Sub(users[1]))
+	require.Len(t, times["/api/other"], 3)
+
+	// Paths outside the base path are not matched as if they were inside.
+	c = NewClient(srv.URL+"/api").WithEndpointRateLimit("/v2/search", 1, time.Hour)
+	clock = newFakeClock(c)
+	start = clock.Now()
+	httpClient := &http.Client{Transport: Transport(c)}
+	for i := 0; i < 2; i++ {
+		resp, err := httpClient.Get(srv.URL + "/apiv2/search")
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	require.Equal(t, start, clock.Now())
+
+	c = NewClient(srv.URL).WithEndpointRateLimit("/search", 0, time.Second)
+	require.EqualError(t, c.Error(), "invalid rate limit of 0 requests per 1s for /search")
+}
+
+func TestRateLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithRateLimit(2, 50*time.Millisecond)
+	clock := newFakeClock(c)
+	start := clock.Now()
+	for i := 0; i < 5; i++ {
+		c.Get("/")
+	}
+	require.NoError(t, c.Error())
+	require.Equal(t, 100*time.Millisecond, clock.Now().Sub(start))
+
+	c = NewClient(srv.URL).WithRateLimit(1, time.Hour).WithTimeout(20 * time.Millisecond)
+	c.Get("/")
+	c.Get("/")
+	require.Error(t, c.Error())
+}
+
+func TestEndpointLimitMatches(t *testing.T) {
+	testCases := []struct {
+		template string
+		path     string
+		matches  bool
+	}{
+		{"/search", "/search", true},
+		{"/search", "/search/", true},
+		{"/search", "/search/more", false},
+		{"/users/{id}", "/users/1", true},
+		{"/users/{id}", "/users", false},
+		{"/users/{id}/posts", "/users/1/posts", true},
+		{"/users/*", "/users/1/posts", true},
+		{"/users/*", "/accounts/1", false},
+	}
+	for _, testCase := range testCases {
+		endpoint := endpointLimit{template: pathSegments(testCase.template)}
+		require.Equal(t, testCase.matches, endpoint.matches(pathSegments(testCase.path)), "%v %v", testCase.template, testCase.path)
+	}
+}
@@ -0,0 +1,91 @@
+package crest
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultCredentialHeaders are the headers removed from requests when a
+// redirect leaves the origin of the original request.
+var DefaultCredentialHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// goSensitiveHeaders are the headers net/http itself drops on redirects to
+// other domains.
+var goSensitiveHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2", "Proxy-Authorization", "Proxy-Authenticate"}
+
+// WithRedirectCredentialHeaders sets the headers removed from requests when a
+// redirect leaves the origin (scheme, host and port) of the original
+// request. With no headers nothing is removed, not even what net/http would
+// remove on its own.
+func (c *client) WithRedirectCredentialHeaders(headers ...string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.credentialHeaders = make([]string, len(headers))
+	for i, header := range headers {
+		c.credentialHeaders[i] = http.CanonicalHeaderKey(header)
+	}
+	return c
+}
+
+// checkRedirect applies the client's credential policy to each redirect,
+// after next (or net/http's default policy) has allowed it.
+func (c *client) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if next != nil {
+			if err := next(req, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+
+		if err := c.checkHost(req.URL); err != nil {
+			return errors.Wrap(err, "not following redirect")
+		}
+
+		initial := via[0]
+		if sameOrigin(initial.URL, req.URL) {
+			return nil
+		}
+		if len(c.credentialHeaders) == 0 {
+			for _, key := range goSensitiveHeaders {
+				if key == "Cookie" && c.httpClient.Jar != nil {
+					// The jar adds the cookies for the new URL itself.
+					continue
+				}
+				if vals, ok := initial.Header[key]; ok {
+					req.Header[key] = vals
+				}
+			}
+			return nil
+		}
+		for _, key := range c.credentialHeaders {
+			req.Header.Del(key)
+		}
+		return nil
+	}
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(hostPort(a), hostPort(b))
+}
+
+// hostPort returns the host and port of u, filling in the port implied by the
+// scheme.
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
@@ -0,0 +1,47 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectCredentialHeaders(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join([]string{r.Header.Get("Authorization"), r.Header.Get("X-Api-Key"), r.Header.Get("X-Trace")}, ",")))
+	}))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			// Another port is another origin, but net/http keeps
+			// credentials for redirects to the same host.
+			http.Redirect(w, r, other.URL+"/", http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/here" {
+			http.Redirect(w, r, "/final", http.StatusFound)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithHeader("Authorization", "Bearer t").WithHeader("X-Api-Key", "k").WithHeader("X-Trace", "1")
+	c.Get("/away").ExpectBodyEquals(",k,1").ExpectNoCredentialLeakOnRedirect()
+	c.Get("/here").ExpectBodyEquals("Bearer t").ExpectNoCredentialLeakOnRedirect()
+	require.NoError(t, c.Error())
+
+	c.Clone().WithRedirectCredentialHeaders("Authorization", "x-api-key").Get("/away").
+		ExpectBodyEquals(",,1").
+		ExpectNoCredentialLeakOnRedirect()
+	require.NoError(t, c.Error())
+
+	c.Clone().WithRedirectCredentialHeaders().Get("/away").
+		ExpectBodyEquals("Bearer t,k,1").
+		ExpectNoCredentialLeakOnRedirect()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected no credentials to leak on redirect, but header Authorization was sent to "+other.URL+"/")
+}
@@ -0,0 +1,177 @@
+package crest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type replayConfig struct {
+	diff []DiffOption
+	keep func(req *http.Request) bool
+}
+
+type ReplayOption func(*replayConfig)
+
+// ReplayDiff sets the options the replayed responses are compared with.
+func ReplayDiff(opts ...DiffOption) ReplayOption {
+	return func(cfg *replayConfig) {
+		cfg.diff = append(cfg.diff, opts...)
+	}
+}
+
+// ReplayFilter replays only the recorded requests keep returns true for.
+func ReplayFilter(keep func(req *http.Request) bool) ReplayOption {
+	return func(cfg *replayConfig) {
+		cfg.keep = keep
+	}
+}
+
+// cassetteEntry is a recorded request and the response it got, or, if err
+// is set, why it got none.
+type cassetteEntry struct {
+	req      *http.Request
+	body     []byte
+	resp     *http.Response
+	respBody string
+	err      string
+}
+
+// Replay sends the requests recorded in the cassette at cassettePath to the
+// scheme and host of target's base URL, keeping their paths, and compares each
+// response with the recorded one as Diff does. A cassette is a HAR file or
+// one or more records written by ExportJSON. Recorded credentials and
+// Accept-Encoding are dropped in favor of target's own. Requests that got no
+// response when recorded are skipped, as there is nothing to compare with.
+// Every other request is replayed even if some fail; the returned
+// *MatrixError lists the failures by request.
+func Replay(cassettePath string, target Client, opts ...ReplayOption) error {
+	cfg := &replayConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	c, ok := target.(*client)
+	if !ok {
+		return errors.Errorf("cannot replay to a %T", target)
+	}
+	if err := c.errGetter(); err != nil {
+		return err
+	}
+	entries, err := readCassette(cassettePath)
+	if err != nil {
+		return errors.Wrapf(err, "reading cassette %v", cassettePath)
+	}
+	base, err := url.Parse(c.rootBaseURL())
+	if err != nil {
+		return errors.Wrap(err, "parsing base URL")
+	}
+
+	failures := make(map[string]error)
+	for i, entry := range entries {
+		if entry.err != "" || cfg.keep != nil && !cfg.keep(entry.req) {
+			continue
+		}
+		name := fmt.Sprintf("%v %v (request %d)", entry.req.Method, entry.req.URL.RequestURI(), i+1)
+		req := c.replayRequest(base, entry)
+		cl := c.detached()
+		rw := cl.Do(req)
+		if err := cl.Error(); err != nil {
+			failures[name] = err
+			continue
+		}
+		if err := Diff(WrapResponse(entry.resp), rw, cfg.diff...); err != nil {
+			failures[name] = err
+		}
+	}
+	if len(failures) > 0 {
+		return &MatrixError{Failures: failures}
+	}
+	return nil
+}
+
+// replayRequest moves a recorded request to the origin of base.
+func (c *client) replayRequest(base *url.URL, entry cassetteEntry) *http.Request {
+	u := *entry.req.URL
+	u.Scheme = base.Scheme
+	u.Host = base.Host
+
+	req := entry.req.Clone(entry.req.Context())
+	req.URL = &u
+	req.Host = ""
+	// The recorded credentials go even if the client was told to treat
+	// other headers as credentials.
+	drop := append([]string{"Accept-Encoding", "Content-Length", "Host"}, DefaultCredentialHeaders...)
+	for _, key := range append(drop, c.credentialHeaders...) {
+		req.Header.Del(key)
+	}
+	body := entry.body
+	req.ContentLength = int64(len(body))
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	return req
+}
+
+func readCassette(path string) ([]cassetteEntry, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var har harFile
+	if err := json.Unmarshal(bs, &har); err == nil && har.Log != nil {
+		return har.entries()
+	}
+
+	var entries []cassetteEntry
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	for {
+		var e exchange
+		if err := dec.Decode(&e); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "parsing cassette, which is neither HAR nor exported JSON")
+		}
+		entry, err := newCassetteEntry(e.Request.Method, e.Request.URL, e.Request.Header, []byte(e.Request.Body),
+			e.Response.StatusCode, e.Response.Header, e.Response.Body)
+		if err != nil {
+			return nil, errors.Wrapf(err, "record %d", len(entries)+1)
+		}
+		if e.Response.StatusCode == 0 {
+			entry.err = e.Error
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func newCassetteEntry(method, rawURL string, reqHeader http.Header, reqBody []byte, status int, respHeader http.Header, respBody string) (cassetteEntry, error) {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return cassetteEntry{}, err
+	}
+	if reqHeader != nil {
+		req.Header = reqHeader.Clone()
+	}
+	if respHeader == nil {
+		respHeader = make(http.Header)
+	}
+	respHeader = respHeader.Clone()
+	// The recorded body is already decoded.
+	respHeader.Del("Content-Encoding")
+	resp := &http.Response{
+		Status:     fmt.Sprintf("%d %v", status, http.StatusText(status)),
+		StatusCode: status,
+		Header:     respHeader,
+		Body:       ioutil.NopCloser(strings.NewReader(respBody)),
+		Request:    req,
+	}
+	return cassetteEntry{req: req, body: reqBody, resp: resp, respBody: respBody}, nil
+}
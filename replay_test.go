@@ -0,0 +1,91 @@
+package crest
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplay(t *testing.T) {
+	handler := func(version string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			switch r.URL.Path {
+			case "/api/echo":
+				w.Write([]byte(`{"got":` + string(body) + `,"auth":"` + r.Header.Get("Authorization") + `"}`))
+			case "/api/version":
+				w.Write([]byte(`{"version":"` + version + `","id":"` + r.URL.Query().Get("id") + `"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+	}
+	v1 := httptest.NewServer(handler("1"))
+	defer v1.Close()
+	v2 := httptest.NewServer(handler("2"))
+	defer v2.Close()
+
+	var cassette bytes.Buffer
+	c := NewClient(v1.URL + "/api")
+	require.NoError(t, c.Post("/echo", map[string]int{"n": 1}).ExportJSON(&cassette))
+	require.NoError(t, c.Get("/version?id=7").ExportJSON(&cassette))
+	require.NoError(t, c.Error())
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(t, ioutil.WriteFile(path, cassette.Bytes(), 0o644))
+
+	require.NoError(t, Replay(path, NewClient(v1.URL+"/api")))
+
+	err := Replay(path, NewClient(v2.URL+"/api"))
+	require.Error(t, err)
+	failures := err.(*MatrixError).Failures
+	require.Len(t, failures, 1)
+	require.Contains(t, failures["GET /api/version?id=7 (request 2)"].Error(), `$.version: "1" != "2"`)
+
+	require.NoError(t, Replay(path, NewClient(v2.URL+"/api"), ReplayDiff(DiffIgnoreFields("version"))))
+	require.NoError(t, Replay(path, NewClient(v2.URL+"/api"), ReplayFilter(func(req *http.Request) bool {
+		return req.Method == http.MethodPost
+	})))
+
+	// The target's credentials replace recorded ones.
+	err = Replay(path, NewClient(v1.URL+"/api").UseBearerToken("new"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `$.auth: "" != "Bearer new"`)
+
+	// Recorded credentials are dropped even if the target treats no headers
+	// as credentials.
+	cassette.Reset()
+	require.NoError(t, NewClient(v1.URL+"/api").UseBearerToken("old").Post("/echo", map[string]int{"n": 1}).ExportJSON(&cassette))
+	require.NoError(t, ioutil.WriteFile(path, cassette.Bytes(), 0o644))
+	err = Replay(path, NewClient(v1.URL+"/api").WithRedirectCredentialHeaders())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `$.auth: "Bearer old" != ""`)
+}
+
+func TestReplayHAR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	har := `{"log": {"version": "1.2", "entries": [
+		{"request": {"method": "POST", "url": "https://prod.example.com/things", "headers": [{"name": ":authority", "value": "prod.example.com"}, {"name": "Content-Type", "value": "text/plain"}], "postData": {"text": "hello"}},
+		 "response": {"status": 200, "headers": [], "content": {"text": "aGVsbG8=", "encoding": "base64"}}},
+		{"request": {"method": "POST", "url": "https://prod.example.com/other", "headers": [], "postData": {"text": "a"}},
+		 "response": {"status": 200, "headers": [], "content": {"text": "b"}}}
+	]}}`
+	path := filepath.Join(t.TempDir(), "traffic.har")
+	require.NoError(t, ioutil.WriteFile(path, []byte(har), 0o644))
+
+	err := Replay(path, NewClient(srv.URL))
+	require.Error(t, err)
+	failures := err.(*MatrixError).Failures
+	require.Len(t, failures, 1)
+	require.Contains(t, failures["POST /other (request 2)"].Error(), `body: "b" != "a"`)
+}
@@ -0,0 +1,85 @@
+package crest
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// RequestBuilder builds a single request whose settings apply to it alone,
+// on top of those of the client it was created from.
+type RequestBuilder interface {
+	WithBody(body interface{}) RequestBuilder
+	WithHeader(key, value string) RequestBuilder
+	WithPathParam(name, value string) RequestBuilder
+	WithQueryParam(key, value string) RequestBuilder
+	AsCurl() string
+	Do() ResponseWrapper
+}
+
+type requestBuilder struct {
+	client  *client
+	method  string
+	path    string
+	body    interface{}
+	hasBody bool
+	params  map[string]string
+}
+
+// Request starts building a request. The request shares the client's error,
+// but settings made on the builder do not change the client.
+func (c *client) Request(method, path string) RequestBuilder {
+	return &requestBuilder{
+		client: c.cloneConfig(),
+		method: method,
+		path:   path,
+	}
+}
+
+// WithBody sends body encoded as JSON, as Post does.
+func (b *requestBuilder) WithBody(body interface{}) RequestBuilder {
+	b.body = body
+	b.hasBody = true
+	return b
+}
+
+// WithHeader adds a header to the request. Headers set on the builder
+// replace the client's headers with the same key rather than adding to them.
+func (b *requestBuilder) WithHeader(key, value string) RequestBuilder {
+	if b.client.headerOverrides == nil {
+		b.client.headerOverrides = make(http.Header)
+	}
+	b.client.headerOverrides.Add(key, value)
+	return b
+}
+
+// WithPathParam replaces {name} in the path with value, escaped as a single
+// path segment unless the client's PathEncoding says otherwise.
+func (b *requestBuilder) WithPathParam(name, value string) RequestBuilder {
+	if b.params == nil {
+		b.params = make(map[string]string)
+	}
+	b.params[name] = value
+	return b
+}
+
+func (b *requestBuilder) WithQueryParam(key, value string) RequestBuilder {
+	if b.client.query == nil {
+		b.client.query = make(url.Values)
+	}
+	b.client.query.Add(key, value)
+	return b
+}
+
+func (b *requestBuilder) Do() ResponseWrapper {
+	if len(b.params) > 0 {
+		b.client.pathTemplate = b.path
+	}
+	if b.hasBody {
+		return b.client.doReqJSON(b.method, b.expandedPath(), b.body)
+	}
+	return b.client.doReqNoBody(b.method, b.expandedPath())
+}
+
+func (b *requestBuilder) expandedPath() string {
+	return b.client.pathEncoding.expandPath(b.path, b.params)
+}
@@ -0,0 +1,55 @@
+package crest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + string(bs)))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithQueryParam("a", "1")
+	c.Request(http.MethodGet, "/items").
+		WithQueryParam("b", "x y").
+		Do().
+		ExpectBodyEquals("GET /items?a=1&b=x+y ")
+	c.Request(http.MethodPost, "/items").
+		WithBody(map[string]int{"n": 1}).
+		Do().
+		ExpectBodyEquals(`POST /items?a=1 {"n":1}`)
+	c.Get("/items").ExpectBodyEquals("GET /items?a=1 ")
+	require.NoError(t, c.Error())
+
+	c.Request(http.MethodGet, "/items").Do().ExpectStatus(http.StatusNotFound)
+	require.Error(t, c.Error())
+	require.Equal(t, c.Error(), c.Request(http.MethodGet, "/items").Do().Error())
+}
+
+func TestRequestBuilderHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Join(r.Header["X-Req-Id"], ",") + " " + strings.Join(r.Header["X-Other"], ",")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithHeader("X-Req-ID", "client").WithHeader("X-Other", "kept")
+	c.Request(http.MethodGet, "/").
+		WithHeader("X-Req-ID", "1").
+		WithHeader("X-Req-ID", "2").
+		Do().
+		ExpectBodyEquals("1,2 kept")
+	c.Group("/group").Request(http.MethodGet, "/").
+		WithHeader("X-Other", "replaced").
+		Do().
+		ExpectBodyEquals("client replaced")
+	c.Get("/").ExpectBodyEquals("client kept")
+	require.NoError(t, c.Error())
+}
@@ -1,70 +1,333 @@
 package crest
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"runtime/debug"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 type ResponseWrapper interface {
+	Attempts() int
 	Body() string
+	DryRun() bool
+	Duration() time.Duration
+	Error() error
+	ExpectAPIVersion(string) ResponseWrapper
 	ExpectBodyContains(string) ResponseWrapper
 	ExpectBodyEquals(string) ResponseWrapper
+	ExpectBodyJSONEquals(expected interface{}) ResponseWrapper
+	ExpectBodyMatches(pattern string) ResponseWrapper
+	ExpectBodyMatchesJSONSchema(schema string) ResponseWrapper
+	ExpectBodyMatchesJSONSchemaFromFile(schemaPath string) ResponseWrapper
 	ExpectBodyNotContains(string) ResponseWrapper
 	ExpectBodyNotEquals(string) ResponseWrapper
+	ExpectBodyNotMatches(pattern string) ResponseWrapper
 	ExpectBodyPasses(func(string) bool) ResponseWrapper
+	ExpectCharset(string) ResponseWrapper
+	ExpectCompressedTransfer() ResponseWrapper
+	ExpectContentLanguage(string) ResponseWrapper
+	ExpectCookieEquals(name, value string) ResponseWrapper
+	ExpectCookieHasFlag(name string, flags CookieFlag) ResponseWrapper
+	ExpectCookiePresent(name string) ResponseWrapper
+	ExpectDurationUnder(d time.Duration) ResponseWrapper
+	ExpectGraphQLNoErrors() ResponseWrapper
 	ExpectHeaderContains(key, value string) ResponseWrapper
 	ExpectHeaderEquals(key, value string) ResponseWrapper
+	ExpectHeaderMatches(key, pattern string) ResponseWrapper
 	ExpectHeaderNotContains(key, value string) ResponseWrapper
 	ExpectHeaderNotEquals(key, value string) ResponseWrapper
 	ExpectHeaderNotPresent(key string) ResponseWrapper
 	ExpectHeaderPresent(key string) ResponseWrapper
+	ExpectHeaderValueCount(key string, n int) ResponseWrapper
+	ExpectJSONPath(path string, expected interface{}) ResponseWrapper
+	ExpectJSONPathPresent(path string) ResponseWrapper
+	ExpectMatchesSpec() ResponseWrapper
+	ExpectNoContent() ResponseWrapper
+	ExpectNoCredentialLeakOnRedirect() ResponseWrapper
+	ExpectNotProxied() ResponseWrapper
 	ExpectPasses(func(resp *http.Response, body string) bool) ResponseWrapper
+	ExpectPreconditionFailed() ResponseWrapper
+	ExpectProxied() ResponseWrapper
+	ExpectRedirectPreservedBody() ResponseWrapper
+	ExpectRequestQuerySent(key, value string) ResponseWrapper
+	ExpectServerTiming(name string, cond DurationCondition) ResponseWrapper
 	ExpectStatus(int) ResponseWrapper
+	ExpectStatus2xx() ResponseWrapper
+	ExpectStatus3xx() ResponseWrapper
+	ExpectStatus4xx() ResponseWrapper
+	ExpectStatus5xx() ResponseWrapper
+	ExpectStatusIn(codes ...int) ResponseWrapper
+	ExpectStreamedBytes(n int64) ResponseWrapper
+	ExpectStreamedSHA256(sum string) ResponseWrapper
+	ExportJSON(w io.Writer) error
+	HasTag(tag string) bool
+	ParseAndValidateBody(interface{}) ResponseWrapper
 	ParseBody(interface{}) ResponseWrapper
+	ParseGraphQLData(v interface{}) ResponseWrapper
+	ParseMultipartBody() ([]Part, error)
+	RemoteAddr() string
+	ReplayRequest() ResponseWrapper
+	Response() *http.Response
+	RetryErrors() []error
+	SentBody() string
+	SentURL() *url.URL
+	ServedBy() string
+	ServerTimings() []ServerTiming
+	Sizes() BodySizes
+	Tags() []string
+	Timings() Timings
+	Wire() WireCapture
+}
+
+// CookieFlag is a set of cookie attributes checked by ExpectCookieHasFlag.
+type CookieFlag int
+
+const (
+	CookieSecure CookieFlag = 1 << iota
+	CookieHttpOnly
+)
+
+// BodySizes holds the number of body bytes received on the wire and the
+// number of bytes left after decoding any Content-Encoding.
+type BodySizes struct {
+	Wire    int64
+	Decoded int64
+	// Truncated is set if the body was cut short at the limit set with
+	// WithMaxBodySize.
+	Truncated bool
+}
+
+// Ratio returns the compression ratio (decoded / wire), or 1 if nothing was
+// received.
+func (s BodySizes) Ratio() float64 {
+	if s.Wire == 0 {
+		return 1
+	}
+	return float64(s.Decoded) / float64(s.Wire)
+}
+
+type responseSettings struct {
+	decompress        bool
+	versionHeader     string
+	normalize         func(string) string
+	credentialHeaders []string
+	// sink, if set, is where the body is streamed to instead of being kept.
+	sink io.Writer
+	// maxBodySize, if positive, limits the body on the wire and decoded.
+	maxBodySize  int64
+	truncateBody bool
+	// spec is what ExpectMatchesSpec checks against, with paths relative to
+	// specRoot.
+	spec     *openAPISpec
+	specRoot string
+}
+
+var defaultResponseSettings = responseSettings{
+	decompress: true,
 }
 
 func newResponseWrapper(resp *http.Response, errChecker func() error, errSetter func(error)) ResponseWrapper {
+	return newResponseWrapperWithSettings(resp, errChecker, errSetter, defaultResponseSettings)
+}
+
+func newResponseWrapperWithSettings(resp *http.Response, errChecker func() error, errSetter func(error), settings responseSettings) *responseWrapper {
 	r := &responseWrapper{
 		error:    errChecker,
+		report:   errChecker,
 		resp:     resp,
 		setError: errSetter,
+		settings: settings,
 	}
 
 	if errChecker() != nil {
 		return r
 	}
+	if settings.sink != nil {
+		r.stream(settings.sink)
+		return r
+	}
 
-	if bs, err := ioutil.ReadAll(r.resp.Body); err != nil {
-		r.setError(errors.Wrap(err, "reading response body"))
-	} else {
-		r.body = string(bs)
+	raw, err := r.readBody(r.resp.Body)
+	if err != nil {
+		r.fail(errors.Wrap(err, "reading response body"))
+		return r
+	}
+	r.sizes.Wire = int64(len(raw))
+
+	if settings.decompress && !r.resp.Uncompressed {
+		encoding := strings.ToLower(strings.TrimSpace(r.resp.Header.Get("Content-Encoding")))
+		decoded, err := r.decodeBody(encoding, raw)
+		if err != nil {
+			r.fail(errors.Wrapf(err, "decoding %v response body", encoding))
+			return r
+		}
+		if decoded != nil {
+			raw = decoded
+			r.encoding = encoding
+			r.resp.Header.Del("Content-Encoding")
+			r.resp.Header.Del("Content-Length")
+			r.resp.ContentLength = -1
+			r.resp.Uncompressed = true
+		}
 	}
+	r.sizes.Decoded = int64(len(raw))
+	r.body = string(raw)
 
 	return r
 }
 
+// fail reports err and makes every later assertion on r a no-op, even if the
+// client is collecting errors rather than stopping at the first.
+func (r *responseWrapper) fail(err error) {
+	r.setError(err)
+	r.error = func() error {
+		return err
+	}
+}
+
+// decodeBody returns the decoded body, or nil if the encoding is not one that
+// crest decodes.
+func (r *responseWrapper) decodeBody(encoding string, raw []byte) ([]byte, error) {
+	rd, err := decodingReader(encoding, bytes.NewReader(raw))
+	if rd == nil || err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	truncated := r.sizes.Truncated
+	decoded, err := r.readBody(rd)
+	if truncated && err == io.ErrUnexpectedEOF {
+		// The encoded body was cut short, so its end is missing.
+		err = nil
+	}
+	return decoded, err
+}
+
+// readBody reads rd up to the limit set with WithMaxBodySize, failing or
+// truncating past it as the client says.
+func (r *responseWrapper) readBody(rd io.Reader) ([]byte, error) {
+	limit := r.settings.maxBodySize
+	if limit <= 0 {
+		return ioutil.ReadAll(rd)
+	}
+	bs, err := ioutil.ReadAll(io.LimitReader(rd, limit+1))
+	if err != nil || int64(len(bs)) <= limit {
+		return bs, err
+	}
+	if !r.settings.truncateBody {
+		return nil, errors.Errorf("the body is larger than the limit of %d bytes", limit)
+	}
+	r.sizes.Truncated = true
+	return bs[:limit], nil
+}
+
+// decodingReader returns a reader decoding rd, or nil if the encoding is not
+// one that crest decodes.
+func decodingReader(encoding string, rd io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(rd)
+	case "deflate":
+		return zlib.NewReader(rd)
+	}
+	return nil, nil
+}
+
+// WrapResponse runs a response obtained elsewhere, e.g. from another HTTP
+// library, through crest's assertions. Failures are reported by the
+// wrapper's Error method.
+func WrapResponse(resp *http.Response) ResponseWrapper {
+	state := &errorState{}
+	rw := newResponseWrapper(resp, state.gate, state.set).(*responseWrapper)
+	rw.report = state.get
+	return rw
+}
+
+// WrapRecorder runs the response captured by rr through crest's assertions,
+// so handler tests can share assertions with tests against a real server.
+func WrapRecorder(rr *httptest.ResponseRecorder) ResponseWrapper {
+	return WrapResponse(rr.Result())
+}
+
 type responseWrapper struct {
 	error    func() error
+	report   func() error
 	setError func(error)
 
-	resp *http.Response
-	body string
+	settings responseSettings
+	req      *http.Request
+	sentBody *bodyRecorder
+	replay   func() ResponseWrapper
+
+	attempts    int
+	retryErrors []error
+	tags        []string
+	timings     Timings
+	wire        WireCapture
+	// proxy is the proxy the request was sent through, if any.
+	proxy    *url.URL
+	resp     *http.Response
+	body     string
+	encoding string
+	sizes    BodySizes
+	// streamed is set, along with sha256, if the body went to a writer.
+	streamed bool
+	sha256   string
+}
+
+// Attempts returns how many times the request was sent to get this response.
+func (r *responseWrapper) Attempts() int {
+	return r.attempts
 }
 
 func (r *responseWrapper) Body() string {
 	return r.body
 }
 
+// Error returns the error of the client that made the request, which includes
+// any failed assertions on this response.
+func (r *responseWrapper) Error() error {
+	return r.report()
+}
+
+// normalized applies the client's Unicode normalization, if any, to s.
+func (r *responseWrapper) normalized(s string) string {
+	if r.settings.normalize == nil {
+		return s
+	}
+	return r.settings.normalize(s)
+}
+
+func (r *responseWrapper) ExpectAPIVersion(version string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if !responseHasVersion(r.resp.Header, r.settings.versionHeader, version) {
+		r.setError(fmt.Errorf("expected API version %q, but the response headers did not report it", version))
+	}
+	return r
+}
+
 func (r *responseWrapper) ExpectBodyContains(needle string) ResponseWrapper {
 	if r.error() != nil {
 		return r
 	}
-	if !strings.Contains(r.body, needle) {
+	if !strings.Contains(r.normalized(r.body), r.normalized(needle)) {
 		r.setError(fmt.Errorf("expected body to contain %q but it did not", needle))
 	}
 	return r
@@ -74,17 +337,106 @@ func (r *responseWrapper) ExpectBodyEquals(value string) ResponseWrapper {
 	if r.error() != nil {
 		return r
 	}
-	if r.body != value {
+	if r.normalized(r.body) != r.normalized(value) {
 		r.setError(fmt.Errorf("expected body to be %q but it was not", value))
 	}
 	return r
 }
 
+// ExpectBodyJSONEquals parses the body as JSON and compares it with expected,
+// ignoring key order and whitespace. expected is JSON text if it is a string,
+// []byte or json.RawMessage, and otherwise a value encoded as JSON. The error
+// lists each differing path as "got != expected".
+func (r *responseWrapper) ExpectBodyJSONEquals(expected interface{}) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	var bs []byte
+	switch e := expected.(type) {
+	case string:
+		bs = []byte(e)
+	case []byte:
+		bs = e
+	case json.RawMessage:
+		bs = e
+	default:
+		var err error
+		if bs, err = json.Marshal(expected); err != nil {
+			r.setError(fmt.Errorf("marshalling expected value: %v", err))
+			return r
+		}
+	}
+	var want, actual interface{}
+	if err := decodeJSON(bs, &want); err != nil {
+		r.setError(fmt.Errorf("unmarshalling expected value: %v", err))
+		return r
+	}
+	if err := decodeJSON([]byte(r.body), &actual); err != nil {
+		r.setError(fmt.Errorf("unmarshalling body: %v", err))
+		return r
+	}
+	if diffs := jsonDiff("$", actual, want); len(diffs) > 0 {
+		r.setError(fmt.Errorf("expected body to equal %v but:\n  %v", jsonString(want), strings.Join(diffs, "\n  ")))
+	}
+	return r
+}
+
+// ExpectBodyMatches passes if the body matches the regular expression
+// pattern anywhere; anchor it with ^ and $ to match the whole body.
+func (r *responseWrapper) ExpectBodyMatches(pattern string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		r.setError(err)
+		return r
+	}
+	if !re.MatchString(r.normalized(r.body)) {
+		r.setError(fmt.Errorf("expected body to match %q but it did not", pattern))
+	}
+	return r
+}
+
+// ExpectBodyNotMatches fails if the body matches the regular expression
+// pattern anywhere.
+func (r *responseWrapper) ExpectBodyNotMatches(pattern string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		r.setError(err)
+		return r
+	}
+	body := r.normalized(r.body)
+	if loc := re.FindStringIndex(body); loc != nil {
+		r.setError(fmt.Errorf("expected body not to match %q but it matched %q", pattern, body[loc[0]:loc[1]]))
+	}
+	return r
+}
+
+// regexps caches compiled patterns, since the same assertion usually runs
+// against many responses.
+var regexps sync.Map
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "compiling pattern %q", pattern)
+	}
+	regexps.Store(pattern, re)
+	return re, nil
+}
+
 func (r *responseWrapper) ExpectBodyNotContains(needle string) ResponseWrapper {
 	if r.error() != nil {
 		return r
 	}
-	if strings.Contains(r.body, needle) {
+	if strings.Contains(r.normalized(r.body), r.normalized(needle)) {
 		r.setError(fmt.Errorf("expected body to not contain %q but it does", needle))
 	}
 	return r
@@ -94,7 +446,7 @@ func (r *responseWrapper) ExpectBodyNotEquals(value string) ResponseWrapper {
 	if r.error() != nil {
 		return r
 	}
-	if r.body == value {
+	if r.normalized(r.body) == r.normalized(value) {
 		r.setError(fmt.Errorf("expected body not to be %q but it was", value))
 	}
 	return r
@@ -104,12 +456,151 @@ func (r *responseWrapper) ExpectBodyPasses(f func(string) bool) ResponseWrapper
 	if r.error() != nil {
 		return r
 	}
-	if !f(r.body) {
+	passed, err := callPredicate(func() bool {
+		return f(r.body)
+	})
+	if err != nil {
+		r.setError(err)
+	} else if !passed {
 		r.setError(fmt.Errorf("expected function to pass, but it did not"))
 	}
 	return r
 }
 
+func (r *responseWrapper) ExpectCharset(charset string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	contentType := r.resp.Header.Get("Content-Type")
+	if contentType == "" {
+		r.setError(fmt.Errorf("expected charset %q, but there is no Content-Type header", charset))
+		return r
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		r.setError(errors.Wrapf(err, "expected charset %q, but Content-Type %q could not be parsed", charset, contentType))
+		return r
+	}
+	if actual, ok := params["charset"]; !ok {
+		r.setError(fmt.Errorf("expected charset %q, but Content-Type %q has none", charset, contentType))
+	} else if !strings.EqualFold(actual, charset) {
+		r.setError(fmt.Errorf("expected charset %q but got %q", charset, actual))
+	}
+	return r
+}
+
+func (r *responseWrapper) ExpectCompressedTransfer() ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if r.encoding != "" {
+		return r
+	}
+	encoding := strings.ToLower(strings.TrimSpace(r.resp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" {
+		r.setError(fmt.Errorf("expected a compressed transfer, but the response was not compressed"))
+	}
+	return r
+}
+
+func (r *responseWrapper) ExpectContentLanguage(tag string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	var languages []string
+	for _, value := range r.resp.Header["Content-Language"] {
+		for _, language := range strings.Split(value, ",") {
+			language = strings.TrimSpace(language)
+			if strings.EqualFold(language, tag) {
+				return r
+			}
+			if language != "" {
+				languages = append(languages, language)
+			}
+		}
+	}
+	if len(languages) == 0 {
+		r.setError(fmt.Errorf("expected content language %q, but there is no Content-Language header", tag))
+	} else {
+		r.setError(fmt.Errorf("expected content language %q but got %q", tag, languages))
+	}
+	return r
+}
+
+// ExpectCookieEquals checks the value of a cookie set by the response.
+func (r *responseWrapper) ExpectCookieEquals(name, value string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	cookie := r.cookie(name)
+	if cookie == nil {
+		r.setError(fmt.Errorf("expected cookie %q to be set to %q, but it was not set", name, value))
+	} else if cookie.Value != value {
+		r.setError(fmt.Errorf("expected cookie %q to be set to %q, but it was set to %q", name, value, cookie.Value))
+	}
+	return r
+}
+
+// ExpectCookieHasFlag checks that a cookie set by the response has all of
+// flags, e.g. CookieSecure|CookieHttpOnly.
+func (r *responseWrapper) ExpectCookieHasFlag(name string, flags CookieFlag) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	cookie := r.cookie(name)
+	if cookie == nil {
+		r.setError(fmt.Errorf("expected cookie %q to be set, but it was not", name))
+		return r
+	}
+	var missing []string
+	if flags&CookieSecure != 0 && !cookie.Secure {
+		missing = append(missing, "Secure")
+	}
+	if flags&CookieHttpOnly != 0 && !cookie.HttpOnly {
+		missing = append(missing, "HttpOnly")
+	}
+	if len(missing) > 0 {
+		r.setError(fmt.Errorf("expected cookie %q to be %v, but it was not", name, strings.Join(missing, " and ")))
+	}
+	return r
+}
+
+// ExpectCookiePresent checks that the response sets a cookie named name,
+// whatever its value.
+func (r *responseWrapper) ExpectCookiePresent(name string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if r.cookie(name) == nil {
+		r.setError(fmt.Errorf("expected cookie %q to be set, but it was not", name))
+	}
+	return r
+}
+
+// ExpectDurationUnder fails if the request took d or longer, from sending it
+// to having read the whole response body. Retries and waiting for rate
+// limits and concurrency slots do not count.
+func (r *responseWrapper) ExpectDurationUnder(d time.Duration) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if r.timings.Total >= d {
+		r.setError(fmt.Errorf("expected the request to take under %v but it took %v", d, r.timings.Total))
+	}
+	return r
+}
+
+// cookie returns the last cookie called name set by the response, or nil.
+func (r *responseWrapper) cookie(name string) *http.Cookie {
+	var found *http.Cookie
+	for _, cookie := range r.resp.Cookies() {
+		if cookie.Name == name {
+			found = cookie
+		}
+	}
+	return found
+}
+
 func (r *responseWrapper) ExpectHeaderContains(key, needle string) ResponseWrapper {
 	if r.error() != nil {
 		return r
@@ -156,6 +647,31 @@ func (r *responseWrapper) ExpectHeaderEquals(key, needle string) ResponseWrapper
 	return r
 }
 
+// ExpectHeaderMatches passes if any value of the header key matches the
+// regular expression pattern.
+func (r *responseWrapper) ExpectHeaderMatches(key, pattern string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		r.setError(err)
+		return r
+	}
+	values := r.resp.Header[key]
+	for _, value := range values {
+		if re.MatchString(value) {
+			return r
+		}
+	}
+	if len(values) == 0 {
+		r.setError(fmt.Errorf("expected a header %q matching %q, but there was none", key, pattern))
+	} else {
+		r.setError(fmt.Errorf("expected a header %q matching %q, but got %q", key, pattern, values))
+	}
+	return r
+}
+
 func (r *responseWrapper) ExpectHeaderNotContains(key, needle string) ResponseWrapper {
 	if r.error() != nil {
 		return r
@@ -225,17 +741,193 @@ func (r *responseWrapper) ExpectHeaderPresent(key string) ResponseWrapper {
 	return r
 }
 
+// ExpectHeaderValueCount passes if the header key was sent exactly n times,
+// e.g. one Set-Cookie per cookie. Values joined with commas in a single
+// header line count once.
+func (r *responseWrapper) ExpectHeaderValueCount(key string, n int) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if values := r.resp.Header[key]; len(values) != n {
+		r.setError(fmt.Errorf("expected %d values of header %q but got %d: %q", n, key, len(values), values))
+	}
+	return r
+}
+
+// ExpectJSONPath parses the body as JSON and compares the value at path, e.g.
+// "data.items.0.name" or "$.data.items[0].name", with expected as it would
+// be encoded as JSON.
+func (r *responseWrapper) ExpectJSONPath(path string, expected interface{}) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	actual, ok := r.lookupJSONPath(path)
+	if !ok {
+		return r
+	}
+	bs, err := json.Marshal(expected)
+	if err != nil {
+		r.setError(fmt.Errorf("marshalling expected value: %v", err))
+		return r
+	}
+	var want interface{}
+	if err := json.Unmarshal(bs, &want); err != nil {
+		r.setError(fmt.Errorf("unmarshalling expected value: %v", err))
+		return r
+	}
+	if !reflect.DeepEqual(want, actual) {
+		r.setError(fmt.Errorf("expected %v at %v but got %v", jsonString(want), path, jsonString(actual)))
+	}
+	return r
+}
+
+// ExpectJSONPathPresent parses the body as JSON and fails if there is no
+// value at path. A null value is present.
+func (r *responseWrapper) ExpectJSONPathPresent(path string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	r.lookupJSONPath(path)
+	return r
+}
+
+func (r *responseWrapper) lookupJSONPath(path string) (interface{}, bool) {
+	var body interface{}
+	if err := json.Unmarshal([]byte(r.body), &body); err != nil {
+		r.setError(fmt.Errorf("unmarshalling body: %v", err))
+		return nil, false
+	}
+	v, err := lookupJSONPath(body, path)
+	if err != nil {
+		r.setError(fmt.Errorf("expected a value at %v, but %v", path, err))
+		return nil, false
+	}
+	return v, true
+}
+
+// ExpectNoContent passes if the status code is 204 and the response has no
+// body, no Content-Type and no Content-Length other than 0, which a 204 must
+// not carry.
+func (r *responseWrapper) ExpectNoContent() ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	var problems []string
+	if r.resp.StatusCode != http.StatusNoContent {
+		problems = append(problems, fmt.Sprintf("got status code %d", r.resp.StatusCode))
+	}
+	if r.body != "" {
+		problems = append(problems, fmt.Sprintf("got a body of %d bytes", len(r.body)))
+	}
+	if ct := r.resp.Header.Get("Content-Type"); ct != "" {
+		problems = append(problems, fmt.Sprintf("got Content-Type %q", ct))
+	}
+	if cl := r.resp.Header.Get("Content-Length"); cl != "" && cl != "0" {
+		problems = append(problems, fmt.Sprintf("got Content-Length %v", cl))
+	}
+	if len(problems) > 0 {
+		r.setError(fmt.Errorf("expected no content but %v", strings.Join(problems, ", ")))
+	}
+	return r
+}
+
+// ExpectNoCredentialLeakOnRedirect fails if a redirect took the request to
+// another origin and any credential header sent with the original request
+// was sent there too.
+func (r *responseWrapper) ExpectNoCredentialLeakOnRedirect() ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if r.req == nil {
+		r.setError(fmt.Errorf("expected no credentials to leak on redirect, but there is no request"))
+		return r
+	}
+	credentialHeaders := r.settings.credentialHeaders
+	if len(credentialHeaders) == 0 {
+		credentialHeaders = DefaultCredentialHeaders
+	}
+	for hop := r.resp.Request; hop != nil && hop != r.req; {
+		if !sameOrigin(r.req.URL, hop.URL) {
+			for _, key := range credentialHeaders {
+				for _, val := range r.req.Header.Values(key) {
+					if contains(hop.Header.Values(key), val) {
+						r.setError(fmt.Errorf("expected no credentials to leak on redirect, but header %v was sent to %v", key, hop.URL.Redacted()))
+						return r
+					}
+				}
+			}
+		}
+		if hop.Response == nil {
+			break
+		}
+		hop = hop.Response.Request
+	}
+	return r
+}
+
 func (r *responseWrapper) ExpectPasses(f func(*http.Response, string) bool) ResponseWrapper {
 	if r.error() != nil {
 		return r
 	}
-	if !f(r.resp, r.body) {
+	passed, err := callPredicate(func() bool {
+		return f(r.resp, r.body)
+	})
+	if err != nil {
+		r.setError(err)
+	} else if !passed {
 		r.setError(fmt.Errorf("expected function to pass, but it did not"))
 	}
 
 	return r
 }
 
+func (r *responseWrapper) ExpectRedirectPreservedBody() ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	final := r.resp.Request
+	if r.req == nil || final == nil || final == r.req {
+		r.setError(fmt.Errorf("expected the request to be redirected, but it was not"))
+		return r
+	}
+	if final.Method != r.req.Method {
+		r.setError(fmt.Errorf("expected the redirected request to keep method %v, but it became %v", r.req.Method, final.Method))
+		return r
+	}
+	original := r.sentBody.original
+	if len(original) > 0 && r.sentBody.Rewinds() == 0 {
+		r.setError(fmt.Errorf("expected the request body to be sent again after the redirect, but it was not"))
+		return r
+	}
+	if sent := r.sentBody.Bytes(); string(sent) != string(original) {
+		r.setError(fmt.Errorf("expected the redirected request body to be %q, but it was %q", original, sent))
+	}
+
+	return r
+}
+
+func (r *responseWrapper) ExpectRequestQuerySent(key, value string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if r.req == nil {
+		r.setError(fmt.Errorf("expected query parameter %q=%q to be sent, but there is no request", key, value))
+		return r
+	}
+	sent := r.req.URL.Query()[key]
+	for _, v := range sent {
+		if v == value {
+			return r
+		}
+	}
+	if len(sent) == 0 {
+		r.setError(fmt.Errorf("expected query parameter %q=%q to be sent, but it was not sent at all", key, value))
+	} else {
+		r.setError(fmt.Errorf("expected query parameter %q=%q to be sent, but it was sent as %q", key, value, sent))
+	}
+	return r
+}
+
 func (r *responseWrapper) ExpectStatus(code int) ResponseWrapper {
 	if r.error() != nil {
 		return r
@@ -247,6 +939,46 @@ func (r *responseWrapper) ExpectStatus(code int) ResponseWrapper {
 	return r
 }
 
+func (r *responseWrapper) ExpectStatus2xx() ResponseWrapper {
+	return r.expectStatusClass(2)
+}
+
+func (r *responseWrapper) ExpectStatus3xx() ResponseWrapper {
+	return r.expectStatusClass(3)
+}
+
+func (r *responseWrapper) ExpectStatus4xx() ResponseWrapper {
+	return r.expectStatusClass(4)
+}
+
+func (r *responseWrapper) ExpectStatus5xx() ResponseWrapper {
+	return r.expectStatusClass(5)
+}
+
+func (r *responseWrapper) expectStatusClass(class int) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if r.resp.StatusCode/100 != class {
+		r.setError(fmt.Errorf("expected a %dxx status code but got %d", class, r.resp.StatusCode))
+	}
+	return r
+}
+
+// ExpectStatusIn passes if the status code is any of codes.
+func (r *responseWrapper) ExpectStatusIn(codes ...int) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	for _, code := range codes {
+		if r.resp.StatusCode == code {
+			return r
+		}
+	}
+	r.setError(fmt.Errorf("expected status code in %v but got %d", codes, r.resp.StatusCode))
+	return r
+}
+
 func (r *responseWrapper) ParseBody(v interface{}) ResponseWrapper {
 	if r.error() != nil {
 		return r
@@ -258,12 +990,154 @@ func (r *responseWrapper) ParseBody(v interface{}) ResponseWrapper {
 	return r
 }
 
-type nopResponseWrapper struct{}
+// RemoteAddr returns the address of the connection the final response was
+// read from, e.g. "[::1]:8080", or "" if the request was not sent over a
+// network connection.
+func (r *responseWrapper) RemoteAddr() string {
+	return r.timings.Chosen
+}
+
+// ReplayRequest sends the request behind this response again, byte for byte,
+// and returns the wrapper for the new response.
+func (r *responseWrapper) ReplayRequest() ResponseWrapper {
+	if r.error() != nil {
+		return nopResponseWrapper{errGetter: r.error}
+	}
+	if r.replay == nil {
+		r.setError(fmt.Errorf("cannot replay a response that was not produced by a request"))
+		return nopResponseWrapper{errGetter: r.error}
+	}
+	return r.replay()
+}
+
+func (r *responseWrapper) Response() *http.Response {
+	return r.resp
+}
+
+// RetryErrors returns why each attempt before the final one failed.
+func (r *responseWrapper) RetryErrors() []error {
+	return r.retryErrors
+}
+
+// SentURL returns the URL the request was sent to, after the client added
+// its settings but before any redirects.
+func (r *responseWrapper) SentURL() *url.URL {
+	if r.req == nil {
+		return nil
+	}
+	u := *r.req.URL
+	return &u
+}
+
+// ServedBy returns the base URL (scheme and host) that served the final
+// response, which differs from the client's if the request was redirected.
+func (r *responseWrapper) ServedBy() string {
+	if r.resp == nil || r.resp.Request == nil || r.resp.Request.URL == nil {
+		return ""
+	}
+	u := r.resp.Request.URL
+	return u.Scheme + "://" + u.Host
+}
+
+func (r *responseWrapper) SentBody() string {
+	return string(r.sentBody.Bytes())
+}
+
+func (r *responseWrapper) Sizes() BodySizes {
+	return r.sizes
+}
+
+// DryRun reports whether the request was not sent because the client is in
+// dry-run mode; it is false for a response.
+func (r *responseWrapper) DryRun() bool {
+	return false
+}
+
+// Duration returns how long the request took, as in Timings().Total.
+func (r *responseWrapper) Duration() time.Duration {
+	return r.timings.Total
+}
+
+// Timings returns how the connection the final response was read from was
+// obtained, including every dial attempt.
+func (r *responseWrapper) Timings() Timings {
+	return r.timings
+}
+
+// Wire returns the bytes that crossed the connection for the final response,
+// if the client was set up with WithWireCapture.
+func (r *responseWrapper) Wire() WireCapture {
+	return r.wire
+}
+
+func contains(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// callPredicate calls a user-supplied predicate, turning a panic into an
+// error carrying the panic value and stack rather than crashing the test.
+func callPredicate(f func() bool) (passed bool, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("expected function to pass, but it panicked: %v\n%s", p, debug.Stack())
+		}
+	}()
+	return f(), nil
+}
+
+// nopResponseWrapper stands in for a response that does not exist. If the
+// request was not sent because the client is in dry-run mode, dryRun holds
+// it; otherwise errGetter, if set, reports why the request was not made.
+type nopResponseWrapper struct {
+	dryRun    *dryRunRequest
+	errGetter func() error
+}
+
+func (n nopResponseWrapper) Attempts() int {
+	return 0
+}
 
 func (n nopResponseWrapper) Body() string {
 	return ""
 }
 
+func (n nopResponseWrapper) DryRun() bool {
+	return n.dryRun != nil
+}
+
+func (n nopResponseWrapper) Duration() time.Duration {
+	return 0
+}
+
+// Error returns the error that kept the request from being made, which is
+// the client's error.
+func (n nopResponseWrapper) Error() error {
+	if n.errGetter == nil {
+		return nil
+	}
+	return n.errGetter()
+}
+
+func (n nopResponseWrapper) ExportJSON(w io.Writer) error {
+	if n.dryRun != nil {
+		return n.dryRun.exportJSON(w)
+	}
+	return errors.New("there is no response to export")
+}
+
+func (n nopResponseWrapper) HasTag(tag string) bool {
+	return contains(n.Tags(), tag)
+}
+
+func (n nopResponseWrapper) ExpectAPIVersion(string) ResponseWrapper {
+	return n
+}
+
 func (n nopResponseWrapper) ExpectBodyContains(string) ResponseWrapper {
 	return n
 }
@@ -272,6 +1146,22 @@ func (n nopResponseWrapper) ExpectBodyEquals(string) ResponseWrapper {
 	return n
 }
 
+func (n nopResponseWrapper) ExpectBodyMatchesJSONSchema(string) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectBodyMatchesJSONSchemaFromFile(string) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectBodyJSONEquals(interface{}) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectBodyMatches(string) ResponseWrapper {
+	return n
+}
+
 func (n nopResponseWrapper) ExpectBodyNotContains(string) ResponseWrapper {
 	return n
 }
@@ -280,10 +1170,46 @@ func (n nopResponseWrapper) ExpectBodyNotEquals(string) ResponseWrapper {
 	return n
 }
 
+func (n nopResponseWrapper) ExpectBodyNotMatches(string) ResponseWrapper {
+	return n
+}
+
 func (n nopResponseWrapper) ExpectBodyPasses(func(string) bool) ResponseWrapper {
 	return n
 }
 
+func (n nopResponseWrapper) ExpectCharset(string) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectCompressedTransfer() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectContentLanguage(string) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectCookieEquals(name, value string) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectCookieHasFlag(name string, flags CookieFlag) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectCookiePresent(name string) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectDurationUnder(time.Duration) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectGraphQLNoErrors() ResponseWrapper {
+	return n
+}
+
 func (n nopResponseWrapper) ExpectHeaderContains(key, value string) ResponseWrapper {
 	return n
 }
@@ -292,6 +1218,10 @@ func (n nopResponseWrapper) ExpectHeaderEquals(key, value string) ResponseWrappe
 	return n
 }
 
+func (n nopResponseWrapper) ExpectHeaderMatches(string, string) ResponseWrapper {
+	return n
+}
+
 func (n nopResponseWrapper) ExpectHeaderNotContains(key, value string) ResponseWrapper {
 	return n
 }
@@ -308,14 +1238,159 @@ func (n nopResponseWrapper) ExpectHeaderPresent(key string) ResponseWrapper {
 	return n
 }
 
+func (n nopResponseWrapper) ExpectHeaderValueCount(string, int) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectJSONPath(string, interface{}) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectJSONPathPresent(string) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectMatchesSpec() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectNoContent() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectNoCredentialLeakOnRedirect() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectNotProxied() ResponseWrapper {
+	return n
+}
+
 func (n nopResponseWrapper) ExpectPasses(func(resp *http.Response, body string) bool) ResponseWrapper {
 	return n
 }
 
+func (n nopResponseWrapper) ExpectPreconditionFailed() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectProxied() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectRedirectPreservedBody() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectRequestQuerySent(key, value string) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectServerTiming(string, DurationCondition) ResponseWrapper {
+	return n
+}
+
 func (n nopResponseWrapper) ExpectStatus(int) ResponseWrapper {
 	return n
 }
 
+func (n nopResponseWrapper) ExpectStatus2xx() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectStatus3xx() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectStatus4xx() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectStatus5xx() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectStatusIn(...int) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectStreamedBytes(int64) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ExpectStreamedSHA256(string) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ParseAndValidateBody(interface{}) ResponseWrapper {
+	return n
+}
+
 func (n nopResponseWrapper) ParseBody(interface{}) ResponseWrapper {
 	return n
 }
+
+func (n nopResponseWrapper) ParseGraphQLData(interface{}) ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) ParseMultipartBody() ([]Part, error) {
+	return nil, errors.New("there is no response to parse")
+}
+
+func (n nopResponseWrapper) RemoteAddr() string {
+	return ""
+}
+
+func (n nopResponseWrapper) ReplayRequest() ResponseWrapper {
+	return n
+}
+
+func (n nopResponseWrapper) Response() *http.Response {
+	return nil
+}
+
+func (n nopResponseWrapper) RetryErrors() []error {
+	return nil
+}
+
+func (n nopResponseWrapper) SentBody() string {
+	if n.dryRun != nil {
+		return n.dryRun.body
+	}
+	return ""
+}
+
+func (n nopResponseWrapper) Sizes() BodySizes {
+	return BodySizes{}
+}
+
+func (n nopResponseWrapper) Tags() []string {
+	if n.dryRun != nil {
+		return n.dryRun.tags
+	}
+	return nil
+}
+
+func (n nopResponseWrapper) Timings() Timings {
+	return Timings{}
+}
+
+func (n nopResponseWrapper) Wire() WireCapture {
+	return WireCapture{}
+}
+
+func (n nopResponseWrapper) SentURL() *url.URL {
+	if n.dryRun != nil {
+		return n.dryRun.req.URL
+	}
+	return nil
+}
+
+func (n nopResponseWrapper) ServedBy() string {
+	return ""
+}
+
+func (n nopResponseWrapper) ServerTimings() []ServerTiming {
+	return nil
+}
@@ -1,11 +1,16 @@
 package crest
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -44,6 +49,14 @@ func respWithBody(s string) *http.Response {
 	return r
 }
 
+func gzipped(s string) string {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.String()
+}
+
 func TestNewResponseWrapperExistingErr(t *testing.T) {
 	body := "some body"
 	resp := respWithBody(body)
@@ -81,6 +94,40 @@ func TestNewResponseWrapperOK(t *testing.T) {
 	require.NoError(t, ec.Error())
 }
 
+func TestWrapResponse(t *testing.T) {
+	resp := respWithBody("some body")
+	resp.Header.Set("X-Test", "value")
+	rw := WrapResponse(resp)
+	rw.ExpectStatus(http.StatusOK).
+		ExpectHeaderEquals("X-Test", "value").
+		ExpectBodyEquals("some body")
+	require.NoError(t, rw.Error())
+
+	rw.ExpectStatus(http.StatusCreated)
+	require.Error(t, rw.Error())
+	require.Contains(t, rw.Error().Error(), "expected status code 201")
+}
+
+func TestWrapRecorder(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 1}`))
+	})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/items", nil))
+
+	var item struct {
+		ID int `json:"id"`
+	}
+	rw := WrapRecorder(rr).
+		ExpectStatus(http.StatusCreated).
+		ExpectCharset("utf-8").
+		ParseBody(&item)
+	require.NoError(t, rw.Error())
+	require.Equal(t, 1, item.ID)
+}
+
 func TestBody(t *testing.T) {
 	expectedBody := "some body"
 	var rw ResponseWrapper
@@ -162,6 +209,84 @@ func TestExpectBodyEquals(t *testing.T) {
 	require.Equal(t, existingError, ec.Error())
 }
 
+func TestExpectBodyJSONEquals(t *testing.T) {
+	body := `{"b": [1, 2.0, {"c": null}], "a": "x"}`
+	passing := []interface{}{
+		`{"a":"x","b":[1,2,{"c":null}]}`,
+		[]byte(`{"a": "x", "b": [1.0, 2, {"c": null}]}`),
+		json.RawMessage(`{"b":[1,2,{"c":null}],"a":"x"}`),
+		map[string]interface{}{"a": "x", "b": []interface{}{1, 2, map[string]interface{}{"c": nil}}},
+	}
+	for _, expected := range passing {
+		ec := &errContainer{}
+		rw := newResponseWrapper(respWithBody(body), neverErr, ec.Set)
+		require.Equal(t, rw, rw.ExpectBodyJSONEquals(expected))
+		require.NoError(t, ec.Error(), "%v", expected)
+	}
+
+	ec := &errContainer{}
+	newResponseWrapper(respWithBody(body), neverErr, ec.Set).ExpectBodyJSONEquals(`{"a":"y","b":[1,2,{}],"d":true}`)
+	require.EqualError(t, ec.Error(), `expected body to equal {"a":"y","b":[1,2,{}],"d":true} but:
+  $.a: "x" != "y"
+  $.b[2].c: null != missing
+  $.d: missing != true`)
+
+	ec = &errContainer{}
+	newResponseWrapper(respWithBody("not json"), neverErr, ec.Set).ExpectBodyJSONEquals(`{}`)
+	require.Contains(t, ec.Error().Error(), "unmarshalling body")
+
+	ec = &errContainer{}
+	newResponseWrapper(respWithBody(body), neverErr, ec.Set).ExpectBodyJSONEquals(`{`)
+	require.Contains(t, ec.Error().Error(), "unmarshalling expected value")
+
+	ec = &errContainer{}
+	newResponseWrapper(respWithBody(`{} {}`), neverErr, ec.Set).ExpectBodyJSONEquals(`{}`)
+	require.Contains(t, ec.Error().Error(), "unmarshalling body")
+
+	// Integers beyond float64 precision keep every digit.
+	ec = &errContainer{}
+	newResponseWrapper(respWithBody(`{"id":9007199254740993}`), neverErr, ec.Set).ExpectBodyJSONEquals(`{"id":9007199254740993}`)
+	require.NoError(t, ec.Error())
+	newResponseWrapper(respWithBody(`{"id":9007199254740993}`), neverErr, ec.Set).ExpectBodyJSONEquals(`{"id":9007199254740992}`)
+	require.EqualError(t, ec.Error(), `expected body to equal {"id":9007199254740992} but:
+  $.id: 9007199254740993 != 9007199254740992`)
+}
+
+func TestExpectBodyMatches(t *testing.T) {
+	body := `{"id":"a1b2","count":42}`
+	testCases := []struct {
+		pattern string
+		matches bool
+	}{
+		{`"id":"[a-z0-9]{4}"`, true},
+		{`"count":\d+`, true},
+		{`^\{.*\}$`, true},
+		{`"count":"`, false},
+		{`^"id"`, false},
+	}
+	for _, testCase := range testCases {
+		ec := &errContainer{}
+		rw := newResponseWrapper(respWithBody(body), neverErr, ec.Set)
+		require.Equal(t, rw, rw.ExpectBodyMatches(testCase.pattern))
+		require.Equal(t, !testCase.matches, ec.Error() != nil, testCase.pattern)
+
+		ec = &errContainer{}
+		rw = newResponseWrapper(respWithBody(body), neverErr, ec.Set)
+		require.Equal(t, rw, rw.ExpectBodyNotMatches(testCase.pattern))
+		require.Equal(t, testCase.matches, ec.Error() != nil, testCase.pattern)
+	}
+
+	ec := &errContainer{}
+	newResponseWrapper(respWithBody(body), neverErr, ec.Set).ExpectBodyNotMatches(`\d{2}`)
+	require.EqualError(t, ec.Error(), `expected body not to match "\\d{2}" but it matched "42"`)
+
+	ec = &errContainer{}
+	newResponseWrapper(respWithBody(body), neverErr, ec.Set).ExpectBodyMatches(`(`)
+	require.Error(t, ec.Error())
+	require.Contains(t, ec.Error().Error(), `compiling pattern "("`)
+	require.Contains(t, ec.Error().Error(), "missing closing )")
+}
+
 func TestExpectBodyNotContains(t *testing.T) {
 	body := "some body\nmore lines\nlast line"
 	testCases := []struct {
@@ -198,6 +323,32 @@ func TestExpectBodyNotContains(t *testing.T) {
 	require.Equal(t, existingError, ec.Error())
 }
 
+func TestNormalizedBodyAssertions(t *testing.T) {
+	body := "Cafe\u0301 Zu\u0308rich"
+	settings := responseSettings{decompress: true, normalize: nfc}
+
+	ec := &errContainer{}
+	rw := newResponseWrapperWithSettings(respWithBody(body), neverErr, ec.Set, settings)
+	rw.ExpectBodyEquals("Caf\u00e9 Z\u00fcrich").
+		ExpectBodyContains("\u00e9").
+		ExpectBodyNotContains("Zurich").
+		ExpectBodyNotEquals("caf\u00e9 z\u00fcrich")
+	require.NoError(t, ec.Error())
+	require.Equal(t, body, rw.Body())
+
+	ec = &errContainer{}
+	settings.normalize = func(s string) string {
+		return foldCase(nfc(s))
+	}
+	rw = newResponseWrapperWithSettings(respWithBody(body), neverErr, ec.Set, settings)
+	rw.ExpectBodyEquals("caf\u00e9 z\u00fcrich")
+	require.NoError(t, ec.Error())
+
+	ec = &errContainer{}
+	newResponseWrapper(respWithBody(body), neverErr, ec.Set).ExpectBodyEquals("Caf\u00e9 Z\u00fcrich")
+	require.Error(t, ec.Error())
+}
+
 func TestExpectBodyNotEquals(t *testing.T) {
 	body := "some body"
 	testCases := []struct {
@@ -275,6 +426,28 @@ func TestExpectBodyPasses(t *testing.T) {
 	require.Equal(t, existingError, ec.Error())
 }
 
+func TestPredicatePanics(t *testing.T) {
+	ec := &errContainer{}
+	rw := newResponseWrapper(respWithBody("body"), neverErr, ec.Set)
+	rw2 := rw.ExpectBodyPasses(func(string) bool {
+		var m map[string]int
+		m["boom"]++
+		return true
+	})
+	require.Equal(t, rw, rw2)
+	require.Error(t, ec.Error())
+	require.Contains(t, ec.Error().Error(), "panicked: assignment to entry in nil map")
+	require.Contains(t, ec.Error().Error(), "TestPredicatePanics")
+
+	ec = &errContainer{}
+	rw = newResponseWrapper(respWithBody("body"), neverErr, ec.Set)
+	rw.ExpectPasses(func(resp *http.Response, body string) bool {
+		panic("custom panic")
+	})
+	require.Error(t, ec.Error())
+	require.Contains(t, ec.Error().Error(), "panicked: custom panic")
+}
+
 func TestExpectHeaderContains(t *testing.T) {
 	testCases := []struct {
 		key    string
@@ -369,6 +542,51 @@ func TestExpectHeaderEquals(t *testing.T) {
 	require.Contains(t, ec.Error().Error(), "no headers")
 }
 
+func TestExpectHeaderMatches(t *testing.T) {
+	resp := respWithBody("")
+	resp.Header.Add("Set-Cookie", "a=1; Path=/")
+	resp.Header.Add("Set-Cookie", "session=abc123; HttpOnly")
+	testCases := []struct {
+		key     string
+		pattern string
+		passes  bool
+	}{
+		{"Set-Cookie", `^session=\w+; HttpOnly$`, true},
+		{"Set-Cookie", `Path=/$`, true},
+		{"Set-Cookie", `Secure`, false},
+		{"Vary", `.*`, false},
+	}
+	for _, testCase := range testCases {
+		ec := &errContainer{}
+		rw := newResponseWrapper(resp, neverErr, ec.Set)
+		require.Equal(t, rw, rw.ExpectHeaderMatches(testCase.key, testCase.pattern))
+		require.Equal(t, !testCase.passes, ec.Error() != nil, testCase.pattern)
+	}
+
+	ec := &errContainer{}
+	newResponseWrapper(resp, neverErr, ec.Set).ExpectHeaderMatches("Set-Cookie", "Secure")
+	require.EqualError(t, ec.Error(), `expected a header "Set-Cookie" matching "Secure", but got ["a=1; Path=/" "session=abc123; HttpOnly"]`)
+
+	ec = &errContainer{}
+	newResponseWrapper(resp, neverErr, ec.Set).ExpectHeaderMatches("Set-Cookie", "[")
+	require.Contains(t, ec.Error().Error(), `compiling pattern "["`)
+}
+
+func TestExpectHeaderValueCount(t *testing.T) {
+	resp := respWithBody("")
+	resp.Header.Add("Set-Cookie", "a=1")
+	resp.Header.Add("Set-Cookie", "b=2")
+	resp.Header.Add("Vary", "Accept, Accept-Encoding")
+
+	ec := &errContainer{}
+	rw := newResponseWrapper(resp, neverErr, ec.Set)
+	require.Equal(t, rw, rw.ExpectHeaderValueCount("Set-Cookie", 2).ExpectHeaderValueCount("Vary", 1).ExpectHeaderValueCount("Etag", 0))
+	require.NoError(t, ec.Error())
+
+	rw.ExpectHeaderValueCount("Set-Cookie", 1)
+	require.EqualError(t, ec.Error(), `expected 1 values of header "Set-Cookie" but got 2: ["a=1" "b=2"]`)
+}
+
 func TestExpectHeaderNotContains(t *testing.T) {
 	testCases := []struct {
 		key    string
@@ -601,6 +819,202 @@ func TestExpectPasses(t *testing.T) {
 	require.Equal(t, existingError, ec.Error())
 }
 
+func TestExpectRequestQuerySent(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/path?key=a&key=b%20c&other=1", nil)
+	require.NoError(t, err)
+	testCases := []struct {
+		key    string
+		value  string
+		passes bool
+	}{
+		{"key", "a", true},
+		{"key", "b c", true},
+		{"other", "1", true},
+		{"key", "c", false},
+		{"missing", "", false},
+	}
+	for _, testCase := range testCases {
+		ec := &errContainer{}
+		rw := newResponseWrapperWithSettings(respWithBody(""), neverErr, ec.Set, defaultResponseSettings)
+		rw.req = req
+		rw2 := rw.ExpectRequestQuerySent(testCase.key, testCase.value)
+		require.Equal(t, rw, rw2)
+		if testCase.passes {
+			require.NoError(t, ec.Error(), "%v=%v", testCase.key, testCase.value)
+		} else {
+			require.Error(t, ec.Error(), "%v=%v", testCase.key, testCase.value)
+		}
+	}
+
+	ec := &errContainer{}
+	rw := newResponseWrapper(respWithBody(""), neverErr, ec.Set)
+	rw.ExpectRequestQuerySent("key", "a")
+	require.Error(t, ec.Error())
+	require.Nil(t, rw.SentURL())
+
+	existingError := fmt.Errorf("existing error")
+	ec = &errContainer{}
+	rw = newResponseWrapper(respWithBody(""), ec.Error, ec.Set)
+	ec.Set(existingError)
+	rw.ExpectRequestQuerySent("key", "a")
+	require.Equal(t, existingError, ec.Error())
+}
+
+func TestExpectCookies(t *testing.T) {
+	resp := respWithBody("")
+	resp.Header.Add("Set-Cookie", "session=abc; Path=/; Secure; HttpOnly")
+	resp.Header.Add("Set-Cookie", "theme=dark; Path=/")
+	resp.Header.Add("Set-Cookie", "csrf=1; Secure")
+	testCases := []struct {
+		expect func(ResponseWrapper) ResponseWrapper
+		err    string
+	}{
+		{func(rw ResponseWrapper) ResponseWrapper { return rw.ExpectCookiePresent("session") }, ""},
+		{func(rw ResponseWrapper) ResponseWrapper { return rw.ExpectCookiePresent("missing") }, `expected cookie "missing" to be set, but it was not`},
+		{func(rw ResponseWrapper) ResponseWrapper { return rw.ExpectCookieEquals("theme", "dark") }, ""},
+		{func(rw ResponseWrapper) ResponseWrapper { return rw.ExpectCookieEquals("theme", "light") }, `expected cookie "theme" to be set to "light", but it was set to "dark"`},
+		{func(rw ResponseWrapper) ResponseWrapper { return rw.ExpectCookieEquals("missing", "x") }, `expected cookie "missing" to be set to "x", but it was not set`},
+		{func(rw ResponseWrapper) ResponseWrapper {
+			return rw.ExpectCookieHasFlag("session", CookieSecure|CookieHttpOnly)
+		}, ""},
+		{func(rw ResponseWrapper) ResponseWrapper { return rw.ExpectCookieHasFlag("csrf", CookieSecure) }, ""},
+		{func(rw ResponseWrapper) ResponseWrapper {
+			return rw.ExpectCookieHasFlag("csrf", CookieSecure|CookieHttpOnly)
+		}, `expected cookie "csrf" to be HttpOnly, but it was not`},
+		{func(rw ResponseWrapper) ResponseWrapper {
+			return rw.ExpectCookieHasFlag("theme", CookieSecure|CookieHttpOnly)
+		}, `expected cookie "theme" to be Secure and HttpOnly, but it was not`},
+		{func(rw ResponseWrapper) ResponseWrapper { return rw.ExpectCookieHasFlag("missing", CookieSecure) }, `expected cookie "missing" to be set, but it was not`},
+	}
+	for i, testCase := range testCases {
+		ec := &errContainer{}
+		rw := newResponseWrapper(resp, neverErr, ec.Set)
+		rw2 := testCase.expect(rw)
+		require.Equal(t, rw, rw2)
+		if testCase.err == "" {
+			require.NoError(t, ec.Error(), "%v", i)
+		} else {
+			require.EqualError(t, ec.Error(), testCase.err, "%v", i)
+		}
+	}
+
+	existingError := fmt.Errorf("existing error")
+	ec := &errContainer{}
+	rw := newResponseWrapper(resp, ec.Error, ec.Set)
+	ec.Set(existingError)
+	rw.ExpectCookiePresent("missing").ExpectCookieEquals("missing", "").ExpectCookieHasFlag("missing", CookieSecure)
+	require.Equal(t, existingError, ec.Error())
+}
+
+func TestExpectJSONPath(t *testing.T) {
+	body := `{"data": {"items": [{"name": "a", "tags": ["x"]}, {"name": "b", "n": 2.5, "none": null}]}, "total": 2}`
+	testCases := []struct {
+		path     string
+		expected interface{}
+		passes   bool
+	}{
+		{"total", 2, true},
+		{"$.total", 2.0, true},
+		{"total", "2", false},
+		{"data.items.0.name", "a", true},
+		{"$.data.items[1].name", "b", true},
+		{"data.items[1].n", 2.5, true},
+		{"data.items.1.none", nil, true},
+		{"data.items.0.tags", []string{"x"}, true},
+		{"data.items.0", map[string]interface{}{"name": "a", "tags": []string{"x"}}, true},
+		{"data.items.0.tags", []string{"y"}, false},
+		{"data.items.2.name", "a", false},
+		{"data.missing", nil, false},
+		{"total.value", 2, false},
+	}
+	for _, testCase := range testCases {
+		ec := &errContainer{}
+		rw := newResponseWrapper(respWithBody(body), neverErr, ec.Set)
+		rw2 := rw.ExpectJSONPath(testCase.path, testCase.expected)
+		require.Equal(t, rw, rw2)
+		if testCase.passes {
+			require.NoError(t, ec.Error(), testCase.path)
+		} else {
+			require.Error(t, ec.Error(), testCase.path)
+		}
+	}
+
+	ec := &errContainer{}
+	newResponseWrapper(respWithBody(body), neverErr, ec.Set).ExpectJSONPath("data.items.0.name", "b")
+	require.EqualError(t, ec.Error(), `expected "b" at data.items.0.name but got "a"`)
+
+	ec = &errContainer{}
+	newResponseWrapper(respWithBody(body), neverErr, ec.Set).ExpectJSONPath("data.items.5", nil)
+	require.EqualError(t, ec.Error(), "expected a value at data.items.5, but $.data.items has no index 5, its length is 2")
+
+	ec = &errContainer{}
+	newResponseWrapper(respWithBody("not json"), neverErr, ec.Set).ExpectJSONPath("a", nil)
+	require.Error(t, ec.Error())
+
+	existingError := fmt.Errorf("existing error")
+	ec = &errContainer{}
+	rw := newResponseWrapper(respWithBody(body), ec.Error, ec.Set)
+	ec.Set(existingError)
+	rw.ExpectJSONPath("total", 3)
+	require.Equal(t, existingError, ec.Error())
+}
+
+func TestExpectJSONPathPresent(t *testing.T) {
+	body := `{"a": {"b": null, "c": [1]}}`
+	testCases := []struct {
+		path   string
+		passes bool
+	}{
+		{"", true},
+		{"a", true},
+		{"a.b", true},
+		{"a.c[0]", true},
+		{"a.c[1]", false},
+		{"a.d", false},
+		{"a.b.c", false},
+	}
+	for _, testCase := range testCases {
+		ec := &errContainer{}
+		rw := newResponseWrapper(respWithBody(body), neverErr, ec.Set)
+		rw2 := rw.ExpectJSONPathPresent(testCase.path)
+		require.Equal(t, rw, rw2)
+		if testCase.passes {
+			require.NoError(t, ec.Error(), testCase.path)
+		} else {
+			require.Error(t, ec.Error(), testCase.path)
+		}
+	}
+
+	ec := &errContainer{}
+	newResponseWrapper(respWithBody(body), neverErr, ec.Set).ExpectJSONPathPresent("a.d")
+	require.EqualError(t, ec.Error(), `expected a value at a.d, but $.a has no field "d"`)
+}
+
+func TestExpectNoContent(t *testing.T) {
+	noContent := func(body string, header ...string) *http.Response {
+		resp := respWithBody(body)
+		resp.StatusCode = http.StatusNoContent
+		for i := 0; i < len(header); i += 2 {
+			resp.Header.Set(header[i], header[i+1])
+		}
+		return resp
+	}
+	ok := respWithBody("")
+
+	ec := &errContainer{}
+	newResponseWrapper(noContent(""), neverErr, ec.Set).ExpectNoContent()
+	require.NoError(t, ec.Error())
+	newResponseWrapper(noContent("", "Content-Length", "0"), neverErr, ec.Set).ExpectNoContent()
+	require.NoError(t, ec.Error())
+
+	newResponseWrapper(ok, neverErr, ec.Set).ExpectNoContent()
+	require.EqualError(t, ec.Error(), "expected no content but got status code 200")
+
+	ec = &errContainer{}
+	newResponseWrapper(noContent("{}", "Content-Type", "application/json", "Content-Length", "2"), neverErr, ec.Set).ExpectNoContent()
+	require.EqualError(t, ec.Error(), `expected no content but got a body of 2 bytes, got Content-Type "application/json", got Content-Length 2`)
+}
+
 func TestExpectStatus(t *testing.T) {
 	testCases := []struct {
 		code   int
@@ -633,6 +1047,77 @@ func TestExpectStatus(t *testing.T) {
 	require.Equal(t, existingError, ec.Error())
 }
 
+func TestExpectStatusClass(t *testing.T) {
+	testCases := []struct {
+		code   int
+		expect func(ResponseWrapper) ResponseWrapper
+		passes bool
+	}{
+		{200, ResponseWrapper.ExpectStatus2xx, true},
+		{204, ResponseWrapper.ExpectStatus2xx, true},
+		{301, ResponseWrapper.ExpectStatus2xx, false},
+		{301, ResponseWrapper.ExpectStatus3xx, true},
+		{404, ResponseWrapper.ExpectStatus4xx, true},
+		{500, ResponseWrapper.ExpectStatus4xx, false},
+		{503, ResponseWrapper.ExpectStatus5xx, true},
+		{200, ResponseWrapper.ExpectStatus5xx, false},
+	}
+	for _, testCase := range testCases {
+		resp := respWithBody("")
+		resp.StatusCode = testCase.code
+		ec := &errContainer{}
+		rw := newResponseWrapper(resp, neverErr, ec.Set)
+		rw2 := testCase.expect(rw)
+		require.Equal(t, rw, rw2)
+		if testCase.passes {
+			require.NoError(t, ec.Error(), "%v", testCase.code)
+		} else {
+			require.Error(t, ec.Error(), "%v", testCase.code)
+		}
+	}
+
+	resp := respWithBody("")
+	resp.StatusCode = 404
+	ec := &errContainer{}
+	newResponseWrapper(resp, neverErr, ec.Set).ExpectStatus2xx()
+	require.EqualError(t, ec.Error(), "expected a 2xx status code but got 404")
+}
+
+func TestExpectStatusIn(t *testing.T) {
+	testCases := []struct {
+		codes  []int
+		passes bool
+	}{
+		{[]int{200}, true},
+		{[]int{201, 200}, true},
+		{[]int{201, 202}, false},
+		{nil, false},
+	}
+	for _, testCase := range testCases {
+		ec := &errContainer{}
+		rw := newResponseWrapper(respWithBody(""), neverErr, ec.Set)
+		rw2 := rw.ExpectStatusIn(testCase.codes...)
+		require.Equal(t, rw, rw2)
+		if testCase.passes {
+			require.NoError(t, ec.Error(), "%v", testCase.codes)
+		} else {
+			require.Error(t, ec.Error(), "%v", testCase.codes)
+		}
+	}
+
+	ec := &errContainer{}
+	newResponseWrapper(respWithBody(""), neverErr, ec.Set).ExpectStatusIn(201, 204)
+	require.EqualError(t, ec.Error(), "expected status code in [201 204] but got 200")
+
+	existingError := fmt.Errorf("existing error")
+	ec = &errContainer{}
+	rw := newResponseWrapper(respWithBody(""), ec.Error, ec.Set)
+	ec.Set(existingError)
+	rw.ExpectStatusIn(500)
+	rw.ExpectStatus5xx()
+	require.Equal(t, existingError, ec.Error())
+}
+
 func TestParseBody(t *testing.T) {
 	type KV struct {
 		Key   string `json:"key"`
@@ -677,21 +1162,324 @@ func TestParseBody(t *testing.T) {
 	require.Equal(t, existingError, ec.Error())
 }
 
+func TestSizes(t *testing.T) {
+	body := strings.Repeat("compressible ", 100)
+	resp := respWithBody(gzipped(body))
+	resp.Header.Set("Content-Encoding", "gzip")
+	ec := &errContainer{}
+	rw := newResponseWrapper(resp, neverErr, ec.Set)
+	require.NoError(t, ec.Error())
+	require.Equal(t, body, rw.Body())
+	require.Empty(t, resp.Header.Get("Content-Encoding"))
+	sizes := rw.Sizes()
+	require.Equal(t, int64(len(gzipped(body))), sizes.Wire)
+	require.Equal(t, int64(len(body)), sizes.Decoded)
+	require.True(t, sizes.Ratio() > 1)
+
+	resp = respWithBody(body)
+	ec = &errContainer{}
+	rw = newResponseWrapper(resp, neverErr, ec.Set)
+	require.NoError(t, ec.Error())
+	require.Equal(t, BodySizes{Wire: int64(len(body)), Decoded: int64(len(body))}, rw.Sizes())
+
+	resp = respWithBody(gzipped(body))
+	resp.Header.Set("Content-Encoding", "gzip")
+	ec = &errContainer{}
+	rw = newResponseWrapperWithSettings(resp, neverErr, ec.Set, responseSettings{decompress: false})
+	require.NoError(t, ec.Error())
+	require.Equal(t, gzipped(body), rw.Body())
+	require.Equal(t, rw.Sizes().Wire, rw.Sizes().Decoded)
+
+	resp = respWithBody("not gzip")
+	resp.Header.Set("Content-Encoding", "gzip")
+	ec = &errContainer{}
+	newResponseWrapper(resp, neverErr, ec.Set)
+	require.Error(t, ec.Error())
+}
+
+func TestMaxBodySize(t *testing.T) {
+	payload := strings.Repeat("x", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte(payload))
+			gz.Close()
+			return
+		}
+		w.Write([]byte(payload))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithMaxBodySize(1000, FailOversizedBody)
+	c.Get("/").ExpectBodyEquals(payload)
+	c.Get("/gzip").ExpectBodyEquals(payload)
+	require.NoError(t, c.Error())
+
+	c.WithMaxBodySize(100, FailOversizedBody).Get("/")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "the body is larger than the limit of 100 bytes")
+
+	// The compressed body fits, but not once it is decoded.
+	c = NewClient(srv.URL).WithMaxBodySize(100, FailOversizedBody)
+	c.Get("/gzip")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "decoding gzip response body")
+
+	c = NewClient(srv.URL).WithMaxBodySize(100, TruncateOversizedBody)
+	rw := c.Get("/").ExpectBodyEquals(payload[:100])
+	require.Equal(t, BodySizes{Wire: 100, Decoded: 100, Truncated: true}, rw.Sizes())
+	rw = c.Get("/gzip").ExpectBodyEquals(payload[:100])
+	require.True(t, rw.Sizes().Truncated)
+	require.NoError(t, c.Error())
+
+	// Cut short on the wire, the body decodes to what the kept bytes hold.
+	c = NewClient(srv.URL).WithMaxBodySize(15, TruncateOversizedBody)
+	rw = c.Get("/gzip")
+	require.NoError(t, c.Error())
+	require.True(t, rw.Sizes().Truncated)
+	require.Equal(t, int64(15), rw.Sizes().Wire)
+	require.True(t, len(rw.Body()) <= 15)
+
+	c = NewClient(srv.URL).WithMaxBodySize(-1, FailOversizedBody)
+	require.Error(t, c.Error())
+}
+
+func TestExpectCompressedTransfer(t *testing.T) {
+	resp := respWithBody(gzipped("body"))
+	resp.Header.Set("Content-Encoding", "gzip")
+	ec := &errContainer{}
+	rw := newResponseWrapper(resp, neverErr, ec.Set)
+	rw2 := rw.ExpectCompressedTransfer()
+	require.Equal(t, rw, rw2)
+	require.NoError(t, ec.Error())
+
+	resp = respWithBody(gzipped("body"))
+	resp.Header.Set("Content-Encoding", "gzip")
+	ec = &errContainer{}
+	rw = newResponseWrapperWithSettings(resp, neverErr, ec.Set, responseSettings{decompress: false})
+	rw.ExpectCompressedTransfer()
+	require.NoError(t, ec.Error())
+
+	resp = respWithBody("body")
+	ec = &errContainer{}
+	rw = newResponseWrapper(resp, neverErr, ec.Set)
+	rw.ExpectCompressedTransfer()
+	require.Error(t, ec.Error())
+
+	resp = respWithBody("body")
+	existingError := fmt.Errorf("existing error")
+	ec = &errContainer{}
+	rw = newResponseWrapper(resp, ec.Error, ec.Set)
+	ec.Set(existingError)
+	rw.ExpectCompressedTransfer()
+	require.Equal(t, existingError, ec.Error())
+}
+
+func TestExpectAPIVersion(t *testing.T) {
+	testCases := []struct {
+		header string
+		value  string
+		passes bool
+	}{
+		{"Api-Version", "v2", true},
+		{"X-Api-Version", "v2", true},
+		{"Custom-Version", "v2", true},
+		{"Content-Type", "application/vnd.foo.v2+json", true},
+		{"Content-Type", "application/json; version=v2", true},
+		{"Content-Type", "application/vnd.foo.v1+json", false},
+		{"Api-Version", "v1", false},
+		{"Other", "v2", false},
+	}
+	for _, testCase := range testCases {
+		resp := respWithBody("")
+		resp.Header.Set(testCase.header, testCase.value)
+		ec := &errContainer{}
+		rw := newResponseWrapperWithSettings(resp, neverErr, ec.Set, responseSettings{versionHeader: "Custom-Version"})
+		rw2 := rw.ExpectAPIVersion("v2")
+		require.Equal(t, rw, rw2)
+		if testCase.passes {
+			require.NoError(t, ec.Error(), "%v: %v", testCase.header, testCase.value)
+		} else {
+			require.Error(t, ec.Error(), "%v: %v", testCase.header, testCase.value)
+		}
+	}
+
+	resp := respWithBody("")
+	existingError := fmt.Errorf("existing error")
+	ec := &errContainer{}
+	rw := newResponseWrapper(resp, ec.Error, ec.Set)
+	ec.Set(existingError)
+	rw.ExpectAPIVersion("v2")
+	require.Equal(t, existingError, ec.Error())
+}
+
+func TestExpectCharset(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		passes      bool
+	}{
+		{"text/html; charset=utf-8", true},
+		{"text/html; charset=UTF-8", true},
+		{`application/json; charset="utf-8"`, true},
+		{"text/html; charset=iso-8859-1", false},
+		{"text/html", false},
+		{"", false},
+		{"text/html; charset", false},
+	}
+	for _, testCase := range testCases {
+		resp := respWithBody("")
+		if testCase.contentType != "" {
+			resp.Header.Set("Content-Type", testCase.contentType)
+		}
+		ec := &errContainer{}
+		rw := newResponseWrapper(resp, neverErr, ec.Set)
+		rw2 := rw.ExpectCharset("utf-8")
+		require.Equal(t, rw, rw2)
+		if testCase.passes {
+			require.NoError(t, ec.Error(), "content type = %q", testCase.contentType)
+		} else {
+			require.Error(t, ec.Error(), "content type = %q", testCase.contentType)
+		}
+	}
+
+	resp := respWithBody("")
+	existingError := fmt.Errorf("existing error")
+	ec := &errContainer{}
+	rw := newResponseWrapper(resp, ec.Error, ec.Set)
+	ec.Set(existingError)
+	rw.ExpectCharset("utf-8")
+	require.Equal(t, existingError, ec.Error())
+}
+
+func TestExpectContentLanguage(t *testing.T) {
+	testCases := []struct {
+		values []string
+		passes bool
+	}{
+		{[]string{"de-DE"}, true},
+		{[]string{"de-de"}, true},
+		{[]string{"en, de-DE"}, true},
+		{[]string{"en", "de-DE"}, true},
+		{[]string{"de"}, false},
+		{nil, false},
+	}
+	for _, testCase := range testCases {
+		resp := respWithBody("")
+		for _, value := range testCase.values {
+			resp.Header.Add("Content-Language", value)
+		}
+		ec := &errContainer{}
+		rw := newResponseWrapper(resp, neverErr, ec.Set)
+		rw2 := rw.ExpectContentLanguage("de-DE")
+		require.Equal(t, rw, rw2)
+		if testCase.passes {
+			require.NoError(t, ec.Error(), "values = %q", testCase.values)
+		} else {
+			require.Error(t, ec.Error(), "values = %q", testCase.values)
+		}
+	}
+
+	resp := respWithBody("")
+	existingError := fmt.Errorf("existing error")
+	ec := &errContainer{}
+	rw := newResponseWrapper(resp, ec.Error, ec.Set)
+	ec.Set(existingError)
+	rw.ExpectContentLanguage("de")
+	require.Equal(t, existingError, ec.Error())
+}
+
+func TestExpectDurationUnder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	rw := c.Get("/slow").ExpectDurationUnder(time.Minute)
+	require.NoError(t, c.Error())
+	require.True(t, rw.Duration() >= 50*time.Millisecond)
+	require.Equal(t, rw.Timings().Total, rw.Duration())
+
+	c.Get("/slow").ExpectDurationUnder(10 * time.Millisecond)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected the request to take under 10ms but it took")
+}
+
 func TestNopResponseWrapper(t *testing.T) {
 	var n nopResponseWrapper
+	require.Equal(t, 0, n.Attempts())
 	require.Equal(t, "", n.Body())
+	require.NoError(t, n.Error())
+	require.Equal(t, n, n.ExpectAPIVersion(""))
 	require.Equal(t, n, n.ExpectBodyContains(""))
 	require.Equal(t, n, n.ExpectBodyEquals(""))
+	require.Equal(t, n, n.ExpectBodyJSONEquals(nil))
+	require.Equal(t, n, n.ExpectBodyMatches(""))
+	require.Equal(t, n, n.ExpectBodyMatchesJSONSchema(""))
+	require.Equal(t, n, n.ExpectBodyMatchesJSONSchemaFromFile(""))
 	require.Equal(t, n, n.ExpectBodyNotContains(""))
 	require.Equal(t, n, n.ExpectBodyNotEquals(""))
+	require.Equal(t, n, n.ExpectBodyNotMatches(""))
 	require.Equal(t, n, n.ExpectBodyPasses(func(string) bool { return true }))
+	require.Equal(t, n, n.ExpectCharset(""))
+	require.Equal(t, n, n.ExpectCompressedTransfer())
+	require.Equal(t, n, n.ExpectContentLanguage(""))
+	require.Equal(t, n, n.ExpectDurationUnder(0))
+	require.Equal(t, n, n.ExpectGraphQLNoErrors())
 	require.Equal(t, n, n.ExpectHeaderContains("", ""))
 	require.Equal(t, n, n.ExpectHeaderEquals("", ""))
+	require.Equal(t, n, n.ExpectHeaderMatches("", ""))
 	require.Equal(t, n, n.ExpectHeaderNotContains("", ""))
 	require.Equal(t, n, n.ExpectHeaderNotEquals("", ""))
 	require.Equal(t, n, n.ExpectHeaderNotPresent(""))
 	require.Equal(t, n, n.ExpectHeaderPresent(""))
+	require.Equal(t, n, n.ExpectHeaderValueCount("", 0))
 	require.Equal(t, n, n.ExpectPasses(func(resp *http.Response, body string) bool { return true }))
+	require.Equal(t, n, n.ExpectPreconditionFailed())
+	require.Equal(t, n, n.ExpectProxied())
+	require.Equal(t, n, n.ExpectRedirectPreservedBody())
+	require.Equal(t, n, n.ExpectCookieEquals("", ""))
+	require.Equal(t, n, n.ExpectCookieHasFlag("", CookieSecure))
+	require.Equal(t, n, n.ExpectCookiePresent(""))
+	require.Equal(t, n, n.ExpectJSONPath("", nil))
+	require.Equal(t, n, n.ExpectJSONPathPresent(""))
+	require.Equal(t, n, n.ExpectMatchesSpec())
+	require.Equal(t, n, n.ExpectNoContent())
+	require.Equal(t, n, n.ExpectNoCredentialLeakOnRedirect())
+	require.Equal(t, n, n.ExpectNotProxied())
+	require.Equal(t, n, n.ExpectRequestQuerySent("", ""))
+	require.Equal(t, n, n.ExpectServerTiming("", nil))
 	require.Equal(t, n, n.ExpectStatus(0))
+	require.Equal(t, n, n.ExpectStatus2xx())
+	require.Equal(t, n, n.ExpectStatus3xx())
+	require.Equal(t, n, n.ExpectStatus4xx())
+	require.Equal(t, n, n.ExpectStatus5xx())
+	require.Equal(t, n, n.ExpectStatusIn(200))
+	require.Equal(t, n, n.ExpectStreamedBytes(0))
+	require.Equal(t, n, n.ExpectStreamedSHA256(""))
+	require.Equal(t, n, n.ParseAndValidateBody(nil))
 	require.Equal(t, n, n.ParseBody(""))
+	require.Equal(t, n, n.ParseGraphQLData(nil))
+	require.Equal(t, n, n.ReplayRequest())
+	require.Nil(t, n.Response())
+	require.Nil(t, n.RetryErrors())
+	require.Equal(t, "", n.SentBody())
+	require.Nil(t, n.SentURL())
+	require.Equal(t, "", n.RemoteAddr())
+	require.Equal(t, "", n.ServedBy())
+	require.Nil(t, n.ServerTimings())
+	require.Equal(t, Timings{}, n.Timings())
+	require.Error(t, n.ExportJSON(&bytes.Buffer{}))
+	parts, err := n.ParseMultipartBody()
+	require.Nil(t, parts)
+	require.Error(t, err)
+	require.Equal(t, WireCapture{}, n.Wire())
+	require.Equal(t, BodySizes{}, n.Sizes())
+	require.Nil(t, n.Tags())
+	require.Equal(t, time.Duration(0), n.Duration())
+	require.False(t, n.DryRun())
+	require.False(t, n.HasTag(""))
 }
@@ -0,0 +1,176 @@
+package crest
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BackoffStrategy returns how long to wait before the given retry, which is
+// 1 for the second attempt, 2 for the third and so on.
+type BackoffStrategy func(retry int) time.Duration
+
+// ConstantBackoff waits d before every retry.
+func ConstantBackoff(d time.Duration) BackoffStrategy {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff waits base before the first retry, doubling the wait
+// for every retry after that up to max.
+func ExponentialBackoff(base, max time.Duration) BackoffStrategy {
+	return func(retry int) time.Duration {
+		d := base
+		for i := 1; i < retry && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// JitteredBackoff waits a random duration between zero and what
+// ExponentialBackoff would wait, so clients retrying at once spread out.
+func JitteredBackoff(base, max time.Duration) BackoffStrategy {
+	exponential := ExponentialBackoff(base, max)
+	return func(retry int) time.Duration {
+		d := exponential(retry)
+		if d <= 0 {
+			return 0
+		}
+		return time.Duration(rand.Int63n(int64(d) + 1))
+	}
+}
+
+// DefaultRetryStatuses are the status codes retried by a client set up with
+// WithRetry, unless changed with WithRetryStatuses.
+var DefaultRetryStatuses = []int{502, 503, 504}
+
+type retryPolicy struct {
+	attempts int
+	backoff  BackoffStrategy
+	statuses []int
+}
+
+func (p *retryPolicy) retriesStatus(code int) bool {
+	for _, status := range p.statuses {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
+// wait sleeps before the given retry, returning early with an error if ctx
+// is done.
+func (p *retryPolicy) wait(ctx context.Context, retry int) error {
+	if p.backoff == nil {
+		return ctx.Err()
+	}
+	d := p.backoff(retry)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithRetry makes the client send each request up to attempts times, waiting
+// as backoff says in between, while it fails with a network error or a
+// status in DefaultRetryStatuses. The response of the last attempt is the one
+// returned. The client's timeout covers all attempts together.
+func (c *client) WithRetry(attempts int, backoff BackoffStrategy) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if attempts < 1 {
+		c.errSetter(errors.Errorf("invalid number of attempts %v", attempts))
+		return c
+	}
+	statuses := DefaultRetryStatuses
+	if c.retry != nil {
+		statuses = c.retry.statuses
+	}
+	c.retry = &retryPolicy{
+		attempts: attempts,
+		backoff:  backoff,
+		statuses: statuses,
+	}
+	return c
+}
+
+// WithRetryStatuses sets the status codes that WithRetry retries.
+func (c *client) WithRetryStatuses(codes ...int) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	retry := retryPolicy{attempts: 1}
+	if c.retry != nil {
+		retry = *c.retry
+	}
+	retry.statuses = append([]int(nil), codes...)
+	c.retry = &retry
+	return c
+}
+
+// isIdempotent reports whether req can safely be sent twice: its method is
+// idempotent, or it carries an idempotency key.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// isConnReset reports whether err is the connection being closed under the
+// request, as happens when a server times out a kept-alive connection just as
+// it is reused.
+func isConnReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryAfter returns how long the Retry-After header in h asks to wait,
+// given in seconds or as a date.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	value := h.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// joinErrors lists errs on a single line.
+func joinErrors(errs []error) string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return "[" + strings.Join(msgs, "; ") + "]"
+}
@@ -0,0 +1,170 @@
+package crest
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffStrategies(t *testing.T) {
+	constant := ConstantBackoff(time.Second)
+	require.Equal(t, time.Second, constant(1))
+	require.Equal(t, time.Second, constant(5))
+
+	exponential := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	require.Equal(t, 10*time.Millisecond, exponential(1))
+	require.Equal(t, 20*time.Millisecond, exponential(2))
+	require.Equal(t, 40*time.Millisecond, exponential(3))
+	require.Equal(t, 50*time.Millisecond, exponential(4))
+	require.Equal(t, 50*time.Millisecond, exponential(100))
+
+	jittered := JitteredBackoff(10*time.Millisecond, 50*time.Millisecond)
+	for retry := 1; retry < 10; retry++ {
+		d := jittered(retry)
+		require.True(t, d >= 0 && d <= exponential(retry), "%v", d)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	var lock sync.Mutex
+	calls := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		calls[r.URL.Path]++
+		n := calls[r.URL.Path]
+		lock.Unlock()
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/flaky" && n < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		case r.URL.Path == "/teapot" && n < 2:
+			w.WriteHeader(http.StatusTeapot)
+		}
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithRetry(3, ConstantBackoff(time.Millisecond))
+	rw := c.PostString("/flaky", "body").ExpectStatus(http.StatusOK).ExpectBodyEquals("body")
+	require.NoError(t, c.Error())
+	require.Equal(t, 3, rw.Attempts())
+	require.Len(t, rw.RetryErrors(), 2)
+	require.EqualError(t, rw.RetryErrors()[0], "attempt 1: got status 503 Service Unavailable")
+	require.Equal(t, "body", rw.SentBody())
+
+	rw = c.Get("/down")
+	require.Equal(t, 3, rw.Attempts())
+	rw.ExpectStatus(http.StatusOK)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "on attempt 3, after [attempt 1: got status 502 Bad Gateway; attempt 2: got status 502 Bad Gateway]")
+
+	c = NewClient(srv.URL).WithRetryStatuses(http.StatusTeapot).WithRetry(2, nil)
+	rw = c.Get("/teapot").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+	require.Equal(t, 2, rw.Attempts())
+	c.Get("/down").ExpectStatus(http.StatusBadGateway)
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL).WithRetry(0, nil)
+	require.EqualError(t, c.Error(), "invalid number of attempts 0")
+}
+
+func TestClientRetryNetworkErrors(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	c := NewClient("http://"+addr).WithRetry(3, ExponentialBackoff(time.Millisecond, 5*time.Millisecond))
+	c.Get("/")
+	require.Error(t, c.Error())
+	require.Equal(t, 3, strings.Count(c.Error().Error(), "connection refused"))
+	require.Contains(t, c.Error().Error(), "attempt 3, after [attempt 1: ")
+
+	c = NewClient("http://"+addr).WithRetry(5, ConstantBackoff(time.Hour)).WithTimeout(20 * time.Millisecond)
+	start := time.Now()
+	c.Get("/")
+	require.True(t, time.Since(start) < time.Second)
+	require.True(t, errors.Is(c.Error(), context.DeadlineExceeded), "%v", c.Error())
+}
+
+func TestRetryOnReusedConnectionReset(t *testing.T) {
+	var lock sync.Mutex
+	perConn := make(map[string]int)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		ioutil.ReadAll(r.Body)
+		lock.Lock()
+		perConn[r.RemoteAddr]++
+		n := perConn[r.RemoteAddr]
+		lock.Unlock()
+		if n == 2 {
+			// Drop the kept-alive connection under the second request.
+			conn, _, err := w.(http.Hijacker).Hijack()
+			require.NoError(t, err)
+			conn.Close()
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	transport := &idleCountingTransport{Transport: &http.Transport{}}
+	c := NewCustomClient(srv.URL, &http.Client{Transport: transport})
+	c.Put("/a", 1).ExpectBodyEquals("ok")
+	rw := c.Put("/a", 2).ExpectBodyEquals("ok")
+	require.NoError(t, c.Error())
+	require.Equal(t, "2", rw.SentBody())
+	require.Equal(t, 1, rw.Attempts())
+	require.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	// The transport may be shared, so its other connections are kept.
+	require.Equal(t, int32(0), atomic.LoadInt32(&transport.closed))
+
+	// A POST might have been acted on, so it is not sent again.
+	c = NewCustomClient(srv.URL, &http.Client{Transport: &http.Transport{}})
+	c.Post("/a", 1).ExpectBodyEquals("ok")
+	c.Post("/a", 2)
+	require.Error(t, c.Error())
+	require.Equal(t, int32(5), atomic.LoadInt32(&requests))
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Duration{
+		"3":                             3 * time.Second,
+		"Mon, 01 Jan 2024 00:00:10 GMT": 10 * time.Second,
+		"Sun, 31 Dec 2023 23:00:00 GMT": 0,
+	} {
+		d, ok := retryAfter(http.Header{"Retry-After": {value}}, now)
+		require.True(t, ok, value)
+		require.Equal(t, want, d, value)
+	}
+	_, ok := retryAfter(http.Header{}, now)
+	require.False(t, ok)
+	_, ok = retryAfter(http.Header{"Retry-After": {"soon"}}, now)
+	require.False(t, ok)
+}
+
+// idleCountingTransport counts the calls to CloseIdleConnections.
+type idleCountingTransport struct {
+	*http.Transport
+	closed int32
+}
+
+func (t *idleCountingTransport) CloseIdleConnections() {
+	atomic.AddInt32(&t.closed, 1)
+	t.Transport.CloseIdleConnections()
+}
@@ -0,0 +1,145 @@
+package crest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Priority orders requests waiting for a slot when the number of requests in
+// flight is limited with WithConcurrencyLimit.
+type Priority int
+
+const (
+	// PriorityBackground is for polling and other traffic nothing waits on.
+	// Background requests never take the last free slot, unless the limit
+	// is one and there is no other slot to take.
+	PriorityBackground Priority = iota - 1
+	// PriorityNormal is the priority of clients that have not set one.
+	PriorityNormal
+	// PriorityHigh is for the requests a test is asserting on.
+	PriorityHigh
+)
+
+// scheduler hands out a limited number of slots for requests in flight,
+// highest priority first. It is shared by a client and its clones.
+type scheduler struct {
+	lock     sync.Mutex
+	limit    int
+	inFlight int
+	waiting  [3][]chan struct{}
+}
+
+func (s *scheduler) lane(p Priority) int {
+	switch {
+	case p > PriorityNormal:
+		return 2
+	case p < PriorityNormal:
+		return 0
+	}
+	return 1
+}
+
+// canRun reports whether a request in lane may take a slot now. The caller
+// must hold the lock.
+func (s *scheduler) canRun(lane int) bool {
+	if s.limit <= 0 {
+		return true
+	}
+	if lane == 0 && s.limit > 1 {
+		// Keep a slot for the requests a test is waiting on.
+		return s.inFlight < s.limit-1
+	}
+	return s.inFlight < s.limit
+}
+
+// acquire waits for a slot for a request of priority p. Requests of the same
+// priority get slots in the order they asked for them.
+func (s *scheduler) acquire(ctx context.Context, p Priority) error {
+	lane := s.lane(p)
+	s.lock.Lock()
+	queued := false
+	for l := lane; l < len(s.waiting); l++ {
+		queued = queued || len(s.waiting[l]) > 0
+	}
+	if !queued && s.canRun(lane) {
+		s.inFlight++
+		s.lock.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	s.waiting[lane] = append(s.waiting[lane], ready)
+	s.lock.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		for i, ch := range s.waiting[lane] {
+			if ch == ready {
+				s.waiting[lane] = append(s.waiting[lane][:i], s.waiting[lane][i+1:]...)
+				return ctx.Err()
+			}
+		}
+		// The slot was handed over while giving up on it.
+		s.inFlight--
+		s.dispatch()
+		return ctx.Err()
+	}
+}
+
+func (s *scheduler) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.inFlight--
+	s.dispatch()
+}
+
+// dispatch hands free slots to waiting requests, highest priority first. The
+// caller must hold the lock.
+func (s *scheduler) dispatch() {
+	for lane := len(s.waiting) - 1; lane >= 0; lane-- {
+		for len(s.waiting[lane]) > 0 && s.canRun(lane) {
+			close(s.waiting[lane][0])
+			s.waiting[lane] = s.waiting[lane][1:]
+			s.inFlight++
+		}
+		if len(s.waiting[lane]) > 0 {
+			// Lower priorities wait until this lane has drained.
+			return
+		}
+	}
+}
+
+// WithConcurrencyLimit limits the requests in flight at once from the client
+// and its clones to n, with waiting requests served by priority. Zero
+// removes the limit.
+func (c *client) WithConcurrencyLimit(n int) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if n < 0 {
+		c.errSetter(errors.Errorf("invalid concurrency limit %v", n))
+		return c
+	}
+	c.scheduler.lock.Lock()
+	defer c.scheduler.lock.Unlock()
+
+	c.scheduler.limit = n
+	c.scheduler.dispatch()
+	return c
+}
+
+// WithPriority sets the priority of the client's requests when the number of
+// requests in flight is limited.
+func (c *client) WithPriority(p Priority) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.priority = p
+	return c
+}
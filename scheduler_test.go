@@ -0,0 +1,103 @@
+package crest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerPriorities(t *testing.T) {
+	s := &scheduler{limit: 1}
+	ctx := context.Background()
+	require.NoError(t, s.acquire(ctx, PriorityNormal))
+
+	var lock sync.Mutex
+	var order []Priority
+	var wg sync.WaitGroup
+	queued := 0
+	for _, p := range []Priority{PriorityBackground, PriorityNormal, PriorityHigh, PriorityNormal} {
+		p := p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, s.acquire(ctx, p))
+			lock.Lock()
+			order = append(order, p)
+			lock.Unlock()
+			s.release()
+		}()
+		// Let the request queue up before the next one.
+		queued++
+		for {
+			s.lock.Lock()
+			n := len(s.waiting[0]) + len(s.waiting[1]) + len(s.waiting[2])
+			s.lock.Unlock()
+			if n == queued {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	s.release()
+	wg.Wait()
+	require.Equal(t, []Priority{PriorityHigh, PriorityNormal, PriorityNormal, PriorityBackground}, order)
+}
+
+func TestSchedulerKeepsSlotFromBackground(t *testing.T) {
+	s := &scheduler{limit: 2}
+	ctx := context.Background()
+	require.NoError(t, s.acquire(ctx, PriorityBackground))
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, s.acquire(timeout, PriorityBackground))
+	require.NoError(t, s.acquire(ctx, PriorityHigh))
+	s.release()
+	s.release()
+	require.Equal(t, 0, s.inFlight)
+	require.Empty(t, s.waiting[0])
+}
+
+func TestClientConcurrencyLimit(t *testing.T) {
+	var lock sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		lock.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		lock.Lock()
+		inFlight--
+		lock.Unlock()
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithConcurrencyLimit(2)
+	poller := c.Clone().WithPriority(PriorityBackground)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.Get("/").ExpectStatus(http.StatusOK)
+		}()
+		go func() {
+			defer wg.Done()
+			poller.Get("/poll").ExpectStatus(http.StatusOK)
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, c.Error())
+	require.True(t, maxInFlight <= 2, "%v", maxInFlight)
+
+	c = NewClient(srv.URL).WithConcurrencyLimit(-1)
+	require.EqualError(t, c.Error(), "invalid concurrency limit -1")
+}
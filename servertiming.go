@@ -0,0 +1,141 @@
+package crest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ServerTiming is a metric a server reported in a Server-Timing header.
+type ServerTiming struct {
+	Name string
+	// Duration is the "dur" of the metric, zero if it has none.
+	Duration    time.Duration
+	HasDuration bool
+	Description string
+}
+
+// DurationCondition is what ExpectServerTiming checks a duration with. It
+// returns an error saying what was expected if d does not pass.
+type DurationCondition func(d time.Duration) error
+
+// Under passes durations shorter than max.
+func Under(max time.Duration) DurationCondition {
+	return func(d time.Duration) error {
+		if d >= max {
+			return errors.Errorf("to be under %v but it was %v", max, d)
+		}
+		return nil
+	}
+}
+
+// AtLeast passes durations of min or longer.
+func AtLeast(min time.Duration) DurationCondition {
+	return func(d time.Duration) error {
+		if d < min {
+			return errors.Errorf("to be at least %v but it was %v", min, d)
+		}
+		return nil
+	}
+}
+
+// ServerTimings returns the metrics in the Server-Timing headers of the
+// response, in order. Malformed parameters are skipped.
+func (r *responseWrapper) ServerTimings() []ServerTiming {
+	var timings []ServerTiming
+	for _, header := range r.resp.Header.Values("Server-Timing") {
+		for _, metric := range splitQuoted(header, ',') {
+			if timing, ok := parseServerTiming(metric); ok {
+				timings = append(timings, timing)
+			}
+		}
+	}
+	return timings
+}
+
+// ExpectServerTiming fails unless the response has a Server-Timing metric
+// called name whose duration passes cond, e.g.
+//
+//	rw.ExpectServerTiming("db", Under(50*time.Millisecond))
+//
+// If there are several, the first one counts.
+func (r *responseWrapper) ExpectServerTiming(name string, cond DurationCondition) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	for _, timing := range r.ServerTimings() {
+		if timing.Name != name {
+			continue
+		}
+		if !timing.HasDuration {
+			r.setError(fmt.Errorf("expected server timing %q to have a duration but it has none", name))
+		} else if err := cond(timing.Duration); err != nil {
+			r.setError(fmt.Errorf("expected server timing %q %v", name, err))
+		}
+		return r
+	}
+	r.setError(fmt.Errorf("expected a server timing %q but there is none", name))
+	return r
+}
+
+// parseServerTiming parses a metric of a Server-Timing header, e.g.
+// `db;dur=53.2;desc="Database queries"`.
+func parseServerTiming(metric string) (ServerTiming, bool) {
+	parts := splitQuoted(metric, ';')
+	timing := ServerTiming{Name: strings.TrimSpace(parts[0])}
+	if timing.Name == "" {
+		return timing, false
+	}
+	for _, param := range parts[1:] {
+		i := strings.IndexByte(param, '=')
+		if i < 0 {
+			continue
+		}
+		key, value := strings.ToLower(strings.TrimSpace(param[:i])), strings.TrimSpace(param[i+1:])
+		if strings.HasPrefix(value, `"`) {
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+		}
+		switch key {
+		case "dur":
+			if timing.HasDuration {
+				continue
+			}
+			ms, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			timing.Duration = time.Duration(ms * float64(time.Millisecond))
+			timing.HasDuration = true
+		case "desc":
+			if timing.Description == "" {
+				timing.Description = value
+			}
+		}
+	}
+	return timing, true
+}
+
+// splitQuoted splits s at each sep outside double quotes.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, escaped, start := false, false, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,47 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTimings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Server-Timing", `db;dur=53.5;desc="Queries; reads, writes", cache;desc=hit`)
+		w.Header().Add("Server-Timing", `app;dur=12, ;dur=1, total;dur=x;dur=70`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	rw := c.Get("/")
+	require.Equal(t, []ServerTiming{
+		{Name: "db", Duration: 53500 * time.Microsecond, HasDuration: true, Description: "Queries; reads, writes"},
+		{Name: "cache", Description: "hit"},
+		{Name: "app", Duration: 12 * time.Millisecond, HasDuration: true},
+		{Name: "total", Duration: 70 * time.Millisecond, HasDuration: true},
+	}, rw.ServerTimings())
+
+	rw.ExpectServerTiming("db", Under(60*time.Millisecond)).
+		ExpectServerTiming("app", AtLeast(12*time.Millisecond))
+	require.NoError(t, c.Error())
+
+	for _, tc := range []struct {
+		name string
+		cond DurationCondition
+		err  string
+	}{
+		{"db", Under(50 * time.Millisecond), `expected server timing "db" to be under 50ms but it was 53.5ms`},
+		{"app", AtLeast(time.Second), `expected server timing "app" to be at least 1s but it was 12ms`},
+		{"cache", Under(time.Second), `expected server timing "cache" to have a duration but it has none`},
+		{"render", Under(time.Second), `expected a server timing "render" but there is none`},
+	} {
+		c := NewClient(srv.URL)
+		c.Get("/").ExpectServerTiming(tc.name, tc.cond)
+		require.Error(t, c.Error())
+		require.Contains(t, c.Error().Error(), tc.err)
+	}
+}
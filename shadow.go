@@ -0,0 +1,73 @@
+package crest
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// shadow mirrors the requests of a client to a secondary client, e.g. a
+// migration target, without affecting the primary client's results.
+type shadow struct {
+	secondary *client
+	compare   bool
+
+	wg   sync.WaitGroup
+	lock sync.Mutex
+	errs []error
+}
+
+func (s *shadow) addError(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.errs = append(s.errs, err)
+}
+
+func (s *shadow) wait() []error {
+	s.wg.Wait()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]error(nil), s.errs...)
+}
+
+// mirror sends a copy of req, which the primary client sent to baseURL
+// with body, to the secondary client and optionally compares the two
+// responses. body is what the primary sent: rewinding req's body would count
+// as a rewind of the primary's.
+func (s *shadow) mirror(baseURL string, req *http.Request, body []byte, primary ResponseWrapper) {
+	path := strings.TrimPrefix(req.URL.String(), baseURL)
+	header := req.Header.Clone()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		secondary := s.secondary.detached()
+		shadowReq := secondary.buildReq(req.Method, path, body)
+		if shadowReq == nil {
+			s.addError(errors.Wrapf(secondary.Error(), "shadowing %v %v", req.Method, path))
+			return
+		}
+		for key, vals := range header {
+			if _, ok := shadowReq.Header[key]; !ok {
+				shadowReq.Header[key] = vals
+			}
+		}
+		rw := secondary.do(shadowReq)
+		if err := secondary.Error(); err != nil {
+			s.addError(errors.Wrapf(err, "shadowing %v %v", req.Method, path))
+			return
+		}
+		if !s.compare {
+			return
+		}
+		if err := Diff(primary, rw); err != nil {
+			s.addError(errors.Wrapf(err, "shadowing %v %v", req.Method, path))
+		}
+	}()
+}
@@ -0,0 +1,51 @@
+package crest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadow(t *testing.T) {
+	handler := func(version string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			bs, _ := ioutil.ReadAll(r.Body)
+			if r.URL.Path == "/version" {
+				w.Write([]byte(version))
+				return
+			}
+			w.Header().Set("X-Test", r.Header.Get("X-Test"))
+			w.Write(bs)
+		}
+	}
+	primarySrv := httptest.NewServer(handler("1"))
+	defer primarySrv.Close()
+	secondarySrv := httptest.NewServer(handler("2"))
+	defer secondarySrv.Close()
+
+	c := NewClient(primarySrv.URL).
+		WithHeader("X-Test", "primary").
+		WithShadow(NewClient(secondarySrv.URL), true)
+	rw := c.PostString("/echo?q=1", "body").ExpectBodyEquals("body")
+	// Mirroring the request does not rewind the primary's body.
+	require.Equal(t, "body", rw.SentBody())
+	require.Equal(t, 0, rw.(*responseWrapper).sentBody.Rewinds())
+	c.Get("/version").ExpectBodyEquals("1")
+	c.Group("/grouped").Get("/version").ExpectBodyEquals("")
+	require.NoError(t, c.Error())
+
+	errs := c.ShadowErrors()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "/version")
+	require.Contains(t, errs[0].Error(), "$: 1 != 2")
+
+	c = NewClient(primarySrv.URL).WithShadow(NewClient("http://127.0.0.1:0"), false)
+	c.Get("/version").ExpectBodyEquals("1")
+	require.NoError(t, c.Error())
+	require.Len(t, c.ShadowErrors(), 1)
+
+	require.Nil(t, NewClient(primarySrv.URL).ShadowErrors())
+}
@@ -0,0 +1,137 @@
+package crest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HMACScheme describes how a webhook provider signs its callbacks with
+// HMAC-SHA256, so crest can send callbacks as that provider would.
+type HMACScheme struct {
+	// Header is the header the signature is sent in.
+	Header string
+	// Prefix is put before the encoded signature, e.g. "sha256=".
+	Prefix string
+	// Base64 encodes the signature as standard base64 rather than hex.
+	Base64 bool
+	// TimestampHeader, if set, is sent with the Unix time the callback was
+	// signed at.
+	TimestampHeader string
+	// Payload returns what is signed, given the Unix time of signing and the
+	// body. If nil, the body alone is signed.
+	Payload func(timestamp string, body []byte) []byte
+}
+
+var (
+	// GitHubSignature is the scheme of X-Hub-Signature-256.
+	GitHubSignature = HMACScheme{
+		Header: "X-Hub-Signature-256",
+		Prefix: "sha256=",
+	}
+	// ShopifySignature is the scheme of X-Shopify-Hmac-Sha256.
+	ShopifySignature = HMACScheme{
+		Header: "X-Shopify-Hmac-Sha256",
+		Base64: true,
+	}
+	// SlackSignature is the scheme of X-Slack-Signature, which signs the
+	// timestamp along with the body.
+	SlackSignature = HMACScheme{
+		Header:          "X-Slack-Signature",
+		Prefix:          "v0=",
+		TimestampHeader: "X-Slack-Request-Timestamp",
+		Payload: func(timestamp string, body []byte) []byte {
+			return append([]byte("v0:"+timestamp+":"), body...)
+		},
+	}
+)
+
+// signer signs requests just before they are sent, after any request hooks.
+type signer func(req *http.Request, now time.Time, body []byte) error
+
+// SignWithHMAC signs every request with secret as scheme says, so the client
+// can stand in for a webhook provider. Signing uses the client's clock, so
+// it can be combined with WithClockSkew to send stale callbacks.
+func (c *client) SignWithHMAC(secret string, scheme HMACScheme) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if scheme.Header == "" {
+		c.errSetter(errors.New("the HMAC scheme has no header"))
+		return c
+	}
+	c.signer = func(req *http.Request, now time.Time, body []byte) error {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		payload := body
+		if scheme.Payload != nil {
+			payload = scheme.Payload(timestamp, body)
+		}
+		sum := hmacSHA256(secret, payload)
+		encoded := hex.EncodeToString(sum)
+		if scheme.Base64 {
+			encoded = base64.StdEncoding.EncodeToString(sum)
+		}
+		req.Header.Set(scheme.Header, scheme.Prefix+encoded)
+		if scheme.TimestampHeader != "" {
+			req.Header.Set(scheme.TimestampHeader, timestamp)
+		}
+		return nil
+	}
+	return c
+}
+
+// SignAsStripe signs every request with secret as Stripe signs its webhook
+// events, in a Stripe-Signature header.
+func (c *client) SignAsStripe(secret string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.signer = func(req *http.Request, now time.Time, body []byte) error {
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		sum := hmacSHA256(secret, append([]byte(timestamp+"."), body...))
+		req.Header.Set("Stripe-Signature", "t="+timestamp+",v1="+hex.EncodeToString(sum))
+		return nil
+	}
+	return c
+}
+
+// NoSignature stops signing requests.
+func (c *client) NoSignature() Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.signer = nil
+	return c
+}
+
+func (c *client) sign(req *http.Request) error {
+	if c.signer == nil {
+		return nil
+	}
+	var body []byte
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		body, err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return c.signer(req, c.now(), body)
+}
+
+func hmacSHA256(secret string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,88 @@
+package crest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAsStripe(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var timestamp, sig string
+		for _, field := range strings.Split(r.Header.Get("Stripe-Signature"), ",") {
+			kv := strings.SplitN(field, "=", 2)
+			switch kv[0] {
+			case "t":
+				timestamp = kv[1]
+			case "v1":
+				sig = kv[1]
+			}
+		}
+		mac := hmac.New(sha256.New, []byte("whsec_test"))
+		mac.Write([]byte(timestamp + "." + string(body)))
+		if sig != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		unix, _ := strconv.ParseInt(timestamp, 10, 64)
+		if time.Since(time.Unix(unix, 0)) > 5*time.Minute {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).SignAsStripe("whsec_test")
+	c.Post("/webhook", map[string]string{"type": "charge.succeeded"}).ExpectStatus(http.StatusOK)
+	c.PostNoBody("/webhook").ExpectStatus(http.StatusOK)
+	c.Clone().WithClockSkew(-time.Hour).Post("/webhook", nil).ExpectStatus(http.StatusForbidden)
+	NewClient(srv.URL).SignAsStripe("wrong").Post("/webhook", nil).ExpectStatus(http.StatusBadRequest)
+	c.Clone().NoSignature().Post("/webhook", nil).ExpectStatus(http.StatusBadRequest)
+	require.NoError(t, c.Error())
+}
+
+func TestSignWithHMAC(t *testing.T) {
+	var last *http.Request
+	var lastBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+		lastBody, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	sum := func(payload string) []byte {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(payload))
+		return mac.Sum(nil)
+	}
+
+	c := NewClient(srv.URL).SignWithHMAC("secret", GitHubSignature)
+	c.PostString("/hook", "payload")
+	require.NoError(t, c.Error())
+	require.Equal(t, "sha256="+hex.EncodeToString(sum("payload")), last.Header.Get("X-Hub-Signature-256"))
+
+	c.SignWithHMAC("secret", ShopifySignature).PostString("/hook", "payload")
+	require.NoError(t, c.Error())
+	require.Equal(t, base64.StdEncoding.EncodeToString(sum("payload")), last.Header.Get("X-Shopify-Hmac-Sha256"))
+
+	c.SignWithHMAC("secret", SlackSignature).PostString("/hook", "payload")
+	require.NoError(t, c.Error())
+	timestamp := last.Header.Get("X-Slack-Request-Timestamp")
+	require.NotEmpty(t, timestamp)
+	require.Equal(t, "v0="+hex.EncodeToString(sum("v0:"+timestamp+":payload")), last.Header.Get("X-Slack-Signature"))
+	require.Equal(t, "payload", string(lastBody))
+
+	c.SignWithHMAC("secret", HMACScheme{})
+	require.Error(t, c.Error())
+}
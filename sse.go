@@ -0,0 +1,231 @@
+package crest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultEventTimeout is how long the assertions of an EventStreamWrapper
+// wait for events, unless set with WithTimeout.
+const DefaultEventTimeout = 10 * time.Second
+
+// Event is an event received from a server-sent event stream.
+type Event struct {
+	// Name is the event type, "message" unless the server named it.
+	Name string
+	Data string
+	ID   string
+	// Retry is the reconnection time the server asked for with the event,
+	// if any.
+	Retry time.Duration
+}
+
+// EventStreamWrapper reads a stream of server-sent events. Its assertions
+// consume events as they arrive, waiting at most the timeout for the ones
+// they expect, and share the error of the client that opened the stream.
+type EventStreamWrapper interface {
+	// WithTimeout sets how long each assertion waits for events.
+	WithTimeout(d time.Duration) EventStreamWrapper
+	// ExpectEvent waits for an event named name whose data contains
+	// dataContains, after the events earlier assertions matched. An empty
+	// name matches any event.
+	ExpectEvent(name, dataContains string) EventStreamWrapper
+	// ExpectEventCountAtLeast waits until at least n events have arrived.
+	ExpectEventCountAtLeast(n int) EventStreamWrapper
+	// Events returns the events received so far.
+	Events() []Event
+	// Response returns the response that carries the stream.
+	Response() *http.Response
+	// Close closes the stream. A failing assertion closes it too.
+	Close() error
+	Error() error
+}
+
+type eventStream struct {
+	liveStream
+	// next is the index of the first event ExpectEvent has not looked at.
+	next   int
+	events []Event
+}
+
+// GetSSE opens the server-sent event stream at path. It fails unless the
+// server answers with status 200 and a text/event-stream body. The stream
+// stays open until Close is called or the client is closed, and is not
+// bounded by the timeout set with WithTimeout or WithReadIdleTimeout on the
+// client.
+func (c *client) GetSSE(path string) EventStreamWrapper {
+	s := &eventStream{liveStream: newLiveStream(c, DefaultEventTimeout)}
+	if c.errGetter() != nil {
+		return s
+	}
+
+	body := s.open(path, "event stream", http.Header{
+		"Accept":        {"text/event-stream"},
+		"Cache-Control": {"no-cache"},
+	})
+	if body == nil {
+		return s
+	}
+	go s.read(body)
+	mediaType, _, _ := mime.ParseMediaType(s.resp.Header.Get("Content-Type"))
+	switch {
+	case s.resp.StatusCode != http.StatusOK:
+		s.fail(errors.Errorf("opening event stream %v: got status %v", path, s.resp.Status))
+	case mediaType != "text/event-stream":
+		s.fail(errors.Errorf("opening event stream %v: got Content-Type %q", path, s.resp.Header.Get("Content-Type")))
+	}
+	return s
+}
+
+// read parses events from rd until it ends.
+func (s *eventStream) read(rd io.Reader) {
+	br := bufio.NewReader(rd)
+	event := Event{}
+	var data []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			s.end(err)
+			return
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			if data != nil {
+				event.Data = strings.Join(data, "\n")
+				if event.Name == "" {
+					event.Name = "message"
+				}
+				s.add(event)
+			}
+			event = Event{ID: event.ID}
+			data = nil
+			continue
+		}
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "":
+			// A comment, e.g. to keep the connection alive.
+		case "event":
+			event.Name = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			event.ID = value
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+func (s *eventStream) add(event Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.events = append(s.events, event)
+	s.notify()
+}
+
+func (s *eventStream) end(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.finish(err)
+}
+
+// wait waits until ready, called with the lock held, returns true, the
+// stream ends or the timeout passes, and returns why it stopped waiting: nil
+// if ready returned true.
+func (s *eventStream) wait(ready func() bool) error {
+	return s.liveStream.wait(ready, func() error {
+		if s.err != nil {
+			return errors.Wrap(s.err, "the stream failed")
+		}
+		return errors.New("the stream ended")
+	})
+}
+
+// fail closes the stream, so that a failing test does not leave it open,
+// and sets the client's error.
+func (s *eventStream) fail(err error) {
+	s.Close()
+	s.client.errSetter(err)
+}
+
+func (s *eventStream) failed() bool {
+	return s.client.errGetter() != nil || s.resp == nil
+}
+
+func (s *eventStream) WithTimeout(d time.Duration) EventStreamWrapper {
+	s.timeout = d
+	return s
+}
+
+func (s *eventStream) ExpectEvent(name, dataContains string) EventStreamWrapper {
+	if s.failed() {
+		return s
+	}
+	err := s.wait(func() bool {
+		for ; s.next < len(s.events); s.next++ {
+			event := s.events[s.next]
+			if (name == "" || event.Name == name) && strings.Contains(event.Data, dataContains) {
+				s.next++
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		s.fail(fmt.Errorf("expected an event %q with data containing %q, but %v", name, dataContains, err))
+	}
+	return s
+}
+
+func (s *eventStream) ExpectEventCountAtLeast(n int) EventStreamWrapper {
+	if s.failed() {
+		return s
+	}
+	count := 0
+	err := s.wait(func() bool {
+		count = len(s.events)
+		return count >= n
+	})
+	if err != nil {
+		s.fail(fmt.Errorf("expected at least %d events, but got %d and %v", n, count, err))
+	}
+	return s
+}
+
+func (s *eventStream) Events() []Event {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]Event(nil), s.events...)
+}
+
+func (s *eventStream) Response() *http.Response {
+	return s.resp
+}
+
+func (s *eventStream) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+func (s *eventStream) Error() error {
+	return s.client.Error()
+}
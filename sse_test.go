@@ -0,0 +1,84 @@
+package crest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSSE(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "/json":
+			w.Write([]byte("{}"))
+			return
+		}
+		require.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		flusher := w.(http.Flusher)
+		fmt.Fprint(w, ": connected\n\nretry: 500\ndata: hello\n\n")
+		flusher.Flush()
+		for i := 1; i <= 3; i++ {
+			time.Sleep(5 * time.Millisecond)
+			fmt.Fprintf(w, "event: tick\r\nid: %d\r\ndata: {\"n\":%d,\r\ndata: \"last\":%v}\r\n\r\n", i, i, i == 3)
+			flusher.Flush()
+		}
+		if r.URL.Path == "/ends" {
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithTimeout(time.Millisecond)
+	stream := c.GetSSE("/events")
+	stream.ExpectEvent("message", "hello").
+		ExpectEvent("tick", `"n":2`).
+		ExpectEventCountAtLeast(4).
+		ExpectEvent("", `"last":true`)
+	require.NoError(t, stream.Error())
+	require.NoError(t, stream.Close())
+	events := stream.Events()
+	require.Len(t, events, 4)
+	require.Equal(t, Event{Name: "message", Data: "hello", Retry: 500 * time.Millisecond}, events[0])
+	require.Equal(t, Event{Name: "tick", Data: "{\"n\":3,\n\"last\":true}", ID: "3"}, events[3])
+	require.Equal(t, http.StatusOK, stream.Response().StatusCode)
+
+	c = NewClient(srv.URL).WithReadIdleTimeout(time.Millisecond)
+	c.GetSSE("/ends").ExpectEventCountAtLeast(4)
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL)
+	stream = c.GetSSE("/events").WithTimeout(50*time.Millisecond).ExpectEvent("tick", `"n":1`).ExpectEvent("tick", `"n":1`)
+	require.NoError(t, stream.Close())
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), `expected an event "tick" with data containing "\"n\":1", but timed out after 50ms`)
+
+	c = NewClient(srv.URL)
+	c.GetSSE("/ends").ExpectEventCountAtLeast(5)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected at least 5 events, but got 4 and the stream ended")
+
+	c = NewClient(srv.URL)
+	c.GetSSE("/missing").ExpectEvent("", "")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "opening event stream /missing: got status 404 Not Found")
+
+	c = NewClient(srv.URL)
+	c.GetSSE("/json")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), `got Content-Type "text/plain; charset=utf-8"`)
+
+	c = NewClient("http://127.0.0.1:0")
+	c.GetSSE("/events").ExpectEvent("", "")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "opening event stream /events")
+}
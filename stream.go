@@ -0,0 +1,246 @@
+package crest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GetStream gets path and copies the response body to w as it arrives
+// rather than keeping it in memory, for downloads too big to hold as a
+// string. Body and the body assertions see an empty body; check what was
+// streamed with ExpectStreamedBytes and ExpectStreamedSHA256.
+func (c *client) GetStream(path string, w io.Writer) ResponseWrapper {
+	if c.errGetter() != nil {
+		return c.nop()
+	}
+	cl := c.cloneConfig()
+	cl.sink = w
+	return cl.doReqNoBody(http.MethodGet, path)
+}
+
+// responseSink is a sink that is given the response before its body is
+// streamed to it, e.g. to check the status of an endless stream.
+type responseSink interface {
+	gotResponse(resp *http.Response)
+}
+
+// pipeSink is the sink the body of a live stream is piped through.
+type pipeSink struct {
+	pw   *io.PipeWriter
+	resp chan *http.Response
+}
+
+func (s *pipeSink) gotResponse(resp *http.Response) {
+	s.resp <- resp
+}
+
+func (s *pipeSink) Write(p []byte) (int, error) {
+	return s.pw.Write(p)
+}
+
+// liveStream is what event streams and WebSocket connections share: the
+// request that stays open, and assertions waiting for what arrives on it.
+type liveStream struct {
+	client  *client
+	timeout time.Duration
+	resp    *http.Response
+	cancel  context.CancelFunc
+
+	lock sync.Mutex
+	// arrived is closed, and replaced, whenever something arrives or the
+	// stream ends.
+	arrived chan struct{}
+	ended   bool
+	err     error
+}
+
+func newLiveStream(c *client, timeout time.Duration) liveStream {
+	return liveStream{client: c, timeout: timeout, arrived: make(chan struct{})}
+}
+
+// open gets path with a detached copy of the client, with header set on top
+// of the client's headers and without the client's timeouts, which would cut
+// the stream short. It returns the body as it arrives, or nil if no response
+// came, in which case the client's error is set.
+func (s *liveStream) open(path, what string, header http.Header) io.ReadCloser {
+	cl := s.client.detached()
+	ctx := cl.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cl.ctx, s.cancel = context.WithCancel(ctx)
+	cl.timeout = 0
+	// The idle watchdog would end quiet streams, and hide that the body of
+	// an upgraded connection can be written to.
+	cl.readIdleTimeout = 0
+	if cl.headers == nil {
+		cl.headers = make(http.Header)
+	}
+	cl.headers = cl.headers.Clone()
+	for key, vals := range header {
+		cl.headers[key] = vals
+	}
+
+	pr, pw := io.Pipe()
+	sink := &pipeSink{pw: pw, resp: make(chan *http.Response, 1)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cl.GetStream(path, sink)
+		pw.CloseWithError(cl.Error())
+	}()
+
+	select {
+	case s.resp = <-sink.resp:
+	case <-done:
+		select {
+		case s.resp = <-sink.resp:
+		default:
+		}
+	}
+	if s.resp == nil {
+		s.cancel()
+		s.client.errSetter(errors.Wrapf(cl.Error(), "opening %v %v", what, path))
+		return nil
+	}
+	return pr
+}
+
+// notify wakes up the assertions waiting. s.lock must be held.
+func (s *liveStream) notify() {
+	close(s.arrived)
+	s.arrived = make(chan struct{})
+}
+
+// finish ends the stream after err, io.EOF for a clean end. s.lock must be
+// held.
+func (s *liveStream) finish(err error) {
+	if err != io.EOF {
+		s.err = err
+	}
+	s.ended = true
+	s.notify()
+}
+
+// wait waits until ready, called with s.lock held, returns true, the stream
+// ends or the timeout passes, and returns why it stopped waiting: nil if
+// ready returned true, or what ended, called with s.lock held, returns if
+// the stream ended.
+func (s *liveStream) wait(ready func() bool, ended func() error) error {
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	for {
+		s.lock.Lock()
+		if ready() {
+			s.lock.Unlock()
+			return nil
+		}
+		if s.ended {
+			err := ended()
+			s.lock.Unlock()
+			return err
+		}
+		arrived := s.arrived
+		s.lock.Unlock()
+		select {
+		case <-arrived:
+		case <-timer.C:
+			return errors.Errorf("timed out after %v", s.timeout)
+		}
+	}
+}
+
+// stream copies the body to w, decoding it if the settings say so, and
+// records its sizes and checksum.
+func (r *responseWrapper) stream(w io.Writer) {
+	wire := &countingReader{rd: r.resp.Body}
+	var rd io.Reader = wire
+	if r.settings.decompress && !r.resp.Uncompressed {
+		encoding := strings.ToLower(strings.TrimSpace(r.resp.Header.Get("Content-Encoding")))
+		decoded, err := decodingReader(encoding, wire)
+		if err != nil {
+			r.fail(errors.Wrapf(err, "decoding %v response body", encoding))
+			return
+		}
+		if decoded != nil {
+			defer decoded.Close()
+			rd = decoded
+			r.encoding = encoding
+			r.resp.Header.Del("Content-Encoding")
+			r.resp.Header.Del("Content-Length")
+			r.resp.ContentLength = -1
+			r.resp.Uncompressed = true
+		}
+	}
+
+	if sink, ok := w.(responseSink); ok {
+		sink.gotResponse(r.resp)
+	}
+	sum := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, sum), rd)
+	r.sizes.Wire = wire.n
+	r.sizes.Decoded = n
+	r.streamed = true
+	r.sha256 = hex.EncodeToString(sum.Sum(nil))
+	if err != nil {
+		r.fail(errors.Wrap(err, "streaming response body"))
+	}
+}
+
+// ExpectStreamedBytes fails if the body streamed by GetStream was not n bytes
+// long, after decoding.
+func (r *responseWrapper) ExpectStreamedBytes(n int64) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if !r.expectStreamed() {
+		return r
+	}
+	if r.sizes.Decoded != n {
+		r.setError(fmt.Errorf("expected %d bytes to be streamed but got %d", n, r.sizes.Decoded))
+	}
+	return r
+}
+
+// ExpectStreamedSHA256 fails if the hex SHA-256 checksum of the body streamed
+// by GetStream, after decoding, is not sum.
+func (r *responseWrapper) ExpectStreamedSHA256(sum string) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if !r.expectStreamed() {
+		return r
+	}
+	if !strings.EqualFold(r.sha256, sum) {
+		r.setError(fmt.Errorf("expected the streamed body to have SHA-256 %v but it has %v", sum, r.sha256))
+	}
+	return r
+}
+
+func (r *responseWrapper) expectStreamed() bool {
+	if !r.streamed {
+		r.setError(errors.New("expected a body streamed by GetStream, but the body was not streamed"))
+	}
+	return r.streamed
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	rd io.Reader
+	n  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.rd.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,64 @@
+package crest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetStream(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 100000)
+	sum := sha256.Sum256(payload)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write(payload)
+			gz.Close()
+			return
+		}
+		w.Write(payload)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	var buf bytes.Buffer
+	rw := c.GetStream("/file", &buf).
+		ExpectStatus(http.StatusOK).
+		ExpectStreamedBytes(int64(len(payload))).
+		ExpectStreamedSHA256(hex.EncodeToString(sum[:]))
+	require.NoError(t, c.Error())
+	require.Equal(t, payload, buf.Bytes())
+	require.Equal(t, "", rw.Body())
+	require.Equal(t, BodySizes{Wire: int64(len(payload)), Decoded: int64(len(payload))}, rw.Sizes())
+
+	buf.Reset()
+	rw = c.GetStream("/gzip", &buf).ExpectStreamedBytes(int64(len(payload)))
+	require.NoError(t, c.Error())
+	require.Equal(t, payload, buf.Bytes())
+	require.True(t, rw.Sizes().Wire < rw.Sizes().Decoded)
+
+	// Later requests from the client are not streamed.
+	c.Get("/file").ExpectBodyEquals(string(payload))
+	require.NoError(t, c.Error())
+
+	c.GetStream("/file", &bytes.Buffer{}).ExpectStreamedSHA256("00")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected the streamed body to have SHA-256 00")
+
+	c = NewClient(srv.URL)
+	c.GetStream("/file", &bytes.Buffer{}).ExpectStreamedBytes(1)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected 1 bytes to be streamed but got 1000000")
+
+	c = NewClient(srv.URL)
+	c.Get("/file").ExpectStreamedBytes(1)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "the body was not streamed")
+}
@@ -0,0 +1,31 @@
+package crest
+
+// Tag labels the client's requests with tags, e.g. "setup" or
+// "critical-path", on top of any it already has. The tags of a request are
+// on its response wrapper, in what it exports and records, and in the
+// Observation of it, so reports and metrics can group requests or leave
+// setup traffic out; TrafficRecorder.Ignore leaves it out of recorded calls.
+func (c *client) Tag(tags ...string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	// Clones share the backing array, so never append to it in place.
+	merged := c.tags[:len(c.tags):len(c.tags)]
+	for _, tag := range tags {
+		if !contains(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	c.tags = merged
+	return c
+}
+
+// Tags returns the tags of the client that made the request.
+func (r *responseWrapper) Tags() []string {
+	return r.tags
+}
+
+// HasTag reports whether the request was tagged with tag.
+func (r *responseWrapper) HasTag(tag string) bool {
+	return contains(r.tags, tag)
+}
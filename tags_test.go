@@ -0,0 +1,39 @@
+package crest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTags(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	setup := c.Clone().Tag("setup")
+	critical := c.Clone().Tag("critical-path", "checkout").Tag("checkout")
+	other := setup.Clone().Tag("cleanup")
+
+	require.Nil(t, c.Get("/").Tags())
+	rw := setup.Get("/")
+	require.Equal(t, []string{"setup"}, rw.Tags())
+	require.True(t, rw.HasTag("setup"))
+	require.False(t, rw.HasTag("checkout"))
+	require.Equal(t, []string{"critical-path", "checkout"}, critical.Get("/").Tags())
+	require.Equal(t, []string{"setup", "cleanup"}, other.Get("/").Tags())
+	require.Equal(t, []string{"setup"}, setup.Get("/").Tags())
+	require.NoError(t, c.Error())
+
+	var buf bytes.Buffer
+	require.NoError(t, rw.ExportJSON(&buf))
+	var exported struct {
+		Tags []string `json:"tags"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &exported))
+	require.Equal(t, []string{"setup"}, exported.Tags)
+}
@@ -0,0 +1,74 @@
+package crest
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// NewTestClient returns a client that fails t as soon as a request or an
+// assertion fails, so tests need not check Error at the end. By default the
+// first failure stops the test with t.Fatalf, which like t.FailNow must be
+// called from the goroutine running the test. With WithErrorCollection every
+// failure is reported with t.Errorf instead and the test carries on. The
+// client is closed when the test finishes. Every request carries the name of
+// the test in DefaultTestNameHeader, see WithTestNameHeader.
+func NewTestClient(t testing.TB, url string) Client {
+	t.Helper()
+	cl := NewClient(url).(*client)
+	cl.testName = t.Name()
+	cl.testNameHeader = DefaultTestNameHeader
+	cl.errState.report = func(err error, fatal bool) {
+		t.Helper()
+		msg := err.Error()
+		if caller := callerOutsideCrest(); caller != "" {
+			msg = caller + ": " + msg
+		}
+		if fatal {
+			t.Fatalf("%v", msg)
+		} else {
+			t.Errorf("%v", msg)
+		}
+	}
+	t.Cleanup(func() {
+		if err := cl.Close(); err != nil {
+			t.Errorf("closing client: %v", err)
+		}
+	})
+	return cl
+}
+
+// DefaultTestNameHeader is the header a client made by NewTestClient sends
+// the name of its test in, so server logs can be filtered by test.
+const DefaultTestNameHeader = "X-Test-Name"
+
+// WithTestNameHeader sets the header the name of the test is sent in by a
+// client made by NewTestClient. An empty name stops it being sent.
+func (c *client) WithTestNameHeader(name string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.testNameHeader = name
+	return c
+}
+
+// callerOutsideCrest returns the file and line of the innermost caller that
+// is not part of crest, which is where the failing request or assertion was
+// made. t.Helper can only hide the frames of functions that call it.
+func callerOutsideCrest() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		inCrest := strings.HasPrefix(frame.Function, "github.com/dr-db/crest.") &&
+			!strings.HasSuffix(frame.File, "_test.go")
+		if !inCrest && frame.File != "" {
+			return fmt.Sprintf("%v:%d", filepath.Base(frame.File), frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package crest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeT records the failures reported to it instead of failing the test.
+type fakeT struct {
+	testing.TB
+	fatals   []string
+	errors   []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Fatalf(format string, args ...interface{}) {
+	t.fatals = append(t.fatals, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Cleanup(f func()) {
+	t.cleanups = append(t.cleanups, f)
+}
+
+func TestNewTestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	ft := &fakeT{TB: t}
+	c := NewTestClient(ft, srv.URL)
+	c.Get("/").ExpectStatus(http.StatusOK).ExpectBodyEquals("ok")
+	require.Empty(t, ft.fatals)
+
+	c.Get("/").ExpectStatus(http.StatusNotFound)
+	c.Get("/").ExpectBodyEquals("nope")
+	require.Len(t, ft.fatals, 1)
+	require.Contains(t, ft.fatals[0], "testclient_test.go:")
+	require.Contains(t, ft.fatals[0], "404")
+	require.Empty(t, ft.errors)
+
+	ft = &fakeT{TB: t}
+	c = NewTestClient(ft, srv.URL).WithErrorCollection()
+	c.Get("/").ExpectStatus(http.StatusNotFound).ExpectBodyEquals("nope")
+	require.Empty(t, ft.fatals)
+	require.Len(t, ft.errors, 2)
+	require.Contains(t, ft.errors[1], "nope")
+
+	require.Len(t, ft.cleanups, 1)
+	ft.cleanups[0]()
+	c.Clone().Get("/")
+	require.Len(t, ft.errors, 3)
+	require.Contains(t, ft.errors[2], ErrClientClosed.Error())
+}
+
+func TestTestNameHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Test-Name") + "|" + r.Header.Get("X-Case")))
+	}))
+	defer srv.Close()
+
+	c := NewTestClient(t, srv.URL)
+	c.Get("/").ExpectBodyEquals("TestTestNameHeader|")
+	c.Clone().WithTestNameHeader("X-Case").Get("/").ExpectBodyEquals("|TestTestNameHeader")
+	c.Clone().WithTestNameHeader("").Get("/").ExpectBodyEquals("|")
+	t.Run("sub", func(t *testing.T) {
+		NewTestClient(t, srv.URL).Get("/").ExpectBodyEquals("TestTestNameHeader/sub|")
+	})
+	plain := NewClient(srv.URL)
+	plain.Get("/").ExpectBodyEquals("|")
+	require.NoError(t, plain.Error())
+}
@@ -0,0 +1,100 @@
+package crest
+
+import (
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings describes how the connection the final response was read from was
+// obtained.
+type Timings struct {
+	// DNS is how long looking up the host took.
+	DNS time.Duration
+	// Dials lists the connection attempts in the order they started. With
+	// Happy Eyeballs, attempts to different addresses overlap.
+	Dials []DialAttempt
+	// Chosen is the address of the connection that was used.
+	Chosen string
+	// Reused is set if an idle connection was used, in which case there were
+	// no lookups or dials.
+	Reused bool
+	// Total is how long the attempt took, from sending the request to having
+	// read the whole response body.
+	Total time.Duration
+}
+
+// DialAttempt is a single attempt to connect to one address.
+type DialAttempt struct {
+	Network  string
+	Address  string
+	Duration time.Duration
+	Err      error
+}
+
+// timingsRecorder fills in Timings from the trace of a request. Dial
+// attempts can finish concurrently, so all of its fields are guarded.
+type timingsRecorder struct {
+	lock     sync.Mutex
+	timings  Timings
+	dnsStart time.Time
+	starts   map[string]time.Time
+}
+
+func (t *timingsRecorder) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			// Each redirect gets a connection of its own; only the last one
+			// is interesting.
+			t.timings = Timings{}
+			t.starts = make(map[string]time.Time)
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.timings.DNS = time.Since(t.dnsStart)
+		},
+		ConnectStart: func(network, addr string) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.starts[network+" "+addr] = time.Now()
+			t.timings.Dials = append(t.timings.Dials, DialAttempt{
+				Network: network,
+				Address: addr,
+			})
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			for i := range t.timings.Dials {
+				dial := &t.timings.Dials[i]
+				if dial.Network == network && dial.Address == addr {
+					dial.Duration = time.Since(t.starts[network+" "+addr])
+					dial.Err = err
+				}
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.lock.Lock()
+			defer t.lock.Unlock()
+			t.timings.Chosen = info.Conn.RemoteAddr().String()
+			t.timings.Reused = info.Reused
+		},
+	}
+}
+
+func (t *timingsRecorder) get() Timings {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	timings := t.timings
+	timings.Dials = append([]DialAttempt(nil), t.timings.Dials...)
+	return timings
+}
@@ -0,0 +1,41 @@
+package crest
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimings(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	port := strconv.Itoa(srv.Listener.Addr().(*net.TCPAddr).Port)
+
+	c := NewClient("http://localhost:" + port)
+	timings := c.Get("/").Timings()
+	require.NoError(t, c.Error())
+	require.False(t, timings.Reused)
+	require.True(t, timings.Total > 0)
+	require.Equal(t, srv.Listener.Addr().String(), timings.Chosen)
+	require.NotEmpty(t, timings.Dials)
+	var chosen *DialAttempt
+	for i, dial := range timings.Dials {
+		require.Equal(t, "tcp", dial.Network)
+		require.True(t, dial.Duration > 0)
+		if dial.Address == timings.Chosen {
+			chosen = &timings.Dials[i]
+		}
+	}
+	require.NotNil(t, chosen)
+	require.NoError(t, chosen.Err)
+
+	timings = c.Get("/").Timings()
+	require.True(t, timings.Reused)
+	require.Empty(t, timings.Dials)
+	require.Equal(t, srv.Listener.Addr().String(), timings.Chosen)
+	require.NoError(t, c.Error())
+}
@@ -0,0 +1,75 @@
+package crest
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// WithTLSConfig makes the client use config for HTTPS connections, e.g. to
+// trust a private CA with RootCAs. The client keeps a copy of config.
+func (c *client) WithTLSConfig(config *tls.Config) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	if config == nil {
+		config = &tls.Config{}
+	}
+	c.useTLSConfig(config.Clone())
+	return c
+}
+
+// WithClientCert makes the client present the certificate in certFile, with
+// the private key in keyFile, both PEM encoded, to servers that ask for one,
+// as mTLS protected endpoints do.
+func (c *client) WithClientCert(certFile, keyFile string) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		c.errSetter(errors.Wrap(err, "loading client certificate"))
+		return c
+	}
+	config := c.tlsConfig()
+	config.Certificates = append(config.Certificates, cert)
+	c.useTLSConfig(config)
+	return c
+}
+
+// InsecureSkipVerify makes the client accept any certificate a server
+// presents, e.g. the self-signed one of a development server. Only use it
+// against servers under test.
+func (c *client) InsecureSkipVerify() Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	config := c.tlsConfig()
+	config.InsecureSkipVerify = true
+	c.useTLSConfig(config)
+	return c
+}
+
+// tlsConfig returns a copy of the TLS configuration the client uses.
+func (c *client) tlsConfig() *tls.Config {
+	if c.dial.tls != nil {
+		return c.dial.tls.Clone()
+	}
+	t := c.dialTransport
+	if t == nil {
+		t, _ = c.httpClient.Transport.(*http.Transport)
+	}
+	if t != nil && t.TLSClientConfig != nil {
+		return t.TLSClientConfig.Clone()
+	}
+	return &tls.Config{}
+}
+
+func (c *client) useTLSConfig(config *tls.Config) {
+	dial := c.dial
+	dial.tls = config
+	if err := c.useDialSettings(dial); err != nil {
+		c.errSetter(errors.Wrap(err, "setting TLS configuration"))
+	}
+}
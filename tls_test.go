@@ -0,0 +1,82 @@
+package crest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to
+// dir, returning the paths of both files.
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "crest-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestTLSOptions(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	c.Get("/")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "certificate")
+
+	c = NewClient(srv.URL).InsecureSkipVerify()
+	c.Get("/").ExpectStatus(http.StatusOK).ExpectBodyEquals("")
+	require.NoError(t, c.Error())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	config := &tls.Config{RootCAs: roots}
+	c = NewClient(srv.URL).WithTLSConfig(config)
+	config.RootCAs = nil
+	c.Get("/").ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+
+	certFile, keyFile := writeClientCert(t, t.TempDir())
+	c = NewCustomClient(srv.URL, srv.Client()).WithClientCert(certFile, keyFile)
+	c.Get("/").ExpectBodyEquals("crest-test")
+	require.NoError(t, c.Error())
+
+	c = NewClient(srv.URL).WithClientCert(keyFile, certFile)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "loading client certificate")
+}
@@ -0,0 +1,219 @@
+package crest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnexpectedRequest is the cause of the error of a request refused by a
+// strict TrafficRecorder because it was not declared.
+var ErrUnexpectedRequest = errors.New("unexpected request")
+
+// TrafficRecorder records the requests made by the clients it is attached
+// to with WithTrafficRecorder, to assert on the calls a flow made, e.g. one
+// driven by higher-level SDK code under test. In strict mode it also
+// refuses the calls that were not declared, see Strict.
+type TrafficRecorder struct {
+	lock  sync.Mutex
+	calls []string
+
+	strict bool
+	// expected are the calls declared with Expect and not made yet.
+	expected []string
+	allowed  []string
+	ignored  []string
+}
+
+// NewTrafficRecorder returns a recorder that has recorded nothing.
+func NewTrafficRecorder() *TrafficRecorder {
+	return &TrafficRecorder{}
+}
+
+// WithTrafficRecorder records every request the client sends in rec, once
+// however many attempts it takes. Clones of the client record into rec too.
+func (c *client) WithTrafficRecorder(rec *TrafficRecorder) Client {
+	if c.errGetter() != nil {
+		return c
+	}
+	c.traffic = rec
+	return c
+}
+
+// Strict makes the clients attached to t refuse every request that is
+// not one of the calls declared with Expect or Allow, failing it with
+// ErrUnexpectedRequest, e.g. to check that a refactoring did not add
+// backend calls.
+func (t *TrafficRecorder) Strict() *TrafficRecorder {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.strict = true
+	return t
+}
+
+// Expect declares calls that may be made once each, in any order. Calls
+// are written as for ExpectOrder.
+func (t *TrafficRecorder) Expect(calls ...string) *TrafficRecorder {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.expected = append(t.expected, calls...)
+	return t
+}
+
+// Allow declares calls that may be made any number of times, e.g. health
+// checks. Calls are written as for ExpectOrder.
+func (t *TrafficRecorder) Allow(calls ...string) *TrafficRecorder {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.allowed = append(t.allowed, calls...)
+	return t
+}
+
+// Ignore makes t leave out the requests of clients tagged with any of tags,
+// see Tag, e.g. to keep setup traffic out of the calls asserted on. Ignored
+// requests are not refused in strict mode either.
+func (t *TrafficRecorder) Ignore(tags ...string) *TrafficRecorder {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.ignored = append(t.ignored, tags...)
+	return t
+}
+
+// admit records req, with its path relative to the base URL of the client
+// that sent it, unless t is strict and req was not declared, in which case
+// it returns an error. Requests of clients with an ignored tag are let
+// through without being recorded.
+func (t *TrafficRecorder) admit(c *client, req *http.Request) error {
+	call := req.Method + " " + c.relativePath(req.URL)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, tag := range c.tags {
+		if contains(t.ignored, tag) {
+			return nil
+		}
+	}
+	if t.strict && !t.declared(call) {
+		return errors.Wrapf(ErrUnexpectedRequest, "%v was not declared", call)
+	}
+	t.calls = append(t.calls, call)
+	return nil
+}
+
+// declared tells whether call was declared, using up the declaration if it
+// was made with Expect.
+func (t *TrafficRecorder) declared(call string) bool {
+	for i, expected := range t.expected {
+		if callMatches(expected, call) {
+			t.expected = append(t.expected[:i:i], t.expected[i+1:]...)
+			return true
+		}
+	}
+	for _, allowed := range t.allowed {
+		if callMatches(allowed, call) {
+			return true
+		}
+	}
+	return false
+}
+
+// relativePath returns the path of u relative to the path of the root base
+// URL, if u is on the same host, or else the whole path.
+func (c *client) relativePath(u *url.URL) string {
+	base, err := url.Parse(c.rootBaseURL())
+	if err != nil || !strings.EqualFold(base.Host, u.Host) {
+		return u.Path
+	}
+	prefix := strings.TrimSuffix(base.Path, "/")
+	if prefix == "" || !strings.HasPrefix(u.Path, prefix+"/") {
+		return u.Path
+	}
+	return strings.TrimPrefix(u.Path, prefix)
+}
+
+// Calls returns the calls recorded so far, in the order they were made, as
+// the method and the path relative to the base URL, e.g. "GET /orders/1".
+func (t *TrafficRecorder) Calls() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return append([]string(nil), t.calls...)
+}
+
+// Reset forgets the calls recorded so far, the declared calls, the ignored
+// tags and strict mode.
+func (t *TrafficRecorder) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.calls = nil
+	t.strict = false
+	t.expected = nil
+	t.allowed = nil
+	t.ignored = nil
+}
+
+// ExpectOrder returns an error unless calls were made in the given order,
+// with any other calls in between. A call is a method and a path, in which
+// "{name}" matches any one segment, e.g. "GET /orders/{id}", or just a path
+// for a call with any method.
+func (t *TrafficRecorder) ExpectOrder(calls ...string) error {
+	recorded := t.Calls()
+	next := 0
+	for i, expected := range calls {
+		for next < len(recorded) && !callMatches(expected, recorded[next]) {
+			next++
+		}
+		if next == len(recorded) {
+			after := ""
+			if i > 0 {
+				after = " after " + calls[i-1]
+			}
+			return errors.Errorf("expected a call %v%v, but the calls were:%v", expected, after, listCalls(recorded))
+		}
+		next++
+	}
+	return nil
+}
+
+// callMatches tells whether the recorded call matches pattern.
+func callMatches(pattern, call string) bool {
+	method, path := "", pattern
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		method, path = pattern[:i], strings.TrimSpace(pattern[i+1:])
+	}
+	i := strings.IndexByte(call, ' ')
+	if method != "" && !strings.EqualFold(method, call[:i]) {
+		return false
+	}
+	want, got := strings.Split(path, "/"), strings.Split(call[i+1:], "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for j, segment := range want {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if got[j] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != got[j] {
+			return false
+		}
+	}
+	return true
+}
+
+func listCalls(calls []string) string {
+	if len(calls) == 0 {
+		return " none"
+	}
+	return "\n  " + strings.Join(calls, "\n  ")
+}
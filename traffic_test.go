@@ -0,0 +1,101 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrafficRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/flaky" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	rec := NewTrafficRecorder()
+	c := NewClient(srv.URL + "/api").WithTrafficRecorder(rec)
+	c.PostString("/orders", "{}")
+	c.Clone().Get("/health")
+	c.PostString("/payments", "{}")
+	c.Get("/orders/42")
+	c.WithRetry(3, nil).Get("/flaky")
+	NewClient(srv.URL).WithTrafficRecorder(rec).Get("/other")
+	require.NoError(t, c.Error())
+	require.Equal(t, []string{
+		"POST /orders",
+		"GET /health",
+		"POST /payments",
+		"GET /orders/42",
+		"GET /flaky",
+		"GET /other",
+	}, rec.Calls())
+
+	require.NoError(t, rec.ExpectOrder("POST /orders", "POST /payments", "GET /orders/{id}"))
+	require.NoError(t, rec.ExpectOrder("/orders", "/orders/{id}", "get /flaky"))
+	require.NoError(t, rec.ExpectOrder())
+
+	err := rec.ExpectOrder("POST /payments", "POST /orders")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "expected a call POST /orders after POST /payments, but the calls were:\n  POST /orders\n  GET /health")
+	err = rec.ExpectOrder("GET /orders/{id}/items")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "expected a call GET /orders/{id}/items, but the calls were:")
+
+	rec.Reset()
+	require.Empty(t, rec.Calls())
+	require.Contains(t, rec.ExpectOrder("/orders").Error(), "but the calls were: none")
+}
+
+func TestTrafficRecorderStrict(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer srv.Close()
+
+	rec := NewTrafficRecorder().
+		Expect("POST /orders", "GET /orders/{id}").
+		Allow("/health").
+		Strict()
+	c := NewClient(srv.URL).WithTrafficRecorder(rec)
+	c.Get("/health")
+	c.Get("/orders/1")
+	c.PostString("/orders", "{}")
+	c.Get("/health")
+	require.NoError(t, c.Error())
+	require.Equal(t, 4, hits)
+
+	c.Get("/orders/2")
+	require.Error(t, c.Error())
+	require.True(t, errors.Is(c.Error(), ErrUnexpectedRequest))
+	require.Contains(t, c.Error().Error(), "GET /orders/2 was not declared")
+	require.Equal(t, 4, hits)
+	require.Len(t, rec.Calls(), 4)
+
+	rec.Reset()
+	c = NewClient(srv.URL).WithTrafficRecorder(rec)
+	c.Get("/orders/2")
+	require.NoError(t, c.Error())
+	require.Equal(t, 5, hits)
+}
+
+func TestTrafficRecorderIgnore(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	rec := NewTrafficRecorder().Ignore("setup").Expect("GET /orders/{id}").Strict()
+	c := NewClient(srv.URL).WithTrafficRecorder(rec)
+	c.Clone().Tag("setup").PostString("/orders", "{}")
+	c.Get("/orders/1")
+	require.NoError(t, c.Error())
+	require.Equal(t, []string{"GET /orders/1"}, rec.Calls())
+
+	rec.Reset()
+	c.Clone().Tag("setup").Get("/orders/2")
+	require.Equal(t, []string{"GET /orders/2"}, rec.Calls())
+}
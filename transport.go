@@ -0,0 +1,55 @@
+package crest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Transport returns an http.RoundTripper that sends requests through c, so
+// code built around *http.Client gets the client's auth, headers and other
+// settings. Redirects are left to the http.Client using the transport, and
+// failures are returned from RoundTrip rather than recorded on c.
+func Transport(c Client) http.RoundTripper {
+	cl, ok := c.(*client)
+	if !ok {
+		return roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errors.Errorf("cannot use a %T as a transport", c)
+		})
+	}
+	return &transport{client: cl}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type transport struct {
+	client *client
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cl := t.client.detached()
+	httpClient := *cl.httpClient
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cl.httpClient = &httpClient
+
+	rw := cl.do(cl.populateReq(req.Clone(req.Context())))
+	if err := cl.Error(); err != nil {
+		return nil, err
+	}
+	if rw.DryRun() {
+		return nil, errors.Wrapf(ErrDryRun, "not doing a %v request to URL %q", req.Method, req.URL.String())
+	}
+	resp := rw.Response()
+	resp.Body = ioutil.NopCloser(strings.NewReader(rw.Body()))
+	resp.ContentLength = int64(len(rw.Body()))
+	resp.Request = req
+	return resp, nil
+}
@@ -0,0 +1,40 @@
+package crest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/target", http.StatusFound)
+			return
+		}
+		user, pass, _ := r.BasicAuth()
+		w.Write([]byte(r.URL.Path + " " + user + ":" + pass + " " + r.Header.Get("X-Test")))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).UseBasicAuth("user", "pass").WithHeader("X-Test", "crest")
+	httpClient := &http.Client{Transport: Transport(c)}
+
+	resp, err := httpClient.Get(srv.URL + "/redirect")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	bs, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "/target user:pass crest", string(bs))
+	require.Equal(t, srv.URL+"/target", resp.Request.URL.String())
+
+	_, err = (&http.Client{Transport: Transport(c)}).Get("http://127.0.0.1:0/")
+	require.Error(t, err)
+	require.NoError(t, c.Error())
+
+	_, err = (&http.Client{Transport: Transport(nil)}).Get(srv.URL)
+	require.Error(t, err)
+}
@@ -0,0 +1,311 @@
+package crest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultPartSize is the part size of multipart uploads, the smallest S3
+// accepts for all but the last part.
+const DefaultPartSize = 5 << 20
+
+// Upload uploads an object in parts with the S3 multipart upload API, which
+// object stores compatible with S3 implement: it initiates the upload, sends
+// the parts concurrently, retrying failed ones, and completes the upload, or
+// aborts it if a part cannot be sent. Each part carries a Content-MD5
+// header, and the ETags the server returns for the parts and the object are
+// checked against the data where they are MD5 digests.
+type Upload interface {
+	// PartSize sets the size of each part but the last one.
+	PartSize(bytes int64) Upload
+	// Concurrency sets how many parts are sent at once.
+	Concurrency(n int) Upload
+	// PartRetries sets how many more times a failed part is sent.
+	PartRetries(n int) Upload
+	// OnProgress calls f with the number of bytes uploaded so far and the
+	// total after each part is uploaded.
+	OnProgress(f func(uploaded, total int64)) Upload
+	// Do runs the upload. It returns the wrapper of the response that
+	// completed it.
+	Do() ResponseWrapper
+}
+
+type upload struct {
+	client      *client
+	path        string
+	data        io.ReaderAt
+	size        int64
+	partSize    int64
+	concurrency int
+	retries     int
+	progress    func(uploaded, total int64)
+}
+
+// MultipartUpload starts an upload of the size bytes of data to the object
+// at path.
+func (c *client) MultipartUpload(path string, data io.ReaderAt, size int64) Upload {
+	return &upload{
+		client:      c,
+		path:        path,
+		data:        data,
+		size:        size,
+		partSize:    DefaultPartSize,
+		concurrency: 4,
+		retries:     2,
+	}
+}
+
+func (u *upload) PartSize(bytes int64) Upload {
+	u.partSize = bytes
+	return u
+}
+
+func (u *upload) Concurrency(n int) Upload {
+	u.concurrency = n
+	return u
+}
+
+func (u *upload) PartRetries(n int) Upload {
+	u.retries = n
+	return u
+}
+
+func (u *upload) OnProgress(f func(uploaded, total int64)) Upload {
+	u.progress = f
+	return u
+}
+
+type uploadedPart struct {
+	XMLName    xml.Name `xml:"Part"`
+	PartNumber int      `xml:"PartNumber"`
+	ETag       string   `xml:"ETag"`
+	sum        []byte
+}
+
+func (u *upload) Do() ResponseWrapper {
+	c := u.client
+	if c.errGetter() != nil {
+		return c.nop()
+	}
+	if u.partSize <= 0 {
+		c.errSetter(errors.Errorf("invalid part size %d", u.partSize))
+		return c.nop()
+	}
+	if u.size < 0 {
+		c.errSetter(errors.Errorf("invalid upload size %d", u.size))
+		return c.nop()
+	}
+
+	rw := c.PostNoBody(u.query("uploads", "")).ExpectStatus2xx()
+	if c.errGetter() != nil || rw.DryRun() {
+		return rw
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal([]byte(rw.Body()), &initiated); err != nil || initiated.UploadID == "" {
+		c.errSetter(errors.Errorf("initiating the upload of %v returned no upload ID: %q", u.path, rw.Body()))
+		return c.nop()
+	}
+	uploadID := initiated.UploadID
+
+	parts, err := u.sendParts(uploadID)
+	if err != nil {
+		c.errSetter(u.abort(uploadID, err))
+		return c.nop()
+	}
+
+	var complete bytes.Buffer
+	complete.WriteString("<CompleteMultipartUpload>")
+	for _, part := range parts {
+		bs, _ := xml.Marshal(part)
+		complete.Write(bs)
+	}
+	complete.WriteString("</CompleteMultipartUpload>")
+	rw = c.PostBytesTyped(u.query("uploadId", uploadID), complete.Bytes(), "application/xml").ExpectStatus2xx()
+	if c.errGetter() != nil {
+		return rw
+	}
+	var completed struct {
+		XMLName xml.Name
+		ETag    string `xml:"ETag"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal([]byte(rw.Body()), &completed); err == nil && completed.XMLName.Local == "Error" {
+		// S3 can report failing to complete an upload in a 200 response.
+		c.errSetter(u.abort(uploadID, errors.Errorf("completing the upload failed: %v: %v", completed.Code, completed.Message)))
+		return c.nop()
+	}
+	if want := multipartETag(parts); isMultipartETag(completed.ETag) && strings.Trim(completed.ETag, `"`) != want {
+		c.errSetter(errors.Errorf("expected the upload of %v to have the ETag %q but got %v", u.path, want, completed.ETag))
+	}
+	return rw
+}
+
+// sendParts sends the parts of the upload, returning them in order.
+func (u *upload) sendParts(uploadID string) ([]uploadedPart, error) {
+	n := int((u.size + u.partSize - 1) / u.partSize)
+	if n == 0 {
+		// S3 needs at least one part, even if empty.
+		n = 1
+	}
+	concurrency := u.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		lock     sync.Mutex
+		parts    []uploadedPart
+		uploaded int64
+		errs     []error
+		wg       sync.WaitGroup
+	)
+	numbers := make(chan int)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range numbers {
+				part, size, err := u.sendPart(uploadID, number)
+				lock.Lock()
+				if err != nil {
+					errs = append(errs, errors.Wrapf(err, "uploading part %d", number))
+				} else {
+					parts = append(parts, part)
+					uploaded += size
+					if u.progress != nil {
+						u.progress(uploaded, u.size)
+					}
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for number := 1; number <= n; number++ {
+		lock.Lock()
+		failed := len(errs) > 0
+		lock.Unlock()
+		if failed {
+			break
+		}
+		numbers <- number
+	}
+	close(numbers)
+	wg.Wait()
+
+	if len(errs) == 1 {
+		return nil, errs[0]
+	} else if len(errs) > 1 {
+		return nil, errors.New(joinErrors(errs))
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+	return parts, nil
+}
+
+func (u *upload) sendPart(uploadID string, number int) (uploadedPart, int64, error) {
+	c := u.client
+	offset := int64(number-1) * u.partSize
+	size := u.partSize
+	if offset+size > u.size {
+		size = u.size - offset
+	}
+	body := make([]byte, size)
+	// ReaderAt may return io.EOF along with the last bytes, so a full read
+	// is what counts.
+	if n, err := u.data.ReadAt(body, offset); n < len(body) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return uploadedPart{}, 0, errors.Wrap(err, "reading data")
+	}
+	sum := md5.Sum(body)
+	path := u.query("uploadId", uploadID) + fmt.Sprintf("&partNumber=%d", number)
+
+	var err error
+	for attempt := 0; attempt <= u.retries; attempt++ {
+		cl := c.detached()
+		req := cl.buildReq(http.MethodPut, path, body)
+		if req == nil {
+			return uploadedPart{}, 0, cl.Error()
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		rw := cl.do(req).ExpectStatus2xx()
+		if err = cl.Error(); err != nil {
+			continue
+		}
+		etag := rw.Response().Header.Get("ETag")
+		if etag == "" {
+			return uploadedPart{}, 0, errors.New("the response has no ETag")
+		}
+		if digest := strings.Trim(etag, `"`); isMD5(digest) && digest != hex.EncodeToString(sum[:]) {
+			err = errors.Errorf("the ETag %v does not match the MD5 %x of the part", etag, sum)
+			continue
+		}
+		return uploadedPart{PartNumber: number, ETag: etag, sum: sum[:]}, size, nil
+	}
+	return uploadedPart{}, 0, err
+}
+
+// abort aborts the upload after err and returns err, noting if aborting
+// failed too.
+func (u *upload) abort(uploadID string, err error) error {
+	cl := u.client.detached()
+	cl.Delete(u.query("uploadId", uploadID)).ExpectStatus2xx()
+	if abortErr := cl.Error(); abortErr != nil {
+		return errors.Wrapf(err, "uploading %v, and aborting the upload failed: %v", u.path, abortErr)
+	}
+	return errors.Wrapf(err, "uploading %v", u.path)
+}
+
+// query returns the upload's path with a query parameter added.
+func (u *upload) query(key, value string) string {
+	sep := "?"
+	if strings.Contains(u.path, "?") {
+		sep = "&"
+	}
+	if value == "" {
+		return u.path + sep + key
+	}
+	return u.path + sep + key + "=" + url.QueryEscape(value)
+}
+
+var (
+	md5Pattern          = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	multipartETagFormat = regexp.MustCompile(`^"?[0-9a-f]{32}-[0-9]+"?$`)
+)
+
+func isMD5(s string) bool {
+	return md5Pattern.MatchString(s)
+}
+
+func isMultipartETag(etag string) bool {
+	return multipartETagFormat.MatchString(etag)
+}
+
+// multipartETag is the ETag S3 gives an object uploaded in parts: the MD5 of
+// the MD5s of the parts, followed by the number of parts.
+func multipartETag(parts []uploadedPart) string {
+	h := md5.New()
+	for _, part := range parts {
+		h.Write(part.sum)
+	}
+	return fmt.Sprintf("%x-%d", h.Sum(nil), len(parts))
+}
@@ -0,0 +1,159 @@
+package crest
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObjectStore implements just enough of the S3 multipart upload API.
+type fakeObjectStore struct {
+	lock     sync.Mutex
+	parts    map[int][]byte
+	objects  map[string][]byte
+	aborted  bool
+	failures map[int]int
+	badETag  bool
+}
+
+func (s *fakeObjectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	query := r.URL.Query()
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.parts = make(map[int][]byte)
+		w.Write([]byte(`<InitiateMultipartUploadResult><UploadId>up-1</UploadId></InitiateMultipartUploadResult>`))
+	case query.Get("uploadId") != "up-1":
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodPut:
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		if s.failures[n] > 0 {
+			s.failures[n]--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		sum := md5.Sum(body)
+		if s.badETag {
+			sum = md5.Sum(nil)
+		}
+		s.parts[n] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum))
+	case r.Method == http.MethodPost:
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+			} `xml:"Part"`
+		}
+		xml.Unmarshal(body, &complete)
+		var object, sums []byte
+		for _, part := range complete.Parts {
+			object = append(object, s.parts[part.PartNumber]...)
+			sum := md5.Sum(s.parts[part.PartNumber])
+			sums = append(sums, sum[:]...)
+		}
+		s.objects[r.URL.Path] = object
+		w.Write([]byte(fmt.Sprintf(`<CompleteMultipartUploadResult><ETag>"%x-%d"</ETag></CompleteMultipartUploadResult>`, md5.Sum(sums), len(complete.Parts))))
+	case r.Method == http.MethodDelete:
+		s.aborted = true
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	store := &fakeObjectStore{objects: make(map[string][]byte), failures: map[int]int{2: 1}}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	data := bytes.Repeat([]byte("0123456789"), 25)
+	var progress []int64
+	c := NewClient(srv.URL)
+	rw := c.MultipartUpload("/bucket/object", bytes.NewReader(data), int64(len(data))).
+		PartSize(100).
+		Concurrency(2).
+		OnProgress(func(uploaded, total int64) {
+			require.Equal(t, int64(len(data)), total)
+			progress = append(progress, uploaded)
+		}).
+		Do()
+	rw.ExpectStatus(http.StatusOK)
+	require.NoError(t, c.Error())
+	require.Equal(t, data, store.objects["/bucket/object"])
+	require.Len(t, progress, 3)
+	require.Equal(t, int64(len(data)), progress[2])
+	require.False(t, store.aborted)
+
+	c = NewClient(srv.URL)
+	c.MultipartUpload("/bucket/empty", bytes.NewReader(nil), 0).Do()
+	require.NoError(t, c.Error())
+	require.Empty(t, store.objects["/bucket/empty"])
+}
+
+func TestMultipartUploadAborts(t *testing.T) {
+	store := &fakeObjectStore{objects: make(map[string][]byte), failures: map[int]int{3: 5}}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	data := bytes.Repeat([]byte("x"), 250)
+	c := NewClient(srv.URL)
+	c.MultipartUpload("/bucket/object", bytes.NewReader(data), int64(len(data))).PartSize(100).PartRetries(1).Do()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "uploading /bucket/object: uploading part 3")
+	require.True(t, store.aborted)
+	require.NotContains(t, store.objects, "/bucket/object")
+
+	store = &fakeObjectStore{objects: make(map[string][]byte), badETag: true}
+	srv2 := httptest.NewServer(store)
+	defer srv2.Close()
+	c = NewClient(srv2.URL)
+	c.MultipartUpload("/bucket/object", bytes.NewReader(data), int64(len(data))).PartSize(100).PartRetries(0).Do()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "does not match the MD5")
+	require.True(t, store.aborted)
+}
+
+// eofReaderAt returns io.EOF along with the last bytes, as ReaderAt allows.
+type eofReaderAt []byte
+
+func (r eofReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := copy(p, r[off:])
+	if off+int64(n) == int64(len(r)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestMultipartUploadReads(t *testing.T) {
+	store := &fakeObjectStore{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	data := bytes.Repeat([]byte("x"), 250)
+	c := NewClient(srv.URL)
+	c.MultipartUpload("/bucket/object", eofReaderAt(data), int64(len(data))).PartSize(100).Do()
+	require.NoError(t, c.Error())
+	require.Equal(t, data, store.objects["/bucket/object"])
+
+	c = NewClient(srv.URL)
+	c.MultipartUpload("/bucket/short", bytes.NewReader(data), 300).PartSize(100).Do()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "reading data: unexpected EOF")
+	require.True(t, store.aborted)
+
+	c = NewClient(srv.URL)
+	c.MultipartUpload("/bucket/negative", bytes.NewReader(data), -1).Do()
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "invalid upload size -1")
+}
@@ -0,0 +1,249 @@
+package crest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseAndValidateBody parses the body into v like ParseBody, then checks
+// the values against the `validate` tags of v's struct fields, which use the
+// syntax of go-playground/validator:
+//
+//	type User struct {
+//		ID    string   `json:"id" validate:"required,uuid"`
+//		Email string   `json:"email" validate:"omitempty,email"`
+//		Role  string   `json:"role" validate:"oneof=admin member"`
+//		Tags  []string `json:"tags" validate:"max=5,dive,min=1"`
+//	}
+//
+// Supported are required, omitempty, len, min, max, eq, ne, gt, gte, lt,
+// lte, oneof, uuid, email, url, alpha, alphanum, numeric, lowercase,
+// uppercase and dive. Lengths are compared for strings, slices and maps, and
+// values for numbers. Nested structs are checked too. The error lists every
+// violation, each prefixed with the JSON path of the value at fault.
+func (r *responseWrapper) ParseAndValidateBody(v interface{}) ResponseWrapper {
+	if r.error() != nil {
+		return r
+	}
+	if err := json.Unmarshal([]byte(r.body), v); err != nil {
+		r.setError(fmt.Errorf("unmarshalling body: %v", err))
+		return r
+	}
+	if problems := validateValue(reflect.ValueOf(v), nil, "$"); len(problems) > 0 {
+		r.setError(fmt.Errorf("expected the body to be valid but %v", strings.Join(problems, "; ")))
+	}
+	return r
+}
+
+// validateValue checks v against rules, the parsed tag of the field holding
+// it, and the fields of v if it is a struct.
+func validateValue(v reflect.Value, rules []string, path string) []string {
+	var problems []string
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	for i, rule := range rules {
+		name, param := rule, ""
+		if j := strings.Index(rule, "="); j >= 0 {
+			name, param = rule[:j], rule[j+1:]
+		}
+		switch name {
+		case "required":
+			if !v.IsValid() || v.IsZero() {
+				fail("missing required value")
+				return problems
+			}
+			continue
+		case "omitempty":
+			if !v.IsValid() || v.IsZero() {
+				return problems
+			}
+			continue
+		}
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return problems
+			}
+			v = v.Elem()
+		}
+		if name == "dive" {
+			switch v.Kind() {
+			case reflect.Slice, reflect.Array:
+				for k := 0; k < v.Len(); k++ {
+					problems = append(problems, validateValue(v.Index(k), rules[i+1:], fmt.Sprintf("%v[%d]", path, k))...)
+				}
+			case reflect.Map:
+				for _, key := range v.MapKeys() {
+					problems = append(problems, validateValue(v.MapIndex(key), rules[i+1:], fmt.Sprintf("%v.%v", path, key))...)
+				}
+			default:
+				fail("cannot dive into a %v", v.Kind())
+			}
+			return problems
+		}
+		if problem := checkRule(v, name, param); problem != "" {
+			fail("%v", problem)
+		}
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return problems
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := field.Name
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			var fieldRules []string
+			if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+				fieldRules = strings.Split(tag, ",")
+			}
+			problems = append(problems, validateValue(v.Field(i), fieldRules, path+"."+name)...)
+		}
+	case reflect.Slice, reflect.Array:
+		if hasStructs(v.Type().Elem()) && !contains(rules, "dive") {
+			for i := 0; i < v.Len(); i++ {
+				problems = append(problems, validateValue(v.Index(i), nil, fmt.Sprintf("%v[%d]", path, i))...)
+			}
+		}
+	}
+	return problems
+}
+
+func hasStructs(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	emailPattern    = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	alphaPattern    = regexp.MustCompile(`^[a-zA-Z]+$`)
+	alphanumPattern = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+	numericPattern  = regexp.MustCompile(`^[-+]?[0-9]+(?:\.[0-9]+)?$`)
+)
+
+// checkRule checks a single rule other than required, omitempty and dive,
+// returning what is wrong with v or "".
+func checkRule(v reflect.Value, name, param string) string {
+	switch name {
+	case "len", "min", "max", "eq", "ne", "gt", "gte", "lt", "lte":
+		return checkBound(v, name, param)
+	case "oneof":
+		actual := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(param) {
+			if actual == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("expected one of [%v] but got %v", param, jsonString(v.Interface()))
+	}
+
+	if v.Kind() != reflect.String {
+		return fmt.Sprintf("cannot check %v on a %v", name, v.Kind())
+	}
+	s := v.String()
+	var ok bool
+	var what string
+	switch name {
+	case "uuid":
+		ok, what = uuidPattern.MatchString(s), "a UUID"
+	case "email":
+		ok, what = emailPattern.MatchString(s), "an email address"
+	case "url":
+		u, err := url.Parse(s)
+		ok, what = err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != ""), "a URL"
+	case "alpha":
+		ok, what = alphaPattern.MatchString(s), "letters"
+	case "alphanum":
+		ok, what = alphanumPattern.MatchString(s), "letters and digits"
+	case "numeric":
+		ok, what = numericPattern.MatchString(s), "a number"
+	case "lowercase":
+		ok, what = s == strings.ToLower(s), "lowercase"
+	case "uppercase":
+		ok, what = s == strings.ToUpper(s), "uppercase"
+	default:
+		return fmt.Sprintf("unknown validation %q", name)
+	}
+	if !ok {
+		return fmt.Sprintf("expected %v but got %q", what, s)
+	}
+	return ""
+}
+
+// checkBound compares the length of strings, slices and maps, and the value
+// of numbers, with param. Strings are compared by value for eq and ne.
+func checkBound(v reflect.Value, name, param string) string {
+	if v.Kind() == reflect.String && (name == "eq" || name == "ne") {
+		if name == "eq" && v.String() != param {
+			return fmt.Sprintf("expected %q but got %q", param, v.String())
+		}
+		if name == "ne" && v.String() == param {
+			return fmt.Sprintf("expected a value other than %q", param)
+		}
+		return ""
+	}
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Sprintf("invalid parameter %q for %v", param, name)
+	}
+	var actual float64
+	unit := ""
+	switch v.Kind() {
+	case reflect.String:
+		actual, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual, unit = float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = v.Float()
+	default:
+		return fmt.Sprintf("cannot check %v on a %v", name, v.Kind())
+	}
+
+	var ok bool
+	var expected string
+	switch name {
+	case "len", "eq":
+		ok, expected = actual == limit, "exactly"
+	case "ne":
+		ok, expected = actual != limit, "other than"
+	case "min", "gte":
+		ok, expected = actual >= limit, "at least"
+	case "max", "lte":
+		ok, expected = actual <= limit, "at most"
+	case "gt":
+		ok, expected = actual > limit, "more than"
+	case "lt":
+		ok, expected = actual < limit, "less than"
+	}
+	if !ok {
+		return fmt.Sprintf("expected %v %v%v but got %v", expected, param, unit, actual)
+	}
+	return ""
+}
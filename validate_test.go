@@ -0,0 +1,74 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type validatedOwner struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type validatedUser struct {
+	ID      string            `json:"id" validate:"required,uuid"`
+	Name    string            `json:"name" validate:"min=1,max=5"`
+	Role    string            `json:"role" validate:"oneof=admin member"`
+	Age     int               `json:"age" validate:"gte=0,lt=150"`
+	Website string            `json:"website" validate:"omitempty,url"`
+	Tags    []string          `json:"tags" validate:"max=2,dive,alphanum"`
+	Owner   *validatedOwner   `json:"owner"`
+	Friends []validatedOwner  `json:"friends"`
+	Labels  map[string]string `json:"labels" validate:"dive,lowercase"`
+	Kind    string            `json:"kind" validate:"eq=user"`
+	skipped string            `validate:"required"`
+}
+
+func TestParseAndValidateBody(t *testing.T) {
+	bodies := map[string]string{
+		"/ok": `{"id":"123e4567-e89b-12d3-a456-426614174000","name":"ann","role":"admin","age":30,
+			"tags":["a1"],"owner":{"email":"o@example.com"},"friends":[{"email":"f@example.com"}],"labels":{"env":"prod"},"kind":"user"}`,
+		"/bad": `{"id":"nope","name":"annabelle","role":"owner","age":150,"website":"example",
+			"tags":["a","b-c","d"],"owner":{},"friends":[{"email":"x"}],"labels":{"env":"Prod"},"kind":"bot"}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(bodies[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	var user validatedUser
+	c.Get("/ok").ParseAndValidateBody(&user)
+	require.NoError(t, c.Error())
+	require.Equal(t, "ann", user.Name)
+
+	c.Get("/bad").ParseAndValidateBody(&validatedUser{})
+	require.Error(t, c.Error())
+	msg := c.Error().Error()
+	require.Contains(t, msg, "expected the body to be valid but")
+	for _, problem := range []string{
+		`$.id: expected a UUID but got "nope"`,
+		"$.name: expected at most 5 characters but got 9",
+		`$.role: expected one of [admin member] but got "owner"`,
+		"$.age: expected less than 150 but got 150",
+		`$.website: expected a URL but got "example"`,
+		"$.tags: expected at most 2 items but got 3",
+		`$.tags[1]: expected letters and digits but got "b-c"`,
+		"$.owner.email: missing required value",
+		`$.friends[0].email: expected an email address but got "x"`,
+		`$.labels.env: expected lowercase but got "Prod"`,
+		`$.kind: expected "user" but got "bot"`,
+	} {
+		require.Contains(t, msg, problem)
+	}
+	require.NotContains(t, msg, "skipped")
+
+	c = NewClient(srv.URL)
+	c.Get("/ok").ParseAndValidateBody(&struct {
+		Name string `json:"name" validate:"shiny"`
+	}{})
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), `$.name: unknown validation "shiny"`)
+}
@@ -0,0 +1,103 @@
+package crest
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type versionKind int
+
+const (
+	versionNone versionKind = iota
+	versionAccept
+	versionHeader
+	versionPath
+	versionQuery
+)
+
+// VersionStyle describes how a client tells the server which API version it
+// wants. See VersionInAccept, VersionInHeader, VersionInPath and
+// VersionInQuery.
+type VersionStyle struct {
+	kind versionKind
+	name string
+}
+
+// VersionInAccept sends Accept: application/vnd.<vendor>.<version>+json.
+func VersionInAccept(vendor string) VersionStyle {
+	return VersionStyle{kind: versionAccept, name: vendor}
+}
+
+// VersionInHeader sends the version as the value of the given header.
+func VersionInHeader(header string) VersionStyle {
+	return VersionStyle{kind: versionHeader, name: http.CanonicalHeaderKey(header)}
+}
+
+// VersionInPath prefixes every path with /<version>.
+func VersionInPath() VersionStyle {
+	return VersionStyle{kind: versionPath}
+}
+
+// VersionInQuery adds the version as the given query parameter.
+func VersionInQuery(key string) VersionStyle {
+	return VersionStyle{kind: versionQuery, name: key}
+}
+
+type apiVersion struct {
+	version string
+	style   VersionStyle
+}
+
+func (v apiVersion) applyPath(path string) string {
+	if v.style.kind != versionPath {
+		return path
+	}
+	return v.version + "/" + strings.TrimPrefix(path, "/")
+}
+
+func (v apiVersion) applyReq(req *http.Request) {
+	switch v.style.kind {
+	case versionAccept:
+		req.Header.Set("Accept", fmt.Sprintf("application/vnd.%v.%v+json", v.style.name, v.version))
+	case versionHeader:
+		req.Header.Set(v.style.name, v.version)
+	case versionQuery:
+		// Appended, so the caller's query keeps its order and encoding.
+		param := url.QueryEscape(v.style.name) + "=" + url.QueryEscape(v.version)
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = param
+		} else {
+			req.URL.RawQuery += "&" + param
+		}
+	}
+}
+
+// responseHasVersion looks for version in the usual places servers report
+// it: a version header, or a vendor media type or version parameter in
+// Content-Type.
+func responseHasVersion(header http.Header, versionHeader, version string) bool {
+	for _, key := range []string{versionHeader, "Api-Version", "X-Api-Version", "Version"} {
+		if key == "" {
+			continue
+		}
+		for _, value := range header[key] {
+			if strings.TrimSpace(value) == version {
+				return true
+			}
+		}
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	if params["version"] == version {
+		return true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		mediaType = mediaType[:i]
+	}
+	return strings.HasPrefix(mediaType, "application/vnd.") && strings.HasSuffix(mediaType, "."+strings.ToLower(url.PathEscape(version)))
+}
@@ -0,0 +1,392 @@
+package crest
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMessageTimeout is how long the assertions of a WSWrapper wait for
+// messages, unless set with WithTimeout.
+const DefaultMessageTimeout = 10 * time.Second
+
+// WebSocket close codes, from RFC 6455 section 7.4.1.
+const (
+	CloseNormal   = 1000
+	CloseNoStatus = 1005
+	CloseAbnormal = 1006
+)
+
+// WSWrapper is a WebSocket connection. Its assertions consume messages as
+// they arrive, waiting at most the timeout for the ones they expect, and
+// share the error of the client that opened the connection.
+type WSWrapper interface {
+	// WithTimeout sets how long each assertion waits.
+	WithTimeout(d time.Duration) WSWrapper
+	// Send sends msg as a text message.
+	Send(msg string) WSWrapper
+	// ExpectReceiveContains waits for a message containing s, after the
+	// messages earlier assertions matched.
+	ExpectReceiveContains(s string) WSWrapper
+	// ExpectClosedWithCode waits for the server to close the connection,
+	// and fails unless it gave code as the reason. A connection that ends
+	// without a close frame has code CloseAbnormal.
+	ExpectClosedWithCode(code int) WSWrapper
+	// Messages returns the messages received so far.
+	Messages() []string
+	// Response returns the response that upgraded the connection.
+	Response() *http.Response
+	// Close closes the connection with CloseNormal. A failing assertion
+	// closes it too.
+	Close() error
+	Error() error
+}
+
+// The opcodes of WebSocket frames.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// wsMaxFrame is the largest frame read, so that a response that is not a
+// WebSocket stream is not taken for a huge frame.
+const wsMaxFrame = 64 << 20
+
+// wsGUID is what the key of a handshake is hashed with, see RFC 6455
+// section 1.3.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+type webSocket struct {
+	liveStream
+	// next is the index of the first message ExpectReceiveContains has not
+	// looked at.
+	next     int
+	messages []string
+	// code is the close code once the connection has ended.
+	code int
+
+	writeLock sync.Mutex
+	conn      io.ReadWriteCloser
+}
+
+// Websocket opens a WebSocket connection to path, with the headers,
+// authentication and transport of the client. It fails unless the server
+// completes the handshake. The connection stays open until Close is called,
+// the server closes it or the client is closed, and is not bounded by the
+// timeout set with WithTimeout or WithReadIdleTimeout on the client.
+func (c *client) Websocket(path string) WSWrapper {
+	s := &webSocket{liveStream: newLiveStream(c, DefaultMessageTimeout)}
+	if c.errGetter() != nil {
+		return s
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		c.errSetter(errors.Wrap(err, "creating WebSocket key"))
+		return s
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	body := s.open(path, "WebSocket", http.Header{
+		"Connection":            {"Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {key},
+	})
+	if body == nil {
+		return s
+	}
+	conn, writable := s.resp.Body.(io.ReadWriteCloser)
+	var err error
+	switch {
+	case s.resp.StatusCode != http.StatusSwitchingProtocols:
+		err = errors.Errorf("opening WebSocket %v: got status %v", path, s.resp.Status)
+	case s.resp.Header.Get("Sec-WebSocket-Accept") != wsAccept(key):
+		err = errors.Errorf("opening WebSocket %v: got Sec-WebSocket-Accept %q", path, s.resp.Header.Get("Sec-WebSocket-Accept"))
+	case !writable:
+		err = errors.Errorf("opening WebSocket %v: the connection cannot be written to", path)
+	}
+	if err != nil {
+		body.Close()
+		if writable {
+			conn.Close()
+		}
+		s.fail(err)
+		return s
+	}
+	s.conn = conn
+	go s.read(body)
+	return s
+}
+
+// wsAccept returns the Sec-WebSocket-Accept a server answers key with.
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// read reads frames from rd until the connection ends.
+func (s *webSocket) read(rd io.Reader) {
+	br := bufio.NewReader(rd)
+	var message []byte
+	for {
+		fin, opcode, payload, err := readWSFrame(br)
+		if err != nil {
+			s.end(CloseAbnormal, err)
+			return
+		}
+		switch opcode {
+		case wsText, wsBinary:
+			message = payload
+		case wsContinuation:
+			message = append(message, payload...)
+		case wsPing:
+			s.write(wsPong, payload)
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			code := CloseNoStatus
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+				payload = payload[:2]
+			}
+			// Echo the close frame to complete the closing handshake.
+			s.write(wsClose, payload)
+			s.conn.Close()
+			s.end(code, nil)
+			return
+		default:
+			s.end(CloseAbnormal, errors.Errorf("got a frame with unknown opcode %#x", opcode))
+			return
+		}
+		if fin {
+			s.add(string(message))
+			message = nil
+		}
+	}
+}
+
+// readWSFrame reads a frame from rd, unmasking its payload if it is masked.
+func readWSFrame(rd io.Reader) (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(rd, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0f
+	masked, n := head[1]&0x80 != 0, uint64(head[1]&0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(rd, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(rd, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxFrame {
+		return false, 0, nil, errors.Errorf("got a frame of %d bytes", n)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(rd, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(rd, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeWSFrame writes payload to w as a single frame, masked as clients
+// must mask their frames if mask is true.
+func writeWSFrame(w io.Writer, opcode byte, payload []byte, mask bool) error {
+	frame := []byte{0x80 | opcode, 0}
+	switch n := len(payload); {
+	case n < 126:
+		frame[1] = byte(n)
+	case n <= 0xffff:
+		frame[1] = 126
+		frame = append(frame, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(n))
+	default:
+		frame[1] = 127
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	if !mask {
+		_, err := w.Write(append(frame, payload...))
+		return err
+	}
+	frame[1] |= 0x80
+	var key [4]byte
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		return err
+	}
+	frame = append(frame, key[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^key[i%4])
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
+func (s *webSocket) write(opcode byte, payload []byte) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	return writeWSFrame(s.conn, opcode, payload, true)
+}
+
+func (s *webSocket) add(message string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.messages = append(s.messages, message)
+	s.notify()
+}
+
+func (s *webSocket) end(code int, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.ended {
+		return
+	}
+	s.code = code
+	s.finish(err)
+}
+
+// wait waits until ready, called with the lock held, returns true, the
+// connection ends or the timeout passes, and returns why it stopped
+// waiting: nil if ready returned true.
+func (s *webSocket) wait(ready func() bool) error {
+	return s.liveStream.wait(ready, func() error {
+		if s.err != nil {
+			return errors.Wrap(s.err, "the connection failed")
+		}
+		return errors.Errorf("the connection was closed with code %d", s.code)
+	})
+}
+
+// fail closes the connection, so that a failing test does not leave it
+// open, and sets the client's error.
+func (s *webSocket) fail(err error) {
+	s.Close()
+	s.client.errSetter(err)
+}
+
+func (s *webSocket) failed() bool {
+	return s.client.errGetter() != nil || s.conn == nil
+}
+
+func (s *webSocket) WithTimeout(d time.Duration) WSWrapper {
+	s.timeout = d
+	return s
+}
+
+func (s *webSocket) Send(msg string) WSWrapper {
+	if s.failed() {
+		return s
+	}
+	if err := s.write(wsText, []byte(msg)); err != nil {
+		s.fail(errors.Wrapf(err, "sending %q", msg))
+	}
+	return s
+}
+
+func (s *webSocket) ExpectReceiveContains(str string) WSWrapper {
+	if s.failed() {
+		return s
+	}
+	err := s.wait(func() bool {
+		for ; s.next < len(s.messages); s.next++ {
+			if strings.Contains(s.messages[s.next], str) {
+				s.next++
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		s.fail(fmt.Errorf("expected a message containing %q, but %v", str, err))
+	}
+	return s
+}
+
+func (s *webSocket) ExpectClosedWithCode(code int) WSWrapper {
+	if s.failed() {
+		return s
+	}
+	got := 0
+	err := s.wait(func() bool {
+		got = s.code
+		return s.ended
+	})
+	switch {
+	case err != nil:
+		s.fail(fmt.Errorf("expected the connection to be closed with code %d, but %v", code, err))
+	case got != code:
+		s.fail(fmt.Errorf("expected the connection to be closed with code %d but it was closed with %d", code, got))
+	}
+	return s
+}
+
+func (s *webSocket) Messages() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]string(nil), s.messages...)
+}
+
+func (s *webSocket) Response() *http.Response {
+	return s.resp
+}
+
+func (s *webSocket) Close() error {
+	if s.conn != nil {
+		s.lock.Lock()
+		open := !s.ended
+		s.lock.Unlock()
+		if open {
+			payload := make([]byte, 2)
+			binary.BigEndian.PutUint16(payload, CloseNormal)
+			s.write(wsClose, payload)
+		}
+		s.conn.Close()
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+func (s *webSocket) Error() error {
+	return s.client.Error()
+}
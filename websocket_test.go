@@ -0,0 +1,135 @@
+package crest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebsocket(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/plain" {
+			return
+		}
+		require.Equal(t, "websocket", r.Header.Get("Upgrade"))
+		require.Equal(t, "13", r.Header.Get("Sec-WebSocket-Version"))
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+		rw.WriteString("Sec-WebSocket-Accept: " + wsAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+
+		send := func(opcode byte, payload string) {
+			require.NoError(t, writeWSFrame(conn, opcode, []byte(payload), false))
+		}
+		send(wsText, "hello")
+		send(wsPing, "ping")
+		for {
+			_, opcode, payload, err := readWSFrame(rw)
+			if err != nil {
+				return
+			}
+			switch opcode {
+			case wsPong:
+				require.Equal(t, "ping", string(payload))
+			case wsClose:
+				writeWSFrame(conn, wsClose, payload, false)
+				return
+			case wsText:
+				msg := string(payload)
+				switch {
+				case msg == "bye":
+					reason := make([]byte, 2)
+					binary.BigEndian.PutUint16(reason, 4001)
+					send(wsClose, string(reason)+"done")
+					readWSFrame(rw)
+					return
+				case msg == "drop":
+					return
+				case msg == "big":
+					msg = strings.Repeat("x", 70000)
+				}
+				// Echo in two fragments.
+				conn.Write([]byte{wsText, 4})
+				conn.Write([]byte("echo"))
+				require.NoError(t, writeWSFrame(conn, wsContinuation, []byte(":"+msg), false))
+			}
+		}
+	}))
+	defer srv.Close()
+
+	newClient := func() Client {
+		return NewClient(srv.URL).WithHeader("Authorization", "Bearer token")
+	}
+
+	c := newClient()
+	ws := c.Websocket("/ws")
+	ws.ExpectReceiveContains("hello").
+		Send("one").
+		Send("big").
+		ExpectReceiveContains("echo:one").
+		ExpectReceiveContains("xxxx").
+		Send("bye").
+		ExpectClosedWithCode(4001)
+	require.NoError(t, ws.Error())
+	require.NoError(t, ws.Close())
+	messages := ws.Messages()
+	require.Len(t, messages, 3)
+	require.Len(t, messages[2], 70005)
+	require.Equal(t, http.StatusSwitchingProtocols, ws.Response().StatusCode)
+
+	c = newClient()
+	ws = c.Websocket("/ws").WithTimeout(50 * time.Millisecond).ExpectReceiveContains("hello").ExpectReceiveContains("hello")
+	require.NoError(t, ws.Close())
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), `expected a message containing "hello", but timed out after 50ms`)
+
+	c = newClient()
+	c.Websocket("/ws").Send("drop").ExpectClosedWithCode(CloseNormal)
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "expected the connection to be closed with code 1000 but it was closed with 1006")
+
+	c = newClient()
+	c.Websocket("/ws").Send("bye").ExpectReceiveContains("echo")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), `expected a message containing "echo", but the connection was closed with code 4001`)
+
+	c = NewClient(srv.URL)
+	c.Websocket("/ws").Send("one").ExpectReceiveContains("")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "opening WebSocket /ws: got status 401 Unauthorized")
+
+	c = newClient()
+	c.Websocket("/plain")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "opening WebSocket /plain: got status 200 OK")
+
+	c = NewClient("http://127.0.0.1:0")
+	c.Websocket("/ws").Send("one")
+	require.Error(t, c.Error())
+	require.Contains(t, c.Error().Error(), "opening WebSocket /ws")
+}
+
+func TestWSFrames(t *testing.T) {
+	for _, n := range []int{0, 125, 126, 65535, 65536} {
+		var b strings.Builder
+		payload := strings.Repeat("a", n)
+		require.NoError(t, writeWSFrame(&b, wsBinary, []byte(payload), true))
+		fin, opcode, got, err := readWSFrame(bufio.NewReader(strings.NewReader(b.String())))
+		require.NoError(t, err)
+		require.True(t, fin)
+		require.Equal(t, byte(wsBinary), opcode)
+		require.Equal(t, payload, string(got))
+	}
+}
@@ -0,0 +1,101 @@
+package crest
+
+import (
+	"bytes"
+	"net"
+	"net/http/httptrace"
+	"sync"
+)
+
+// WireCapture holds the bytes that crossed the connection for a request, as
+// captured by a client created with WithWireCapture. Over TLS, these are the
+// encrypted bytes.
+type WireCapture struct {
+	Sent     []byte
+	Received []byte
+}
+
+// wireRecorder collects the bytes of a single request. The connection it is
+// attached to may still be reading while the wrapper copies them out.
+type wireRecorder struct {
+	lock     sync.Mutex
+	sent     bytes.Buffer
+	received bytes.Buffer
+}
+
+// trace attaches the recorder to each connection the request gets, so a
+// connection reused by a later request records into that request's recorder.
+func (w *wireRecorder) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			w.lock.Lock()
+			defer w.lock.Unlock()
+			// Each redirect gets a connection of its own; only the last one
+			// is interesting.
+			w.sent.Reset()
+			w.received.Reset()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			conn := info.Conn
+			if tlsConn, ok := conn.(interface{ NetConn() net.Conn }); ok {
+				conn = tlsConn.NetConn()
+			}
+			if wc, ok := conn.(*wireConn); ok {
+				wc.record(w)
+			}
+		},
+	}
+}
+
+func (w *wireRecorder) get() WireCapture {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return WireCapture{
+		Sent:     append([]byte(nil), w.sent.Bytes()...),
+		Received: append([]byte(nil), w.received.Bytes()...),
+	}
+}
+
+// wireConn copies everything written to and read from the connection into
+// the recorder of the request currently using it.
+type wireConn struct {
+	net.Conn
+
+	lock     sync.Mutex
+	recorder *wireRecorder
+}
+
+func (c *wireConn) record(w *wireRecorder) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.recorder = w
+}
+
+func (c *wireConn) current() *wireRecorder {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.recorder
+}
+
+func (c *wireConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if w := c.current(); w != nil && n > 0 {
+		w.lock.Lock()
+		w.received.Write(b[:n])
+		w.lock.Unlock()
+	}
+	return n, err
+}
+
+func (c *wireConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if w := c.current(); w != nil && n > 0 {
+		w.lock.Lock()
+		w.sent.Write(b[:n])
+		w.lock.Unlock()
+	}
+	return n, err
+}
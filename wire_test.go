@@ -0,0 +1,35 @@
+package crest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWireCapture(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL).WithWireCapture().WithHeader("X-Test", "1")
+	wire := c.Get("/first").ExpectBodyEquals("hello/first").Wire()
+	require.True(t, strings.HasPrefix(string(wire.Sent), "GET /first HTTP/1.1\r\n"), string(wire.Sent))
+	require.Contains(t, string(wire.Sent), "\r\nX-Test: 1\r\n")
+	require.Contains(t, string(wire.Received), "\r\nTransfer-Encoding: chunked\r\n")
+	require.True(t, strings.HasSuffix(string(wire.Received), "\r\n\r\n5\r\nhello\r\n6\r\n/first\r\n0\r\n\r\n"), string(wire.Received))
+
+	rw := c.Get("/second")
+	require.True(t, rw.Timings().Reused)
+	wire = rw.Wire()
+	require.True(t, strings.HasPrefix(string(wire.Sent), "GET /second HTTP/1.1\r\n"), string(wire.Sent))
+	require.NotContains(t, string(wire.Received), "/first")
+	require.NoError(t, c.Error())
+
+	require.Equal(t, WireCapture{}, NewClient(srv.URL).Get("/").Wire())
+}